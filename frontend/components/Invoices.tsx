@@ -3,15 +3,26 @@ import {
   RefreshCcw,
 } from "lucide-react";
 
+// Amounts are whole minor units (cents for USD) of an ISO 4217 currency.
+interface Money {
+  amount: number;
+  currency: string;
+}
+
 interface Invoice {
   id: string;
-  invoice_amount: number;
+  invoice_amount: Money;
   start_date: string;
   end_date: string;
   status: string;
   invoice_name: string;
 }
 
+// Invoices in these statuses still have an outstanding balance to pay.
+const PAYABLE_STATUSES = ["issued", "partially_paid", "disputed"];
+
+const isPayable = (status: string) => PAYABLE_STATUSES.includes(status.toLowerCase());
+
 interface InvoicesProps {
   invoices: Invoice[] | null;
   isLoading: boolean;
@@ -22,11 +33,13 @@ export default function Invoices({ invoices, isLoading }: InvoicesProps) {
     return new Date(dateString).toLocaleDateString();
   };
 
-  const formatCurrency = (amount: number) => {
-    return new Intl.NumberFormat('en-US', {
+  const formatCurrency = ({ amount, currency }: Money) => {
+    const formatter = new Intl.NumberFormat('en-US', {
       style: 'currency',
-      currency: 'USD'
-    }).format(amount);
+      currency,
+    });
+    const minorUnits = formatter.resolvedOptions().maximumFractionDigits ?? 2;
+    return formatter.format(amount / 10 ** minorUnits);
   };
 
   if (isLoading) {
@@ -84,7 +97,7 @@ export default function Invoices({ invoices, isLoading }: InvoicesProps) {
                 <td className="dark:text-white whitespace-nowrap px-3 py-1.5 text-sm text-gray-900">
                   <span
                     className={`inline-flex items-center rounded-md px-2 py-1 text-xs font-medium ring-1 ring-inset ${
-                      invoice.status.toLowerCase() === "unpaid"
+                      isPayable(invoice.status)
                         ? "bg-red-50 text-red-700 ring-red-600/20"
                         : "bg-green-50 text-green-700 ring-green-600/20"
                     }`}
@@ -93,27 +106,27 @@ export default function Invoices({ invoices, isLoading }: InvoicesProps) {
                   </span>
                 </td>
                 <td className="flex items-center justify-end pr-4 relative whitespace-nowrap py-1.5 text-right text-sm font-medium">
-                  <a className={invoice.status.toLowerCase() === "paid" ? "pointer-events-none" : ""}>
+                  <a className={!isPayable(invoice.status) ? "pointer-events-none" : ""}>
                     <RefreshCcw
                       className={`mr-2 py-1 ${
-                        invoice.status.toLowerCase() === "paid"
+                        !isPayable(invoice.status)
                           ? "text-gray-300 cursor-default"
                           : "text-gray-600 cursor-pointer hover:text-gray-900"
                       }`}
                     />
                   </a>
                   <a
-                    href={invoice.status.toLowerCase() === "paid" ? "#" : "#actual-pay-link"}
+                    href={!isPayable(invoice.status) ? "#" : "#actual-pay-link"}
                     className={`flex items-center px-1 rounded-md border ${
-                      invoice.status.toLowerCase() === "paid"
+                      !isPayable(invoice.status)
                         ? "bg-gray-100 text-gray-400 cursor-default"
                         : "hover:bg-gray-100 text-gray-700"
                     }`}
-                    onClick={(e) => invoice.status.toLowerCase() === "paid" && e.preventDefault()}
+                    onClick={(e) => !isPayable(invoice.status) && e.preventDefault()}
                   >
                     <Receipt
                       className={`py-1 ${
-                        invoice.status.toLowerCase() === "paid"
+                        !isPayable(invoice.status)
                           ? "text-gray-400"
                           : "text-gray-600"
                       }`}
@@ -13,7 +13,7 @@ import Invoices from "./Invoices";
 
 interface Invoice {
   id: string;
-  invoice_amount: number;
+  invoice_amount: { amount: number; currency: string };
   start_date: string;
   end_date: string;
   status: string;
@@ -54,7 +54,7 @@ export default function MainContainer() {
         }
 
         const data = await response.json();
-        setInvoiceData(data);
+        setInvoiceData(data.invoices);
         
       } catch (error) {
         console.error('Error fetching search results:', error);
@@ -77,7 +77,7 @@ export default function MainContainer() {
           throw new Error('Network response was not ok');
         }
         const data = await response.json();
-        setInvoiceData(data);
+        setInvoiceData(data.invoices);
       } catch (error) {
         console.error('Error fetching initial data:', error);
         setError(error instanceof Error ? error.message : 'An error occurred');
@@ -0,0 +1,88 @@
+// Command billing runs the invoice generation engine for a billing period.
+// It is safe to run repeatedly (for example from a cron job) because each
+// approved timesheet is billed at most once.
+//
+//	go run ./cmd/billing -start 2024-10-07 -end 2024-10-13
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/config"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/logger"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func main() {
+	ctx := context.Background()
+	slogHandler := &logger.ContextHandler{Handler: slog.NewJSONHandler(os.Stderr, nil)}
+	slog.SetDefault(slog.New(slogHandler))
+
+	lastWeekStart, lastWeekEnd := previousWeek(time.Now().UTC())
+	start := flag.String("start", lastWeekStart.Format(time.DateOnly), "first day of the billing period (YYYY-MM-DD)")
+	end := flag.String("end", lastWeekEnd.Format(time.DateOnly), "last day of the billing period (YYYY-MM-DD)")
+	employerID := flag.String("employer", "", "only bill this employer")
+	feeBasisPoints := flag.Int64("fee-bps", service.DefaultPlatformFeeBasisPoints, "platform fee in basis points")
+	taxBasisPoints := flag.Int64("tax-bps", 0, "tax applied to labor lines in basis points")
+	flag.Parse()
+
+	periodStart, err := time.Parse(time.DateOnly, *start)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid start date", "error", err)
+		os.Exit(1)
+	}
+	periodEnd, err := time.Parse(time.DateOnly, *end)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid end date", "error", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("postgres", cfg.DBConnString)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to open database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	svc := service.NewService(db)
+	result, err := svc.GenerateInvoices(ctx, service.BillingRunInput{
+		PeriodStart:            periodStart,
+		PeriodEnd:              periodEnd,
+		EmployerID:             *employerID,
+		PlatformFeeBasisPoints: *feeBasisPoints,
+		TaxBasisPoints:         *taxBasisPoints,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "billing run failed", "error", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		slog.ErrorContext(ctx, "failed to write billing result", "error", err)
+		os.Exit(1)
+	}
+}
+
+// previousWeek returns the Monday and Sunday of the last full week before now.
+func previousWeek(now time.Time) (time.Time, time.Time) {
+	today := now.Truncate(24 * time.Hour)
+	daysSinceMonday := (int(today.Weekday()) + 6) % 7
+	thisMonday := today.AddDate(0, 0, -daysSinceMonday)
+	return thisMonday.AddDate(0, 0, -7), thisMonday.AddDate(0, 0, -1)
+}
@@ -0,0 +1,53 @@
+// Command ledger prints the balance of every ledger account, platform-wide and
+// for each organization, so finance can reconcile them against the bank and
+// the payment and payout providers.
+//
+//	go run ./cmd/ledger
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	_ "github.com/lib/pq"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/config"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/logger"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func main() {
+	ctx := context.Background()
+	slogHandler := &logger.ContextHandler{Handler: slog.NewJSONHandler(os.Stderr, nil)}
+	slog.SetDefault(slog.New(slogHandler))
+
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("postgres", cfg.DBConnString)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to open database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	svc := service.NewService(db)
+	balances, err := svc.ListLedgerAccountBalances(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list ledger balances", "error", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(balances); err != nil {
+		slog.ErrorContext(ctx, "failed to write ledger balances", "error", err)
+		os.Exit(1)
+	}
+}
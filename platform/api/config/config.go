@@ -13,6 +13,9 @@ import (
 )
 
 type Config struct {
+	// Env is the deployment the config was loaded for, from the ENV variable.
+	Env string `json:"-"`
+
 	ServerPort         string `json:"BACKEND_PORT"`
 	DBConnString       string `json:"CONN_STRING"`
 	Auth0Secret        string `json:"AUTH0_SECRET"`
@@ -21,9 +24,15 @@ type Config struct {
 	Auth0IssuerBaseURL string `json:"AUTH0_ISSUER_BASE_URL"`
 	Auth0ClientID      string `json:"AUTH0_CLIENT_ID"`
 	Auth0ClientSecret  string `json:"AUTH0_CLIENT_SECRET"`
-	Auth0RoleID        string `json:"AUTH0_ROLE_ID"`
-	Auth0Audience      string `json:"AUTH0_AUDIENCE"`
-	Auth0HookSecret    string `json:"AUTH0_HOOK_SECRET"`
+	// Auth0RoleID is the tenant's legacy employer role ID; tokens still
+	// carrying it instead of a role name act as employer_admin.
+	Auth0RoleID     string `json:"AUTH0_ROLE_ID"`
+	Auth0Audience   string `json:"AUTH0_AUDIENCE"`
+	Auth0HookSecret string `json:"AUTH0_HOOK_SECRET"`
+
+	PaymentsWebhookSecret string `json:"PAYMENTS_WEBHOOK_SECRET"`
+	// NotificationsDir is where the file sender writes email and SMS messages.
+	NotificationsDir string `json:"NOTIFICATIONS_DIR"`
 }
 
 func LoadConfig(ctx context.Context) (*Config, error) {
@@ -70,6 +79,13 @@ func LoadConfig(ctx context.Context) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal secret value: %v", err)
 	}
+	config.Env = env
 
 	return &config, nil
 }
+
+// IsProduction reports whether the config is for the production deployment,
+// where development stand-ins such as the fake payment gateway must not run.
+func (c *Config) IsProduction() bool {
+	return c.Env == "prod" || c.Env == "production"
+}
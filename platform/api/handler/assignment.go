@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Worker-facing endpoints
+
+func (h *Handler) HandleListOpenShifts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	shifts, err := h.svc.ListOpenShifts(ctx)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list open shifts")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, shifts)
+}
+
+func (h *Handler) HandleApplyToShift(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	assignment, err := h.svc.ApplyToShift(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to apply to shift")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, assignment)
+}
+
+func (h *Handler) HandleWithdrawAssignment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	assignment, err := h.svc.WithdrawAssignment(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to withdraw assignment")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, assignment)
+}
+
+func (h *Handler) HandleListMyAssignments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	assignments, err := h.svc.ListWorkerAssignments(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list assignments")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, assignments)
+}
+
+// Employer-facing endpoints
+
+func (h *Handler) HandleListShiftAssignments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	assignments, err := h.svc.ListShiftAssignments(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list shift assignments")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, assignments)
+}
+
+func (h *Handler) HandleAcceptAssignment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	assignment, err := h.svc.AcceptAssignment(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to accept assignment")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, assignment)
+}
+
+func (h *Handler) HandleDeclineAssignment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	assignment, err := h.svc.DeclineAssignment(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to decline assignment")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, assignment)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleListAuditLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	query := r.URL.Query()
+	filter := service.AuditFilter{
+		EntityType: service.AuditEntityType(query.Get("entity_type")),
+		EntityID:   query.Get("entity_id"),
+	}
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(dateLayout, from); err != nil {
+			http.Error(w, "from must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(dateLayout, to); err != nil {
+			http.Error(w, "to must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.svc.ListAuditLog(ctx, customClaims.DBUserId, filter)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list audit log")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, entries)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleCreateCreditNote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var req service.CreditNoteInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "failed to decode credit note", "error", err)
+		http.Error(w, "failed to decode credit note", http.StatusBadRequest)
+		return
+	}
+
+	note, err := h.svc.CreateCreditNote(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), &req)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to create credit note")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, note)
+}
+
+func (h *Handler) HandleListCreditNotes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	notes, err := h.svc.ListCreditNotes(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list credit notes")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, notes)
+}
@@ -1,22 +1,18 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 
-	"github.com/auth0/go-jwt-middleware/v2"
-	"github.com/auth0/go-jwt-middleware/v2/validator"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/config"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
 )
 
-type role string
-
-const EMPLOYER role = "rol_lz7KugKHb6tiTJVl"
-
 type Handler struct {
 	svc service.Service
 	cfg *config.Config
@@ -35,12 +31,7 @@ type CreateUserReq struct {
 }
 
 func (h *Handler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
-	token := r.Context().Value(jwtmiddleware.ContextKey{}).(*validator.ValidatedClaims)
-	customClaims := token.CustomClaims.(*middleware.CustomClaims)
-	if customClaims.Roles[0] != string(h.cfg.Auth0RoleID) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+	customClaims := claimsFromContext(r.Context())
 
 	// todo update this by getting the id
 	user, err := h.svc.GetUserByID(r.Context(), customClaims.DBUserId)
@@ -100,25 +91,54 @@ func (h *Handler) HandleFetchInvoices(w http.ResponseWriter, r *http.Request) {
 	// Get the user from the context
 	ctx := r.Context()
 	slog.InfoContext(ctx, "fetching invoices")
-	token := r.Context().Value(jwtmiddleware.ContextKey{}).(*validator.ValidatedClaims)
-	customClaims := token.CustomClaims.(*middleware.CustomClaims)
+	customClaims := claimsFromContext(ctx)
 
-	slog.InfoContext(ctx, "fetching invoices", "user_id", customClaims)
-	if customClaims.Roles[0] != string(EMPLOYER) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	slog.InfoContext(ctx, "fetching invoices", "user_id", customClaims.DBUserId)
+	params, err := parseInvoiceListParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	searchTerm := r.URL.Query().Get("search")
 
-	invoices, err := h.svc.FetchInvoices(ctx, customClaims.DBUserId, searchTerm)
+	page, err := h.svc.FetchInvoices(ctx, customClaims.DBUserId, params)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to fetch invoices", "error", err)
-		http.Error(w, "failed to fetch invoices", http.StatusInternalServerError)
+		sendServiceError(ctx, w, err, "failed to fetch invoices")
 		return
 	}
 
 	// Return the invoices
-	sendJSONResponse(w, http.StatusOK, invoices)
+	sendJSONResponse(w, http.StatusOK, page)
+}
+
+// claimsFromContext returns the custom claims of the validated JWT on the
+// request. Routes are guarded by middleware.RequirePermission, so handlers can
+// rely on the claims being present.
+func claimsFromContext(ctx context.Context) *middleware.CustomClaims {
+	return middleware.ClaimsFromContext(ctx)
+}
+
+// sendServiceError maps errors returned by the service layer onto HTTP status codes.
+func sendServiceError(ctx context.Context, w http.ResponseWriter, err error, msg string) {
+	var validationErr *service.ValidationError
+	var transitionErr *service.InvalidTransitionError
+	var scheduleErr *service.ScheduleConflictError
+	switch {
+	case errors.As(err, &validationErr):
+		sendJSONResponse(w, http.StatusBadRequest, validationErr)
+	case errors.As(err, &transitionErr):
+		sendJSONResponse(w, http.StatusConflict, transitionErr)
+	case errors.As(err, &scheduleErr):
+		sendJSONResponse(w, http.StatusConflict, scheduleErr)
+	case errors.Is(err, service.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		slog.ErrorContext(ctx, msg, "error", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
 }
 
 func sendJSONResponse(w http.ResponseWriter, status int, payload interface{}) {
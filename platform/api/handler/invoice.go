@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleGetInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	invoice, err := h.svc.GetInvoice(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get invoice")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, invoice)
+}
+
+type InvoiceTransitionRequest struct {
+	ToStatus service.InvoiceStatus `json:"to_status"`
+	Reason   string                `json:"reason"`
+}
+
+func (h *Handler) HandleTransitionInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var req InvoiceTransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "failed to decode invoice transition", "error", err)
+		http.Error(w, "failed to decode invoice transition", http.StatusBadRequest)
+		return
+	}
+
+	transition, err := h.svc.TransitionInvoice(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), req.ToStatus, req.Reason)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to transition invoice")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, transition)
+}
+
+func (h *Handler) HandleListInvoiceTransitions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	transitions, err := h.svc.ListInvoiceTransitions(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list invoice transitions")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, transitions)
+}
+
+// parseInvoiceListParams reads the invoice list filters, sort order and page
+// from the query string. Amounts are in minor units and dates are YYYY-MM-DD.
+func parseInvoiceListParams(query url.Values) (service.InvoiceListParams, error) {
+	params := service.InvoiceListParams{
+		Search: query.Get("search"),
+		Sort:   service.InvoiceSortField(query.Get("sort")),
+		Cursor: query.Get("cursor"),
+	}
+
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				params.Statuses = append(params.Statuses, service.InvoiceStatus(status))
+			}
+		}
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		params.Descending = true
+	default:
+		return params, fmt.Errorf("order must be asc or desc")
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		if params.From, err = time.Parse(dateLayout, from); err != nil {
+			return params, fmt.Errorf("from must be formatted as YYYY-MM-DD")
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if params.To, err = time.Parse(dateLayout, to); err != nil {
+			return params, fmt.Errorf("to must be formatted as YYYY-MM-DD")
+		}
+	}
+	if params.MinAmount, err = parseOptionalInt64(query, "min_amount"); err != nil {
+		return params, err
+	}
+	if params.MaxAmount, err = parseOptionalInt64(query, "max_amount"); err != nil {
+		return params, err
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if params.Limit, err = strconv.Atoi(limit); err != nil || params.Limit <= 0 {
+			return params, fmt.Errorf("limit must be a positive integer")
+		}
+	}
+
+	return params, nil
+}
+
+func parseOptionalInt64(query url.Values, name string) (*int64, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a whole number of minor units", name)
+	}
+	return &value, nil
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleGetInvoiceNumbering(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	numbering, err := h.svc.GetInvoiceNumbering(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get invoice numbering")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, numbering)
+}
+
+func (h *Handler) HandlePutInvoiceNumbering(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	var input service.InvoiceNumberingInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode invoice numbering", "error", err)
+		http.Error(w, "failed to decode invoice numbering", http.StatusBadRequest)
+		return
+	}
+
+	numbering, err := h.svc.SetInvoiceNumbering(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to save invoice numbering")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, numbering)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) HandleGetLedgerBalances(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	balances, err := h.svc.GetLedgerBalances(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get ledger balances")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, balances)
+}
+
+func (h *Handler) HandleListLedgerEntries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.svc.ListLedgerEntries(ctx, customClaims.DBUserId, limit)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list ledger entries")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, entries)
+}
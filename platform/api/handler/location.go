@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleCreateLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.LocationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode location", "error", err)
+		http.Error(w, "failed to decode location", http.StatusBadRequest)
+		return
+	}
+
+	location, err := h.svc.CreateLocation(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to create location")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, location)
+}
+
+func (h *Handler) HandleListLocations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	locations, err := h.svc.ListLocations(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list locations")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, locations)
+}
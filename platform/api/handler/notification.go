@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	preferences, err := h.svc.GetNotificationPreferences(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get notification preferences")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, preferences)
+}
+
+func (h *Handler) HandlePutNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	var preferences []service.NotificationPreference
+	if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+		slog.ErrorContext(ctx, "failed to decode notification preferences", "error", err)
+		http.Error(w, "failed to decode notification preferences", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.svc.UpdateNotificationPreferences(ctx, customClaims.DBUserId, preferences)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to save notification preferences")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, saved)
+}
+
+func (h *Handler) HandleListMyNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	notificationList, err := h.svc.ListNotifications(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list notifications")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, notificationList)
+}
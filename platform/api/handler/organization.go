@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleGetOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	organization, err := h.svc.GetOrganization(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get organization")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, organization)
+}
+
+func (h *Handler) HandleInviteOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.OrganizationMemberInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode organization invitation", "error", err)
+		http.Error(w, "failed to decode organization invitation", http.StatusBadRequest)
+		return
+	}
+
+	invitation, err := h.svc.InviteOrganizationMember(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to invite organization member")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, invitation)
+}
+
+func (h *Handler) HandleListOrganizationInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	invitations, err := h.svc.ListOrganizationInvitations(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list organization invitations")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, invitations)
+}
+
+func (h *Handler) HandleAcceptOrganizationInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	member, err := h.svc.AcceptOrganizationInvitation(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to accept organization invitation")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, member)
+}
+
+func (h *Handler) HandleDeclineOrganizationInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	invitation, err := h.svc.DeclineOrganizationInvitation(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to decline organization invitation")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, invitation)
+}
+
+func (h *Handler) HandleRemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	if err := h.svc.RemoveOrganizationMember(ctx, customClaims.DBUserId, chi.URLParam(r, "userID")); err != nil {
+		sendServiceError(ctx, w, err, "failed to remove organization member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleGetPayRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	rules, err := h.svc.GetPayRules(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get pay rules")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, rules)
+}
+
+func (h *Handler) HandlePutPayRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	var input service.PayRules
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode pay rules", "error", err)
+		http.Error(w, "failed to decode pay rules", http.StatusBadRequest)
+		return
+	}
+
+	rules, err := h.svc.SetPayRules(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to save pay rules")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, rules)
+}
+
+type billingRateRequest struct {
+	HourlyRate int64 `json:"hourly_rate"`
+}
+
+// HandlePutBillingRate sets the hourly rate the caller's organization is
+// billed for the role in the path.
+func (h *Handler) HandlePutBillingRate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	var req billingRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "failed to decode billing rate", "error", err)
+		http.Error(w, "failed to decode billing rate", http.StatusBadRequest)
+		return
+	}
+
+	rate, err := h.svc.SetBillingRate(ctx, customClaims.DBUserId, chi.URLParam(r, "role"), req.HourlyRate)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to save billing rate")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, rate)
+}
+
+type minimumShiftAbsorptionRequest struct {
+	AbsorbMinimumShift bool `json:"absorb_minimum_shift"`
+}
+
+// HandlePutMinimumShiftAbsorption lets platform staff set whether the
+// platform pays an organization's minimum shift guarantees.
+func (h *Handler) HandlePutMinimumShiftAbsorption(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	var req minimumShiftAbsorptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "failed to decode minimum shift absorption", "error", err)
+		http.Error(w, "failed to decode minimum shift absorption", http.StatusBadRequest)
+		return
+	}
+
+	rules, err := h.svc.SetMinimumShiftAbsorption(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), req.AbsorbMinimumShift)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to save pay rules")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, rules)
+}
+
+// HandleGetWorkerPayWeek shows how a worker's hours in a week fall into pay
+// categories. week_start is the Monday the week starts on.
+func (h *Handler) HandleGetWorkerPayWeek(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	weekStart, err := time.Parse(dateLayout, r.URL.Query().Get("week_start"))
+	if err != nil {
+		http.Error(w, "week_start must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	week, err := h.svc.EvaluatePayWeek(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), weekStart)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to evaluate pay week")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, week)
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payments"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+// maxWebhookBytes bounds the size of an inbound webhook body.
+const maxWebhookBytes = 1 << 20
+
+type CreatePaymentRequest struct {
+	// Amount defaults to the invoice's outstanding balance.
+	Amount *money.Money `json:"amount"`
+}
+
+func (h *Handler) HandleCreatePayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var req CreatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		slog.ErrorContext(ctx, "failed to decode payment", "error", err)
+		http.Error(w, "failed to decode payment", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.svc.CreatePayment(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), req.Amount)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to create payment")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, payment)
+}
+
+func (h *Handler) HandleListPayments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	result, err := h.svc.ListInvoicePayments(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list payments")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, result)
+}
+
+type CapturePaymentRequest struct {
+	// Amount defaults to the full payment amount.
+	Amount *money.Money `json:"amount"`
+}
+
+func (h *Handler) HandleCapturePayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var req CapturePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		slog.ErrorContext(ctx, "failed to decode capture", "error", err)
+		http.Error(w, "failed to decode capture", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.svc.CapturePayment(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), req.Amount)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to capture payment")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusAccepted, payment)
+}
+
+func (h *Handler) HandleRefundPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var req service.RefundInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "failed to decode refund", "error", err)
+		http.Error(w, "failed to decode refund", http.StatusBadRequest)
+		return
+	}
+
+	refund, err := h.svc.RefundPayment(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), &req)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to refund payment")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, refund)
+}
+
+func (h *Handler) HandleListRefunds(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	refunds, err := h.svc.ListInvoiceRefunds(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list refunds")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, refunds)
+}
+
+// HandlePaymentWebhook receives events from the payment provider. It is not
+// behind JWT auth; the provider's signature is verified instead.
+func (h *Handler) HandlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read payment webhook", "error", err)
+		http.Error(w, "failed to read payment webhook", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.HandlePaymentWebhook(ctx, payload, r.Header); err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			slog.WarnContext(ctx, "rejected payment webhook", "error", err)
+			http.Error(w, "invalid signature", http.StatusBadRequest)
+			return
+		}
+		sendServiceError(ctx, w, err, "failed to process payment webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
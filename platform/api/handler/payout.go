@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) HandleGetMyEarnings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	query := r.URL.Query()
+	var from, to time.Time
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse(dateLayout, value); err != nil {
+			http.Error(w, "from must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(dateLayout, value); err != nil {
+			http.Error(w, "to must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	earnings, err := h.svc.GetEarnings(ctx, customClaims.DBUserId, from, to)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get earnings")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, earnings)
+}
+
+func (h *Handler) HandleListMyPayouts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	payoutList, err := h.svc.ListPayouts(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list payouts")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, payoutList)
+}
+
+// HandleRetryPayout lets platform staff resubmit a payout the provider failed.
+func (h *Handler) HandleRetryPayout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	payout, err := h.svc.RetryPayout(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to retry payout")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, payout)
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	query := r.URL.Query()
+	var limit int
+	if raw := query.Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.svc.Search(ctx, customClaims.DBUserId, query.Get("q"), limit)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to search")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, results)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+const dateLayout = "2006-01-02"
+
+func (h *Handler) HandleCreateShift(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.ShiftInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode shift", "error", err)
+		http.Error(w, "failed to decode shift", http.StatusBadRequest)
+		return
+	}
+
+	shift, err := h.svc.CreateShift(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to create shift")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, shift)
+}
+
+// HandleUpdateShift edits a single shift. With ?scope=following on a shift
+// from a series it edits that occurrence and every one after it instead.
+func (h *Handler) HandleUpdateShift(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("scope") == "following" {
+		h.handleUpdateFollowingShifts(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.ShiftInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode shift", "error", err)
+		http.Error(w, "failed to decode shift", http.StatusBadRequest)
+		return
+	}
+
+	shift, err := h.svc.UpdateShift(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to update shift")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, shift)
+}
+
+func (h *Handler) HandleCancelShift(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	shift, err := h.svc.CancelShift(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to cancel shift")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, shift)
+}
+
+func (h *Handler) HandleGetShift(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	shift, err := h.svc.GetShift(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get shift")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, shift)
+}
+
+func (h *Handler) HandleListShifts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	query := r.URL.Query()
+	filter := service.ShiftFilter{
+		Status: service.ShiftStatus(query.Get("status")),
+	}
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(dateLayout, from); err != nil {
+			http.Error(w, "from must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(dateLayout, to); err != nil {
+			http.Error(w, "to must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	shifts, err := h.svc.ListShifts(ctx, customClaims.DBUserId, filter)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list shifts")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, shifts)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleCreateShiftSeries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.ShiftSeriesInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode shift series", "error", err)
+		http.Error(w, "failed to decode shift series", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.svc.CreateShiftSeries(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to create shift series")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, series)
+}
+
+func (h *Handler) HandleListShiftSeries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	seriesList, err := h.svc.ListShiftSeries(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list shift series")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, seriesList)
+}
+
+func (h *Handler) HandleGetShiftSeries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	series, err := h.svc.GetShiftSeries(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get shift series")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, series)
+}
+
+func (h *Handler) handleUpdateFollowingShifts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.ShiftSeriesInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode shift series", "error", err)
+		http.Error(w, "failed to decode shift series", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.svc.UpdateFollowingShifts(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to update shift series")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, series)
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleClockIn(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	timesheet, err := h.svc.ClockIn(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to clock in")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, timesheet)
+}
+
+func (h *Handler) HandleClockOut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	timesheet, err := h.svc.ClockOut(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), chi.URLParam(r, "timesheetID"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to clock out")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, timesheet)
+}
+
+func (h *Handler) HandleStartBreak(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	timesheet, err := h.svc.StartBreak(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), chi.URLParam(r, "timesheetID"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to start break")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, timesheet)
+}
+
+func (h *Handler) HandleEndBreak(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	timesheet, err := h.svc.EndBreak(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), chi.URLParam(r, "timesheetID"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to end break")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, timesheet)
+}
+
+func (h *Handler) HandleListTimesheets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	timesheets, err := h.svc.ListShiftTimesheets(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list timesheets")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, timesheets)
+}
+
+func (h *Handler) HandleGetTimesheetVersions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	versions, err := h.svc.GetTimesheetVersions(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), chi.URLParam(r, "timesheetID"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get timesheet versions")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, versions)
+}
+
+func (h *Handler) HandleCorrectTimesheet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.TimesheetCorrection
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode timesheet correction", "error", err)
+		http.Error(w, "failed to decode timesheet correction", http.StatusBadRequest)
+		return
+	}
+
+	timesheet, err := h.svc.CorrectTimesheet(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), chi.URLParam(r, "timesheetID"), &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to correct timesheet")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, timesheet)
+}
+
+func (h *Handler) HandleApproveTimesheet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	timesheet, err := h.svc.ApproveTimesheet(ctx, customClaims.DBUserId, chi.URLParam(r, "id"), chi.URLParam(r, "timesheetID"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to approve timesheet")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, timesheet)
+}
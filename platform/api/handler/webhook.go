@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+func (h *Handler) HandleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	var input service.WebhookEndpointInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode webhook endpoint", "error", err)
+		http.Error(w, "failed to decode webhook endpoint", http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := h.svc.CreateWebhookEndpoint(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to create webhook endpoint")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusCreated, endpoint)
+}
+
+func (h *Handler) HandleListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	endpoints, err := h.svc.ListWebhookEndpoints(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list webhook endpoints")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, endpoints)
+}
+
+func (h *Handler) HandleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	if err := h.svc.DeleteWebhookEndpoint(ctx, customClaims.DBUserId, chi.URLParam(r, "id")); err != nil {
+		sendServiceError(ctx, w, err, "failed to delete webhook endpoint")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	deliveries, err := h.svc.ListWebhookDeliveries(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to list webhook deliveries")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, deliveries)
+}
+
+func (h *Handler) HandleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+	delivery, err := h.svc.RedeliverWebhook(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to redeliver webhook")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusAccepted, delivery)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
+)
+
+// Worker-facing endpoints
+
+func (h *Handler) HandleGetMyProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	profile, err := h.svc.GetWorkerProfile(ctx, customClaims.DBUserId)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get worker profile")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, profile)
+}
+
+func (h *Handler) HandlePutMyProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	var input service.WorkerProfileInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.ErrorContext(ctx, "failed to decode worker profile", "error", err)
+		http.Error(w, "failed to decode worker profile", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.svc.UpsertWorkerProfile(ctx, customClaims.DBUserId, &input)
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to save worker profile")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, profile)
+}
+
+func (h *Handler) HandleDeleteMyProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	if err := h.svc.DeleteWorkerProfile(ctx, customClaims.DBUserId); err != nil {
+		sendServiceError(ctx, w, err, "failed to delete worker profile")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Employer-facing endpoints
+
+func (h *Handler) HandleGetWorkerProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	customClaims := claimsFromContext(ctx)
+
+	profile, err := h.svc.GetAssignedWorkerProfile(ctx, customClaims.DBUserId, chi.URLParam(r, "id"))
+	if err != nil {
+		sendServiceError(ctx, w, err, "failed to get worker profile")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, profile)
+}
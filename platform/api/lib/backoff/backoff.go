@@ -0,0 +1,18 @@
+// Package backoff spaces out retries of work that failed.
+package backoff
+
+import "time"
+
+// Exponential returns how long to wait after the given failed attempt
+// (starting at 1) before trying again. The delay starts at initial and
+// doubles with each attempt until it reaches max.
+func Exponential(attempt int, initial, max time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
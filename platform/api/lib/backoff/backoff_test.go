@@ -0,0 +1,25 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Exponential(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{attempt: 0, expected: time.Second},
+		{attempt: 1, expected: time.Second},
+		{attempt: 2, expected: 2 * time.Second},
+		{attempt: 4, expected: 8 * time.Second},
+		{attempt: 7, expected: time.Minute},
+		{attempt: 1000, expected: time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Exponential(tt.attempt, time.Second, time.Minute), "attempt %d", tt.attempt)
+	}
+}
@@ -0,0 +1,225 @@
+// Package invoicenumber formats the human invoice numbers accounting works
+// with, such as "ACME-2026-00042", from a template. A template's date tokens
+// also decide when its sequence starts again from one: every year when it
+// shows the year, every month when it shows the month too, and never when it
+// shows neither.
+package invoicenumber
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTemplate is returned for templates that are malformed or use
+// tokens this package does not know.
+var ErrInvalidTemplate = errors.New("invalid invoice number template")
+
+// DefaultTemplate numbers invoices per year with five digits.
+const DefaultTemplate = "{PREFIX}-{YYYY}-{SEQ:5}"
+
+// DefaultPrefix is used for organizations whose name has no letters or
+// digits to derive a prefix from.
+const DefaultPrefix = "INV"
+
+// MaxSequenceWidth bounds the zero padding of {SEQ:n}.
+const MaxSequenceWidth = 12
+
+type tokenKind int
+
+const (
+	tokenLiteral tokenKind = iota
+	tokenPrefix
+	tokenYear
+	tokenShortYear
+	tokenMonth
+	tokenSequence
+)
+
+var tokenNames = map[string]tokenKind{
+	"PREFIX": tokenPrefix,
+	"YYYY":   tokenYear,
+	"YY":     tokenShortYear,
+	"MM":     tokenMonth,
+	"SEQ":    tokenSequence,
+}
+
+type token struct {
+	kind tokenKind
+	// text is the literal text of tokenLiteral.
+	text string
+	// width is the zero padding of tokenSequence.
+	width int
+}
+
+// Template is a parsed invoice number template.
+type Template struct {
+	source string
+	tokens []token
+}
+
+// Parse parses a template such as "{PREFIX}-{YYYY}-{SEQ:5}". It must contain
+// exactly one {SEQ} or {SEQ:n} token, where n zero-pads the number to n
+// digits; {PREFIX}, {YYYY}, {YY} and {MM} may appear anywhere, but {MM} only
+// alongside a year so that monthly sequences never repeat across years.
+func Parse(s string) (*Template, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("%w: empty template", ErrInvalidTemplate)
+	}
+
+	t := &Template{source: s}
+	var sequences, years, months int
+	for rest := s; rest != ""; {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.tokens = append(t.tokens, token{kind: tokenLiteral, text: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("%w: unmatched }", ErrInvalidTemplate)
+		}
+		if open > 0 {
+			t.tokens = append(t.tokens, token{kind: tokenLiteral, text: rest[:open]})
+		}
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end < 0 || rest[open+1+end] != '}' {
+			return nil, fmt.Errorf("%w: unterminated token in %q", ErrInvalidTemplate, rest[open:])
+		}
+		body := rest[open+1 : open+1+end]
+		rest = rest[open+end+2:]
+
+		name, param, hasParam := strings.Cut(body, ":")
+		kind, ok := tokenNames[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown token {%s}", ErrInvalidTemplate, body)
+		}
+		tok := token{kind: kind}
+		switch kind {
+		case tokenSequence:
+			sequences++
+			tok.width = 1
+			if hasParam {
+				width, err := strconv.Atoi(param)
+				if err != nil || width < 1 || width > MaxSequenceWidth {
+					return nil, fmt.Errorf("%w: {SEQ:n} width must be between 1 and %d", ErrInvalidTemplate, MaxSequenceWidth)
+				}
+				tok.width = width
+			}
+		case tokenYear, tokenShortYear:
+			years++
+		case tokenMonth:
+			months++
+		}
+		if hasParam && kind != tokenSequence {
+			return nil, fmt.Errorf("%w: {%s} takes no width", ErrInvalidTemplate, name)
+		}
+		t.tokens = append(t.tokens, tok)
+	}
+
+	if sequences != 1 {
+		return nil, fmt.Errorf("%w: must contain exactly one {SEQ} token", ErrInvalidTemplate)
+	}
+	if months > 0 && years == 0 {
+		return nil, fmt.Errorf("%w: {MM} needs {YYYY} or {YY} as well", ErrInvalidTemplate)
+	}
+	return t, nil
+}
+
+// MustParse is like Parse but panics on error. It is meant for templates
+// fixed at compile time.
+func MustParse(s string) *Template {
+	t, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the template as it was parsed.
+func (t *Template) String() string {
+	return t.source
+}
+
+func (t *Template) has(kinds ...tokenKind) bool {
+	for _, tok := range t.tokens {
+		for _, kind := range kinds {
+			if tok.kind == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Period names the sequence an invoice issued on date draws its number from:
+// "2026-10" for monthly templates, "2026" for yearly ones and "" for
+// templates whose sequence never restarts.
+func (t *Template) Period(date time.Time) string {
+	switch {
+	case t.has(tokenMonth):
+		return date.Format("2006-01")
+	case t.has(tokenYear, tokenShortYear):
+		return date.Format("2006")
+	default:
+		return ""
+	}
+}
+
+// Format renders the number for the seq-th invoice of date's period.
+func (t *Template) Format(prefix string, date time.Time, seq int64) string {
+	var b strings.Builder
+	for _, tok := range t.tokens {
+		switch tok.kind {
+		case tokenLiteral:
+			b.WriteString(tok.text)
+		case tokenPrefix:
+			b.WriteString(prefix)
+		case tokenYear:
+			b.WriteString(date.Format("2006"))
+		case tokenShortYear:
+			b.WriteString(date.Format("06"))
+		case tokenMonth:
+			b.WriteString(date.Format("01"))
+		case tokenSequence:
+			fmt.Fprintf(&b, "%0*d", tok.width, seq)
+		}
+	}
+	return b.String()
+}
+
+// PrefixFromName derives a prefix from an organization's name: its first
+// four ASCII letters and digits, upper-cased, so "Acme, Inc." becomes "ACME".
+func PrefixFromName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if b.Len() == 4 {
+			break
+		}
+		switch {
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return DefaultPrefix
+	}
+	return b.String()
+}
+
+// ValidPrefix reports whether prefix is one to twelve ASCII letters, digits,
+// dashes or underscores.
+func ValidPrefix(prefix string) bool {
+	if prefix == "" || len(prefix) > 12 {
+		return false
+	}
+	for _, r := range prefix {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package invoicenumber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "default", template: DefaultTemplate},
+		{name: "unpadded sequence", template: "INV{SEQ}"},
+		{name: "monthly", template: "{PREFIX}/{YY}{MM}/{SEQ:4}"},
+		{name: "empty", template: "  ", wantErr: true},
+		{name: "no sequence", template: "{PREFIX}-{YYYY}", wantErr: true},
+		{name: "two sequences", template: "{SEQ}-{SEQ}", wantErr: true},
+		{name: "month without year", template: "{MM}-{SEQ}", wantErr: true},
+		{name: "zero width", template: "{SEQ:0}", wantErr: true},
+		{name: "too wide", template: "{SEQ:13}", wantErr: true},
+		{name: "width on other token", template: "{YYYY:2}-{SEQ}", wantErr: true},
+		{name: "unknown token", template: "{DD}-{SEQ}", wantErr: true},
+		{name: "lower case token", template: "{seq}", wantErr: true},
+		{name: "unterminated", template: "{SEQ", wantErr: true},
+		{name: "nested", template: "{{SEQ}}", wantErr: true},
+		{name: "stray close", template: "A}{SEQ}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := Parse(tt.template)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTemplate)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.template, template.String())
+		})
+	}
+}
+
+func Test_Format(t *testing.T) {
+	issued := time.Date(2026, time.March, 9, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		seq      int64
+		want     string
+		period   string
+	}{
+		{name: "default", template: DefaultTemplate, seq: 42, want: "ACME-2026-00042", period: "2026"},
+		{name: "overflows padding", template: "{PREFIX}-{SEQ:2}", seq: 1234, want: "ACME-1234", period: ""},
+		{name: "monthly", template: "{PREFIX}/{YY}{MM}/{SEQ:4}", seq: 7, want: "ACME/2603/0007", period: "2026-03"},
+		{name: "short year", template: "{YY}-{SEQ}", seq: 3, want: "26-3", period: "2026"},
+		{name: "literal only", template: "INV #{SEQ:6}", seq: 10, want: "INV #000010", period: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := MustParse(tt.template)
+			assert.Equal(t, tt.want, template.Format("ACME", issued, tt.seq))
+			assert.Equal(t, tt.period, template.Period(issued))
+		})
+	}
+}
+
+func Test_PrefixFromName(t *testing.T) {
+	assert.Equal(t, "ACME", PrefixFromName("Acme, Inc."))
+	assert.Equal(t, "A1B", PrefixFromName("a-1 b"))
+	assert.Equal(t, "CAFE", PrefixFromName("Café Emporium"))
+	assert.Equal(t, DefaultPrefix, PrefixFromName("日本"))
+	assert.Equal(t, DefaultPrefix, PrefixFromName(""))
+}
+
+func Test_ValidPrefix(t *testing.T) {
+	assert.True(t, ValidPrefix("ACME"))
+	assert.True(t, ValidPrefix("acme_us-2"))
+	assert.False(t, ValidPrefix(""))
+	assert.False(t, ValidPrefix("ACME CORP"))
+	assert.False(t, ValidPrefix("ABCDEFGHIJKLM"))
+	assert.False(t, ValidPrefix("{SEQ}"))
+}
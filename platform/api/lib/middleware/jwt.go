@@ -38,6 +38,8 @@ func EnsureValidToken(ctx context.Context, cfg *config.Config) func(next http.Ha
 		log.Fatalf("Failed to parse the issuer url: %v", err)
 	}
 
+	registerLegacyRoleID(cfg.Auth0RoleID, RoleEmployerAdmin)
+
 	// todo: do i need to create a new caching provider every time?
 	provider := jwks.NewCachingProvider(issuerURL, 5*time.Minute)
 
@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+)
+
+// Role is a named role granted to a user in Auth0 and carried in the roles
+// claim of their token.
+type Role string
+
+const (
+	RoleEmployerAdmin  Role = "employer_admin"
+	RoleEmployerMember Role = "employer_member"
+	RoleWorker         Role = "worker"
+	RolePlatformAdmin  Role = "platform_admin"
+)
+
+// Permission is an action a route requires, named resource:verb.
+type Permission string
+
+const (
+	PermissionUserRead           Permission = "user:read"
+	PermissionOrganizationRead   Permission = "organization:read"
+	PermissionOrganizationManage Permission = "organization:manage"
+	PermissionInvoicesRead       Permission = "invoices:read"
+	PermissionInvoicesWrite      Permission = "invoices:write"
+	PermissionPaymentsWrite      Permission = "payments:write"
+	PermissionShiftsRead         Permission = "shifts:read"
+	PermissionShiftsWrite        Permission = "shifts:write"
+	PermissionShiftsApply        Permission = "shifts:apply"
+	PermissionAssignmentsWrite   Permission = "assignments:write"
+	PermissionProfileManage      Permission = "profile:manage"
+	PermissionWorkersRead        Permission = "workers:read"
+	PermissionTimesheetsRead     Permission = "timesheets:read"
+	PermissionTimesheetsWrite    Permission = "timesheets:write"
+	PermissionTimesheetsApprove  Permission = "timesheets:approve"
+	PermissionAuditRead          Permission = "audit:read"
+	PermissionWebhooksManage     Permission = "webhooks:manage"
+	PermissionPayRulesManage     Permission = "pay_rules:manage"
+	// PermissionBillingRatesManage covers the hourly rates an organization is
+	// billed for each role.
+	PermissionBillingRatesManage Permission = "billing_rates:manage"
+	// PermissionNotificationsManage covers a user's own notification
+	// preferences and delivery log.
+	PermissionNotificationsManage Permission = "notifications:manage"
+	// PermissionEarningsRead covers a worker's own earnings and payouts.
+	PermissionEarningsRead Permission = "earnings:read"
+	// PermissionLedgerRead covers an organization's ledger balances and
+	// journal entries.
+	PermissionLedgerRead Permission = "ledger:read"
+	// PermissionCreditNotesWrite and PermissionRefundsWrite reduce what a
+	// customer owes and return their money, so only platform staff hold them,
+	// for any organization's invoices.
+	PermissionCreditNotesWrite Permission = "credit_notes:write"
+	PermissionRefundsWrite     Permission = "refunds:write"
+	// PermissionMinimumShiftAbsorb lets platform staff have the platform pay
+	// an organization's minimum shift guarantees instead of billing them.
+	PermissionMinimumShiftAbsorb Permission = "pay_rules:absorb_minimum_shift"
+	// PermissionPayoutsManage lets platform staff reissue payouts the payout
+	// provider failed.
+	PermissionPayoutsManage Permission = "payouts:manage"
+)
+
+var employerMemberPermissions = []Permission{
+	PermissionUserRead,
+	PermissionOrganizationRead,
+	PermissionInvoicesRead,
+	PermissionShiftsRead,
+	PermissionShiftsWrite,
+	PermissionAssignmentsWrite,
+	PermissionWorkersRead,
+	PermissionTimesheetsRead,
+	PermissionTimesheetsApprove,
+	PermissionNotificationsManage,
+}
+
+var employerAdminPermissions = slices.Concat(employerMemberPermissions, []Permission{
+	PermissionOrganizationManage,
+	PermissionInvoicesWrite,
+	PermissionPaymentsWrite,
+	PermissionAuditRead,
+	PermissionWebhooksManage,
+	PermissionPayRulesManage,
+	PermissionBillingRatesManage,
+	PermissionLedgerRead,
+})
+
+var workerPermissions = []Permission{
+	PermissionUserRead,
+	PermissionShiftsApply,
+	PermissionProfileManage,
+	PermissionTimesheetsRead,
+	PermissionTimesheetsWrite,
+	PermissionNotificationsManage,
+	PermissionEarningsRead,
+}
+
+// platformPermissions are held by platform staff alone; no employer or
+// worker role grants them.
+var platformPermissions = []Permission{
+	PermissionCreditNotesWrite,
+	PermissionRefundsWrite,
+	PermissionMinimumShiftAbsorb,
+	PermissionPayoutsManage,
+}
+
+// rolePermissions maps each role onto what it may do. Platform admins may do
+// anything; outside the platform permissions the service still scopes their
+// data to their own organization.
+var rolePermissions = map[Role]map[Permission]bool{
+	RoleEmployerAdmin:  permissionSet(employerAdminPermissions...),
+	RoleEmployerMember: permissionSet(employerMemberPermissions...),
+	RoleWorker:         permissionSet(workerPermissions...),
+	RolePlatformAdmin:  permissionSet(slices.Concat(employerAdminPermissions, workerPermissions, platformPermissions)...),
+}
+
+// legacyRoleIDs maps the Auth0 role IDs that tokens carried before roles were
+// named onto the roles that replaced them. The only role then was the
+// employer role, whose holders managed everything their organization owns.
+// Tokens issued before the rename keep working until they expire or the
+// user's roles are reassigned in Auth0.
+var legacyRoleIDs = map[string]Role{
+	"rol_lz7KugKHb6tiTJVl": RoleEmployerAdmin,
+}
+
+// registerLegacyRoleID treats tokens carrying the Auth0 role ID as holding
+// role. Deployments whose tenant issued a different employer role ID set it
+// through config.
+func registerLegacyRoleID(id string, role Role) {
+	if id != "" {
+		legacyRoleIDs[id] = role
+	}
+}
+
+// roleNamed resolves a roles claim entry, which is a role name or a legacy
+// Auth0 role ID.
+func roleNamed(name string) Role {
+	if role, ok := legacyRoleIDs[name]; ok {
+		return role
+	}
+	return Role(name)
+}
+
+func permissionSet(permissions ...Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(permissions))
+	for _, permission := range permissions {
+		set[permission] = true
+	}
+	return set
+}
+
+// HasPermission reports whether any of the caller's roles grants permission.
+// Legacy role IDs count as the role they map to; unknown roles grant nothing.
+func (c *CustomClaims) HasPermission(permission Permission) bool {
+	if c == nil {
+		return false
+	}
+	for _, role := range c.Roles {
+		if rolePermissions[roleNamed(role)][permission] {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimsFromContext returns the custom claims of the validated JWT on the
+// request, or nil when there is none.
+func ClaimsFromContext(ctx context.Context) *CustomClaims {
+	token, ok := ctx.Value(jwtmiddleware.ContextKey{}).(*validator.ValidatedClaims)
+	if !ok {
+		return nil
+	}
+	customClaims, _ := token.CustomClaims.(*CustomClaims)
+	return customClaims
+}
+
+// RequirePermission rejects requests whose token does not grant permission. It
+// must run after EnsureValidToken.
+func RequirePermission(permission Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := ClaimsFromContext(r.Context())
+			if claims == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !claims.HasPermission(permission) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HasPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     *CustomClaims
+		permission Permission
+		expected   bool
+	}{
+		{name: "admin manages webhooks", claims: &CustomClaims{Roles: []string{"employer_admin"}}, permission: PermissionWebhooksManage, expected: true},
+		{name: "admin inherits member permissions", claims: &CustomClaims{Roles: []string{"employer_admin"}}, permission: PermissionInvoicesRead, expected: true},
+		{name: "member cannot manage webhooks", claims: &CustomClaims{Roles: []string{"employer_member"}}, permission: PermissionWebhooksManage},
+		{name: "worker applies to shifts", claims: &CustomClaims{Roles: []string{"worker"}}, permission: PermissionShiftsApply, expected: true},
+		{name: "worker cannot read invoices", claims: &CustomClaims{Roles: []string{"worker"}}, permission: PermissionInvoicesRead},
+		{name: "workers manage their notifications", claims: &CustomClaims{Roles: []string{"worker"}}, permission: PermissionNotificationsManage, expected: true},
+		{name: "workers read their earnings", claims: &CustomClaims{Roles: []string{"worker"}}, permission: PermissionEarningsRead, expected: true},
+		{name: "members have no earnings", claims: &CustomClaims{Roles: []string{"employer_member"}}, permission: PermissionEarningsRead},
+		{name: "admins set billing rates", claims: &CustomClaims{Roles: []string{"employer_admin"}}, permission: PermissionBillingRatesManage, expected: true},
+		{name: "members cannot set billing rates", claims: &CustomClaims{Roles: []string{"employer_member"}}, permission: PermissionBillingRatesManage},
+		{name: "members cannot read the ledger", claims: &CustomClaims{Roles: []string{"employer_member"}}, permission: PermissionLedgerRead},
+		{name: "admins cannot write credit notes", claims: &CustomClaims{Roles: []string{"employer_admin"}}, permission: PermissionCreditNotesWrite},
+		{name: "admins cannot refund", claims: &CustomClaims{Roles: []string{"employer_admin"}}, permission: PermissionRefundsWrite},
+		{name: "platform admin refunds", claims: &CustomClaims{Roles: []string{"platform_admin"}}, permission: PermissionRefundsWrite, expected: true},
+		{name: "admins cannot absorb minimum shifts", claims: &CustomClaims{Roles: []string{"employer_admin"}}, permission: PermissionMinimumShiftAbsorb},
+		{name: "workers cannot reissue payouts", claims: &CustomClaims{Roles: []string{"worker"}}, permission: PermissionPayoutsManage},
+		{name: "any role may grant", claims: &CustomClaims{Roles: []string{"worker", "employer_member"}}, permission: PermissionShiftsWrite, expected: true},
+		{name: "platform admin may do anything", claims: &CustomClaims{Roles: []string{"platform_admin"}}, permission: PermissionTimesheetsWrite, expected: true},
+		{name: "legacy employer role id acts as admin", claims: &CustomClaims{Roles: []string{"rol_lz7KugKHb6tiTJVl"}}, permission: PermissionWebhooksManage, expected: true},
+		{name: "legacy role ids grant no platform permissions", claims: &CustomClaims{Roles: []string{"rol_lz7KugKHb6tiTJVl"}}, permission: PermissionRefundsWrite},
+		{name: "unknown role grants nothing", claims: &CustomClaims{Roles: []string{"rol_unknown"}}, permission: PermissionUserRead},
+		{name: "no roles", claims: &CustomClaims{}, permission: PermissionUserRead},
+		{name: "no claims", claims: nil, permission: PermissionUserRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.claims.HasPermission(tt.permission))
+		})
+	}
+}
+
+func Test_RequirePermission(t *testing.T) {
+	handler := RequirePermission(PermissionInvoicesRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	withRoles := func(roles ...string) context.Context {
+		return context.WithValue(context.Background(), jwtmiddleware.ContextKey{}, &validator.ValidatedClaims{
+			CustomClaims: &CustomClaims{Roles: roles},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected int
+	}{
+		{name: "granted", ctx: withRoles("employer_member"), expected: http.StatusNoContent},
+		{name: "not granted", ctx: withRoles("worker"), expected: http.StatusForbidden},
+		{name: "empty roles", ctx: withRoles(), expected: http.StatusForbidden},
+		{name: "no token", ctx: context.Background(), expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/invoices", nil).WithContext(tt.ctx)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Package money represents monetary amounts exactly, as a whole number of
+// minor units (cents for USD) in an ISO 4217 currency. Amounts are never held
+// as floats, and every operation that could overflow or mix currencies returns
+// an error instead of silently producing a wrong total.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+const (
+	USD Currency = "USD"
+	CAD Currency = "CAD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	AUD Currency = "AUD"
+	JPY Currency = "JPY"
+)
+
+// minorUnits is the number of decimal places of each supported currency.
+var minorUnits = map[Currency]int{
+	USD: 2,
+	CAD: 2,
+	EUR: 2,
+	GBP: 2,
+	AUD: 2,
+	JPY: 0,
+}
+
+// ParseCurrency validates an ISO 4217 code, accepting any letter case.
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if !c.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Valid reports whether the currency is supported.
+func (c Currency) Valid() bool {
+	_, ok := minorUnits[c]
+	return ok
+}
+
+// MinorUnits returns the number of decimal places the currency is quoted in.
+func (c Currency) MinorUnits() int {
+	return minorUnits[c]
+}
+
+// Money is an amount in the minor unit of its currency.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// New returns amount minor units of currency.
+func New(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Zero returns a zero amount in currency.
+func Zero(currency Currency) Money {
+	return Money{Currency: currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, o)
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	diff := m.Amount - o.Amount
+	if (o.Amount > 0 && diff > m.Amount) || (o.Amount < 0 && diff < m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, o)
+	}
+	return Money{Amount: diff, Currency: m.Currency}, nil
+}
+
+// Neg returns -m.
+func (m Money) Neg() (Money, error) {
+	if m.Amount == math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: -(%s)", ErrOverflow, m)
+	}
+	return Money{Amount: -m.Amount, Currency: m.Currency}, nil
+}
+
+// MulRat returns m * num / den rounded to the nearest minor unit, with halves
+// rounded away from zero. The intermediate product is exact, so this is the
+// one place fractional amounts are rounded.
+func (m Money) MulRat(num, den int64) (Money, error) {
+	if den == 0 {
+		return Money{}, errors.New("money: division by zero")
+	}
+	if den < 0 {
+		num, den = -num, -den
+	}
+
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(num))
+	negative := product.Sign() < 0
+	product.Abs(product)
+
+	// round(|p| / den) = floor((2|p| + den) / 2den)
+	bigDen := big.NewInt(den)
+	product.Add(product.Lsh(product, 1), bigDen)
+	product.Quo(product, bigDen.Lsh(bigDen, 1))
+	if negative {
+		product.Neg(product)
+	}
+
+	if !product.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s * %d / %d", ErrOverflow, m, num, den)
+	}
+	return Money{Amount: product.Int64(), Currency: m.Currency}, nil
+}
+
+// BasisPoints returns bps hundredths of a percent of m, rounded as MulRat.
+func (m Money) BasisPoints(bps int64) (Money, error) {
+	return m.MulRat(bps, 10000)
+}
+
+// Sum adds amounts that must all be in currency. An empty list sums to zero.
+func Sum(currency Currency, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// String formats the amount in major units, e.g. "1234.50 USD".
+func (m Money) String() string {
+	units := m.Currency.MinorUnits()
+	if units == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+
+	sign := ""
+	abs := new(big.Int).Abs(big.NewInt(m.Amount)).String()
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	if len(abs) <= units {
+		abs = strings.Repeat("0", units-len(abs)+1) + abs
+	}
+	split := len(abs) - units
+	return fmt.Sprintf("%s%s.%s %s", sign, abs[:split], abs[split:], m.Currency)
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes {"amount": 1234, "currency": "USD"}, rejecting unknown
+// currencies and fractional amounts.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	amount, err := raw.Amount.Int64()
+	if err != nil {
+		return fmt.Errorf("money amount must be a whole number of minor units: %w", err)
+	}
+	currency, err := ParseCurrency(raw.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = Money{Amount: amount, Currency: currency}
+	return nil
+}
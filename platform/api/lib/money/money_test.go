@@ -0,0 +1,84 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AddAndSub(t *testing.T) {
+	sum, err := New(1999, USD).Add(New(1, USD))
+	require.NoError(t, err)
+	assert.Equal(t, New(2000, USD), sum)
+
+	diff, err := New(500, USD).Sub(New(750, USD))
+	require.NoError(t, err)
+	assert.Equal(t, New(-250, USD), diff)
+
+	_, err = New(100, USD).Add(New(100, EUR))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = New(math.MaxInt64, USD).Add(New(1, USD))
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	_, err = New(math.MinInt64, USD).Sub(New(1, USD))
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func Test_MulRat(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		num      int64
+		den      int64
+		expected int64
+	}{
+		{name: "exact", amount: 2000, num: 3, den: 2, expected: 3000},
+		{name: "rounds half up", amount: 1530, num: 1, den: 60, expected: 26},
+		{name: "rounds down below half", amount: 1000, num: 1, den: 3, expected: 333},
+		{name: "rounds negative half away from zero", amount: -5, num: 1, den: 2, expected: -3},
+		{name: "negative denominator", amount: 100, num: 1, den: -4, expected: -25},
+		{name: "large intermediate product", amount: math.MaxInt64 / 2, num: 2, den: 2, expected: math.MaxInt64 / 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(tt.amount, USD).MulRat(tt.num, tt.den)
+			require.NoError(t, err)
+			assert.Equal(t, New(tt.expected, USD), result)
+		})
+	}
+
+	_, err := New(math.MaxInt64, USD).MulRat(2, 1)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func Test_BasisPointsDoesNotDrift(t *testing.T) {
+	// 8.25% of $108.00 is $8.91 exactly, which float64 arithmetic gets wrong.
+	tax, err := New(10800, USD).BasisPoints(825)
+	require.NoError(t, err)
+	assert.Equal(t, New(891, USD), tax)
+}
+
+func Test_String(t *testing.T) {
+	assert.Equal(t, "1234.50 USD", New(123450, USD).String())
+	assert.Equal(t, "0.05 USD", New(5, USD).String())
+	assert.Equal(t, "-0.50 EUR", New(-50, EUR).String())
+	assert.Equal(t, "1500 JPY", New(1500, JPY).String())
+}
+
+func Test_JSON(t *testing.T) {
+	encoded, err := json.Marshal(New(1999, USD))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 1999, "currency": "USD"}`, string(encoded))
+
+	var decoded Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount": 1999, "currency": "usd"}`), &decoded))
+	assert.Equal(t, New(1999, USD), decoded)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount": 1, "currency": "XXX"}`), &decoded), ErrUnknownCurrency)
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 19.99, "currency": "USD"}`), &decoded))
+}
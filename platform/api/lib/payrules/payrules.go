@@ -0,0 +1,490 @@
+// Package payrules classifies a worker's hours into pay categories: regular
+// time, daily and weekly overtime, double time, holiday pay, night
+// differentials and minimum shift guarantees. Work is classified in the order
+// it happened, so a minute's category depends only on the work before it in
+// the same day and week, never on the order timesheets are billed in.
+package payrules
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned for rules that are inconsistent or out of range.
+var ErrInvalid = errors.New("invalid pay rules")
+
+// Category is the kind of time a line pays for.
+type Category string
+
+const (
+	CategoryRegular    Category = "regular"
+	CategoryOvertime   Category = "overtime"
+	CategoryDoubleTime Category = "double_time"
+	CategoryHoliday    Category = "holiday"
+	// CategoryNight is a premium paid on top of another category for hours
+	// worked in the night window, so its hours are also counted elsewhere.
+	CategoryNight Category = "night_differential"
+	// CategoryMinimumShift tops a short shift up to the guaranteed minimum. It
+	// is paid time that was not worked, so it never counts towards overtime.
+	CategoryMinimumShift Category = "minimum_shift"
+)
+
+// Categories lists every category in the order lines are produced.
+var Categories = []Category{CategoryRegular, CategoryOvertime, CategoryDoubleTime, CategoryHoliday, CategoryNight, CategoryMinimumShift}
+
+// BasisPointsOne is a 1x rate multiplier.
+const BasisPointsOne = 10000
+
+// Default multipliers used when a rule is enabled without one.
+const (
+	DefaultOvertimeBasisPoints   = 15000
+	DefaultDoubleTimeBasisPoints = 20000
+	DefaultHolidayBasisPoints    = 15000
+)
+
+// Holiday is a calendar day paid at the holiday rate. Date is compared by
+// its year, month and day in the timezone of the work.
+type Holiday struct {
+	Date time.Time
+	Name string
+}
+
+// Rules configures the engine. A zero threshold, window or guarantee turns
+// that rule off, so the zero Rules pays everything as regular time.
+// Multipliers are in basis points of the base rate; zero means the default.
+type Rules struct {
+	// DailyOvertimeAfter is the time worked in a calendar day after which
+	// hours are overtime.
+	DailyOvertimeAfter time.Duration
+	// DailyDoubleTimeAfter is the time worked in a calendar day after which
+	// hours are double time. It must be longer than DailyOvertimeAfter.
+	DailyDoubleTimeAfter time.Duration
+	// WeeklyOvertimeAfter is the time worked in a week, Monday to Sunday,
+	// after which hours are overtime.
+	WeeklyOvertimeAfter   time.Duration
+	OvertimeBasisPoints   int64
+	DoubleTimeBasisPoints int64
+
+	Holidays           []Holiday
+	HolidayBasisPoints int64
+
+	// NightStart and NightEnd bound the night window as offsets from local
+	// midnight. The window wraps past midnight when NightEnd is before
+	// NightStart, e.g. 22:00 to 06:00.
+	NightStart time.Duration
+	NightEnd   time.Duration
+	// NightDifferentialBasisPoints is the premium added for night hours, e.g.
+	// 1000 pays an extra 10% of the base rate.
+	NightDifferentialBasisPoints int64
+
+	// MinimumShift is the time a worker is paid for when a shift ends early.
+	MinimumShift time.Duration
+	// AbsorbMinimumShift pays the worker the guarantee without billing it to
+	// the employer.
+	AbsorbMinimumShift bool
+}
+
+// Validate checks that the rules are consistent.
+func (r Rules) Validate() error {
+	for name, d := range map[string]time.Duration{
+		"daily overtime threshold":    r.DailyOvertimeAfter,
+		"daily double time threshold": r.DailyDoubleTimeAfter,
+		"weekly overtime threshold":   r.WeeklyOvertimeAfter,
+		"minimum shift":               r.MinimumShift,
+	} {
+		if d < 0 {
+			return fmt.Errorf("%w: %s must not be negative", ErrInvalid, name)
+		}
+	}
+	if r.DailyOvertimeAfter > 24*time.Hour || r.DailyDoubleTimeAfter > 24*time.Hour {
+		return fmt.Errorf("%w: daily thresholds must be at most 24h", ErrInvalid)
+	}
+	if r.DailyDoubleTimeAfter > 0 && r.DailyDoubleTimeAfter <= r.DailyOvertimeAfter {
+		return fmt.Errorf("%w: daily double time threshold must be after the daily overtime threshold", ErrInvalid)
+	}
+	for name, bps := range map[string]int64{
+		"overtime multiplier":    r.OvertimeBasisPoints,
+		"double time multiplier": r.DoubleTimeBasisPoints,
+		"holiday multiplier":     r.HolidayBasisPoints,
+	} {
+		if bps != 0 && bps < BasisPointsOne {
+			return fmt.Errorf("%w: %s must be at least 1x", ErrInvalid, name)
+		}
+	}
+	if r.NightStart < 0 || r.NightStart >= 24*time.Hour || r.NightEnd < 0 || r.NightEnd >= 24*time.Hour {
+		return fmt.Errorf("%w: night window must be within the day", ErrInvalid)
+	}
+	if r.NightDifferentialBasisPoints < 0 {
+		return fmt.Errorf("%w: night differential must not be negative", ErrInvalid)
+	}
+	if r.nightEnabled() && r.NightDifferentialBasisPoints == 0 {
+		return fmt.Errorf("%w: night window needs a differential", ErrInvalid)
+	}
+	seen := map[string]bool{}
+	for _, holiday := range r.Holidays {
+		key := holiday.Date.Format(time.DateOnly)
+		if seen[key] {
+			return fmt.Errorf("%w: holiday %s is listed twice", ErrInvalid, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func (r Rules) nightEnabled() bool {
+	return r.NightStart != r.NightEnd
+}
+
+func (r Rules) multiplier(category Category) int64 {
+	var bps int64
+	switch category {
+	case CategoryOvertime:
+		bps = r.OvertimeBasisPoints
+		if bps == 0 {
+			bps = DefaultOvertimeBasisPoints
+		}
+	case CategoryDoubleTime:
+		bps = r.DoubleTimeBasisPoints
+		if bps == 0 {
+			bps = DefaultDoubleTimeBasisPoints
+		}
+	case CategoryHoliday:
+		bps = r.HolidayBasisPoints
+		if bps == 0 {
+			bps = DefaultHolidayBasisPoints
+		}
+	case CategoryNight:
+		bps = r.NightDifferentialBasisPoints
+	default:
+		bps = BasisPointsOne
+	}
+	return bps
+}
+
+// Interval is a stretch of time actually worked.
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Work is one timesheet: the intervals worked, with breaks left out, and the
+// timezone its days and weeks are counted in.
+type Work struct {
+	ID        string
+	Intervals []Interval
+	Location  *time.Location
+	// Prior work counts towards the day's and week's thresholds but produces
+	// no lines, e.g. timesheets that have already been billed.
+	Prior bool
+}
+
+func (w Work) start() time.Time {
+	if len(w.Intervals) == 0 {
+		return time.Time{}
+	}
+	return w.Intervals[0].Start
+}
+
+// Line is the time one piece of work is paid for in one category.
+// BasisPoints is the multiplier on the base rate, or for night differentials
+// the premium on top of it. Billable and payable time only differ when the
+// platform absorbs a minimum shift guarantee.
+type Line struct {
+	WorkID      string
+	Category    Category
+	BasisPoints int64
+	Billable    time.Duration
+	Payable     time.Duration
+	// Trace explains how the time was arrived at, one reason per entry.
+	Trace []string
+}
+
+// Evaluate classifies work under rules. Lines come out in the order the work
+// was given, categories in the order of Categories, and only for categories
+// with time in them.
+func Evaluate(rules Rules, work []Work) ([]Line, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	holidays := make(map[string]string, len(rules.Holidays))
+	for _, holiday := range rules.Holidays {
+		holidays[holiday.Date.Format(time.DateOnly)] = holiday.Name
+	}
+
+	chronological := slices.Clone(work)
+	sort.SliceStable(chronological, func(i, j int) bool {
+		return chronological[i].start().Before(chronological[j].start())
+	})
+
+	e := &evaluator{
+		rules:    rules,
+		holidays: holidays,
+		daily:    map[string]time.Duration{},
+		weekly:   map[string]time.Duration{},
+		results:  map[string]*workResult{},
+	}
+	for _, w := range chronological {
+		if err := e.classify(w); err != nil {
+			return nil, err
+		}
+	}
+
+	var lines []Line
+	for _, w := range work {
+		if w.Prior {
+			continue
+		}
+		result := e.results[w.ID]
+		for _, category := range Categories {
+			acc, ok := result.categories[category]
+			if !ok || acc.total <= 0 {
+				continue
+			}
+			line := Line{
+				WorkID:      w.ID,
+				Category:    category,
+				BasisPoints: rules.multiplier(category),
+				Billable:    acc.total,
+				Payable:     acc.total,
+				Trace:       acc.trace(),
+			}
+			if category == CategoryMinimumShift && rules.AbsorbMinimumShift {
+				line.Billable = 0
+				line.Trace = append(line.Trace, "not billed: the guarantee is paid by the platform")
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+type evaluator struct {
+	rules    Rules
+	holidays map[string]string
+	// daily and weekly hold the time worked so far in each local day and
+	// week, keyed by date.
+	daily   map[string]time.Duration
+	weekly  map[string]time.Duration
+	results map[string]*workResult
+}
+
+type workResult struct {
+	categories map[Category]*accumulator
+}
+
+func (r *workResult) add(category Category, reason string, d time.Duration) {
+	acc, ok := r.categories[category]
+	if !ok {
+		acc = &accumulator{reasons: map[string]time.Duration{}}
+		r.categories[category] = acc
+	}
+	acc.add(reason, d)
+}
+
+// accumulator sums a category's time by reason, keeping reasons in the order
+// they first applied. Reasons contain a %s for the time they cover.
+type accumulator struct {
+	total   time.Duration
+	order   []string
+	reasons map[string]time.Duration
+}
+
+func (a *accumulator) add(reason string, d time.Duration) {
+	if _, ok := a.reasons[reason]; !ok {
+		a.order = append(a.order, reason)
+	}
+	a.reasons[reason] += d
+	a.total += d
+}
+
+func (a *accumulator) trace() []string {
+	trace := make([]string, len(a.order))
+	for i, reason := range a.order {
+		trace[i] = fmt.Sprintf(reason, FormatDuration(a.reasons[reason]))
+	}
+	return trace
+}
+
+func (e *evaluator) classify(w Work) error {
+	if _, ok := e.results[w.ID]; ok {
+		return fmt.Errorf("work %s is listed twice", w.ID)
+	}
+	result := &workResult{categories: map[Category]*accumulator{}}
+	e.results[w.ID] = result
+
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	var worked time.Duration
+	for _, interval := range w.Intervals {
+		if interval.End.Before(interval.Start) {
+			return fmt.Errorf("work %s has an interval that ends before it starts", w.ID)
+		}
+		for at := interval.Start; at.Before(interval.End); {
+			next := e.classifySegment(result, at.In(loc), interval.End)
+			worked += next.Sub(at)
+			at = next
+		}
+	}
+
+	if e.rules.MinimumShift > 0 && worked < e.rules.MinimumShift {
+		result.add(CategoryMinimumShift,
+			fmt.Sprintf("%%s to make up the %s minimum shift; %s was worked", FormatDuration(e.rules.MinimumShift), FormatDuration(worked)),
+			e.rules.MinimumShift-worked)
+	}
+	return nil
+}
+
+// classifySegment classifies work from at, which is in the work's timezone,
+// up to the first point the category could change or end, and returns that
+// point.
+func (e *evaluator) classifySegment(result *workResult, at time.Time, end time.Time) time.Time {
+	rules := e.rules
+	day := at.Format(time.DateOnly)
+	week := weekStart(at).Format(time.DateOnly)
+	worked, workedWeek := e.daily[day], e.weekly[week]
+
+	next := startOfDay(at).AddDate(0, 0, 1)
+	if end.Before(next) {
+		next = end
+	}
+	night := false
+	if rules.nightEnabled() {
+		var boundary time.Time
+		night, boundary = e.nightWindow(at)
+		if boundary.Before(next) {
+			next = boundary
+		}
+	}
+	// Stop where the next threshold is crossed so time either side of it is
+	// classified separately.
+	for _, threshold := range []struct {
+		after, worked time.Duration
+	}{
+		{rules.DailyOvertimeAfter, worked},
+		{rules.DailyDoubleTimeAfter, worked},
+		{rules.WeeklyOvertimeAfter, workedWeek},
+	} {
+		if threshold.after > 0 && threshold.worked < threshold.after {
+			if crossing := at.Add(threshold.after - threshold.worked); crossing.Before(next) {
+				next = crossing
+			}
+		}
+	}
+	d := next.Sub(at)
+
+	type candidate struct {
+		category Category
+		reason   string
+	}
+	var candidates []candidate
+	if rules.DailyDoubleTimeAfter > 0 && worked >= rules.DailyDoubleTimeAfter {
+		candidates = append(candidates, candidate{CategoryDoubleTime,
+			fmt.Sprintf("%%s over the %s daily double time threshold on %s", FormatDuration(rules.DailyDoubleTimeAfter), day)})
+	}
+	if rules.DailyOvertimeAfter > 0 && worked >= rules.DailyOvertimeAfter {
+		candidates = append(candidates, candidate{CategoryOvertime,
+			fmt.Sprintf("%%s over the %s daily overtime threshold on %s", FormatDuration(rules.DailyOvertimeAfter), day)})
+	} else if rules.WeeklyOvertimeAfter > 0 && workedWeek >= rules.WeeklyOvertimeAfter {
+		candidates = append(candidates, candidate{CategoryOvertime,
+			fmt.Sprintf("%%s over the %s weekly overtime threshold in the week of %s", FormatDuration(rules.WeeklyOvertimeAfter), week)})
+	}
+	if name, ok := e.holidays[day]; ok {
+		candidates = append(candidates, candidate{CategoryHoliday, fmt.Sprintf("%%s on %s (%s)", name, day)})
+	}
+
+	// The highest multiplier wins; premiums do not stack. Ties go to the
+	// first candidate, so overtime is preferred to an equal holiday rate.
+	chosen := candidate{CategoryRegular, fmt.Sprintf("%%s regular time on %s", day)}
+	var outranked []string
+	for _, c := range candidates {
+		if rules.multiplier(c.category) > rules.multiplier(chosen.category) {
+			if chosen.category != CategoryRegular {
+				outranked = append(outranked, string(chosen.category))
+			}
+			chosen = c
+		} else {
+			outranked = append(outranked, string(c.category))
+		}
+	}
+	reason := chosen.reason
+	if len(outranked) > 0 {
+		reason += fmt.Sprintf(", paid instead of %s", strings.ReplaceAll(strings.Join(outranked, " and "), "_", " "))
+	}
+	result.add(chosen.category, reason, d)
+
+	if night {
+		result.add(CategoryNight, fmt.Sprintf("%%s in the %s to %s night window on %s",
+			formatClock(rules.NightStart), formatClock(rules.NightEnd), day), d)
+	}
+
+	e.daily[day] += d
+	e.weekly[week] += d
+	return next
+}
+
+// nightWindow reports whether at falls in the night window and when that next
+// changes.
+func (e *evaluator) nightWindow(at time.Time) (bool, time.Time) {
+	rules := e.rules
+	midnight := startOfDay(at)
+	var boundaries []time.Time
+	for days := 0; days <= 1; days++ {
+		day := midnight.AddDate(0, 0, days)
+		boundaries = append(boundaries, atOffset(day, rules.NightStart), atOffset(day, rules.NightEnd))
+	}
+	next := midnight.AddDate(0, 0, 2)
+	for _, boundary := range boundaries {
+		if boundary.After(at) && boundary.Before(next) {
+			next = boundary
+		}
+	}
+
+	offset := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute +
+		time.Duration(at.Second())*time.Second + time.Duration(at.Nanosecond())
+	var inside bool
+	if rules.NightStart < rules.NightEnd {
+		inside = offset >= rules.NightStart && offset < rules.NightEnd
+	} else {
+		inside = offset >= rules.NightStart || offset < rules.NightEnd
+	}
+	return inside, next
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// atOffset returns the wall clock time offset from midnight on day, so that
+// 22:00 is 22:00 even on days a DST change makes longer or shorter.
+func atOffset(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, int(offset), day.Location())
+}
+
+// weekStart returns midnight on the Monday starting t's week.
+func weekStart(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return startOfDay(t.AddDate(0, 0, -daysSinceMonday))
+}
+
+// FormatDuration renders time to the minute, e.g. "8h", "2h30m" or "45m".
+func FormatDuration(d time.Duration) string {
+	minutes := int64(d.Round(time.Minute) / time.Minute)
+	switch {
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	default:
+		return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+	}
+}
+
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset.Hours()), int(offset.Minutes())%60)
+}
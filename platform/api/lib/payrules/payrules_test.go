@@ -0,0 +1,212 @@
+package payrules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Monday 9 March 2026
+var monday = time.Date(2026, time.March, 9, 0, 0, 0, 0, time.UTC)
+
+func shift(id string, day int, startHour, hours float64) Work {
+	start := monday.AddDate(0, 0, day).Add(time.Duration(startHour * float64(time.Hour)))
+	return Work{ID: id, Intervals: []Interval{{Start: start, End: start.Add(time.Duration(hours * float64(time.Hour)))}}}
+}
+
+// summary maps each work's categories to billable hours.
+func summary(lines []Line) map[string]map[Category]float64 {
+	out := map[string]map[Category]float64{}
+	for _, line := range lines {
+		if out[line.WorkID] == nil {
+			out[line.WorkID] = map[Category]float64{}
+		}
+		out[line.WorkID][line.Category] = line.Billable.Hours()
+	}
+	return out
+}
+
+func Test_Evaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    Rules
+		work     []Work
+		expected map[string]map[Category]float64
+	}{
+		{
+			name:     "no rules pays regular time",
+			work:     []Work{shift("a", 0, 9, 13)},
+			expected: map[string]map[Category]float64{"a": {CategoryRegular: 13}},
+		},
+		{
+			name:     "daily overtime and double time",
+			rules:    Rules{DailyOvertimeAfter: 8 * time.Hour, DailyDoubleTimeAfter: 12 * time.Hour},
+			work:     []Work{shift("a", 0, 6, 13)},
+			expected: map[string]map[Category]float64{"a": {CategoryRegular: 8, CategoryOvertime: 4, CategoryDoubleTime: 1}},
+		},
+		{
+			name:  "daily overtime counts earlier shifts the same day",
+			rules: Rules{DailyOvertimeAfter: 8 * time.Hour},
+			work:  []Work{shift("a", 0, 6, 5), shift("b", 0, 14, 5)},
+			expected: map[string]map[Category]float64{
+				"a": {CategoryRegular: 5},
+				"b": {CategoryRegular: 3, CategoryOvertime: 2},
+			},
+		},
+		{
+			name:  "weekly overtime",
+			rules: Rules{WeeklyOvertimeAfter: 40 * time.Hour},
+			work: []Work{
+				shift("mon", 0, 8, 9), shift("tue", 1, 8, 9), shift("wed", 2, 8, 9), shift("thu", 3, 8, 9), shift("fri", 4, 8, 9),
+				shift("next-mon", 7, 8, 9),
+			},
+			expected: map[string]map[Category]float64{
+				"mon": {CategoryRegular: 9}, "tue": {CategoryRegular: 9}, "wed": {CategoryRegular: 9}, "thu": {CategoryRegular: 9},
+				"fri":      {CategoryRegular: 4, CategoryOvertime: 5},
+				"next-mon": {CategoryRegular: 9},
+			},
+		},
+		{
+			name:  "prior work counts towards thresholds without producing lines",
+			rules: Rules{WeeklyOvertimeAfter: 40 * time.Hour},
+			work: []Work{
+				{ID: "fri", Intervals: shift("", 4, 8, 9).Intervals},
+				{ID: "mon", Intervals: shift("", 0, 8, 9).Intervals, Prior: true},
+				{ID: "tue", Intervals: shift("", 1, 8, 9).Intervals, Prior: true},
+				{ID: "wed", Intervals: shift("", 2, 8, 9).Intervals, Prior: true},
+				{ID: "thu", Intervals: shift("", 3, 8, 9).Intervals, Prior: true},
+			},
+			expected: map[string]map[Category]float64{"fri": {CategoryRegular: 4, CategoryOvertime: 5}},
+		},
+		{
+			name:  "the higher holiday rate replaces overtime",
+			rules: Rules{DailyOvertimeAfter: 8 * time.Hour, Holidays: []Holiday{{Date: monday, Name: "Founders Day"}}, HolidayBasisPoints: 20000},
+			work:  []Work{shift("a", 0, 8, 10), shift("b", 1, 8, 10)},
+			expected: map[string]map[Category]float64{
+				"a": {CategoryHoliday: 10},
+				"b": {CategoryRegular: 8, CategoryOvertime: 2},
+			},
+		},
+		{
+			name:     "night differential is paid on top",
+			rules:    Rules{NightStart: 22 * time.Hour, NightEnd: 6 * time.Hour, NightDifferentialBasisPoints: 1000},
+			work:     []Work{shift("a", 0, 20, 10)},
+			expected: map[string]map[Category]float64{"a": {CategoryRegular: 10, CategoryNight: 8}},
+		},
+		{
+			name:     "minimum shift guarantee",
+			rules:    Rules{MinimumShift: 4 * time.Hour},
+			work:     []Work{shift("a", 0, 9, 2.5), shift("b", 1, 9, 5)},
+			expected: map[string]map[Category]float64{"a": {CategoryRegular: 2.5, CategoryMinimumShift: 1.5}, "b": {CategoryRegular: 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := Evaluate(tt.rules, tt.work)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, summary(lines))
+		})
+	}
+}
+
+func Test_EvaluateTrace(t *testing.T) {
+	rules := Rules{
+		DailyOvertimeAfter: 4 * time.Hour,
+		Holidays:           []Holiday{{Date: monday.AddDate(0, 0, 1), Name: "Founders Day"}},
+		NightStart:         22 * time.Hour,
+		NightEnd:           6 * time.Hour,
+		// 10%
+		NightDifferentialBasisPoints: 1000,
+	}
+	// 18:00 Monday to 05:30 Tuesday, with Tuesday a holiday at the same rate
+	// as overtime. Each day's hours count towards that day's threshold.
+	lines, err := Evaluate(rules, []Work{shift("a", 0, 18, 11.5)})
+	require.NoError(t, err)
+	require.Len(t, lines, 4)
+
+	assert.Equal(t, Line{WorkID: "a", Category: CategoryRegular, BasisPoints: 10000, Billable: 4 * time.Hour, Payable: 4 * time.Hour,
+		Trace: []string{"4h regular time on 2026-03-09"}}, lines[0])
+	assert.Equal(t, CategoryOvertime, lines[1].Category)
+	assert.Equal(t, int64(15000), lines[1].BasisPoints)
+	assert.Equal(t, 210*time.Minute, lines[1].Billable)
+	assert.Equal(t, []string{
+		"2h over the 4h daily overtime threshold on 2026-03-09",
+		"1h30m over the 4h daily overtime threshold on 2026-03-10, paid instead of holiday",
+	}, lines[1].Trace)
+	assert.Equal(t, CategoryHoliday, lines[2].Category)
+	assert.Equal(t, []string{"4h on Founders Day (2026-03-10)"}, lines[2].Trace)
+	assert.Equal(t, CategoryNight, lines[3].Category)
+	assert.Equal(t, int64(1000), lines[3].BasisPoints)
+	assert.Equal(t, []string{
+		"2h in the 22:00 to 06:00 night window on 2026-03-09",
+		"5h30m in the 22:00 to 06:00 night window on 2026-03-10",
+	}, lines[3].Trace)
+}
+
+func Test_EvaluateAbsorbedMinimumShift(t *testing.T) {
+	lines, err := Evaluate(Rules{MinimumShift: 4 * time.Hour, AbsorbMinimumShift: true}, []Work{shift("a", 0, 9, 1)})
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Equal(t, CategoryMinimumShift, lines[1].Category)
+	assert.Equal(t, time.Duration(0), lines[1].Billable)
+	assert.Equal(t, 3*time.Hour, lines[1].Payable)
+	assert.Equal(t, []string{
+		"3h to make up the 4h minimum shift; 1h was worked",
+		"not billed: the guarantee is paid by the platform",
+	}, lines[1].Trace)
+}
+
+func Test_EvaluateLocalDays(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	// 20:00 to 04:00 New York time, across local midnight; in UTC the whole
+	// shift falls on one day.
+	start := time.Date(2026, time.March, 9, 20, 0, 0, 0, loc)
+	work := Work{ID: "a", Location: loc, Intervals: []Interval{{Start: start, End: start.Add(8 * time.Hour)}}}
+
+	lines, err := Evaluate(Rules{DailyOvertimeAfter: 6 * time.Hour}, []Work{work})
+	require.NoError(t, err)
+	require.Len(t, lines, 1, "neither local day reaches the threshold")
+	assert.Equal(t, []string{"4h regular time on 2026-03-09", "4h regular time on 2026-03-10"}, lines[0].Trace)
+}
+
+func Test_EvaluateBreaks(t *testing.T) {
+	start := monday.Add(8 * time.Hour)
+	work := Work{ID: "a", Intervals: []Interval{
+		{Start: start, End: start.Add(4 * time.Hour)},
+		{Start: start.Add(5 * time.Hour), End: start.Add(10 * time.Hour)},
+	}}
+	lines, err := Evaluate(Rules{DailyOvertimeAfter: 8 * time.Hour}, []Work{work})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[Category]float64{"a": {CategoryRegular: 8, CategoryOvertime: 1}}, summary(lines))
+}
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+	}{
+		{name: "negative threshold", rules: Rules{WeeklyOvertimeAfter: -time.Hour}},
+		{name: "double time before overtime", rules: Rules{DailyOvertimeAfter: 10 * time.Hour, DailyDoubleTimeAfter: 8 * time.Hour}},
+		{name: "multiplier below 1x", rules: Rules{DailyOvertimeAfter: 8 * time.Hour, OvertimeBasisPoints: 5000}},
+		{name: "night window without differential", rules: Rules{NightStart: 22 * time.Hour, NightEnd: 6 * time.Hour}},
+		{name: "night window past midnight", rules: Rules{NightStart: 22 * time.Hour, NightEnd: 30 * time.Hour, NightDifferentialBasisPoints: 1000}},
+		{name: "duplicate holiday", rules: Rules{Holidays: []Holiday{{Date: monday}, {Date: monday}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.rules.Validate(), ErrInvalid)
+		})
+	}
+	assert.NoError(t, Rules{}.Validate())
+}
+
+func Test_FormatDuration(t *testing.T) {
+	assert.Equal(t, "8h", FormatDuration(8*time.Hour))
+	assert.Equal(t, "2h30m", FormatDuration(150*time.Minute))
+	assert.Equal(t, "1h05m", FormatDuration(65*time.Minute))
+	assert.Equal(t, "45m", FormatDuration(45*time.Minute))
+}
@@ -0,0 +1,276 @@
+// Package rrule expands the subset of iCalendar (RFC 5545) recurrence rules
+// used to schedule shift series: a FREQ of DAILY, WEEKLY or MONTHLY with
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and WKST.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned for rules that are malformed or use parts this
+// package does not support.
+var ErrInvalid = errors.New("invalid recurrence rule")
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Rule is a parsed recurrence rule. Occurrences keep the time of day of the
+// start they are expanded from.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Count limits the rule to its first Count occurrences; zero means no limit.
+	Count int
+	// Until is the last instant an occurrence may fall on; zero means no limit.
+	Until time.Time
+	// ByDay limits occurrences to these weekdays.
+	ByDay []time.Weekday
+	// ByMonthDay limits occurrences to these days of the month; negative days
+	// count back from the end of the month.
+	ByMonthDay []int
+	WeekStart  time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+var untilLayouts = []string{"20060102T150405Z", "20060102T150405", "20060102"}
+
+// Parse parses a rule such as "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR". A leading
+// "RRULE:" is allowed. Floating UNTIL values are read as UTC.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalid)
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalid, part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s given more than once", ErrInvalid, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				return nil, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalid, value)
+			}
+		case "INTERVAL":
+			if rule.Interval, err = strconv.Atoi(value); err != nil || rule.Interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive integer", ErrInvalid)
+			}
+		case "COUNT":
+			if rule.Count, err = strconv.Atoi(value); err != nil || rule.Count < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive integer", ErrInvalid)
+			}
+		case "UNTIL":
+			if rule.Until, err = parseUntil(value); err != nil {
+				return nil, err
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("%w: unsupported BYDAY value %s", ErrInvalid, day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("%w: BYMONTHDAY values must be between -31 and 31, excluding 0", ErrInvalid)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "WKST":
+			weekday, ok := weekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown WKST %s", ErrInvalid, value)
+			}
+			rule.WeekStart = weekday
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalid, name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalid)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot both be set", ErrInvalid)
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range untilLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: UNTIL must be formatted as YYYYMMDD or YYYYMMDDTHHMMSSZ", ErrInvalid)
+}
+
+// String formats the rule in its canonical form.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayouts[0]))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayNames[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns, in order, the occurrences of the rule started at dtstart
+// that fall within [from, to]. COUNT is counted from dtstart, so occurrences
+// before from still use up the count.
+func (r *Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	n := 0
+	for period := 0; ; period++ {
+		periodStart, candidates := r.expand(dtstart, period)
+		if periodStart.After(to) || (!r.Until.IsZero() && periodStart.After(r.Until)) {
+			return occurrences
+		}
+		for _, t := range candidates {
+			if t.Before(dtstart) {
+				continue
+			}
+			if t.After(to) || (!r.Until.IsZero() && t.After(r.Until)) {
+				return occurrences
+			}
+			n++
+			if r.Count > 0 && n > r.Count {
+				return occurrences
+			}
+			if !t.Before(from) {
+				occurrences = append(occurrences, t)
+			}
+		}
+	}
+}
+
+// expand returns the start of the nth period after dtstart and the sorted
+// candidate occurrences within it.
+func (r *Rule) expand(dtstart time.Time, n int) (time.Time, []time.Time) {
+	interval := max(r.Interval, 1)
+	switch r.Freq {
+	case Weekly:
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := dtstart.AddDate(0, 0, n*interval*7-offset)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{dtstart.Weekday()}
+		}
+		var candidates []time.Time
+		for _, day := range days {
+			t := weekStart.AddDate(0, 0, (int(day)-int(r.WeekStart)+7)%7)
+			if r.matchesMonthDay(t) {
+				candidates = append(candidates, t)
+			}
+		}
+		return weekStart, sortUnique(candidates)
+	case Monthly:
+		monthStart := time.Date(dtstart.Year(), dtstart.Month()+time.Month(n*interval), 1,
+			dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location())
+		daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+		var candidates []time.Time
+		for day := 1; day <= daysInMonth; day++ {
+			t := monthStart.AddDate(0, 0, day-1)
+			switch {
+			case len(r.ByMonthDay) > 0:
+				if !r.matchesMonthDay(t) || !r.matchesWeekday(t) {
+					continue
+				}
+			case len(r.ByDay) > 0:
+				if !r.matchesWeekday(t) {
+					continue
+				}
+			case day != dtstart.Day():
+				continue
+			}
+			candidates = append(candidates, t)
+		}
+		return monthStart, candidates
+	default:
+		t := dtstart.AddDate(0, 0, n*interval)
+		if !r.matchesWeekday(t) || !r.matchesMonthDay(t) {
+			return t, nil
+		}
+		return t, []time.Time{t}
+	}
+}
+
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	return len(r.ByDay) == 0 || slices.Contains(r.ByDay, t.Weekday())
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, day := range r.ByMonthDay {
+		if day == t.Day() || (day < 0 && daysInMonth+day+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortUnique(times []time.Time) []time.Time {
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(times, func(a, b time.Time) bool { return a.Equal(b) })
+}
@@ -0,0 +1,164 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      string
+		canonical string
+		wantErr   bool
+	}{
+		{name: "weekdays", rule: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", canonical: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{name: "prefix and lower case", rule: "RRULE:freq=daily;interval=2", canonical: "FREQ=DAILY;INTERVAL=2"},
+		{name: "until date", rule: "FREQ=DAILY;UNTIL=20250131", canonical: "FREQ=DAILY;UNTIL=20250131T000000Z"},
+		{name: "month days", rule: "FREQ=MONTHLY;BYMONTHDAY=1,-1;COUNT=6", canonical: "FREQ=MONTHLY;COUNT=6;BYMONTHDAY=1,-1"},
+		{name: "week start", rule: "FREQ=WEEKLY;INTERVAL=2;WKST=SU", canonical: "FREQ=WEEKLY;INTERVAL=2;WKST=SU"},
+		{name: "empty", rule: "", wantErr: true},
+		{name: "missing freq", rule: "BYDAY=MO", wantErr: true},
+		{name: "yearly", rule: "FREQ=YEARLY", wantErr: true},
+		{name: "zero interval", rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{name: "count and until", rule: "FREQ=DAILY;COUNT=2;UNTIL=20250101", wantErr: true},
+		{name: "ordinal weekday", rule: "FREQ=MONTHLY;BYDAY=1MO", wantErr: true},
+		{name: "zero month day", rule: "FREQ=MONTHLY;BYMONTHDAY=0", wantErr: true},
+		{name: "unsupported part", rule: "FREQ=DAILY;BYHOUR=9", wantErr: true},
+		{name: "repeated part", rule: "FREQ=DAILY;FREQ=WEEKLY", wantErr: true},
+		{name: "malformed", rule: "FREQ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalid)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.canonical, rule.String())
+
+			reparsed, err := Parse(rule.String())
+			require.NoError(t, err)
+			assert.Equal(t, rule, reparsed)
+		})
+	}
+}
+
+func Test_Between(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		dtstart  time.Time
+		from     time.Time
+		to       time.Time
+		expected []time.Time
+	}{
+		{
+			name:    "every weekday",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+			dtstart: date(2025, time.January, 1), // a Wednesday
+			from:    date(2025, time.January, 1),
+			to:      date(2025, time.January, 8),
+			expected: []time.Time{
+				date(2025, time.January, 1), date(2025, time.January, 2), date(2025, time.January, 3),
+				date(2025, time.January, 6), date(2025, time.January, 7), date(2025, time.January, 8),
+			},
+		},
+		{
+			name:     "every other day",
+			rule:     "FREQ=DAILY;INTERVAL=2",
+			dtstart:  date(2025, time.January, 30),
+			from:     date(2025, time.January, 30),
+			to:       date(2025, time.February, 4),
+			expected: []time.Time{date(2025, time.January, 30), date(2025, time.February, 1), date(2025, time.February, 3)},
+		},
+		{
+			name:     "daily limited to weekends",
+			rule:     "FREQ=DAILY;BYDAY=SA,SU",
+			dtstart:  date(2025, time.January, 1),
+			from:     date(2025, time.January, 1),
+			to:       date(2025, time.January, 12),
+			expected: []time.Time{date(2025, time.January, 4), date(2025, time.January, 5), date(2025, time.January, 11), date(2025, time.January, 12)},
+		},
+		{
+			name:     "fortnightly defaults to start weekday",
+			rule:     "FREQ=WEEKLY;INTERVAL=2",
+			dtstart:  date(2025, time.January, 6),
+			from:     date(2025, time.January, 1),
+			to:       date(2025, time.February, 28),
+			expected: []time.Time{date(2025, time.January, 6), date(2025, time.January, 20), date(2025, time.February, 3), date(2025, time.February, 17)},
+		},
+		{
+			name:     "count is taken from dtstart",
+			rule:     "FREQ=DAILY;COUNT=3",
+			dtstart:  date(2025, time.January, 1),
+			from:     date(2025, time.January, 2),
+			to:       date(2025, time.January, 31),
+			expected: []time.Time{date(2025, time.January, 2), date(2025, time.January, 3)},
+		},
+		{
+			name:     "until is inclusive",
+			rule:     "FREQ=WEEKLY;UNTIL=20250115",
+			dtstart:  date(2025, time.January, 1),
+			from:     date(2025, time.January, 1),
+			to:       date(2025, time.December, 31),
+			expected: []time.Time{date(2025, time.January, 1), date(2025, time.January, 8), date(2025, time.January, 15)},
+		},
+		{
+			name:     "monthly skips months without the day",
+			rule:     "FREQ=MONTHLY",
+			dtstart:  date(2025, time.January, 31),
+			from:     date(2025, time.January, 1),
+			to:       date(2025, time.May, 31),
+			expected: []time.Time{date(2025, time.January, 31), date(2025, time.March, 31), date(2025, time.May, 31)},
+		},
+		{
+			name:     "last day of the month",
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart:  date(2024, time.January, 15),
+			from:     date(2024, time.January, 1),
+			to:       date(2024, time.March, 31),
+			expected: []time.Time{date(2024, time.January, 31), date(2024, time.February, 29), date(2024, time.March, 31)},
+		},
+		{
+			name:     "monthly on weekdays",
+			rule:     "FREQ=MONTHLY;BYDAY=MO;COUNT=3",
+			dtstart:  date(2025, time.January, 15),
+			from:     date(2025, time.January, 1),
+			to:       date(2025, time.December, 31),
+			expected: []time.Time{date(2025, time.January, 20), date(2025, time.January, 27), date(2025, time.February, 3)},
+		},
+		{
+			name:     "time of day is kept",
+			rule:     "FREQ=DAILY;COUNT=2",
+			dtstart:  time.Date(2025, time.January, 1, 9, 30, 0, 0, time.UTC),
+			from:     date(2025, time.January, 1),
+			to:       date(2025, time.January, 31),
+			expected: []time.Time{time.Date(2025, time.January, 1, 9, 30, 0, 0, time.UTC), time.Date(2025, time.January, 2, 9, 30, 0, 0, time.UTC)},
+		},
+		{
+			name:    "window before dtstart",
+			rule:    "FREQ=DAILY",
+			dtstart: date(2025, time.February, 1),
+			from:    date(2025, time.January, 1),
+			to:      date(2025, time.January, 31),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rule.Between(tt.dtstart, tt.from, tt.to))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package signature signs and verifies webhook payloads with a timestamped
+// HMAC-SHA256, so receivers can reject both forged and replayed requests.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalid = errors.New("invalid webhook signature")
+
+// Tolerance is how old a signed payload may be before it is rejected as a
+// possible replay.
+const Tolerance = 5 * time.Minute
+
+// Sign returns a signature header value of the form "t=<unix>,v1=<hex>", where
+// v1 is the HMAC-SHA256 of "<unix>.<payload>".
+func Sign(secret []byte, timestamp time.Time, payload []byte) string {
+	unix := timestamp.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", unix, computeSignature(secret, unix, payload))
+}
+
+// Verify checks a header produced by Sign. Any v1 entry may match,
+// which lets senders include signatures for both an old and a new secret while
+// rotating.
+func Verify(secret []byte, header string, payload []byte, now time.Time) error {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: malformed timestamp", ErrInvalid)
+			}
+			timestamp = parsed
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return fmt.Errorf("%w: missing timestamp or signature", ErrInvalid)
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > Tolerance || age < -Tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalid)
+	}
+
+	expected := []byte(computeSignature(secret, timestamp, payload))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no matching signature", ErrInvalid)
+}
+
+func computeSignature(secret []byte, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
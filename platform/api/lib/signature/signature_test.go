@@ -0,0 +1,45 @@
+package signature
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Verify(t *testing.T) {
+	secret := []byte("whsec_test")
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign(secret, now, payload)
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		header  string
+		payload []byte
+		now     time.Time
+		valid   bool
+	}{
+		{name: "valid", secret: secret, header: header, payload: payload, now: now, valid: true},
+		{name: "within tolerance", secret: secret, header: header, payload: payload, now: now.Add(Tolerance), valid: true},
+		{name: "rotated secret", secret: secret, header: header + ",v1=" + computeSignature([]byte("old"), now.Unix(), payload), payload: payload, now: now, valid: true},
+		{name: "wrong secret", secret: []byte("other"), header: header, payload: payload, now: now},
+		{name: "tampered payload", secret: secret, header: header, payload: []byte(`{"id":"evt_2"}`), now: now},
+		{name: "replayed too late", secret: secret, header: header, payload: payload, now: now.Add(Tolerance + time.Second)},
+		{name: "missing signature", secret: secret, header: fmt.Sprintf("t=%d", now.Unix()), payload: payload, now: now},
+		{name: "empty header", secret: secret, header: "", payload: payload, now: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.payload, tt.now)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalid)
+			}
+		})
+	}
+}
@@ -9,16 +9,38 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/config"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/handler"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/logger"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/notifications"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/outbox"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payments"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payouts"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/service"
 	"github.com/rs/cors"
 )
 
+const (
+	outboxRelayInterval     = time.Second
+	outboxRelayBatch        = 100
+	webhookDispatchInterval = 5 * time.Second
+	webhookDispatchBatch    = 50
+	shiftSeriesInterval     = time.Hour
+	notificationInterval    = 5 * time.Second
+	notificationBatch       = 50
+	overdueInvoicesInterval = time.Hour
+	payoutInterval          = time.Minute
+	payoutBatch             = 50
+	// fakePayoutSettleAfter is how long the fake provider takes to pay a
+	// transfer, so payouts can be seen processing in development.
+	fakePayoutSettleAfter = 2 * time.Minute
+)
+
 // todo add logger later on
 func main() {
 	ctx := context.Background()
@@ -41,7 +63,41 @@ func main() {
 
 	slog.InfoContext(ctx, "Connected to database")
 
-	svc := service.NewService(db)
+	// todo: swap the fake gateway for a real provider once one is chosen. Until
+	// then production takes no payments rather than trusting fake webhooks.
+	var paymentOptions []service.Option
+	if cfg.IsProduction() {
+		slog.WarnContext(ctx, "no payment gateway is configured; payments are disabled")
+	} else {
+		gateway, err := payments.NewFakeGateway(cfg.PaymentsWebhookSecret)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to configure payment gateway", "error", err)
+			os.Exit(1)
+		}
+		paymentOptions = append(paymentOptions, service.WithPaymentGateway(gateway))
+	}
+	// todo: swap the file sender for real email and SMS providers once they are chosen
+	notificationsDir := cfg.NotificationsDir
+	if notificationsDir == "" {
+		notificationsDir = filepath.Join(os.TempDir(), "notifications")
+	}
+	notifier := notifications.NewFileSender(notificationsDir)
+	// todo: swap the fake payout provider for a real one once one is chosen
+	payoutProvider := payouts.NewFakeProvider(fakePayoutSettleAfter)
+	svc := service.NewService(db, append(paymentOptions,
+		service.WithNotificationSender(notifier),
+		service.WithPayoutProvider(payoutProvider),
+	)...)
+	// Domain events recorded by the service are relayed from the outbox to
+	// these sinks; the bus is where in-process consumers subscribe.
+	bus := outbox.NewBus()
+	relay := outbox.NewRelay(db, outbox.LogSink{}, bus, service.NewWebhookSink(db), service.NewNotificationSink(db))
+	go relay.Run(ctx, outboxRelayInterval, outboxRelayBatch)
+	go dispatchWebhooks(ctx, svc)
+	go dispatchNotifications(ctx, svc)
+	go materializeShiftSeries(ctx, svc)
+	go markOverdueInvoices(ctx, svc)
+	go processPayouts(ctx, svc)
 	// todo: look more into why it is more appropriate to pass in pointers vs values
 	h := handler.NewHandler(svc, cfg)
 	r := chi.NewRouter()
@@ -61,10 +117,118 @@ func main() {
 	}).Handler)
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.EnsureValidToken(ctx, cfg))
-		r.Get("/api/invoices", h.HandleFetchInvoices)
-		r.Get("/api/user", h.HandleGetUser)
+		// Each route declares the permission it needs; see middleware.RequirePermission.
+		can := middleware.RequirePermission
+		r.With(can(middleware.PermissionInvoicesRead)).Get("/api/invoices", h.HandleFetchInvoices)
+		r.With(can(middleware.PermissionInvoicesRead)).Get("/api/invoices/{id}", h.HandleGetInvoice)
+		r.With(can(middleware.PermissionInvoicesRead)).Get("/api/invoices/{id}/transitions", h.HandleListInvoiceTransitions)
+		r.With(can(middleware.PermissionInvoicesWrite)).Post("/api/invoices/{id}/transitions", h.HandleTransitionInvoice)
+		r.With(can(middleware.PermissionInvoicesRead)).Get("/api/invoices/{id}/payments", h.HandleListPayments)
+		r.With(can(middleware.PermissionPaymentsWrite)).Post("/api/invoices/{id}/payments", h.HandleCreatePayment)
+		r.With(can(middleware.PermissionInvoicesRead)).Get("/api/invoices/{id}/credit-notes", h.HandleListCreditNotes)
+		r.With(can(middleware.PermissionCreditNotesWrite)).Post("/api/invoices/{id}/credit-notes", h.HandleCreateCreditNote)
+		r.With(can(middleware.PermissionInvoicesRead)).Get("/api/invoices/{id}/refunds", h.HandleListRefunds)
+		r.With(can(middleware.PermissionPaymentsWrite)).Post("/api/payments/{id}/capture", h.HandleCapturePayment)
+		r.With(can(middleware.PermissionRefundsWrite)).Post("/api/payments/{id}/refunds", h.HandleRefundPayment)
+		r.With(can(middleware.PermissionInvoicesRead)).Get("/api/search", h.HandleSearch)
+		r.With(can(middleware.PermissionUserRead)).Get("/api/user", h.HandleGetUser)
+		r.With(can(middleware.PermissionOrganizationRead)).Get("/api/organization", h.HandleGetOrganization)
+		r.With(can(middleware.PermissionOrganizationManage)).Post("/api/organization/invitations", h.HandleInviteOrganizationMember)
+		r.With(can(middleware.PermissionOrganizationRead)).Get("/api/organization-invitations", h.HandleListOrganizationInvitations)
+		r.With(can(middleware.PermissionOrganizationRead)).Post("/api/organization-invitations/{id}/accept", h.HandleAcceptOrganizationInvitation)
+		r.With(can(middleware.PermissionOrganizationRead)).Post("/api/organization-invitations/{id}/decline", h.HandleDeclineOrganizationInvitation)
+		r.With(can(middleware.PermissionOrganizationManage)).Delete("/api/organization/members/{userID}", h.HandleRemoveOrganizationMember)
+		r.With(can(middleware.PermissionShiftsRead)).Get("/api/locations", h.HandleListLocations)
+		r.With(can(middleware.PermissionShiftsWrite)).Post("/api/locations", h.HandleCreateLocation)
+
+		r.Route("/api/shifts", func(r chi.Router) {
+			r.With(can(middleware.PermissionShiftsWrite)).Post("/", h.HandleCreateShift)
+			r.With(can(middleware.PermissionShiftsRead)).Get("/", h.HandleListShifts)
+			r.With(can(middleware.PermissionShiftsApply)).Get("/open", h.HandleListOpenShifts)
+			r.With(can(middleware.PermissionShiftsRead)).Get("/{id}", h.HandleGetShift)
+			r.With(can(middleware.PermissionShiftsWrite)).Put("/{id}", h.HandleUpdateShift)
+			r.With(can(middleware.PermissionShiftsWrite)).Post("/{id}/cancel", h.HandleCancelShift)
+			r.With(can(middleware.PermissionShiftsApply)).Post("/{id}/applications", h.HandleApplyToShift)
+			r.With(can(middleware.PermissionShiftsRead)).Get("/{id}/assignments", h.HandleListShiftAssignments)
+
+			r.Route("/{id}/timesheets", func(r chi.Router) {
+				r.With(can(middleware.PermissionTimesheetsWrite)).Post("/", h.HandleClockIn)
+				r.With(can(middleware.PermissionTimesheetsRead)).Get("/", h.HandleListTimesheets)
+				r.With(can(middleware.PermissionTimesheetsApprove)).Put("/{timesheetID}", h.HandleCorrectTimesheet)
+				r.With(can(middleware.PermissionTimesheetsRead)).Get("/{timesheetID}/versions", h.HandleGetTimesheetVersions)
+				r.With(can(middleware.PermissionTimesheetsWrite)).Post("/{timesheetID}/clock-out", h.HandleClockOut)
+				r.With(can(middleware.PermissionTimesheetsWrite)).Post("/{timesheetID}/breaks/start", h.HandleStartBreak)
+				r.With(can(middleware.PermissionTimesheetsWrite)).Post("/{timesheetID}/breaks/end", h.HandleEndBreak)
+				r.With(can(middleware.PermissionTimesheetsApprove)).Post("/{timesheetID}/approve", h.HandleApproveTimesheet)
+			})
+		})
+
+		r.Route("/api/shift-series", func(r chi.Router) {
+			r.With(can(middleware.PermissionShiftsWrite)).Post("/", h.HandleCreateShiftSeries)
+			r.With(can(middleware.PermissionShiftsRead)).Get("/", h.HandleListShiftSeries)
+			r.With(can(middleware.PermissionShiftsRead)).Get("/{id}", h.HandleGetShiftSeries)
+		})
+
+		r.Route("/api/assignments", func(r chi.Router) {
+			r.With(can(middleware.PermissionAssignmentsWrite)).Post("/{id}/accept", h.HandleAcceptAssignment)
+			r.With(can(middleware.PermissionAssignmentsWrite)).Post("/{id}/decline", h.HandleDeclineAssignment)
+			r.With(can(middleware.PermissionShiftsApply)).Post("/{id}/withdraw", h.HandleWithdrawAssignment)
+		})
+		r.With(can(middleware.PermissionShiftsApply)).Get("/api/me/assignments", h.HandleListMyAssignments)
+
+		r.Route("/api/me/profile", func(r chi.Router) {
+			r.Use(can(middleware.PermissionProfileManage))
+			r.Get("/", h.HandleGetMyProfile)
+			r.Put("/", h.HandlePutMyProfile)
+			r.Delete("/", h.HandleDeleteMyProfile)
+		})
+		r.With(can(middleware.PermissionWorkersRead)).Get("/api/workers/{id}/profile", h.HandleGetWorkerProfile)
+		r.With(can(middleware.PermissionWorkersRead)).Get("/api/workers/{id}/pay-week", h.HandleGetWorkerPayWeek)
+
+		r.Route("/api/invoice-numbering", func(r chi.Router) {
+			r.Use(can(middleware.PermissionOrganizationManage))
+			r.Get("/", h.HandleGetInvoiceNumbering)
+			r.Put("/", h.HandlePutInvoiceNumbering)
+		})
+
+		r.Route("/api/pay-rules", func(r chi.Router) {
+			r.Use(can(middleware.PermissionPayRulesManage))
+			r.Get("/", h.HandleGetPayRules)
+			r.Put("/", h.HandlePutPayRules)
+		})
+		r.With(can(middleware.PermissionBillingRatesManage)).Put("/api/billing-rates/{role}", h.HandlePutBillingRate)
+		r.With(can(middleware.PermissionMinimumShiftAbsorb)).Put("/api/organizations/{id}/minimum-shift-absorption", h.HandlePutMinimumShiftAbsorption)
+
+		r.With(can(middleware.PermissionEarningsRead)).Get("/api/me/earnings", h.HandleGetMyEarnings)
+		r.With(can(middleware.PermissionEarningsRead)).Get("/api/me/payouts", h.HandleListMyPayouts)
+		r.With(can(middleware.PermissionPayoutsManage)).Post("/api/payouts/{id}/retry", h.HandleRetryPayout)
+
+		r.Route("/api/me/notification-preferences", func(r chi.Router) {
+			r.Use(can(middleware.PermissionNotificationsManage))
+			r.Get("/", h.HandleGetNotificationPreferences)
+			r.Put("/", h.HandlePutNotificationPreferences)
+		})
+		r.With(can(middleware.PermissionNotificationsManage)).Get("/api/me/notifications", h.HandleListMyNotifications)
+
+		r.With(can(middleware.PermissionAuditRead)).Get("/api/audit", h.HandleListAuditLog)
+
+		r.Route("/api/ledger", func(r chi.Router) {
+			r.Use(can(middleware.PermissionLedgerRead))
+			r.Get("/balances", h.HandleGetLedgerBalances)
+			r.Get("/entries", h.HandleListLedgerEntries)
+		})
+
+		r.Route("/api/webhooks", func(r chi.Router) {
+			r.Use(can(middleware.PermissionWebhooksManage))
+			r.Post("/", h.HandleCreateWebhookEndpoint)
+			r.Get("/", h.HandleListWebhookEndpoints)
+			r.Delete("/{id}", h.HandleDeleteWebhookEndpoint)
+			r.Get("/{id}/deliveries", h.HandleListWebhookDeliveries)
+			r.Post("/deliveries/{id}/redeliver", h.HandleRedeliverWebhook)
+		})
 	})
 	r.Post("/hook/user", h.HandleCreateUser) // New endpoint for getting/creating user
+	r.Post("/hook/payments", h.HandlePaymentWebhook)
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -80,6 +244,90 @@ func main() {
 	}
 }
 
+// dispatchWebhooks sends due outbound webhooks until ctx is done.
+func dispatchWebhooks(ctx context.Context, svc service.Service) {
+	ticker := time.NewTicker(webhookDispatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.DispatchWebhooks(ctx, webhookDispatchBatch); err != nil {
+				slog.ErrorContext(ctx, "failed to dispatch webhooks", "error", err)
+			}
+		}
+	}
+}
+
+// dispatchNotifications sends due email and SMS notifications until ctx is
+// done.
+func dispatchNotifications(ctx context.Context, svc service.Service) {
+	ticker := time.NewTicker(notificationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.DispatchNotifications(ctx, notificationBatch); err != nil {
+				slog.ErrorContext(ctx, "failed to dispatch notifications", "error", err)
+			}
+		}
+	}
+}
+
+// markOverdueInvoices flags invoices as they pass their due date until ctx is
+// done.
+func markOverdueInvoices(ctx context.Context, svc service.Service) {
+	ticker := time.NewTicker(overdueInvoicesInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.MarkOverdueInvoices(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to mark overdue invoices", "error", err)
+			}
+		}
+	}
+}
+
+// processPayouts submits pending payouts to the payout provider and records
+// their outcome until ctx is done.
+func processPayouts(ctx context.Context, svc service.Service) {
+	ticker := time.NewTicker(payoutInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.ProcessPayouts(ctx, payoutBatch); err != nil {
+				slog.ErrorContext(ctx, "failed to process payouts", "error", err)
+			}
+		}
+	}
+}
+
+// materializeShiftSeries keeps every shift series materialized up to its
+// horizon until ctx is done.
+func materializeShiftSeries(ctx context.Context, svc service.Service) {
+	ticker := time.NewTicker(shiftSeriesInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.MaterializeShiftSeries(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to materialize shift series", "error", err)
+			}
+		}
+	}
+}
+
 // NewDBClient creates a new database client
 func NewDBClient(psqlConnStr string) (*sql.DB, error) {
 	// u, err := url.Parse(psqlConnStr)
@@ -0,0 +1,85 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileSender writes each message to a file in a directory instead of sending
+// it, for local development. Emails are written as .eml files any mail client
+// can open and SMS as .txt. A retried message overwrites its earlier file.
+type FileSender struct {
+	dir string
+}
+
+var _ Sender = &FileSender{}
+
+func NewFileSender(dir string) *FileSender {
+	return &FileSender{dir: dir}
+}
+
+func (s *FileSender) Send(ctx context.Context, message Message) error {
+	var content strings.Builder
+	var ext string
+	switch message.Channel {
+	case ChannelEmail:
+		ext = ".eml"
+		fmt.Fprintf(&content, "Message-Id: <%s>\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", message.ID, message.To, message.Subject)
+		content.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	case ChannelSMS:
+		ext = ".txt"
+		fmt.Fprintf(&content, "To: %s\n\n%s\n", message.To, message.Body)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedChannel, message.Channel)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create notification directory: %w", err)
+	}
+	path := filepath.Join(s.dir, filepath.Base(message.ID)+ext)
+	if err := os.WriteFile(path, []byte(content.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write notification %s: %w", message.ID, err)
+	}
+	return nil
+}
+
+// CaptureSender keeps sent messages in memory for tests. SetError makes
+// subsequent sends fail, to exercise retries.
+type CaptureSender struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+var _ Sender = &CaptureSender{}
+
+func NewCaptureSender() *CaptureSender {
+	return &CaptureSender{}
+}
+
+func (s *CaptureSender) Send(ctx context.Context, message Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (s *CaptureSender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message{}, s.messages...)
+}
+
+func (s *CaptureSender) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
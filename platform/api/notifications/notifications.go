@@ -0,0 +1,77 @@
+// Package notifications sends email and SMS messages to users. The service
+// layer renders a Template into a Message and hands it to a Sender, so
+// providers can be swapped without touching the code that decides who to
+// notify. FileSender and CaptureSender stand in for real providers in
+// development and tests.
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/backoff"
+)
+
+// Channel is how a message reaches its recipient.
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+)
+
+// Channels lists every channel, in the order preferences are shown.
+var Channels = []Channel{ChannelEmail, ChannelSMS}
+
+func (c Channel) Valid() bool {
+	return c == ChannelEmail || c == ChannelSMS
+}
+
+// ErrUnsupportedChannel is returned by a Sender asked to use a channel it
+// cannot deliver on.
+var ErrUnsupportedChannel = errors.New("unsupported notification channel")
+
+// Message is a rendered notification. ID stays the same across retries so
+// senders can deduplicate. Subject is only used by email.
+type Message struct {
+	ID      string
+	Channel Channel
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender is implemented by each email or SMS provider.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// ChannelSenders routes each message to the Sender for its channel, so email
+// and SMS can use different providers.
+type ChannelSenders map[Channel]Sender
+
+var _ Sender = ChannelSenders{}
+
+func (s ChannelSenders) Send(ctx context.Context, message Message) error {
+	sender, ok := s[message.Channel]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedChannel, message.Channel)
+	}
+	return sender.Send(ctx, message)
+}
+
+// MaxAttempts is how many times a message is tried before it is marked failed.
+const MaxAttempts = 5
+
+const (
+	initialBackoff = time.Minute
+	maxBackoff     = time.Hour
+)
+
+// Backoff is how long to wait before resending a message after the given
+// failed attempt: 1m, doubling up to 1h.
+func Backoff(attempt int) time.Duration {
+	return backoff.Exponential(attempt, initialBackoff, maxBackoff)
+}
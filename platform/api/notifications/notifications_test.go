@@ -0,0 +1,137 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+func Test_Backoff(t *testing.T) {
+	assert.Equal(t, time.Minute, Backoff(0))
+	assert.Equal(t, time.Minute, Backoff(1))
+	assert.Equal(t, 4*time.Minute, Backoff(3))
+	assert.Equal(t, time.Hour, Backoff(MaxAttempts+10))
+}
+
+func Test_Render(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	shift := ShiftAssignedData{
+		RecipientName: "Ada",
+		ShiftName:     "Morning Picking",
+		Location:      "Main Street Warehouse",
+		StartsAt:      time.Date(2026, time.March, 9, 9, 0, 0, 0, loc),
+		EndsAt:        time.Date(2026, time.March, 9, 17, 0, 0, 0, loc),
+	}
+	invoice := InvoiceData{
+		RecipientName: "Grace",
+		InvoiceID:     "invoice_1",
+		InvoiceName:   "March",
+		Amount:        money.New(123450, money.USD),
+		DueDate:       time.Date(2026, time.April, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name            string
+		template        Template
+		channel         Channel
+		data            any
+		expectedSubject string
+		expectedBody    []string
+	}{
+		{
+			name:            "shift assigned email",
+			template:        TemplateShiftAssigned,
+			channel:         ChannelEmail,
+			data:            shift,
+			expectedSubject: "You're booked: Morning Picking on Mon Mar 9",
+			expectedBody:    []string{"Hi Ada,", "Monday, March 9 at 9:00 AM to 5:00 PM EDT"},
+		},
+		{
+			name:         "shift assigned sms",
+			template:     TemplateShiftAssigned,
+			channel:      ChannelSMS,
+			data:         shift,
+			expectedBody: []string{"Booked: Morning Picking at Main Street Warehouse, Mon Mar 9 9:00 AM-5:00 PM EDT."},
+		},
+		{
+			name:            "invoice issued email",
+			template:        TemplateInvoiceIssued,
+			channel:         ChannelEmail,
+			data:            invoice,
+			expectedSubject: "Invoice March for 1234.50 USD",
+			expectedBody:    []string{"is due on April 8, 2026"},
+		},
+		{
+			name:            "invoice overdue email",
+			template:        TemplateInvoiceOverdue,
+			channel:         ChannelEmail,
+			data:            invoice,
+			expectedSubject: "Invoice March is overdue",
+			expectedBody:    []string{"was due on April 8, 2026"},
+		},
+		{
+			name:         "invoice overdue sms",
+			template:     TemplateInvoiceOverdue,
+			channel:      ChannelSMS,
+			data:         invoice,
+			expectedBody: []string{"Invoice March was due Apr 8 and is overdue."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, body, err := Render(tt.template, tt.channel, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSubject, subject)
+			for _, expected := range tt.expectedBody {
+				assert.Contains(t, body, expected)
+			}
+		})
+	}
+
+	_, _, err = Render("shift_exploded", ChannelEmail, shift)
+	assert.Error(t, err)
+	_, _, err = Render(TemplateShiftAssigned, "pigeon", shift)
+	assert.ErrorIs(t, err, ErrUnsupportedChannel)
+}
+
+func Test_FileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewFileSender(filepath.Join(dir, "outbox"))
+	ctx := context.Background()
+
+	require.NoError(t, sender.Send(ctx, Message{ID: "notif_1", Channel: ChannelEmail, To: "ada@example.com", Subject: "Hello", Body: "Hi\nthere"}))
+	require.NoError(t, sender.Send(ctx, Message{ID: "notif_2", Channel: ChannelSMS, To: "+15555550100", Body: "Hi"}))
+
+	email, err := os.ReadFile(filepath.Join(dir, "outbox", "notif_1.eml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(email), "To: ada@example.com\r\nSubject: Hello\r\n")
+	assert.Contains(t, string(email), "\r\n\r\nHi\r\nthere")
+
+	sms, err := os.ReadFile(filepath.Join(dir, "outbox", "notif_2.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "To: +15555550100\n\nHi\n", string(sms))
+}
+
+func Test_ChannelSenders(t *testing.T) {
+	email := NewCaptureSender()
+	senders := ChannelSenders{ChannelEmail: email}
+	ctx := context.Background()
+
+	require.NoError(t, senders.Send(ctx, Message{ID: "notif_1", Channel: ChannelEmail}))
+	assert.ErrorIs(t, senders.Send(ctx, Message{ID: "notif_2", Channel: ChannelSMS}), ErrUnsupportedChannel)
+
+	email.SetError(errors.New("mailbox full"))
+	assert.Error(t, senders.Send(ctx, Message{ID: "notif_3", Channel: ChannelEmail}))
+	require.Len(t, email.Messages(), 1)
+	assert.Equal(t, "notif_1", email.Messages()[0].ID)
+}
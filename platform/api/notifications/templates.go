@@ -0,0 +1,122 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+// Template names a kind of notification. Users set their channel preferences
+// per template.
+type Template string
+
+const (
+	TemplateShiftAssigned  Template = "shift_assigned"
+	TemplateInvoiceIssued  Template = "invoice_issued"
+	TemplateInvoiceOverdue Template = "invoice_overdue"
+)
+
+// Templates lists every template, in the order preferences are shown.
+var Templates = []Template{TemplateShiftAssigned, TemplateInvoiceIssued, TemplateInvoiceOverdue}
+
+func (t Template) Valid() bool {
+	_, ok := templates[t]
+	return ok
+}
+
+// ShiftAssignedData fills TemplateShiftAssigned. Times are local to the shift.
+type ShiftAssignedData struct {
+	RecipientName string
+	ShiftName     string
+	Location      string
+	StartsAt      time.Time
+	EndsAt        time.Time
+}
+
+// InvoiceData fills TemplateInvoiceIssued and TemplateInvoiceOverdue.
+type InvoiceData struct {
+	RecipientName string
+	InvoiceID     string
+	InvoiceName   string
+	Amount        money.Money
+	DueDate       time.Time
+}
+
+type messageTemplate struct {
+	subject *template.Template
+	email   *template.Template
+	sms     *template.Template
+}
+
+func newMessageTemplate(name Template, subject, email, sms string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New(string(name) + ".subject").Parse(subject)),
+		email:   template.Must(template.New(string(name) + ".email").Parse(email)),
+		sms:     template.Must(template.New(string(name) + ".sms").Parse(sms)),
+	}
+}
+
+var templates = map[Template]messageTemplate{
+	TemplateShiftAssigned: newMessageTemplate(TemplateShiftAssigned,
+		`You're booked: {{.ShiftName}} on {{.StartsAt.Format "Mon Jan 2"}}`,
+		`Hi {{.RecipientName}},
+
+You've been confirmed for {{.ShiftName}} at {{.Location}}.
+
+When: {{.StartsAt.Format "Monday, January 2 at 3:04 PM"}} to {{.EndsAt.Format "3:04 PM MST"}}
+
+If you can no longer make it, please withdraw as early as you can so the employer can find cover.
+`,
+		`Booked: {{.ShiftName}} at {{.Location}}, {{.StartsAt.Format "Mon Jan 2 3:04 PM"}}-{{.EndsAt.Format "3:04 PM MST"}}.`,
+	),
+	TemplateInvoiceIssued: newMessageTemplate(TemplateInvoiceIssued,
+		`Invoice {{.InvoiceName}} for {{.Amount}}`,
+		`Hi {{.RecipientName}},
+
+Invoice {{.InvoiceName}} ({{.InvoiceID}}) for {{.Amount}} has been issued{{if not .DueDate.IsZero}} and is due on {{.DueDate.Format "January 2, 2006"}}{{end}}.
+`,
+		`Invoice {{.InvoiceName}} for {{.Amount}} has been issued{{if not .DueDate.IsZero}}, due {{.DueDate.Format "Jan 2"}}{{end}}.`,
+	),
+	TemplateInvoiceOverdue: newMessageTemplate(TemplateInvoiceOverdue,
+		`Invoice {{.InvoiceName}} is overdue`,
+		`Hi {{.RecipientName}},
+
+Invoice {{.InvoiceName}} ({{.InvoiceID}}) was due on {{.DueDate.Format "January 2, 2006"}} and has not been paid in full. The invoice total is {{.Amount}}.
+`,
+		`Invoice {{.InvoiceName}} was due {{.DueDate.Format "Jan 2"}} and is overdue.`,
+	),
+}
+
+// Render fills the template for channel with data, returning the subject and
+// body of the message. SMS messages have no subject.
+func Render(name Template, channel Channel, data any) (string, string, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification template %q", name)
+	}
+	switch channel {
+	case ChannelEmail:
+		subject, err := execute(tmpl.subject, data)
+		if err != nil {
+			return "", "", err
+		}
+		body, err := execute(tmpl.email, data)
+		return subject, body, err
+	case ChannelSMS:
+		body, err := execute(tmpl.sms, data)
+		return "", body, err
+	default:
+		return "", "", fmt.Errorf("%w: %s", ErrUnsupportedChannel, channel)
+	}
+}
+
+func execute(tmpl *template.Template, data any) (string, error) {
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", tmpl.Name(), err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,70 @@
+// Package outbox publishes domain events reliably. Producers write events to
+// the outbox table in the same transaction as the change they describe, and a
+// Relay later hands committed events to each Sink. Delivery is at least once:
+// a sink may see an event again after a crash or a failure in another sink, so
+// sinks must deduplicate on Event.DedupeKey.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Event is a domain event recorded by a service mutation.
+type Event struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	AggregateType string `json:"aggregate_type"`
+	AggregateID   string `json:"aggregate_id"`
+	// EmployerID is the employer the event belongs to, if any.
+	EmployerID string `json:"employer_id,omitempty"`
+	// DedupeKey identifies the event for consumers. It defaults to ID; set it
+	// to a natural key when the producer itself may retry.
+	DedupeKey string          `json:"dedupe_key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NewEvent encodes data as the payload of an event of the given type. The
+// aggregate type is taken from the event type, e.g. "invoice" for
+// "invoice.paid".
+func NewEvent(eventType string, employerID string, aggregateID string, data interface{}) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	aggregateType, _, _ := strings.Cut(eventType, ".")
+	return Event{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EmployerID:    employerID,
+		Payload:       payload,
+	}, nil
+}
+
+// Enqueue records the event in tx. It is only published if tx commits, and an
+// event whose DedupeKey is already in the outbox is ignored.
+func Enqueue(ctx context.Context, tx *sql.Tx, event Event) error {
+	if event.ID == "" {
+		event.ID = "evt_" + ksuid.New().String()
+	}
+	if event.DedupeKey == "" {
+		event.DedupeKey = event.ID
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO outbox (id, dedupe_key, event_type, aggregate_type, aggregate_id, employer_id, payload)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		ON CONFLICT (dedupe_key) DO NOTHING`,
+		event.ID, event.DedupeKey, event.Type, event.AggregateType, event.AggregateID, event.EmployerID, []byte(event.Payload),
+	); err != nil {
+		return fmt.Errorf("failed to enqueue %s event: %w", event.Type, err)
+	}
+	return nil
+}
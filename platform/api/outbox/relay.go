@@ -0,0 +1,155 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/backoff"
+)
+
+const (
+	initialBackoff = time.Second
+	maxBackoff     = 5 * time.Minute
+)
+
+// Backoff is how long the relay waits before retrying an event after the
+// given failed attempt.
+func Backoff(attempt int) time.Duration {
+	return backoff.Exponential(attempt, initialBackoff, maxBackoff)
+}
+
+// Relay moves committed events from the outbox to its sinks. Any number of
+// relays may run against the same database; rows are claimed with SKIP LOCKED
+// so each batch is handled by one relay at a time.
+type Relay struct {
+	db    *sql.DB
+	sinks []Sink
+}
+
+func NewRelay(db *sql.DB, sinks ...Sink) *Relay {
+	return &Relay{db: db, sinks: sinks}
+}
+
+// Run publishes due events every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.ProcessBatch(ctx, batchSize); err != nil {
+				slog.ErrorContext(ctx, "failed to relay outbox events", "error", err)
+			}
+		}
+	}
+}
+
+// ProcessBatch publishes up to limit due events and returns how many were
+// fully published. An event is retried with backoff until every sink has
+// accepted it; sinks that already accepted it are not called again.
+func (r *Relay) ProcessBatch(ctx context.Context, limit int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, dedupe_key, event_type, aggregate_type, aggregate_id, COALESCE(employer_id, ''), payload,
+			created_at, published_sinks, attempts
+		FROM outbox
+		WHERE published_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY created_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	type claimed struct {
+		event     Event
+		published []string
+		attempts  int
+	}
+	var batch []claimed
+	for rows.Next() {
+		var c claimed
+		var payload []byte
+		if err := rows.Scan(
+			&c.event.ID,
+			&c.event.DedupeKey,
+			&c.event.Type,
+			&c.event.AggregateType,
+			&c.event.AggregateID,
+			&c.event.EmployerID,
+			&payload,
+			&c.event.CreatedAt,
+			pq.Array(&c.published),
+			&c.attempts,
+		); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		c.event.Payload = payload
+		batch = append(batch, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate outbox events: %w", err)
+	}
+
+	published := 0
+	for _, c := range batch {
+		var errs []error
+		for _, sink := range r.sinks {
+			if slices.Contains(c.published, sink.Name()) {
+				continue
+			}
+			if err := sink.Publish(ctx, c.event); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+				continue
+			}
+			c.published = append(c.published, sink.Name())
+		}
+
+		if len(errs) == 0 {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE outbox SET published_sinks = $1, attempts = attempts + 1, last_error = NULL, published_at = NOW()
+				WHERE id = $2`,
+				pq.Array(c.published), c.event.ID,
+			); err != nil {
+				return 0, fmt.Errorf("failed to mark outbox event %s published: %w", c.event.ID, err)
+			}
+			published++
+			continue
+		}
+
+		publishErr := errors.Join(errs...)
+		slog.WarnContext(ctx, "failed to publish outbox event",
+			"event_id", c.event.ID, "event_type", c.event.Type, "attempts", c.attempts+1, "error", publishErr)
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE outbox
+			SET published_sinks = $1, attempts = attempts + 1, last_error = $2,
+				next_attempt_at = NOW() + make_interval(secs => $3)
+			WHERE id = $4`,
+			pq.Array(c.published), publishErr.Error(), Backoff(c.attempts+1).Seconds(), c.event.ID,
+		); err != nil {
+			return 0, fmt.Errorf("failed to reschedule outbox event %s: %w", c.event.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return published, nil
+}
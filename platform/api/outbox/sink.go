@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// Sink receives events from the relay. Name must be stable across restarts,
+// since it records which sinks have already accepted an event.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+// Handler handles an event published on a Bus.
+type Handler func(ctx context.Context, event Event) error
+
+// Bus is an in-process sink that fans events out to subscribed handlers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+var _ Sink = &Bus{}
+
+// AllEvents subscribes a handler to every event type.
+const AllEvents = "*"
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]Handler{}}
+}
+
+func (b *Bus) Name() string {
+	return "bus"
+}
+
+// Subscribe registers handler for events of eventType, or AllEvents.
+func (b *Bus) Subscribe(eventType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish calls every matching handler and returns their combined errors. A
+// failed event is retried for all handlers, so they must be idempotent.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler{}, b.handlers[event.Type]...), b.handlers[AllEvents]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogSink writes each event to the structured log.
+type LogSink struct{}
+
+var _ Sink = LogSink{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(ctx context.Context, event Event) error {
+	slog.InfoContext(ctx, "domain event",
+		"event_id", event.ID,
+		"event_type", event.Type,
+		"aggregate_type", event.AggregateType,
+		"aggregate_id", event.AggregateID,
+		"dedupe_key", event.DedupeKey,
+	)
+	return nil
+}
@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Bus(t *testing.T) {
+	bus := NewBus()
+	var paid, all []string
+	bus.Subscribe("invoice.paid", func(ctx context.Context, event Event) error {
+		paid = append(paid, event.ID)
+		return nil
+	})
+	bus.Subscribe(AllEvents, func(ctx context.Context, event Event) error {
+		all = append(all, event.ID)
+		if event.Type == "shift.cancelled" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, bus.Publish(ctx, Event{ID: "evt_1", Type: "invoice.paid"}))
+	assert.NoError(t, bus.Publish(ctx, Event{ID: "evt_2", Type: "shift.created"}))
+	assert.ErrorContains(t, bus.Publish(ctx, Event{ID: "evt_3", Type: "shift.cancelled"}), "boom")
+
+	assert.Equal(t, []string{"evt_1"}, paid)
+	assert.Equal(t, []string{"evt_1", "evt_2", "evt_3"}, all)
+}
+
+func Test_NewEvent(t *testing.T) {
+	event, err := NewEvent("invoice.paid", "user_1", "invoice_1", map[string]string{"status": "paid"})
+	assert.NoError(t, err)
+	assert.Equal(t, "invoice", event.AggregateType)
+	assert.Equal(t, "invoice_1", event.AggregateID)
+	assert.JSONEq(t, `{"status":"paid"}`, string(event.Payload))
+}
+
+func Test_Backoff(t *testing.T) {
+	assert.Equal(t, time.Second, Backoff(1))
+	assert.Equal(t, 8*time.Second, Backoff(4))
+	assert.Equal(t, 5*time.Minute, Backoff(20))
+}
@@ -0,0 +1,160 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/signature"
+)
+
+// FakeSignatureHeader carries the signature of FakeGateway webhooks.
+const FakeSignatureHeader = "Fake-Signature"
+
+// FakeGateway is an in-memory Gateway for local development and tests. Intents
+// never leave the process; use SignEvent to produce the webhook a real
+// provider would send after the payer completes an intent.
+type FakeGateway struct {
+	secret []byte
+	now    func() time.Time
+
+	mu          sync.Mutex
+	intents     map[string]*Intent
+	idempotency map[string]string
+}
+
+var _ Gateway = &FakeGateway{}
+
+// NewFakeGateway returns a gateway whose webhooks are signed with
+// webhookSecret. The secret is required: anyone can compute a valid signature
+// under an empty key.
+func NewFakeGateway(webhookSecret string) (*FakeGateway, error) {
+	if webhookSecret == "" {
+		return nil, ErrMissingWebhookSecret
+	}
+	return &FakeGateway{
+		secret:      []byte(webhookSecret),
+		now:         time.Now,
+		intents:     map[string]*Intent{},
+		idempotency: map[string]string{},
+	}, nil
+}
+
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (g *FakeGateway) CreatePaymentIntent(ctx context.Context, input CreateIntentInput) (*Intent, error) {
+	if input.Amount.Amount <= 0 || !input.Amount.Currency.Valid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAmount, input.Amount)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if id, ok := g.idempotency[input.IdempotencyKey]; ok && input.IdempotencyKey != "" {
+		intent := *g.intents[id]
+		return &intent, nil
+	}
+
+	id := "fake_pi_" + ksuid.New().String()
+	intent := &Intent{
+		ID:             id,
+		Amount:         input.Amount,
+		CapturedAmount: money.Zero(input.Amount.Currency),
+		RefundedAmount: money.Zero(input.Amount.Currency),
+		Status:         IntentStatusRequiresPayment,
+		ClientSecret:   id + "_secret_" + ksuid.New().String(),
+	}
+	g.intents[id] = intent
+	if input.IdempotencyKey != "" {
+		g.idempotency[input.IdempotencyKey] = id
+	}
+
+	result := *intent
+	return &result, nil
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, intentID string, amount money.Money) (*Intent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIntentNotFound, intentID)
+	}
+	if intent.Status != IntentStatusRequiresPayment {
+		return nil, fmt.Errorf("%w: intent %s is %s", ErrInvalidState, intentID, intent.Status)
+	}
+	if amount.IsZero() {
+		amount = intent.Amount
+	}
+	if amount.Currency != intent.Amount.Currency || amount.Amount <= 0 || amount.Amount > intent.Amount.Amount {
+		return nil, fmt.Errorf("%w: cannot capture %s of %s", ErrInvalidAmount, amount, intent.Amount)
+	}
+
+	intent.CapturedAmount = amount
+	intent.Status = IntentStatusSucceeded
+	result := *intent
+	return &result, nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, intentID string, amount money.Money) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIntentNotFound, intentID)
+	}
+	if intent.Status != IntentStatusSucceeded {
+		return nil, fmt.Errorf("%w: intent %s is %s", ErrInvalidState, intentID, intent.Status)
+	}
+	refundable, err := intent.CapturedAmount.Sub(intent.RefundedAmount)
+	if err != nil {
+		return nil, err
+	}
+	if amount.Currency != refundable.Currency || amount.Amount <= 0 || amount.Amount > refundable.Amount {
+		return nil, fmt.Errorf("%w: cannot refund %s of %s", ErrInvalidAmount, amount, refundable)
+	}
+
+	if intent.RefundedAmount, err = intent.RefundedAmount.Add(amount); err != nil {
+		return nil, err
+	}
+	return &Refund{ID: "fake_re_" + ksuid.New().String(), IntentID: intentID, Amount: amount}, nil
+}
+
+func (g *FakeGateway) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := signature.Verify(g.secret, header.Get(FakeSignatureHeader), payload, g.now()); err != nil {
+		return nil, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook event: %w", err)
+	}
+	return &event, nil
+}
+
+// SignEvent encodes and signs event the way the fake provider's webhook would,
+// filling in its id and timestamp when unset.
+func (g *FakeGateway) SignEvent(event Event) ([]byte, http.Header, error) {
+	if event.ID == "" {
+		event.ID = "fake_evt_" + ksuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = g.now().UTC()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, signature.Sign(g.secret, g.now(), payload))
+	return payload, header, nil
+}
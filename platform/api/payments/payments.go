@@ -0,0 +1,91 @@
+// Package payments abstracts the payment provider used to collect invoice
+// payments. The service layer only talks to a Gateway, so providers can be
+// swapped without touching billing code, and FakeGateway stands in for a real
+// provider in development and tests.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/signature"
+)
+
+var (
+	ErrInvalidSignature = signature.ErrInvalid
+	ErrIntentNotFound   = errors.New("payment intent not found")
+	ErrInvalidAmount    = errors.New("invalid payment amount")
+	ErrInvalidState     = errors.New("payment intent is not in a state that allows this")
+	// ErrMissingWebhookSecret is returned when a gateway is configured
+	// without the secret its webhooks are verified with.
+	ErrMissingWebhookSecret = errors.New("payment webhook secret is required")
+)
+
+type IntentStatus string
+
+const (
+	IntentStatusRequiresPayment IntentStatus = "requires_payment"
+	IntentStatusSucceeded       IntentStatus = "succeeded"
+	IntentStatusCancelled       IntentStatus = "cancelled"
+)
+
+// Intent is a provider-side request to collect an amount. The payer completes
+// it with ClientSecret; the outcome arrives later as a webhook Event.
+type Intent struct {
+	ID             string       `json:"id"`
+	Amount         money.Money  `json:"amount"`
+	CapturedAmount money.Money  `json:"captured_amount"`
+	RefundedAmount money.Money  `json:"refunded_amount"`
+	Status         IntentStatus `json:"status"`
+	ClientSecret   string       `json:"client_secret"`
+}
+
+type CreateIntentInput struct {
+	Amount money.Money
+	// Reference identifies what is being paid for, e.g. the invoice id.
+	Reference string
+	// IdempotencyKey makes retried requests return the original intent.
+	IdempotencyKey string
+}
+
+type Refund struct {
+	ID       string      `json:"id"`
+	IntentID string      `json:"intent_id"`
+	Amount   money.Money `json:"amount"`
+}
+
+type EventType string
+
+const (
+	EventPaymentSucceeded EventType = "payment.succeeded"
+	EventPaymentFailed    EventType = "payment.failed"
+	EventRefundSucceeded  EventType = "refund.succeeded"
+)
+
+// Event is a verified webhook notification from the provider. Amount is the
+// amount captured or refunded by this event, and RefundID the refund a
+// refund event settles.
+type Event struct {
+	ID         string      `json:"id"`
+	Type       EventType   `json:"type"`
+	IntentID   string      `json:"intent_id"`
+	RefundID   string      `json:"refund_id,omitempty"`
+	Amount     money.Money `json:"amount"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// Gateway is implemented by each payment provider.
+type Gateway interface {
+	// Name identifies the provider in stored payment records.
+	Name() string
+	CreatePaymentIntent(ctx context.Context, input CreateIntentInput) (*Intent, error)
+	// Capture collects amount, or the full intent amount when amount is zero.
+	Capture(ctx context.Context, intentID string, amount money.Money) (*Intent, error)
+	Refund(ctx context.Context, intentID string, amount money.Money) (*Refund, error)
+	// ParseWebhook verifies the request signature and decodes the event. It
+	// returns ErrInvalidSignature when the payload cannot be trusted.
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
+}
@@ -0,0 +1,109 @@
+package payouts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// FakeProvider is an in-memory Provider for local development and tests.
+// Transfers are paid once settleAfter has passed since they were created,
+// unless Settle or Fail decides them first.
+type FakeProvider struct {
+	settleAfter time.Duration
+	now         func() time.Time
+
+	mu          sync.Mutex
+	transfers   map[string]*fakeTransfer
+	idempotency map[string]string
+}
+
+type fakeTransfer struct {
+	Transfer
+	createdAt time.Time
+}
+
+var _ Provider = &FakeProvider{}
+
+func NewFakeProvider(settleAfter time.Duration) *FakeProvider {
+	return &FakeProvider{
+		settleAfter: settleAfter,
+		now:         time.Now,
+		transfers:   map[string]*fakeTransfer{},
+		idempotency: map[string]string{},
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateTransfer(ctx context.Context, input CreateTransferInput) (*Transfer, error) {
+	if input.Amount.Amount <= 0 || !input.Amount.Currency.Valid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAmount, input.Amount)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.idempotency[input.IdempotencyKey]; ok && input.IdempotencyKey != "" {
+		transfer := p.transfers[id].Transfer
+		return &transfer, nil
+	}
+
+	transfer := &fakeTransfer{
+		Transfer:  Transfer{ID: "fake_tr_" + ksuid.New().String(), Amount: input.Amount, Status: TransferStatusPending},
+		createdAt: p.now(),
+	}
+	p.transfers[transfer.ID] = transfer
+	if input.IdempotencyKey != "" {
+		p.idempotency[input.IdempotencyKey] = transfer.ID
+	}
+
+	result := transfer.Transfer
+	return &result, nil
+}
+
+func (p *FakeProvider) GetTransfer(ctx context.Context, transferID string) (*Transfer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transfer, ok := p.transfers[transferID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTransferNotFound, transferID)
+	}
+	if transfer.Status == TransferStatusPending && !p.now().Before(transfer.createdAt.Add(p.settleAfter)) {
+		transfer.Status = TransferStatusPaid
+	}
+	result := transfer.Transfer
+	return &result, nil
+}
+
+// Settle pays a pending transfer straight away.
+func (p *FakeProvider) Settle(transferID string) error {
+	return p.decide(transferID, TransferStatusPaid, "")
+}
+
+// Fail fails a pending transfer, e.g. as a bank would reject it.
+func (p *FakeProvider) Fail(transferID string, reason string) error {
+	return p.decide(transferID, TransferStatusFailed, reason)
+}
+
+func (p *FakeProvider) decide(transferID string, status TransferStatus, reason string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transfer, ok := p.transfers[transferID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTransferNotFound, transferID)
+	}
+	if transfer.Status != TransferStatusPending {
+		return fmt.Errorf("%w: transfer %s is %s", ErrInvalidState, transferID, transfer.Status)
+	}
+	transfer.Status = status
+	transfer.FailureReason = reason
+	return nil
+}
@@ -0,0 +1,55 @@
+// Package payouts abstracts the provider used to pay workers. The service
+// layer only talks to a Provider, so providers can be swapped without
+// touching earnings code, and FakeProvider stands in for a real provider in
+// development and tests.
+package payouts
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+var (
+	ErrTransferNotFound = errors.New("payout transfer not found")
+	ErrInvalidAmount    = errors.New("invalid payout amount")
+	ErrInvalidState     = errors.New("payout transfer is not in a state that allows this")
+)
+
+type TransferStatus string
+
+const (
+	TransferStatusPending TransferStatus = "pending"
+	TransferStatusPaid    TransferStatus = "paid"
+	TransferStatusFailed  TransferStatus = "failed"
+)
+
+// Transfer is a provider-side payment to a worker. Transfers settle
+// asynchronously, so callers check back with GetTransfer until the status is
+// no longer pending.
+type Transfer struct {
+	ID     string         `json:"id"`
+	Amount money.Money    `json:"amount"`
+	Status TransferStatus `json:"status"`
+	// FailureReason is set when the transfer failed.
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+type CreateTransferInput struct {
+	Amount money.Money
+	// RecipientID identifies the worker being paid.
+	RecipientID string
+	// Reference identifies what is being paid, e.g. the payout id.
+	Reference string
+	// IdempotencyKey makes retried requests return the original transfer.
+	IdempotencyKey string
+}
+
+// Provider is implemented by each payout provider.
+type Provider interface {
+	// Name identifies the provider in stored payout records.
+	Name() string
+	CreateTransfer(ctx context.Context, input CreateTransferInput) (*Transfer, error)
+	GetTransfer(ctx context.Context, transferID string) (*Transfer, error)
+}
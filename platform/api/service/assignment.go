@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type AssignmentStatus string
+
+const (
+	AssignmentStatusApplied   AssignmentStatus = "applied"
+	AssignmentStatusAccepted  AssignmentStatus = "accepted"
+	AssignmentStatusDeclined  AssignmentStatus = "declined"
+	AssignmentStatusWithdrawn AssignmentStatus = "withdrawn"
+)
+
+// ShiftAssignment links a worker to a shift they applied for.
+type ShiftAssignment struct {
+	ID        string           `json:"id" db:"id"`
+	ShiftID   string           `json:"shift_id" db:"shift_id"`
+	WorkerID  string           `json:"worker_id" db:"worker_id"`
+	Status    AssignmentStatus `json:"status" db:"status"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+const assignmentColumns = `
+	id,
+	shift_id,
+	worker_id,
+	status,
+	created_at,
+	COALESCE(updated_at, created_at)`
+
+func scanAssignment(row rowScanner) (*ShiftAssignment, error) {
+	var assignment ShiftAssignment
+	err := row.Scan(
+		&assignment.ID,
+		&assignment.ShiftID,
+		&assignment.WorkerID,
+		&assignment.Status,
+		&assignment.CreatedAt,
+		&assignment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// ListOpenShifts returns upcoming shifts that still have unfilled headcount.
+func (s *service) ListOpenShifts(ctx context.Context) ([]Shift, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+shiftColumns+`
+		FROM shifts
+		WHERE status = $1
+		AND starts_at >= $2
+		AND shifts_filled < headcount
+		ORDER BY starts_at, id`,
+		ShiftStatusOpen, time.Now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying open shifts: %w", err)
+	}
+	defer rows.Close()
+
+	return collectShifts(rows)
+}
+
+func (s *service) ApplyToShift(ctx context.Context, workerID string, shiftID string) (*ShiftAssignment, error) {
+	if workerID == "" {
+		return nil, newValidationError("worker_id", "is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	shift, err := scanShift(tx.QueryRowContext(ctx, `SELECT`+shiftColumns+` FROM shifts WHERE id = $1 FOR SHARE`, shiftID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("shift %s: %w", shiftID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching shift with id %s: %w", shiftID, err)
+	}
+	if shift.Status != ShiftStatusOpen {
+		return nil, fmt.Errorf("shift %s is not open: %w", shiftID, ErrConflict)
+	}
+	if shift.ShiftsFilled >= shift.Headcount {
+		return nil, fmt.Errorf("shift %s is already full: %w", shiftID, ErrConflict)
+	}
+	if member, err := isOrganizationMember(ctx, tx, workerID, shift.OrganizationID); err != nil {
+		return nil, err
+	} else if member {
+		return nil, newValidationError("shift_id", "cannot apply to your own organization's shift")
+	}
+	if err := checkScheduleConflicts(ctx, tx, workerID, shift); err != nil {
+		return nil, err
+	}
+
+	existing, err := scanAssignment(tx.QueryRowContext(ctx, `
+		SELECT`+assignmentColumns+`
+		FROM shift_assignments
+		WHERE shift_id = $1 AND worker_id = $2
+		FOR UPDATE`,
+		shiftID, workerID,
+	))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error fetching assignment: %w", err)
+	}
+
+	var assignment *ShiftAssignment
+	switch {
+	case existing == nil:
+		assignment, err = scanAssignment(tx.QueryRowContext(ctx, `
+			INSERT INTO shift_assignments (id, shift_id, worker_id, status, created_by)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING`+assignmentColumns,
+			generateID(AssignmentPrefix), shiftID, workerID, AssignmentStatusApplied, workerID,
+		))
+	case existing.Status == AssignmentStatusWithdrawn:
+		// A worker who withdrew can change their mind and apply again.
+		assignment, err = scanAssignment(tx.QueryRowContext(ctx, `
+			UPDATE shift_assignments
+			SET status = $1, updated_by = $2, updated_at = NOW()
+			WHERE id = $3
+			RETURNING`+assignmentColumns,
+			AssignmentStatusApplied, workerID, existing.ID,
+		))
+	default:
+		return nil, fmt.Errorf("worker already has a %s assignment for shift %s: %w", existing.Status, shiftID, ErrConflict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error applying to shift %s: %w", shiftID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    workerID,
+		EmployerID: shift.OrganizationID,
+		Action:     AuditActionApply,
+		EntityType: AuditEntityAssignment,
+		EntityID:   assignment.ID,
+		Before:     existing,
+		After:      assignment,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return assignment, nil
+}
+
+func (s *service) WithdrawAssignment(ctx context.Context, workerID string, assignmentID string) (*ShiftAssignment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getAssignmentForUpdate(ctx, tx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if current.WorkerID != workerID {
+		return nil, fmt.Errorf("assignment %s: %w", assignmentID, ErrForbidden)
+	}
+	if current.Status != AssignmentStatusApplied && current.Status != AssignmentStatusAccepted {
+		return nil, fmt.Errorf("cannot withdraw a %s assignment: %w", current.Status, ErrConflict)
+	}
+
+	var organizationID string
+	if current.Status == AssignmentStatusAccepted {
+		// Free up the slot the worker was holding.
+		err = tx.QueryRowContext(ctx, `
+			UPDATE shifts
+			SET shifts_filled = shifts_filled - 1, updated_by = $1, updated_at = NOW()
+			WHERE id = $2
+			RETURNING organization_id`,
+			workerID, current.ShiftID,
+		).Scan(&organizationID)
+	} else {
+		err = tx.QueryRowContext(ctx, `SELECT organization_id FROM shifts WHERE id = $1`, current.ShiftID).Scan(&organizationID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error releasing shift %s: %w", current.ShiftID, err)
+	}
+
+	assignment, err := setAssignmentStatus(ctx, tx, assignmentID, AssignmentStatusWithdrawn, workerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    workerID,
+		EmployerID: organizationID,
+		Action:     AuditActionWithdraw,
+		EntityType: AuditEntityAssignment,
+		EntityID:   assignment.ID,
+		Before:     current,
+		After:      assignment,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return assignment, nil
+}
+
+func (s *service) ListWorkerAssignments(ctx context.Context, workerID string) ([]ShiftAssignment, error) {
+	if workerID == "" {
+		return nil, newValidationError("worker_id", "is required")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+assignmentColumns+`
+		FROM shift_assignments
+		WHERE worker_id = $1
+		ORDER BY created_at DESC, id`,
+		workerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying assignments: %w", err)
+	}
+	defer rows.Close()
+
+	return collectAssignments(rows)
+}
+
+func (s *service) ListShiftAssignments(ctx context.Context, employerID string, shiftID string) ([]ShiftAssignment, error) {
+	if _, err := s.GetShift(ctx, employerID, shiftID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+assignmentColumns+`
+		FROM shift_assignments
+		WHERE shift_id = $1
+		ORDER BY created_at, id`,
+		shiftID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying assignments: %w", err)
+	}
+	defer rows.Close()
+
+	return collectAssignments(rows)
+}
+
+func (s *service) AcceptAssignment(ctx context.Context, employerID string, assignmentID string) (*ShiftAssignment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getAssignmentForUpdate(ctx, tx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	// Locking the shift serialises concurrent accepts so headcount cannot be exceeded.
+	shift, err := getShiftForUpdate(ctx, tx, employerID, current.ShiftID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != AssignmentStatusApplied {
+		return nil, fmt.Errorf("cannot accept a %s assignment: %w", current.Status, ErrConflict)
+	}
+	if shift.Status != ShiftStatusOpen {
+		return nil, fmt.Errorf("shift %s is not open: %w", shift.ID, ErrConflict)
+	}
+	if shift.ShiftsFilled >= shift.Headcount {
+		return nil, fmt.Errorf("shift %s already has %d of %d workers: %w", shift.ID, shift.ShiftsFilled, shift.Headcount, ErrConflict)
+	}
+	if err := lockWorker(ctx, tx, current.WorkerID); err != nil {
+		return nil, err
+	}
+	if err := checkScheduleConflicts(ctx, tx, current.WorkerID, shift); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE shifts
+		SET shifts_filled = shifts_filled + 1, updated_by = $1, updated_at = NOW()
+		WHERE id = $2`,
+		employerID, shift.ID,
+	); err != nil {
+		return nil, fmt.Errorf("error filling shift %s: %w", shift.ID, err)
+	}
+
+	assignment, err := setAssignmentStatus(ctx, tx, assignmentID, AssignmentStatusAccepted, employerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: shift.OrganizationID,
+		Action:     AuditActionAccept,
+		EntityType: AuditEntityAssignment,
+		EntityID:   assignment.ID,
+		Before:     current,
+		After:      assignment,
+	}); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, shift.OrganizationID, EventAssignmentAccepted, assignment.ID, assignment); err != nil {
+		return nil, err
+	}
+	if shift.ShiftsFilled+1 == shift.Headcount {
+		shift.ShiftsFilled++
+		if err := recordEvent(ctx, tx, shift.OrganizationID, EventShiftFilled, shift.ID, shift); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return assignment, nil
+}
+
+func (s *service) DeclineAssignment(ctx context.Context, employerID string, assignmentID string) (*ShiftAssignment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getAssignmentForUpdate(ctx, tx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	shift, err := getShiftForUpdate(ctx, tx, employerID, current.ShiftID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != AssignmentStatusApplied {
+		return nil, fmt.Errorf("cannot decline a %s assignment: %w", current.Status, ErrConflict)
+	}
+
+	assignment, err := setAssignmentStatus(ctx, tx, assignmentID, AssignmentStatusDeclined, employerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: shift.OrganizationID,
+		Action:     AuditActionDecline,
+		EntityType: AuditEntityAssignment,
+		EntityID:   assignment.ID,
+		Before:     current,
+		After:      assignment,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return assignment, nil
+}
+
+func getAssignmentForUpdate(ctx context.Context, tx *sql.Tx, assignmentID string) (*ShiftAssignment, error) {
+	assignment, err := scanAssignment(tx.QueryRowContext(ctx, `
+		SELECT`+assignmentColumns+`
+		FROM shift_assignments
+		WHERE id = $1
+		FOR UPDATE`,
+		assignmentID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("assignment %s: %w", assignmentID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching assignment with id %s: %w", assignmentID, err)
+	}
+	return assignment, nil
+}
+
+func setAssignmentStatus(ctx context.Context, tx *sql.Tx, assignmentID string, status AssignmentStatus, actorID string) (*ShiftAssignment, error) {
+	assignment, err := scanAssignment(tx.QueryRowContext(ctx, `
+		UPDATE shift_assignments
+		SET status = $1, updated_by = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING`+assignmentColumns,
+		status, actorID, assignmentID,
+	))
+	if isOverlapViolation(err) {
+		return nil, fmt.Errorf("assignment %s would double-book its worker: %w", assignmentID, ErrConflict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating assignment %s: %w", assignmentID, err)
+	}
+	return assignment, nil
+}
+
+func collectAssignments(rows *sql.Rows) ([]ShiftAssignment, error) {
+	assignments := []ShiftAssignment{}
+	for rows.Next() {
+		assignment, err := scanAssignment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning assignment row: %w", err)
+		}
+		assignments = append(assignments, *assignment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating assignment rows: %w", err)
+	}
+	return assignments, nil
+}
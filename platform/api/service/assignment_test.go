@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AssignmentWorkflow(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	firstWorkerID := createTestUser(t, db, "First")
+	secondWorkerID := createTestUser(t, db, "Second")
+
+	input := validShiftInput()
+	input.Headcount = 1
+	shift, err := svc.CreateShift(ctx, employerID, input)
+	require.NoError(t, err)
+
+	openShifts, err := svc.ListOpenShifts(ctx)
+	require.NoError(t, err)
+	assert.Len(t, openShifts, 1)
+
+	first, err := svc.ApplyToShift(ctx, firstWorkerID, shift.ID)
+	require.NoError(t, err)
+	assert.Equal(t, AssignmentStatusApplied, first.Status)
+
+	_, err = svc.ApplyToShift(ctx, firstWorkerID, shift.ID)
+	assert.ErrorIs(t, err, ErrConflict, "a worker cannot apply twice")
+
+	second, err := svc.ApplyToShift(ctx, secondWorkerID, shift.ID)
+	require.NoError(t, err)
+
+	_, err = svc.AcceptAssignment(ctx, generateID(UserPrefix), first.ID)
+	assert.ErrorIs(t, err, ErrForbidden, "only the owning employer can accept")
+
+	accepted, err := svc.AcceptAssignment(ctx, employerID, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, AssignmentStatusAccepted, accepted.Status)
+
+	_, err = svc.AcceptAssignment(ctx, employerID, second.ID)
+	assert.ErrorIs(t, err, ErrConflict, "headcount cannot be exceeded")
+
+	openShifts, err = svc.ListOpenShifts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, openShifts)
+
+	declined, err := svc.DeclineAssignment(ctx, employerID, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, AssignmentStatusDeclined, declined.Status)
+
+	_, err = svc.WithdrawAssignment(ctx, secondWorkerID, first.ID)
+	assert.ErrorIs(t, err, ErrForbidden, "workers can only withdraw their own assignments")
+
+	withdrawn, err := svc.WithdrawAssignment(ctx, firstWorkerID, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, AssignmentStatusWithdrawn, withdrawn.Status)
+
+	refreshed, err := svc.GetShift(ctx, employerID, shift.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, refreshed.ShiftsFilled)
+
+	assignments, err := svc.ListShiftAssignments(ctx, employerID, shift.ID)
+	require.NoError(t, err)
+	assert.Len(t, assignments, 2)
+
+	mine, err := svc.ListWorkerAssignments(ctx, firstWorkerID)
+	require.NoError(t, err)
+	require.Len(t, mine, 1)
+	assert.Equal(t, AssignmentStatusWithdrawn, mine[0].Status)
+
+	reapplied, err := svc.ApplyToShift(ctx, firstWorkerID, shift.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, reapplied.ID)
+	assert.Equal(t, AssignmentStatusApplied, reapplied.Status)
+
+	clearTestData(t, db)
+}
+
+func Test_ApplyToCancelledShift(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+	_, err = svc.CancelShift(ctx, employerID, shift.ID)
+	require.NoError(t, err)
+
+	_, err = svc.ApplyToShift(ctx, workerID, shift.ID)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = svc.ApplyToShift(ctx, workerID, "shift_missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	clearTestData(t, db)
+}
+
+func Test_AssignmentScheduleConflicts(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	monday := startOfWeek(today().AddDate(0, 0, 7))
+	shiftAt := func(start time.Time, length time.Duration) *Shift {
+		input := validShiftInput()
+		input.StartsAt, input.EndsAt = start, start.Add(length)
+		shift, err := svc.CreateShift(ctx, employerID, input)
+		require.NoError(t, err)
+		return shift
+	}
+	booked := shiftAt(monday.Add(9*time.Hour), 8*time.Hour)
+	overlapping := shiftAt(monday.Add(12*time.Hour), 8*time.Hour)
+
+	// Both applications go in before either is accepted.
+	first, err := svc.ApplyToShift(ctx, workerID, booked.ID)
+	require.NoError(t, err)
+	second, err := svc.ApplyToShift(ctx, workerID, overlapping.ID)
+	require.NoError(t, err)
+	_, err = svc.AcceptAssignment(ctx, employerID, first.ID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		apply        func() error
+		expectedType ScheduleConflictType
+	}{
+		{
+			name: "overlapping shift",
+			apply: func() error {
+				_, err := svc.AcceptAssignment(ctx, employerID, second.ID)
+				return err
+			},
+			expectedType: ScheduleConflictOverlap,
+		},
+		{
+			name: "too little rest",
+			apply: func() error {
+				_, err := svc.ApplyToShift(ctx, workerID, shiftAt(monday.Add(20*time.Hour), 3*time.Hour).ID)
+				return err
+			},
+			expectedType: ScheduleConflictRestPeriod,
+		},
+		{
+			name: "too many hours in the week",
+			apply: func() error {
+				_, err := svc.ApplyToShift(ctx, workerID, shiftAt(monday.Add(32*time.Hour), 54*time.Hour).ID)
+				return err
+			},
+			expectedType: ScheduleConflictWeeklyHours,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conflictErr *ScheduleConflictError
+			err := tt.apply()
+			require.ErrorAs(t, err, &conflictErr)
+			assert.ErrorIs(t, err, ErrConflict)
+			require.Len(t, conflictErr.Conflicts, 1)
+			assert.Equal(t, tt.expectedType, conflictErr.Conflicts[0].Type)
+		})
+	}
+
+	t.Run("cancelled shifts free the worker", func(t *testing.T) {
+		_, err := svc.CancelShift(ctx, employerID, booked.ID)
+		require.NoError(t, err)
+
+		accepted, err := svc.AcceptAssignment(ctx, employerID, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, AssignmentStatusAccepted, accepted.Status)
+	})
+
+	t.Run("moving a staffed shift onto another booking", func(t *testing.T) {
+		other := shiftAt(monday.AddDate(0, 0, 3).Add(9*time.Hour), 8*time.Hour)
+		assignment, err := svc.ApplyToShift(ctx, workerID, other.ID)
+		require.NoError(t, err)
+		_, err = svc.AcceptAssignment(ctx, employerID, assignment.ID)
+		require.NoError(t, err)
+
+		input := validShiftInput()
+		input.StartsAt, input.EndsAt = overlapping.StartsAt, overlapping.EndsAt
+		_, err = svc.UpdateShift(ctx, employerID, other.ID, input)
+		assert.ErrorIs(t, err, ErrConflict)
+
+		input.StartsAt = overlapping.EndsAt.Add(2 * time.Hour)
+		input.EndsAt = input.StartsAt.Add(4 * time.Hour)
+		_, err = svc.UpdateShift(ctx, employerID, other.ID, input)
+		var conflictErr *ScheduleConflictError
+		require.ErrorAs(t, err, &conflictErr, "the worker would get too little rest")
+		require.Len(t, conflictErr.Conflicts, 1)
+		assert.Equal(t, ScheduleConflictRestPeriod, conflictErr.Conflicts[0].Type)
+		assert.Equal(t, workerID, conflictErr.WorkerID)
+	})
+
+	clearTestData(t, db)
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BillingActor is recorded as the actor of changes made by billing runs.
+const BillingActor = "system:billing"
+
+type AuditAction string
+
+const (
+	AuditActionCreate     AuditAction = "create"
+	AuditActionUpdate     AuditAction = "update"
+	AuditActionDelete     AuditAction = "delete"
+	AuditActionCancel     AuditAction = "cancel"
+	AuditActionApply      AuditAction = "apply"
+	AuditActionWithdraw   AuditAction = "withdraw"
+	AuditActionAccept     AuditAction = "accept"
+	AuditActionDecline    AuditAction = "decline"
+	AuditActionClockIn    AuditAction = "clock_in"
+	AuditActionStartBreak AuditAction = "start_break"
+	AuditActionEndBreak   AuditAction = "end_break"
+	AuditActionClockOut   AuditAction = "clock_out"
+	AuditActionCorrect    AuditAction = "correct"
+	AuditActionApprove    AuditAction = "approve"
+	AuditActionBill       AuditAction = "bill"
+	AuditActionTransition AuditAction = "transition"
+	AuditActionCapture    AuditAction = "capture"
+	AuditActionFail       AuditAction = "fail"
+	AuditActionRefund     AuditAction = "refund"
+	AuditActionRedeliver  AuditAction = "redeliver"
+	AuditActionSubmit     AuditAction = "submit"
+	AuditActionPay        AuditAction = "pay"
+	AuditActionReissue    AuditAction = "reissue"
+)
+
+type AuditEntityType string
+
+const (
+	AuditEntityUser            AuditEntityType = "user"
+	AuditEntityShift           AuditEntityType = "shift"
+	AuditEntityAssignment      AuditEntityType = "assignment"
+	AuditEntityTimesheet       AuditEntityType = "timesheet"
+	AuditEntityBillingRate     AuditEntityType = "billing_rate"
+	AuditEntityInvoice         AuditEntityType = "invoice"
+	AuditEntityPayment         AuditEntityType = "payment"
+	AuditEntityWebhookEndpoint AuditEntityType = "webhook_endpoint"
+	AuditEntityWebhookDelivery AuditEntityType = "webhook_delivery"
+
+	AuditEntityOrganization            AuditEntityType = "organization"
+	AuditEntityOrganizationMember      AuditEntityType = "organization_member"
+	AuditEntityOrganizationInvitation  AuditEntityType = "organization_invitation"
+	AuditEntityLocation                AuditEntityType = "location"
+	AuditEntityWorkerProfile           AuditEntityType = "worker_profile"
+	AuditEntityShiftSeries             AuditEntityType = "shift_series"
+	AuditEntityNotificationPreferences AuditEntityType = "notification_preferences"
+	AuditEntityPayRules                AuditEntityType = "pay_rules"
+	AuditEntityPayout                  AuditEntityType = "payout"
+	AuditEntityCreditNote              AuditEntityType = "credit_note"
+	AuditEntityRefund                  AuditEntityType = "refund"
+	AuditEntityInvoiceNumbering        AuditEntityType = "invoice_numbering"
+)
+
+// AuditChange is the before and after value of a single changed field.
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// AuditEntry records one mutation: who made it, to what, and how the entity
+// changed. Before is empty for creations.
+type AuditEntry struct {
+	ID         string                 `json:"id" db:"id"`
+	ActorID    string                 `json:"actor_id" db:"actor_id"`
+	Action     AuditAction            `json:"action" db:"action"`
+	EntityType AuditEntityType        `json:"entity_type" db:"entity_type"`
+	EntityID   string                 `json:"entity_id" db:"entity_id"`
+	Before     json.RawMessage        `json:"before,omitempty" db:"before"`
+	After      json.RawMessage        `json:"after,omitempty" db:"after"`
+	Changes    map[string]AuditChange `json:"changes" db:"changes"`
+	CreatedAt  time.Time              `json:"created_at" db:"created_at"`
+}
+
+type AuditFilter struct {
+	EntityType AuditEntityType
+	EntityID   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+)
+
+// auditIgnoredFields change on every write and would only add noise to diffs.
+var auditIgnoredFields = map[string]bool{"updated_at": true}
+
+// auditRecord describes a mutation to be written to the audit log.
+type auditRecord struct {
+	ActorID string
+	// EmployerID is the organization whose audit log the entry appears in.
+	EmployerID string
+	Action     AuditAction
+	EntityType AuditEntityType
+	EntityID   string
+	// Before and After are JSON-encoded snapshots of the entity; nil when it
+	// did not exist.
+	Before interface{}
+	After  interface{}
+}
+
+// recordAudit appends a mutation to the audit log in tx, so the entry exists
+// exactly when the change commits. Every write in the service goes through
+// here, apart from the demo data seeded for new users, bookkeeping done by
+// the outbox relay and webhook dispatcher, and ledger postings, which are an
+// append-only record of their own.
+func recordAudit(ctx context.Context, tx *sql.Tx, record auditRecord) error {
+	before, err := auditSnapshot(record.Before)
+	if err != nil {
+		return fmt.Errorf("error encoding %s %s before audit: %w", record.EntityType, record.EntityID, err)
+	}
+	after, err := auditSnapshot(record.After)
+	if err != nil {
+		return fmt.Errorf("error encoding %s %s after audit: %w", record.EntityType, record.EntityID, err)
+	}
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		return fmt.Errorf("error diffing %s %s for audit: %w", record.EntityType, record.EntityID, err)
+	}
+	encodedChanges, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("error encoding audit changes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_log (id, actor_id, employer_id, action, entity_type, entity_id, before, after, changes)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9)`,
+		generateID(AuditPrefix), record.ActorID, record.EmployerID, record.Action, record.EntityType, record.EntityID,
+		nullJSON(before), nullJSON(after), encodedChanges,
+	); err != nil {
+		return fmt.Errorf("error recording audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAuditLog returns the audit entries of the employer's organization,
+// newest first.
+func (s *service) ListAuditLog(ctx context.Context, employerID string, filter AuditFilter) ([]AuditEntry, error) {
+	if employerID == "" {
+		return nil, newValidationError("employer_id", "is required")
+	}
+	if filter.EntityID != "" && filter.EntityType == "" {
+		return nil, newValidationError("entity_type", "is required when filtering by entity_id")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, newValidationError("to", "must not be before from")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		return nil, newValidationError("limit", fmt.Sprintf("must be at most %d", maxAuditLimit))
+	}
+
+	query := `
+		SELECT id, actor_id, action, entity_type, entity_id, before, after, changes, created_at
+		FROM audit_log
+		WHERE employer_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)`
+	args := []interface{}{employerID}
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		query += fmt.Sprintf(` AND entity_type = $%d`, len(args))
+	}
+	if filter.EntityID != "" {
+		args = append(args, filter.EntityID)
+		query += fmt.Sprintf(` AND entity_id = $%d`, len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(` AND created_at >= $%d`, len(args))
+	}
+	if !filter.To.IsZero() {
+		// To is a date, so include the whole day.
+		args = append(args, filter.To.AddDate(0, 0, 1))
+		query += fmt.Sprintf(` AND created_at < $%d`, len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after, changes []byte
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&before,
+			&after,
+			&changes,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning audit log row: %w", err)
+		}
+		entry.Before = before
+		entry.After = after
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, fmt.Errorf("error decoding audit changes %s: %w", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log rows: %w", err)
+	}
+	return entries, nil
+}
+
+func auditSnapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(encoded, []byte("null")) {
+		return nil, nil
+	}
+	return encoded, nil
+}
+
+// auditChanges compares the top-level fields of two JSON objects and returns
+// those that differ.
+func auditChanges(before, after json.RawMessage) (map[string]AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(beforeFields)+len(afterFields))
+	for key := range beforeFields {
+		keys = append(keys, key)
+	}
+	for key := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := map[string]AuditChange{}
+	for _, key := range keys {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		b, a := beforeFields[key], afterFields[key]
+		if bytes.Equal(b, a) {
+			continue
+		}
+		changes[key] = AuditChange{Before: nullRaw(b), After: nullRaw(a)}
+	}
+	return changes, nil
+}
+
+func auditFields(snapshot json.RawMessage) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if snapshot == nil {
+		return fields, nil
+	}
+	if err := json.Unmarshal(snapshot, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, value); err != nil {
+			return nil, err
+		}
+		fields[key] = compacted.Bytes()
+	}
+	return fields, nil
+}
+
+// nullRaw encodes a missing field as JSON null.
+func nullRaw(v json.RawMessage) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	return v
+}
+
+// nullJSON stores a missing snapshot as SQL NULL.
+func nullJSON(v json.RawMessage) interface{} {
+	if v == nil {
+		return nil
+	}
+	return []byte(v)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AuditLog(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	otherEmployerID := createTestUser(t, db, "Other")
+
+	shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+	input := validShiftInput()
+	input.ShiftName = "Evening Picking"
+	_, err = svc.UpdateShift(ctx, employerID, shift.ID, input)
+	require.NoError(t, err)
+
+	entries, err := svc.ListAuditLog(ctx, employerID, AuditFilter{EntityType: AuditEntityShift, EntityID: shift.ID})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	update, create := entries[0], entries[1]
+	assert.Equal(t, AuditActionCreate, create.Action)
+	assert.Equal(t, employerID, create.ActorID)
+	assert.Nil(t, create.Before)
+	assert.NotEmpty(t, create.After)
+
+	assert.Equal(t, AuditActionUpdate, update.Action)
+	assert.Equal(t, employerID, update.ActorID)
+	require.Contains(t, update.Changes, "shift_name")
+	assert.JSONEq(t, `"Morning Picking"`, string(update.Changes["shift_name"].Before))
+	assert.JSONEq(t, `"Evening Picking"`, string(update.Changes["shift_name"].After))
+	assert.NotContains(t, update.Changes, "updated_at")
+	assert.NotContains(t, update.Changes, "location", "unchanged fields are left out")
+
+	var after Shift
+	require.NoError(t, json.Unmarshal(update.After, &after))
+	assert.Equal(t, employerID, after.UpdatedBy, "updated_by is written on update")
+
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	entries, err = svc.ListAuditLog(ctx, employerID, AuditFilter{From: tomorrow})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = svc.ListAuditLog(ctx, otherEmployerID, AuditFilter{EntityType: AuditEntityShift})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = svc.ListAuditLog(ctx, employerID, AuditFilter{EntityID: shift.ID})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = db.Exec(`UPDATE audit_log SET action = 'delete' WHERE entity_id = $1`, shift.ID)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = db.Exec(`DELETE FROM audit_log WHERE entity_id = $1`, shift.ID)
+	assert.ErrorContains(t, err, "append-only")
+}
+
+func Test_auditChanges(t *testing.T) {
+	changes, err := auditChanges(
+		json.RawMessage(`{"name": "a", "count": 1, "updated_at": "x", "gone": true}`),
+		json.RawMessage(`{"name":"b","count":1,"updated_at":"y","added":[1, 2]}`),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]AuditChange{
+		"name":  {Before: json.RawMessage(`"a"`), After: json.RawMessage(`"b"`)},
+		"gone":  {Before: json.RawMessage(`true`), After: json.RawMessage(`null`)},
+		"added": {Before: json.RawMessage(`null`), After: json.RawMessage(`[1,2]`)},
+	}, changes)
+
+	changes, err = auditChanges(nil, json.RawMessage(`{"id":"x"}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]AuditChange{
+		"id": {Before: json.RawMessage(`null`), After: json.RawMessage(`"x"`)},
+	}, changes)
+}
@@ -0,0 +1,555 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/payrules"
+)
+
+// DefaultPlatformFeeBasisPoints is the fee added on top of billable hours (15%).
+const DefaultPlatformFeeBasisPoints = 1500
+
+// DefaultCurrency is the currency invoices are raised in. Shift and billing
+// rates are minor units of this currency.
+const DefaultCurrency = money.USD
+
+// BillingRunInput selects the billing period to invoice. Both dates are
+// inclusive calendar days; a timesheet falls on the day it clocked in on in
+// its shift's timezone.
+type BillingRunInput struct {
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// EmployerID limits the run to a single employer organization when set.
+	EmployerID string
+	// PlatformFeeBasisPoints overrides DefaultPlatformFeeBasisPoints when non-zero.
+	PlatformFeeBasisPoints int64
+	// TaxBasisPoints is applied to each labor line. Zero means no tax.
+	TaxBasisPoints int64
+}
+
+// BilledInvoice summarises what a billing run did for one employer.
+type BilledInvoice struct {
+	InvoiceID         string      `json:"invoice_id"`
+	EmployerID        string      `json:"employer_id"`
+	Created           bool        `json:"created"`
+	TimesheetCount    int         `json:"timesheet_count"`
+	SubtotalAmount    money.Money `json:"subtotal_amount"`
+	PlatformFeeAmount money.Money `json:"platform_fee_amount"`
+	TaxAmount         money.Money `json:"tax_amount"`
+	InvoiceAmount     money.Money `json:"invoice_amount"`
+}
+
+type BillingRunResult struct {
+	PeriodStart time.Time       `json:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end"`
+	Invoices    []BilledInvoice `json:"invoices"`
+	// UnratedTimesheetIDs were left unbilled because neither the shift nor the
+	// employer's role rates define an hourly rate.
+	UnratedTimesheetIDs []string `json:"unrated_timesheet_ids"`
+	// LockedTimesheetIDs were left unbilled because the period's invoice has
+	// already been issued and can no longer be amended.
+	LockedTimesheetIDs []string `json:"locked_timesheet_ids"`
+}
+
+// BillingRate is an employer organization's default hourly rate in cents for
+// a role.
+type BillingRate struct {
+	ID         string    `json:"id" db:"id"`
+	EmployerID string    `json:"employer_id" db:"employer_id"`
+	Role       string    `json:"role" db:"role"`
+	HourlyRate int64     `json:"hourly_rate" db:"hourly_rate"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// billableTimesheet is an approved timesheet together with the hourly rate it
+// is billed at, which is also the base rate its worker is paid.
+type billableTimesheet struct {
+	timesheet  *Timesheet
+	shiftName  string
+	hourlyRate money.Money
+	// timezone is the shift's, used to describe when the work happened.
+	timezone *time.Location
+}
+
+// SetBillingRate sets the caller's organization's hourly rate for a role,
+// which shifts without a rate of their own are billed at.
+func (s *service) SetBillingRate(ctx context.Context, employerID string, role string, hourlyRate int64) (*BillingRate, error) {
+	role = strings.TrimSpace(role)
+	if role == "" {
+		return nil, newValidationError("role", "is required")
+	}
+	if hourlyRate <= 0 {
+		return nil, newValidationError("hourly_rate", "must be positive")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := organizationForUser(ctx, tx, employerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var before *BillingRate
+	var existing BillingRate
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, employer_id, role, hourly_rate, created_at, COALESCE(updated_at, created_at)
+		FROM billing_rates
+		WHERE employer_id = $1 AND role = $2
+		FOR UPDATE`,
+		organizationID, role,
+	).Scan(&existing.ID, &existing.EmployerID, &existing.Role, &existing.HourlyRate, &existing.CreatedAt, &existing.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error fetching billing rate: %w", err)
+	}
+	if err == nil {
+		before = &existing
+	}
+
+	var rate BillingRate
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO billing_rates (id, employer_id, role, hourly_rate, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (employer_id, role) DO UPDATE
+		SET hourly_rate = EXCLUDED.hourly_rate, updated_by = $5, updated_at = NOW()
+		RETURNING id, employer_id, role, hourly_rate, created_at, COALESCE(updated_at, created_at)`,
+		generateID(BillingRatePrefix), organizationID, role, hourlyRate, employerID,
+	).Scan(&rate.ID, &rate.EmployerID, &rate.Role, &rate.HourlyRate, &rate.CreatedAt, &rate.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error setting billing rate: %w", err)
+	}
+	action := AuditActionCreate
+	if before != nil {
+		action = AuditActionUpdate
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: organizationID,
+		Action:     action,
+		EntityType: AuditEntityBillingRate,
+		EntityID:   rate.ID,
+		Before:     before,
+		After:      rate,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &rate, nil
+}
+
+// GenerateInvoices bills approved timesheet hours for the period, producing one
+// draft invoice per employer. Each timesheet is billed at most once, so re-running
+// the same period only picks up timesheets approved since the last run.
+func (s *service) GenerateInvoices(ctx context.Context, input BillingRunInput) (*BillingRunResult, error) {
+	periodStart := localDate(input.PeriodStart)
+	periodEnd := localDate(input.PeriodEnd)
+	if periodStart.IsZero() || periodEnd.IsZero() {
+		return nil, newValidationError("period", "start and end are required")
+	}
+	if periodEnd.Before(periodStart) {
+		return nil, newValidationError("period", "end must not be before start")
+	}
+	feeBasisPoints := input.PlatformFeeBasisPoints
+	if feeBasisPoints == 0 {
+		feeBasisPoints = DefaultPlatformFeeBasisPoints
+	}
+	if feeBasisPoints < 0 {
+		return nil, newValidationError("platform_fee_basis_points", "must not be negative")
+	}
+	if input.TaxBasisPoints < 0 {
+		return nil, newValidationError("tax_basis_points", "must not be negative")
+	}
+
+	employerIDs, err := s.employersWithUnbilledHours(ctx, periodStart, periodEnd, input.EmployerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BillingRunResult{
+		PeriodStart:         periodStart,
+		PeriodEnd:           periodEnd,
+		Invoices:            []BilledInvoice{},
+		UnratedTimesheetIDs: []string{},
+		LockedTimesheetIDs:  []string{},
+	}
+	for _, employerID := range employerIDs {
+		if err := s.billEmployer(ctx, employerID, periodStart, periodEnd, feeBasisPoints, input.TaxBasisPoints, result); err != nil {
+			return nil, fmt.Errorf("error billing employer %s: %w", employerID, err)
+		}
+	}
+
+	slog.InfoContext(ctx, "billing run completed",
+		"period_start", periodStart.Format(time.DateOnly),
+		"period_end", periodEnd.Format(time.DateOnly),
+		"invoices", len(result.Invoices),
+		"unrated_timesheets", len(result.UnratedTimesheetIDs),
+		"locked_timesheets", len(result.LockedTimesheetIDs),
+	)
+	return result, nil
+}
+
+func (s *service) employersWithUnbilledHours(ctx context.Context, periodStart, periodEnd time.Time, employerID string) ([]string, error) {
+	query := `
+		SELECT DISTINCT s.organization_id
+		FROM timesheets t
+		JOIN shifts s ON t.shift_id = s.id
+		WHERE t.status = $1
+		AND t.invoice_id IS NULL
+		AND (t.clock_in_at AT TIME ZONE s.timezone)::date BETWEEN $2 AND $3`
+	args := []interface{}{TimesheetStatusApproved, periodStart, periodEnd}
+	if employerID != "" {
+		query += ` AND s.organization_id = $4`
+		args = append(args, employerID)
+	}
+	query += ` ORDER BY s.organization_id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying employers to bill: %w", err)
+	}
+	defer rows.Close()
+
+	var employerIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning employer row: %w", err)
+		}
+		employerIDs = append(employerIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating employer rows: %w", err)
+	}
+	return employerIDs, nil
+}
+
+// billEmployer creates or amends the employer's invoice for the period in a
+// single transaction.
+func (s *service) billEmployer(ctx context.Context, employerID string, periodStart, periodEnd time.Time, feeBasisPoints, taxBasisPoints int64, result *BillingRunResult) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise concurrent runs for the same employer and period.
+	lockKey := fmt.Sprintf("billing:%s:%s:%s", employerID, periodStart.Format(time.DateOnly), periodEnd.Format(time.DateOnly))
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, lockKey); err != nil {
+		return fmt.Errorf("error acquiring billing lock: %w", err)
+	}
+
+	billable, unrated, err := loadBillableTimesheets(ctx, tx, employerID, periodStart, periodEnd)
+	if err != nil {
+		return err
+	}
+	result.UnratedTimesheetIDs = append(result.UnratedTimesheetIDs, unrated...)
+	if len(billable) == 0 {
+		return nil
+	}
+
+	var invoiceID string
+	var status InvoiceStatus
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, status
+		FROM invoices
+		WHERE organization_id = $1 AND period_start = $2 AND period_end = $3
+		FOR UPDATE`,
+		employerID, periodStart, periodEnd,
+	).Scan(&invoiceID, &status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error fetching existing invoice: %w", err)
+	}
+	created := errors.Is(err, sql.ErrNoRows)
+
+	if !created && status != InvoiceStatusDraft {
+		for _, b := range billable {
+			result.LockedTimesheetIDs = append(result.LockedTimesheetIDs, b.timesheet.ID)
+		}
+		return nil
+	}
+
+	var before *InvoiceDetail
+	if created {
+		invoiceID = generateID(InvoicePrefix)
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO invoices (id, invoice_amount, currency, status, invoice_name, period_start, period_end, organization_id, created_by)
+			VALUES ($1, 0, $2, $3, $4, $5, $6, $7, $8)`,
+			invoiceID, DefaultCurrency, InvoiceStatusDraft, billingInvoiceName(periodStart, periodEnd), periodStart, periodEnd, employerID, BillingActor,
+		); err != nil {
+			return fmt.Errorf("error creating invoice: %w", err)
+		}
+	} else if before, err = getInvoiceDetail(ctx, tx, invoiceID); err != nil {
+		return err
+	}
+
+	classified, err := classifyTimesheets(ctx, tx, employerID, billable)
+	if err != nil {
+		return err
+	}
+	for _, b := range billable {
+		lines, err := laborLines(b, classified[b.timesheet.ID], taxBasisPoints)
+		if err != nil {
+			return fmt.Errorf("error pricing timesheet %s: %w", b.timesheet.ID, err)
+		}
+		for _, line := range lines {
+			if err := insertLineItem(ctx, tx, invoiceID, BillingActor, line); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE timesheets SET invoice_id = $1, updated_by = $2, updated_at = NOW() WHERE id = $3`,
+			invoiceID, BillingActor, b.timesheet.ID,
+		); err != nil {
+			return fmt.Errorf("error marking timesheet %s billed: %w", b.timesheet.ID, err)
+		}
+	}
+
+	if err := replacePlatformFee(ctx, tx, invoiceID, BillingActor, feeBasisPoints); err != nil {
+		return err
+	}
+	totals, err := recalculateInvoiceTotals(ctx, tx, invoiceID, BillingActor)
+	if err != nil {
+		return err
+	}
+	after, err := getInvoiceDetail(ctx, tx, invoiceID)
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    BillingActor,
+		EmployerID: employerID,
+		Action:     AuditActionBill,
+		EntityType: AuditEntityInvoice,
+		EntityID:   invoiceID,
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		return err
+	}
+
+	billed := BilledInvoice{
+		InvoiceID:         invoiceID,
+		EmployerID:        employerID,
+		Created:           created,
+		TimesheetCount:    len(billable),
+		SubtotalAmount:    totals.subtotal,
+		PlatformFeeAmount: totals.platformFee,
+		TaxAmount:         totals.tax,
+		InvoiceAmount:     totals.total,
+	}
+	eventType := EventInvoiceUpdated
+	if created {
+		eventType = EventInvoiceCreated
+	}
+	if err := recordEvent(ctx, tx, employerID, eventType, invoiceID, billed); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result.Invoices = append(result.Invoices, billed)
+	return nil
+}
+
+// replacePlatformFee recomputes the platform fee line from the invoice's labor lines.
+func replacePlatformFee(ctx context.Context, tx *sql.Tx, invoiceID string, actorID string, feeBasisPoints int64) error {
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM invoice_line_items WHERE invoice_id = $1 AND kind = $2`,
+		invoiceID, LineItemKindPlatformFee,
+	); err != nil {
+		return fmt.Errorf("error clearing platform fee: %w", err)
+	}
+
+	lines, err := getInvoiceLineItems(ctx, tx, invoiceID)
+	if err != nil {
+		return err
+	}
+	labor := money.Zero(DefaultCurrency)
+	for _, line := range lines {
+		if line.Kind != LineItemKindLabor {
+			continue
+		}
+		if labor, err = labor.Add(line.Amount); err != nil {
+			return fmt.Errorf("error summing labor lines: %w", err)
+		}
+	}
+
+	fee, err := labor.BasisPoints(feeBasisPoints)
+	if err != nil {
+		return fmt.Errorf("error calculating platform fee: %w", err)
+	}
+	if fee.IsZero() {
+		return nil
+	}
+	return insertLineItem(ctx, tx, invoiceID, actorID, &InvoiceLineItem{
+		Kind:        LineItemKindPlatformFee,
+		Description: fmt.Sprintf("Platform fee (%s%%)", formatBasisPoints(feeBasisPoints)),
+		Quantity:    1,
+		UnitPrice:   fee,
+		Amount:      fee,
+	})
+}
+
+// loadBillableTimesheets locks the employer's approved, unbilled timesheets in
+// the period and resolves the hourly rate for each one.
+func loadBillableTimesheets(ctx context.Context, tx *sql.Tx, employerID string, periodStart, periodEnd time.Time) ([]billableTimesheet, []string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT t.id, t.shift_id, s.shift_name, s.timezone, COALESCE(s.hourly_rate, br.hourly_rate, 0)
+		FROM timesheets t
+		JOIN shifts s ON t.shift_id = s.id
+		LEFT JOIN billing_rates br ON br.employer_id = s.organization_id AND br.role = s.role
+		WHERE s.organization_id = $1
+		AND t.status = $2
+		AND t.invoice_id IS NULL
+		AND (t.clock_in_at AT TIME ZONE s.timezone)::date BETWEEN $3 AND $4
+		ORDER BY t.clock_in_at, t.id
+		FOR UPDATE OF t`,
+		employerID, TimesheetStatusApproved, periodStart, periodEnd,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying billable timesheets: %w", err)
+	}
+
+	type rated struct {
+		timesheetID string
+		shiftID     string
+		shiftName   string
+		timezone    string
+		hourlyRate  int64
+	}
+	var candidates []rated
+	for rows.Next() {
+		var r rated
+		if err := rows.Scan(&r.timesheetID, &r.shiftID, &r.shiftName, &r.timezone, &r.hourlyRate); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("error scanning billable timesheet row: %w", err)
+		}
+		candidates = append(candidates, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating billable timesheet rows: %w", err)
+	}
+
+	var billable []billableTimesheet
+	var unrated []string
+	for _, c := range candidates {
+		if c.hourlyRate <= 0 {
+			unrated = append(unrated, c.timesheetID)
+			continue
+		}
+		timesheet, err := getTimesheet(ctx, tx, c.shiftID, c.timesheetID, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		timezone, err := loadTimezone(c.timezone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("shift %s has an invalid timezone: %w", c.shiftID, err)
+		}
+		billable = append(billable, billableTimesheet{
+			timesheet:  timesheet,
+			shiftName:  c.shiftName,
+			hourlyRate: money.New(c.hourlyRate, DefaultCurrency),
+			timezone:   timezone,
+		})
+	}
+	return billable, unrated, nil
+}
+
+// laborLines prices a timesheet's pay rule lines at its hourly rate, one
+// invoice line per category. Time is billed to the minute. A timesheet with
+// no billable time still gets a zero regular line so it is visibly billed.
+func laborLines(b billableTimesheet, lines []payrules.Line, taxBasisPoints int64) ([]*InvoiceLineItem, error) {
+	description := fmt.Sprintf("%s on %s", b.shiftName, b.timesheet.ClockInAt.In(b.timezone).Format(time.DateOnly))
+	var items []*InvoiceLineItem
+	for _, line := range lines {
+		if line.Billable < time.Minute {
+			continue
+		}
+		// Worked time is measured between instants, so an hour gained or lost
+		// to a DST change is billed as it was actually worked.
+		amount, err := billableAmount(line.Billable, b.hourlyRate, line.BasisPoints)
+		if err != nil {
+			return nil, err
+		}
+		unitPrice, err := b.hourlyRate.BasisPoints(line.BasisPoints)
+		if err != nil {
+			return nil, err
+		}
+		tax, err := amount.BasisPoints(taxBasisPoints)
+		if err != nil {
+			return nil, err
+		}
+		item := &InvoiceLineItem{
+			Kind:                  LineItemKindLabor,
+			Description:           description,
+			Quantity:              billedHours(line.Billable),
+			UnitPrice:             unitPrice,
+			Amount:                amount,
+			TaxRateBasisPoints:    taxBasisPoints,
+			TaxAmount:             tax,
+			ShiftID:               b.timesheet.ShiftID,
+			TimesheetID:           b.timesheet.ID,
+			PayCategory:           line.Category,
+			MultiplierBasisPoints: line.BasisPoints,
+			Trace:                 line.Trace,
+		}
+		if line.Category != payrules.CategoryRegular {
+			item.Description += fmt.Sprintf(" (%s)", payCategoryLabel(line.Category, line.BasisPoints))
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		items = append(items, &InvoiceLineItem{
+			Kind:                  LineItemKindLabor,
+			Description:           description,
+			UnitPrice:             b.hourlyRate,
+			Amount:                money.Zero(b.hourlyRate.Currency),
+			TaxRateBasisPoints:    taxBasisPoints,
+			ShiftID:               b.timesheet.ShiftID,
+			TimesheetID:           b.timesheet.ID,
+			PayCategory:           payrules.CategoryRegular,
+			MultiplierBasisPoints: payrules.BasisPointsOne,
+		})
+	}
+	return items, nil
+}
+
+// billableAmount prices worked time at an hourly rate scaled by a multiplier
+// in basis points, rounding half a cent up. Time is billed to the minute.
+func billableAmount(worked time.Duration, hourlyRate money.Money, bps int64) (money.Money, error) {
+	minutes := int64(worked / time.Minute)
+	if minutes <= 0 {
+		return money.Zero(hourlyRate.Currency), nil
+	}
+	return hourlyRate.MulRat(minutes*bps, 60*payrules.BasisPointsOne)
+}
+
+// formatBasisPoints renders basis points as a percentage, e.g. 1500 as "15" and 825 as "8.25".
+func formatBasisPoints(bps int64) string {
+	if bps%100 == 0 {
+		return fmt.Sprintf("%d", bps/100)
+	}
+	return strings.TrimRight(fmt.Sprintf("%d.%02d", bps/100, bps%100), "0")
+}
+
+func billingInvoiceName(periodStart, periodEnd time.Time) string {
+	return fmt.Sprintf("Shifts %s to %s", periodStart.Format(time.DateOnly), periodEnd.Format(time.DateOnly))
+}
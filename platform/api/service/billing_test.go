@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+// Helper function to record an approved timesheet of exactly the given length on a staffed shift
+func createApprovedTimesheet(t *testing.T, svc Service, employerID, workerID string, shift *Shift, worked time.Duration) *Timesheet {
+	ctx := context.Background()
+
+	timesheet, err := svc.ClockIn(ctx, workerID, shift.ID)
+	require.NoError(t, err)
+	timesheet, err = svc.ClockOut(ctx, workerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+
+	clockIn := today().Add(time.Hour)
+	timesheet, err = svc.CorrectTimesheet(ctx, employerID, shift.ID, timesheet.ID, &TimesheetCorrection{
+		ClockInAt:  clockIn,
+		ClockOutAt: clockIn.Add(worked),
+		Reason:     "test hours",
+		Version:    timesheet.Version,
+	})
+	require.NoError(t, err)
+	timesheet, err = svc.ApproveTimesheet(ctx, employerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+
+	return timesheet
+}
+
+func Test_GenerateInvoices(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	firstWorkerID := createTestUser(t, db, "First")
+	secondWorkerID := createTestUser(t, db, "Second")
+
+	// One shift with its own rate, one falling back to the employer's role rate.
+	rated := createStaffedShift(t, svc, employerID, firstWorkerID)
+	input := runningShiftInput()
+	input.HourlyRate = 2000
+	rated, err := svc.UpdateShift(ctx, employerID, rated.ID, input)
+	require.NoError(t, err)
+
+	byRole := createStaffedShift(t, svc, employerID, secondWorkerID)
+	input.HourlyRate = 0
+	input.Role = "forklift"
+	byRole, err = svc.UpdateShift(ctx, employerID, byRole.ID, input)
+	require.NoError(t, err)
+	organizationID := testOrganizationID(t, db, employerID)
+	_, err = svc.SetBillingRate(ctx, employerID, "forklift", 1800)
+	require.NoError(t, err)
+	rate, err := svc.SetBillingRate(ctx, employerID, " forklift ", 2500)
+	require.NoError(t, err)
+	assert.Equal(t, organizationID, rate.EmployerID)
+	assert.Equal(t, "forklift", rate.Role)
+	_, err = svc.SetBillingRate(ctx, employerID, "forklift", 0)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	createApprovedTimesheet(t, svc, employerID, firstWorkerID, rated, 8*time.Hour)
+	createApprovedTimesheet(t, svc, employerID, secondWorkerID, byRole, 90*time.Minute)
+
+	period := BillingRunInput{PeriodStart: today(), PeriodEnd: today()}
+	result, err := svc.GenerateInvoices(ctx, period)
+	require.NoError(t, err)
+	require.Len(t, result.Invoices, 1)
+
+	invoice := result.Invoices[0]
+	assert.True(t, invoice.Created)
+	assert.Equal(t, organizationID, invoice.EmployerID)
+	assert.Equal(t, 2, invoice.TimesheetCount)
+	// 8h at $20.00 plus 1.5h at $25.00
+	assert.Equal(t, money.New(16000+3750, money.USD), invoice.SubtotalAmount)
+	assert.Equal(t, money.New(2963, money.USD), invoice.PlatformFeeAmount)
+	assert.Equal(t, money.New(19750+2963, money.USD), invoice.InvoiceAmount)
+
+	var stored money.Money
+	err = db.QueryRow(`SELECT invoice_amount, currency FROM invoices WHERE id = $1`, invoice.InvoiceID).Scan(&stored.Amount, &stored.Currency)
+	require.NoError(t, err)
+	assert.Equal(t, invoice.InvoiceAmount, stored)
+
+	// Running the same period again must not bill anything twice.
+	rerun, err := svc.GenerateInvoices(ctx, period)
+	require.NoError(t, err)
+	assert.Empty(t, rerun.Invoices)
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM invoices WHERE organization_id = $1 AND period_start IS NOT NULL`, organizationID).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	clearTestData(t, db)
+}
+
+func Test_GenerateInvoicesBillsTheLocalDay(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	shift := createStaffedShift(t, svc, employerID, workerID)
+	input := runningShiftInput()
+	input.HourlyRate = 2000
+	input.Location = "Pier 39"
+	input.Timezone = "America/Los_Angeles"
+	shift, err := svc.UpdateShift(ctx, employerID, shift.ID, input)
+	require.NoError(t, err)
+
+	// 03:00 UTC is still the previous evening in Los Angeles.
+	timesheet, err := svc.ClockIn(ctx, workerID, shift.ID)
+	require.NoError(t, err)
+	timesheet, err = svc.ClockOut(ctx, workerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+	clockIn := today().Add(3 * time.Hour)
+	timesheet, err = svc.CorrectTimesheet(ctx, employerID, shift.ID, timesheet.ID, &TimesheetCorrection{
+		ClockInAt:  clockIn,
+		ClockOutAt: clockIn.Add(4 * time.Hour),
+		Reason:     "evening shift",
+		Version:    timesheet.Version,
+	})
+	require.NoError(t, err)
+	_, err = svc.ApproveTimesheet(ctx, employerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+
+	result, err := svc.GenerateInvoices(ctx, BillingRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	assert.Empty(t, result.Invoices, "the hours were worked yesterday where the shift is")
+
+	yesterday := today().AddDate(0, 0, -1)
+	result, err = svc.GenerateInvoices(ctx, BillingRunInput{PeriodStart: yesterday, PeriodEnd: yesterday})
+	require.NoError(t, err)
+	require.Len(t, result.Invoices, 1)
+	assert.Equal(t, 1, result.Invoices[0].TimesheetCount)
+
+	clearTestData(t, db)
+}
+
+func Test_GenerateInvoicesSkipsUnratedShifts(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	shift := createStaffedShift(t, svc, employerID, workerID)
+	timesheet := createApprovedTimesheet(t, svc, employerID, workerID, shift, 4*time.Hour)
+
+	result, err := svc.GenerateInvoices(ctx, BillingRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	assert.Empty(t, result.Invoices)
+	assert.Equal(t, []string{timesheet.ID}, result.UnratedTimesheetIDs)
+
+	_, err = svc.GenerateInvoices(ctx, BillingRunInput{PeriodStart: today(), PeriodEnd: today().AddDate(0, 0, -1)})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	clearTestData(t, db)
+}
+
+func Test_BillableAmount(t *testing.T) {
+	tests := []struct {
+		name       string
+		worked     time.Duration
+		hourlyRate int64
+		bps        int64
+		expected   int64
+	}{
+		{name: "whole hours", worked: 8 * time.Hour, hourlyRate: 2000, bps: 10000, expected: 16000},
+		{name: "partial hour", worked: 90 * time.Minute, hourlyRate: 2500, bps: 10000, expected: 3750},
+		{name: "rounds half a cent up", worked: time.Minute, hourlyRate: 1530, bps: 10000, expected: 26},
+		{name: "ignores seconds", worked: time.Minute + 59*time.Second, hourlyRate: 6000, bps: 10000, expected: 100},
+		{name: "no time worked", worked: 0, hourlyRate: 2000, bps: 10000, expected: 0},
+		{name: "time and a half", worked: 90 * time.Minute, hourlyRate: 2500, bps: 15000, expected: 5625},
+		{name: "night differential", worked: 7 * time.Minute, hourlyRate: 2000, bps: 1000, expected: 23},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := billableAmount(tt.worked, money.New(tt.hourlyRate, money.USD), tt.bps)
+			require.NoError(t, err)
+			assert.Equal(t, money.New(tt.expected, money.USD), amount)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// minRestBetweenShifts is the shortest break a worker may have between
+	// the end of one accepted shift and the start of the next.
+	minRestBetweenShifts = 10 * time.Hour
+	// maxWeeklyHours caps the scheduled time of a worker's accepted shifts
+	// starting in one week, Monday to Sunday in the shift's timezone.
+	maxWeeklyHours = 60 * time.Hour
+)
+
+type ScheduleConflictType string
+
+const (
+	ScheduleConflictOverlap     ScheduleConflictType = "overlap"
+	ScheduleConflictRestPeriod  ScheduleConflictType = "rest_period"
+	ScheduleConflictWeeklyHours ScheduleConflictType = "weekly_hours"
+)
+
+// ScheduleConflict is one reason a worker cannot take a shift. Overlap and
+// rest period conflicts name the worker's other shift; for rest periods Hours
+// is the rest the worker would get, and for weekly hours it is the week's
+// total including the new shift. LimitHours is the rule being broken.
+type ScheduleConflict struct {
+	Type       ScheduleConflictType `json:"type"`
+	Message    string               `json:"message"`
+	ShiftID    string               `json:"shift_id,omitempty"`
+	StartsAt   *time.Time           `json:"starts_at,omitempty"`
+	EndsAt     *time.Time           `json:"ends_at,omitempty"`
+	WeekStart  *time.Time           `json:"week_start,omitempty"`
+	Hours      float64              `json:"hours,omitempty"`
+	LimitHours float64              `json:"limit_hours,omitempty"`
+}
+
+// ScheduleConflictError is returned when assigning a worker to a shift would
+// double-book them or break the rest and weekly hours rules.
+type ScheduleConflictError struct {
+	WorkerID  string             `json:"worker_id"`
+	ShiftID   string             `json:"shift_id"`
+	Conflicts []ScheduleConflict `json:"conflicts"`
+}
+
+func (e *ScheduleConflictError) Error() string {
+	messages := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		messages[i] = conflict.Message
+	}
+	return fmt.Sprintf("worker %s cannot work shift %s: %s", e.WorkerID, e.ShiftID, strings.Join(messages, "; "))
+}
+
+func (e *ScheduleConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// checkScheduleConflicts compares shift with the other shifts workerID is
+// accepted onto and returns a *ScheduleConflictError listing every rule it
+// would break. The exclusion constraint on shift_assignments backs up the
+// overlap check.
+func checkScheduleConflicts(ctx context.Context, tx *sql.Tx, workerID string, shift *Shift) error {
+	loc, err := loadTimezone(shift.Timezone)
+	if err != nil {
+		return fmt.Errorf("shift %s has an invalid timezone: %w", shift.ID, err)
+	}
+	weekStart := startOfWeek(shift.StartsAt.In(loc))
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	from := shift.StartsAt.Add(-minRestBetweenShifts)
+	if weekStart.Before(from) {
+		from = weekStart
+	}
+	to := shift.EndsAt.Add(minRestBetweenShifts)
+	if weekEnd.After(to) {
+		to = weekEnd
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT`+shiftColumns+`
+		FROM shifts
+		WHERE id IN (SELECT shift_id FROM shift_assignments WHERE worker_id = $1 AND status = $2)
+		AND id <> $3
+		AND status <> $4
+		AND starts_at < $5
+		AND ends_at > $6
+		ORDER BY starts_at, id`,
+		workerID, AssignmentStatusAccepted, shift.ID, ShiftStatusCancelled, to, from,
+	)
+	if err != nil {
+		return fmt.Errorf("error querying shifts for worker %s: %w", workerID, err)
+	}
+	others, err := collectShifts(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	var conflicts []ScheduleConflict
+	weekly := shift.EndsAt.Sub(shift.StartsAt)
+	for _, other := range others {
+		if !other.StartsAt.Before(weekStart) && other.StartsAt.Before(weekEnd) {
+			weekly += other.EndsAt.Sub(other.StartsAt)
+		}
+
+		var rest time.Duration
+		switch {
+		case other.StartsAt.Before(shift.EndsAt) && other.EndsAt.After(shift.StartsAt):
+			conflicts = append(conflicts, ScheduleConflict{
+				Type:     ScheduleConflictOverlap,
+				Message:  fmt.Sprintf("already booked on shift %s at the same time", other.ID),
+				ShiftID:  other.ID,
+				StartsAt: &other.StartsAt,
+				EndsAt:   &other.EndsAt,
+			})
+			continue
+		case !other.EndsAt.After(shift.StartsAt):
+			rest = shift.StartsAt.Sub(other.EndsAt)
+		default:
+			rest = other.StartsAt.Sub(shift.EndsAt)
+		}
+		if rest < minRestBetweenShifts {
+			conflicts = append(conflicts, ScheduleConflict{
+				Type:       ScheduleConflictRestPeriod,
+				Message:    fmt.Sprintf("only %s of rest next to shift %s, at least %s is required", formatHours(rest), other.ID, formatHours(minRestBetweenShifts)),
+				ShiftID:    other.ID,
+				StartsAt:   &other.StartsAt,
+				EndsAt:     &other.EndsAt,
+				Hours:      rest.Hours(),
+				LimitHours: minRestBetweenShifts.Hours(),
+			})
+		}
+	}
+	if weekly > maxWeeklyHours {
+		conflicts = append(conflicts, ScheduleConflict{
+			Type:       ScheduleConflictWeeklyHours,
+			Message:    fmt.Sprintf("would be scheduled for %s in the week of %s, at most %s is allowed", formatHours(weekly), weekStart.Format(time.DateOnly), formatHours(maxWeeklyHours)),
+			WeekStart:  &weekStart,
+			Hours:      weekly.Hours(),
+			LimitHours: maxWeeklyHours.Hours(),
+		})
+	}
+
+	if len(conflicts) > 0 {
+		return &ScheduleConflictError{WorkerID: workerID, ShiftID: shift.ID, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// startOfWeek returns midnight on the Monday on or before t, in t's location.
+func startOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return startOfLocalDay(t.AddDate(0, 0, -daysSinceMonday), t.Location())
+}
+
+func formatHours(d time.Duration) string {
+	return fmt.Sprintf("%gh", d.Round(time.Minute).Hours())
+}
+
+// isOverlapViolation reports whether err comes from the exclusion constraint
+// that stops a worker being accepted onto overlapping shifts.
+func isOverlapViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23P01" && pqErr.Constraint == "shift_assignments_no_overlap"
+}
+
+// lockWorker serialises bookings for a worker so that concurrent accepts see
+// each other when checking rest periods and weekly hours.
+func lockWorker(ctx context.Context, tx *sql.Tx, workerID string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, workerID); err != nil {
+		return fmt.Errorf("error locking worker %s: %w", workerID, err)
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+// CreditNote reduces what is owed on an invoice by crediting back some or all
+// of its line items. Its amounts are split the way the invoice's are.
+type CreditNote struct {
+	ID                string           `json:"id" db:"id"`
+	InvoiceID         string           `json:"invoice_id" db:"invoice_id"`
+	OrganizationID    string           `json:"organization_id" db:"organization_id"`
+	Reason            string           `json:"reason" db:"reason"`
+	SubtotalAmount    money.Money      `json:"subtotal_amount" db:"subtotal_amount"`
+	PlatformFeeAmount money.Money      `json:"platform_fee_amount" db:"platform_fee_amount"`
+	TaxAmount         money.Money      `json:"tax_amount" db:"tax_amount"`
+	Amount            money.Money      `json:"amount" db:"amount"`
+	Lines             []CreditNoteLine `json:"lines"`
+	CreatedBy         string           `json:"created_by" db:"created_by"`
+	CreatedAt         time.Time        `json:"created_at" db:"created_at"`
+}
+
+// CreditNoteLine credits part or all of one invoice line item and its tax.
+type CreditNoteLine struct {
+	ID          string       `json:"id" db:"id"`
+	LineItemID  string       `json:"line_item_id" db:"line_item_id"`
+	Kind        LineItemKind `json:"kind" db:"kind"`
+	Description string       `json:"description" db:"description"`
+	Reason      string       `json:"reason" db:"reason"`
+	Amount      money.Money  `json:"amount" db:"amount"`
+	TaxAmount   money.Money  `json:"tax_amount" db:"tax_amount"`
+}
+
+type CreditNoteInput struct {
+	Reason string `json:"reason"`
+	// Lines to credit. Leaving it empty credits everything on the invoice
+	// that has not been credited yet.
+	Lines []CreditNoteLineInput `json:"lines"`
+}
+
+type CreditNoteLineInput struct {
+	LineItemID string `json:"line_item_id"`
+	// Amount defaults to what is left of the line. Tax is credited in
+	// proportion to it.
+	Amount *money.Money `json:"amount"`
+	// Reason defaults to the credit note's.
+	Reason string `json:"reason"`
+}
+
+// creditableInvoiceStatuses are the statuses an invoice can be credited in.
+// Drafts are still being amended and have nothing to credit.
+var creditableInvoiceStatuses = []InvoiceStatus{InvoiceStatusIssued, InvoiceStatusPartiallyPaid, InvoiceStatusDisputed, InvoiceStatusPaid}
+
+// CreateCreditNote credits an invoice in full or in part and moves it on to
+// whatever status its reduced balance calls for. Anything the customer has
+// overpaid as a result can then be returned with RefundPayment. Only platform
+// staff may credit invoices, and they may credit any organization's.
+func (s *service) CreateCreditNote(ctx context.Context, actorID string, invoiceID string, input *CreditNoteInput) (*CreditNote, error) {
+	if err := authorizePlatform(ctx, middleware.PermissionCreditNotesWrite); err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" {
+		return nil, newValidationError("reason", "is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice, err := getInvoiceForUpdate(ctx, tx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(creditableInvoiceStatuses, invoice.Status) {
+		return nil, fmt.Errorf("invoice %s is %s and cannot be credited: %w", invoiceID, invoice.Status, ErrConflict)
+	}
+
+	lines, err := creditNoteLines(ctx, tx, invoice, reason, input.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := invoice.Amount.Currency
+	note := CreditNote{
+		ID:                generateID(CreditNotePrefix),
+		InvoiceID:         invoice.ID,
+		OrganizationID:    invoice.OrganizationID,
+		Reason:            reason,
+		SubtotalAmount:    money.Zero(currency),
+		PlatformFeeAmount: money.Zero(currency),
+		TaxAmount:         money.Zero(currency),
+		Lines:             lines,
+		CreatedBy:         actorID,
+	}
+	for _, line := range lines {
+		if line.Kind == LineItemKindPlatformFee {
+			note.PlatformFeeAmount, err = note.PlatformFeeAmount.Add(line.Amount)
+		} else {
+			note.SubtotalAmount, err = note.SubtotalAmount.Add(line.Amount)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error totalling credit note: %w", err)
+		}
+		if note.TaxAmount, err = note.TaxAmount.Add(line.TaxAmount); err != nil {
+			return nil, fmt.Errorf("error totalling credit note: %w", err)
+		}
+	}
+	if note.Amount, err = money.Sum(currency, note.SubtotalAmount, note.PlatformFeeAmount, note.TaxAmount); err != nil {
+		return nil, fmt.Errorf("error totalling credit note: %w", err)
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO credit_notes (id, invoice_id, organization_id, reason, subtotal_amount, platform_fee_amount, tax_amount, amount, currency, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at`,
+		note.ID, note.InvoiceID, note.OrganizationID, note.Reason, note.SubtotalAmount.Amount, note.PlatformFeeAmount.Amount,
+		note.TaxAmount.Amount, note.Amount.Amount, currency, actorID,
+	).Scan(&note.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error inserting credit note: %w", err)
+	}
+	for i := range note.Lines {
+		line := &note.Lines[i]
+		line.ID = generateID(CreditNoteLinePrefix)
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO credit_note_lines (id, credit_note_id, line_item_id, kind, description, reason, amount, tax_amount, currency)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			line.ID, note.ID, line.LineItemID, line.Kind, line.Description, line.Reason, line.Amount.Amount, line.TaxAmount.Amount, currency,
+		); err != nil {
+			return nil, fmt.Errorf("error inserting credit note line: %w", err)
+		}
+	}
+
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: invoice.OrganizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityCreditNote,
+		EntityID:   note.ID,
+		After:      note,
+	}); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, invoice.OrganizationID, EventCreditNoteCreated, invoice.ID, note); err != nil {
+		return nil, err
+	}
+	if err := postLedgerEntry(ctx, tx, creditNoteEntry(&note, actorID)); err != nil {
+		return nil, err
+	}
+	if err := settleInvoice(ctx, tx, invoice.ID, actorID, fmt.Sprintf("credit note %s", note.ID)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &note, nil
+}
+
+// creditNoteLines resolves the requested lines against what is left to credit
+// on each of the invoice's line items.
+func creditNoteLines(ctx context.Context, tx *sql.Tx, invoice *lockedInvoice, reason string, requested []CreditNoteLineInput) ([]CreditNoteLine, error) {
+	items, err := getInvoiceLineItems(ctx, tx, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := uncreditedLineItems(ctx, tx, invoice.ID, items)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []CreditNoteLine
+	if len(requested) == 0 {
+		for _, item := range items {
+			left := remaining[item.ID]
+			if left.amount.IsZero() && left.tax.IsZero() {
+				continue
+			}
+			lines = append(lines, CreditNoteLine{
+				LineItemID:  item.ID,
+				Kind:        item.Kind,
+				Description: item.Description,
+				Reason:      reason,
+				Amount:      left.amount,
+				TaxAmount:   left.tax,
+			})
+		}
+		if len(lines) == 0 {
+			return nil, newValidationError("lines", fmt.Sprintf("invoice %s has nothing left to credit", invoice.ID))
+		}
+		return lines, nil
+	}
+
+	seen := map[string]bool{}
+	for i, req := range requested {
+		field := fmt.Sprintf("lines[%d]", i)
+		index := slices.IndexFunc(items, func(item InvoiceLineItem) bool { return item.ID == req.LineItemID })
+		if index < 0 {
+			return nil, newValidationError(field+".line_item_id", fmt.Sprintf("is not a line of invoice %s", invoice.ID))
+		}
+		if seen[req.LineItemID] {
+			return nil, newValidationError(field+".line_item_id", "is credited more than once")
+		}
+		seen[req.LineItemID] = true
+		item := items[index]
+		left := remaining[item.ID]
+
+		amount, tax := left.amount, left.tax
+		if req.Amount != nil {
+			amount = *req.Amount
+			if amount.Currency != left.amount.Currency {
+				return nil, newValidationError(field+".amount", fmt.Sprintf("must be in %s", left.amount.Currency))
+			}
+			if amount.Amount <= 0 || amount.Amount > left.amount.Amount {
+				return nil, newValidationError(field+".amount", fmt.Sprintf("must be positive and at most the %s left to credit", left.amount))
+			}
+			if amount != left.amount {
+				// Tax follows the share of the line credited; the last
+				// credit on a line takes whatever tax is left, so rounding
+				// never leaves any behind.
+				if tax, err = item.TaxAmount.MulRat(amount.Amount, item.Amount.Amount); err != nil {
+					return nil, fmt.Errorf("error crediting tax on line %s: %w", item.ID, err)
+				}
+				tax.Amount = min(tax.Amount, left.tax.Amount)
+			}
+		}
+		if amount.IsZero() && tax.IsZero() {
+			return nil, newValidationError(field+".line_item_id", "has already been credited in full")
+		}
+
+		lineReason := strings.TrimSpace(req.Reason)
+		if lineReason == "" {
+			lineReason = reason
+		}
+		lines = append(lines, CreditNoteLine{
+			LineItemID:  item.ID,
+			Kind:        item.Kind,
+			Description: item.Description,
+			Reason:      lineReason,
+			Amount:      amount,
+			TaxAmount:   tax,
+		})
+	}
+	return lines, nil
+}
+
+type uncreditedLine struct {
+	amount money.Money
+	tax    money.Money
+}
+
+// uncreditedLineItems returns how much of each line item, and of its tax,
+// earlier credit notes have left.
+func uncreditedLineItems(ctx context.Context, q querier, invoiceID string, items []InvoiceLineItem) (map[string]uncreditedLine, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT l.line_item_id, SUM(l.amount), SUM(l.tax_amount)
+		FROM credit_note_lines l
+		JOIN credit_notes n ON l.credit_note_id = n.id
+		WHERE n.invoice_id = $1
+		GROUP BY l.line_item_id`,
+		invoiceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying credited line items: %w", err)
+	}
+	defer rows.Close()
+
+	credited := map[string][2]int64{}
+	for rows.Next() {
+		var lineItemID string
+		var amount, tax int64
+		if err := rows.Scan(&lineItemID, &amount, &tax); err != nil {
+			return nil, fmt.Errorf("error scanning credited line item row: %w", err)
+		}
+		credited[lineItemID] = [2]int64{amount, tax}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating credited line item rows: %w", err)
+	}
+
+	remaining := make(map[string]uncreditedLine, len(items))
+	for _, item := range items {
+		c := credited[item.ID]
+		remaining[item.ID] = uncreditedLine{
+			amount: money.New(item.Amount.Amount-c[0], item.Amount.Currency),
+			tax:    money.New(item.TaxAmount.Amount-c[1], item.TaxAmount.Currency),
+		}
+	}
+	return remaining, nil
+}
+
+// ListCreditNotes returns the invoice's credit notes with their lines, oldest
+// first.
+func (s *service) ListCreditNotes(ctx context.Context, employerID string, invoiceID string) ([]CreditNote, error) {
+	if err := authorizeInvoice(ctx, s.db, employerID, invoiceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, invoice_id, organization_id, reason, subtotal_amount, platform_fee_amount, tax_amount, amount, currency, created_by, created_at
+		FROM credit_notes
+		WHERE invoice_id = $1
+		ORDER BY created_at, id`,
+		invoiceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying credit notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []CreditNote{}
+	for rows.Next() {
+		var note CreditNote
+		var currency money.Currency
+		if err := rows.Scan(&note.ID, &note.InvoiceID, &note.OrganizationID, &note.Reason, &note.SubtotalAmount.Amount,
+			&note.PlatformFeeAmount.Amount, &note.TaxAmount.Amount, &note.Amount.Amount, &currency, &note.CreatedBy, &note.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning credit note row: %w", err)
+		}
+		note.SubtotalAmount.Currency = currency
+		note.PlatformFeeAmount.Currency = currency
+		note.TaxAmount.Currency = currency
+		note.Amount.Currency = currency
+		note.Lines = []CreditNoteLine{}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating credit note rows: %w", err)
+	}
+	rows.Close()
+
+	for i := range notes {
+		if notes[i].Lines, err = getCreditNoteLines(ctx, s.db, notes[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+func getCreditNoteLines(ctx context.Context, q querier, creditNoteID string) ([]CreditNoteLine, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, line_item_id, kind, description, reason, amount, tax_amount, currency
+		FROM credit_note_lines
+		WHERE credit_note_id = $1
+		ORDER BY kind, id`,
+		creditNoteID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying credit note lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []CreditNoteLine{}
+	for rows.Next() {
+		var line CreditNoteLine
+		var currency money.Currency
+		if err := rows.Scan(&line.ID, &line.LineItemID, &line.Kind, &line.Description, &line.Reason,
+			&line.Amount.Amount, &line.TaxAmount.Amount, &currency); err != nil {
+			return nil, fmt.Errorf("error scanning credit note line row: %w", err)
+		}
+		line.Amount.Currency = currency
+		line.TaxAmount.Currency = currency
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating credit note line rows: %w", err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payments"
+)
+
+func Test_CreditNotesAndRefunds(t *testing.T) {
+	gateway := newTestGateway(t, "test-secret")
+	svc := NewService(db, WithPaymentGateway(gateway))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+	staffID := createTestUser(t, db, "Staff")
+	// Platform staff credit and refund; employers only read the results.
+	staffCtx := contextWithRoles(staffID, string(middleware.RolePlatformAdmin))
+	employerCtx := contextWithRoles(employerID, string(middleware.RoleEmployerAdmin))
+
+	// 4h at $25.00 plus the 15% platform fee is $115.00.
+	invoiceID := createIssuedInvoice(t, svc, employerID, workerID, 2500, 4*time.Hour)
+	payment, err := svc.CreatePayment(ctx, employerID, invoiceID, nil)
+	require.NoError(t, err)
+	completePayment(t, svc, gateway, payment)
+
+	invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusPaid, invoice.Status)
+	assert.Equal(t, money.New(0, money.USD), invoice.BalanceDue)
+	var labor InvoiceLineItem
+	for _, line := range invoice.LineItems {
+		if line.Kind == LineItemKindLabor {
+			labor = line
+		}
+	}
+	require.NotEmpty(t, labor.ID)
+
+	t.Run("employers cannot credit or refund their own invoices", func(t *testing.T) {
+		_, err := svc.CreateCreditNote(employerCtx, employerID, invoiceID, &CreditNoteInput{Reason: "Discount"})
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = svc.RefundPayment(employerCtx, employerID, payment.ID, &RefundInput{Reason: "Discount"})
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("nothing to refund before the invoice is credited", func(t *testing.T) {
+		_, err := svc.RefundPayment(staffCtx, staffID, payment.ID, &RefundInput{Reason: "goodwill"})
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	invalid := []struct {
+		name  string
+		input CreditNoteInput
+		field string
+	}{
+		{name: "no reason", input: CreditNoteInput{}, field: "reason"},
+		{name: "unknown line", input: CreditNoteInput{Reason: "x", Lines: []CreditNoteLineInput{{LineItemID: "line_missing"}}}, field: "lines[0].line_item_id"},
+		{name: "more than the line", input: CreditNoteInput{Reason: "x", Lines: []CreditNoteLineInput{{LineItemID: labor.ID, Amount: &money.Money{Amount: 10001, Currency: money.USD}}}}, field: "lines[0].amount"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateCreditNote(staffCtx, staffID, invoiceID, &tt.input)
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+
+	partial := money.New(2000, money.USD)
+	note, err := svc.CreateCreditNote(staffCtx, staffID, invoiceID, &CreditNoteInput{
+		Reason: "Hours disputed",
+		Lines:  []CreditNoteLineInput{{LineItemID: labor.ID, Amount: &partial, Reason: "Worker left 48 minutes early"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, partial, note.Amount)
+	assert.Equal(t, partial, note.SubtotalAmount)
+	require.Len(t, note.Lines, 1)
+	assert.Equal(t, "Worker left 48 minutes early", note.Lines[0].Reason)
+
+	invoice, err = svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusPaid, invoice.Status, "a partial credit leaves a paid invoice paid")
+	assert.Equal(t, money.New(11500, money.USD), invoice.InvoiceAmount, "the original invoice is unchanged")
+	assert.Equal(t, partial, invoice.CreditedAmount)
+	assert.Equal(t, money.New(9500, money.USD), invoice.AdjustedAmount)
+	assert.Equal(t, money.New(-2000, money.USD), invoice.BalanceDue, "the credit is owed back")
+
+	refund, err := svc.RefundPayment(staffCtx, staffID, payment.ID, &RefundInput{Reason: "Hours disputed"})
+	require.NoError(t, err)
+	assert.Equal(t, partial, refund.Amount)
+	assert.Equal(t, RefundStatusPending, refund.Status)
+	_, err = svc.RefundPayment(staffCtx, staffID, payment.ID, &RefundInput{Reason: "again"})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr, "pending refunds count against the credit")
+
+	payload, header, err := gateway.SignEvent(payments.Event{
+		Type:     payments.EventRefundSucceeded,
+		IntentID: payment.ProviderIntentID,
+		RefundID: refund.ProviderRefundID,
+		Amount:   refund.Amount,
+	})
+	require.NoError(t, err)
+	require.NoError(t, svc.HandlePaymentWebhook(ctx, payload, header))
+
+	refunds, err := svc.ListInvoiceRefunds(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, RefundStatusSucceeded, refunds[0].Status)
+	invoice, err = svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(9500, money.USD), invoice.PaidAmount)
+	assert.Equal(t, money.New(0, money.USD), invoice.BalanceDue)
+
+	remainder, err := svc.CreateCreditNote(staffCtx, staffID, invoiceID, &CreditNoteInput{Reason: "Shift cancelled"})
+	require.NoError(t, err)
+	assert.Equal(t, money.New(9500, money.USD), remainder.Amount, "the rest of the labor and the whole fee")
+	assert.Equal(t, money.New(1500, money.USD), remainder.PlatformFeeAmount)
+
+	invoice, err = svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusCredited, invoice.Status)
+	assert.Equal(t, money.New(0, money.USD), invoice.AdjustedAmount)
+	notes, err := svc.ListCreditNotes(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	require.Len(t, notes, 2)
+	assert.Equal(t, note.ID, notes[0].ID)
+	require.Len(t, notes[1].Lines, 2)
+
+	_, err = svc.CreateCreditNote(staffCtx, staffID, invoiceID, &CreditNoteInput{Reason: "again"})
+	assert.ErrorIs(t, err, ErrConflict, "credited invoices are final")
+
+	t.Run("invoices with credit notes cannot be voided", func(t *testing.T) {
+		otherEmployerID := createTestUser(t, db, "Other Employer")
+		otherWorkerID := createTestUser(t, db, "Other Worker")
+		otherInvoiceID := createIssuedInvoice(t, svc, otherEmployerID, otherWorkerID, 2500, 4*time.Hour)
+		amount := money.New(100, money.USD)
+		other, err := svc.GetInvoice(ctx, otherEmployerID, otherInvoiceID)
+		require.NoError(t, err)
+		_, err = svc.CreateCreditNote(staffCtx, staffID, otherInvoiceID, &CreditNoteInput{
+			Reason: "Rounding",
+			Lines:  []CreditNoteLineInput{{LineItemID: other.LineItems[0].ID, Amount: &amount}},
+		})
+		require.NoError(t, err)
+
+		_, err = svc.TransitionInvoice(ctx, otherEmployerID, otherInvoiceID, InvoiceStatusVoid, "")
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = svc.CreateCreditNote(employerCtx, employerID, otherInvoiceID, &CreditNoteInput{Reason: "x"})
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+	clearTestData(t, db)
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden is returned when the caller does not own the record they are trying to access.
+	ErrForbidden = errors.New("forbidden")
+	// ErrConflict is returned when a request is valid but cannot be applied to the record in its current state.
+	ErrConflict = errors.New("conflict")
+)
+
+// ValidationError describes a single invalid field on an incoming request.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+func newValidationError(field, message string) error {
+	return &ValidationError{Field: field, Message: message}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/outbox"
+)
+
+// EventType names a domain event. Events are recorded in the outbox by the
+// mutation that causes them and published by the outbox relay.
+type EventType string
+
+const (
+	EventInvoiceCreated       EventType = "invoice.created"
+	EventInvoiceUpdated       EventType = "invoice.updated"
+	EventInvoiceStatusChanged EventType = "invoice.status_changed"
+	EventInvoiceIssued        EventType = "invoice.issued"
+	EventInvoicePartiallyPaid EventType = "invoice.partially_paid"
+	EventInvoicePaid          EventType = "invoice.paid"
+	EventInvoiceVoided        EventType = "invoice.voided"
+	EventInvoiceDisputed      EventType = "invoice.disputed"
+	EventInvoiceOverdue       EventType = "invoice.overdue"
+	EventInvoiceCredited      EventType = "invoice.credited"
+	EventCreditNoteCreated    EventType = "credit_note.created"
+	EventShiftCreated         EventType = "shift.created"
+	EventShiftUpdated         EventType = "shift.updated"
+	EventShiftCancelled       EventType = "shift.cancelled"
+	EventShiftFilled          EventType = "shift.filled"
+	EventAssignmentAccepted   EventType = "assignment.accepted"
+	EventTimesheetApproved    EventType = "timesheet.approved"
+	EventPaymentSucceeded     EventType = "payment.succeeded"
+	EventPaymentRefunded      EventType = "payment.refunded"
+
+	// EventAll subscribes a webhook endpoint to every event type.
+	EventAll EventType = "*"
+)
+
+var knownEventTypes = []EventType{
+	EventInvoiceCreated,
+	EventInvoiceUpdated,
+	EventInvoiceStatusChanged,
+	EventInvoiceIssued,
+	EventInvoicePartiallyPaid,
+	EventInvoicePaid,
+	EventInvoiceVoided,
+	EventInvoiceDisputed,
+	EventInvoiceOverdue,
+	EventInvoiceCredited,
+	EventCreditNoteCreated,
+	EventShiftCreated,
+	EventShiftUpdated,
+	EventShiftCancelled,
+	EventShiftFilled,
+	EventAssignmentAccepted,
+	EventTimesheetApproved,
+	EventPaymentSucceeded,
+	EventPaymentRefunded,
+}
+
+// invoiceStatusEvents is the status-specific event sent alongside
+// invoice.status_changed when an invoice enters a status.
+var invoiceStatusEvents = map[InvoiceStatus]EventType{
+	InvoiceStatusIssued:        EventInvoiceIssued,
+	InvoiceStatusPartiallyPaid: EventInvoicePartiallyPaid,
+	InvoiceStatusPaid:          EventInvoicePaid,
+	InvoiceStatusVoid:          EventInvoiceVoided,
+	InvoiceStatusDisputed:      EventInvoiceDisputed,
+	InvoiceStatusCredited:      EventInvoiceCredited,
+}
+
+func (t EventType) Valid() bool {
+	if t == EventAll {
+		return true
+	}
+	for _, known := range knownEventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEvent writes a domain event to the outbox in tx, so it is published
+// only if the change it describes commits.
+func recordEvent(ctx context.Context, tx *sql.Tx, employerID string, eventType EventType, aggregateID string, data interface{}) error {
+	event, err := outbox.NewEvent(string(eventType), employerID, aggregateID, data)
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(ctx, tx, event)
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/payrules"
+)
+
+type LineItemKind string
+
+const (
+	LineItemKindLabor       LineItemKind = "labor"
+	LineItemKindPlatformFee LineItemKind = "platform_fee"
+)
+
+// InvoiceLineItem is a single charge on an invoice. All amounts share the
+// invoice's currency.
+type InvoiceLineItem struct {
+	ID                 string       `json:"id" db:"id"`
+	InvoiceID          string       `json:"invoice_id" db:"invoice_id"`
+	Kind               LineItemKind `json:"kind" db:"kind"`
+	Description        string       `json:"description" db:"description"`
+	Quantity           float64      `json:"quantity" db:"quantity"`
+	UnitPrice          money.Money  `json:"unit_price" db:"unit_price"`
+	Amount             money.Money  `json:"amount" db:"amount"`
+	TaxRateBasisPoints int64        `json:"tax_rate_bps" db:"tax_rate_bps"`
+	TaxAmount          money.Money  `json:"tax_amount" db:"tax_amount"`
+	ShiftID            string       `json:"shift_id,omitempty" db:"shift_id"`
+	TimesheetID        string       `json:"timesheet_id,omitempty" db:"timesheet_id"`
+	// PayCategory, MultiplierBasisPoints and Trace are set on labor lines for
+	// timesheets: the kind of time billed, its multiplier on the hourly rate
+	// (or for night differentials the premium on top), and why the hours fell
+	// in that category.
+	PayCategory           payrules.Category `json:"pay_category,omitempty" db:"pay_category"`
+	MultiplierBasisPoints int64             `json:"multiplier_bps,omitempty" db:"multiplier_bps"`
+	Trace                 []string          `json:"trace,omitempty" db:"trace"`
+	CreatedAt             time.Time         `json:"created_at" db:"created_at"`
+}
+
+// InvoiceDetail is an invoice header together with its line items and where
+// its balance stands. AdjustedAmount is InvoiceAmount less credit notes, and
+// BalanceDue is that less what has been paid; it is negative while credit is
+// owed back to the customer.
+type InvoiceDetail struct {
+	Invoice
+	CreditedAmount money.Money       `json:"credited_amount"`
+	AdjustedAmount money.Money       `json:"adjusted_amount"`
+	PaidAmount     money.Money       `json:"paid_amount"`
+	BalanceDue     money.Money       `json:"balance_due"`
+	LineItems      []InvoiceLineItem `json:"line_items"`
+}
+
+type invoiceTotals struct {
+	subtotal    money.Money
+	platformFee money.Money
+	tax         money.Money
+	total       money.Money
+}
+
+func (s *service) GetInvoice(ctx context.Context, employerID string, invoiceID string) (*InvoiceDetail, error) {
+	detail, err := getInvoiceDetail(ctx, s.db, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeOrganization(ctx, s.db, employerID, detail.OrganizationID); err != nil {
+		return nil, fmt.Errorf("invoice %s: %w", invoiceID, err)
+	}
+	return detail, nil
+}
+
+func getInvoiceDetail(ctx context.Context, q querier, invoiceID string) (*InvoiceDetail, error) {
+	var detail InvoiceDetail
+	var shiftID sql.NullString
+	var currency money.Currency
+	var total, subtotal, platformFee, tax int64
+	err := q.QueryRowContext(ctx, `
+		SELECT
+			i.id,
+			COALESCE(i.period_start, (s.starts_at AT TIME ZONE s.timezone)::date),
+			COALESCE(i.period_end, (s.ends_at AT TIME ZONE s.timezone)::date),
+			i.invoice_amount,
+			i.subtotal_amount,
+			i.platform_fee_amount,
+			i.tax_amount,
+			i.currency,
+			i.status,
+			i.shift_id,
+			i.organization_id,
+			i.created_by,
+			COALESCE(i.updated_by, ''),
+			COALESCE(i.invoice_name, ''),
+			COALESCE(i.invoice_number, ''),
+			i.created_at,
+			COALESCE(i.updated_at, i.created_at)
+		FROM invoices i
+		LEFT JOIN shifts s ON i.shift_id = s.id
+		WHERE i.id = $1`,
+		invoiceID,
+	).Scan(
+		&detail.ID,
+		&detail.StartDate,
+		&detail.EndDate,
+		&total,
+		&subtotal,
+		&platformFee,
+		&tax,
+		&currency,
+		&detail.Status,
+		&shiftID,
+		&detail.OrganizationID,
+		&detail.CreatedBy,
+		&detail.UpdatedBy,
+		&detail.InvoiceName,
+		&detail.InvoiceNumber,
+		&detail.CreatedAt,
+		&detail.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("invoice %s: %w", invoiceID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching invoice with id %s: %w", invoiceID, err)
+	}
+	detail.ShiftID = shiftID.String
+	detail.InvoiceAmount = money.New(total, currency)
+	detail.SubtotalAmount = money.New(subtotal, currency)
+	detail.PlatformFeeAmount = money.New(platformFee, currency)
+	detail.TaxAmount = money.New(tax, currency)
+
+	balance, err := getInvoiceBalance(ctx, q, invoiceID, detail.InvoiceAmount)
+	if err != nil {
+		return nil, err
+	}
+	detail.CreditedAmount = balance.credited
+	detail.AdjustedAmount = balance.adjusted
+	detail.PaidAmount = balance.paid
+	detail.BalanceDue = balance.due
+
+	if detail.LineItems, err = getInvoiceLineItems(ctx, q, invoiceID); err != nil {
+		return nil, err
+	}
+
+	return &detail, nil
+}
+
+func getInvoiceLineItems(ctx context.Context, q querier, invoiceID string) ([]InvoiceLineItem, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, invoice_id, kind, description, quantity, unit_price, amount, tax_rate_bps, tax_amount, currency,
+			COALESCE(shift_id, ''), COALESCE(timesheet_id, ''), COALESCE(pay_category, ''), COALESCE(multiplier_bps, 0), trace, created_at
+		FROM invoice_line_items
+		WHERE invoice_id = $1
+		ORDER BY kind, created_at, id`,
+		invoiceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying invoice line items: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []InvoiceLineItem{}
+	for rows.Next() {
+		var line InvoiceLineItem
+		var currency money.Currency
+		var trace []byte
+		if err := rows.Scan(
+			&line.ID,
+			&line.InvoiceID,
+			&line.Kind,
+			&line.Description,
+			&line.Quantity,
+			&line.UnitPrice.Amount,
+			&line.Amount.Amount,
+			&line.TaxRateBasisPoints,
+			&line.TaxAmount.Amount,
+			&currency,
+			&line.ShiftID,
+			&line.TimesheetID,
+			&line.PayCategory,
+			&line.MultiplierBasisPoints,
+			&trace,
+			&line.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning invoice line item row: %w", err)
+		}
+		if trace != nil {
+			if err := json.Unmarshal(trace, &line.Trace); err != nil {
+				return nil, fmt.Errorf("error decoding trace of invoice line item %s: %w", line.ID, err)
+			}
+		}
+		line.UnitPrice.Currency = currency
+		line.Amount.Currency = currency
+		line.TaxAmount.Currency = currency
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invoice line item rows: %w", err)
+	}
+	return lines, nil
+}
+
+// insertLineItem adds a line to an invoice. A zero TaxAmount may be left
+// without a currency; every other amount must match the invoice's currency.
+func insertLineItem(ctx context.Context, tx *sql.Tx, invoiceID string, actorID string, line *InvoiceLineItem) error {
+	var currency money.Currency
+	if err := tx.QueryRowContext(ctx, `SELECT currency FROM invoices WHERE id = $1`, invoiceID).Scan(&currency); err != nil {
+		return fmt.Errorf("error fetching invoice %s currency: %w", invoiceID, err)
+	}
+	if line.TaxAmount.IsZero() {
+		line.TaxAmount = money.Zero(currency)
+	}
+	for _, amount := range []money.Money{line.UnitPrice, line.Amount, line.TaxAmount} {
+		if amount.Currency != currency {
+			return fmt.Errorf("line item in %s on invoice %s in %s: %w", amount.Currency, invoiceID, currency, money.ErrCurrencyMismatch)
+		}
+	}
+
+	var trace []byte
+	if line.Trace != nil {
+		var err error
+		if trace, err = json.Marshal(line.Trace); err != nil {
+			return fmt.Errorf("error encoding line item trace: %w", err)
+		}
+	}
+
+	line.ID = generateID(LineItemPrefix)
+	line.InvoiceID = invoiceID
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO invoice_line_items (id, invoice_id, kind, description, quantity, unit_price, amount, tax_rate_bps, tax_amount, currency,
+			shift_id, timesheet_id, pay_category, multiplier_bps, trace, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, 0), $15, $16)`,
+		line.ID, invoiceID, line.Kind, line.Description, line.Quantity, line.UnitPrice.Amount, line.Amount.Amount,
+		line.TaxRateBasisPoints, line.TaxAmount.Amount, currency, line.ShiftID, line.TimesheetID,
+		line.PayCategory, line.MultiplierBasisPoints, trace, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting invoice line item: %w", err)
+	}
+	return nil
+}
+
+// recalculateInvoiceTotals rewrites the invoice header amounts from its line
+// items. Every write to invoice_line_items must be followed by a call to this
+// within the same transaction so the stored total always equals the lines.
+func recalculateInvoiceTotals(ctx context.Context, tx *sql.Tx, invoiceID string, actorID string) (*invoiceTotals, error) {
+	var currency money.Currency
+	if err := tx.QueryRowContext(ctx, `SELECT currency FROM invoices WHERE id = $1`, invoiceID).Scan(&currency); err != nil {
+		return nil, fmt.Errorf("error fetching invoice %s currency: %w", invoiceID, err)
+	}
+	lines, err := getInvoiceLineItems(ctx, tx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := sumLineItems(currency, lines)
+	if err != nil {
+		return nil, fmt.Errorf("error totalling invoice %s: %w", invoiceID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE invoices
+		SET subtotal_amount = $2,
+			platform_fee_amount = $3,
+			tax_amount = $4,
+			invoice_amount = $5,
+			updated_by = $6,
+			updated_at = NOW()
+		WHERE id = $1`,
+		invoiceID, totals.subtotal.Amount, totals.platformFee.Amount, totals.tax.Amount, totals.total.Amount, actorID,
+	); err != nil {
+		return nil, fmt.Errorf("error recalculating invoice %s totals: %w", invoiceID, err)
+	}
+	return totals, nil
+}
+
+// sumLineItems splits lines into subtotal, platform fee and tax and adds them up.
+func sumLineItems(currency money.Currency, lines []InvoiceLineItem) (*invoiceTotals, error) {
+	totals := invoiceTotals{
+		subtotal:    money.Zero(currency),
+		platformFee: money.Zero(currency),
+		tax:         money.Zero(currency),
+	}
+	var err error
+	for _, line := range lines {
+		if line.Kind == LineItemKindPlatformFee {
+			totals.platformFee, err = totals.platformFee.Add(line.Amount)
+		} else {
+			totals.subtotal, err = totals.subtotal.Add(line.Amount)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if totals.tax, err = totals.tax.Add(line.TaxAmount); err != nil {
+			return nil, err
+		}
+	}
+	if totals.total, err = money.Sum(currency, totals.subtotal, totals.platformFee, totals.tax); err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
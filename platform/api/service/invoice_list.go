@@ -0,0 +1,121 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultInvoicePageSize = 25
+	MaxInvoicePageSize     = 100
+)
+
+type InvoiceSortField string
+
+const (
+	InvoiceSortDate   InvoiceSortField = "date"
+	InvoiceSortAmount InvoiceSortField = "amount"
+	InvoiceSortStatus InvoiceSortField = "status"
+)
+
+// invoiceSortColumns maps each sort field onto the expression it orders by.
+// Invoice ids break ties so every row has a unique position in the order.
+var invoiceSortColumns = map[InvoiceSortField]string{
+	InvoiceSortDate:   "COALESCE(i.period_start, (s.starts_at AT TIME ZONE s.timezone)::date)",
+	InvoiceSortAmount: "i.invoice_amount",
+	InvoiceSortStatus: "i.status",
+}
+
+// InvoiceListParams filters, sorts and pages an employer's invoices. Zero
+// values leave the corresponding filter off.
+type InvoiceListParams struct {
+	Search   string
+	Statuses []InvoiceStatus
+	// From and To bound the invoiced period, inclusive.
+	From time.Time
+	To   time.Time
+	// MinAmount and MaxAmount bound the invoice total in minor units, inclusive.
+	MinAmount *int64
+	MaxAmount *int64
+
+	Sort       InvoiceSortField
+	Descending bool
+
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+// InvoicePage is one page of invoices. NextCursor is empty on the last page.
+type InvoicePage struct {
+	Invoices   []InvoiceResponse `json:"invoices"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// invoiceCursor is the position after the last invoice on a page. It records
+// the order it was produced under so it cannot be replayed against another.
+type invoiceCursor struct {
+	Sort       InvoiceSortField `json:"s"`
+	Descending bool             `json:"d"`
+	Value      string           `json:"v"`
+	ID         string           `json:"id"`
+}
+
+func encodeInvoiceCursor(c invoiceCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeInvoiceCursor(raw string) (*invoiceCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, newValidationError("cursor", "is malformed")
+	}
+	var c invoiceCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, newValidationError("cursor", "is malformed")
+	}
+	return &c, nil
+}
+
+// invoiceSortValue renders an invoice's sort key the way Postgres parses it
+// back when the cursor is used as a query parameter.
+func invoiceSortValue(sort InvoiceSortField, inv *InvoiceResponse) string {
+	switch sort {
+	case InvoiceSortAmount:
+		return fmt.Sprintf("%d", inv.InvoiceAmount.Amount)
+	case InvoiceSortStatus:
+		return string(inv.Status)
+	default:
+		return inv.StartDate.Format(time.DateOnly)
+	}
+}
+
+func normalizeInvoiceListParams(params *InvoiceListParams) error {
+	if params.Sort == "" {
+		params.Sort = InvoiceSortDate
+	}
+	if _, ok := invoiceSortColumns[params.Sort]; !ok {
+		return newValidationError("sort", fmt.Sprintf("must be one of %s, %s or %s", InvoiceSortDate, InvoiceSortAmount, InvoiceSortStatus))
+	}
+	if params.Limit == 0 {
+		params.Limit = DefaultInvoicePageSize
+	}
+	if params.Limit < 0 || params.Limit > MaxInvoicePageSize {
+		return newValidationError("limit", fmt.Sprintf("must be between 1 and %d", MaxInvoicePageSize))
+	}
+	for _, status := range params.Statuses {
+		if !status.Valid() {
+			return newValidationError("status", fmt.Sprintf("unknown invoice status %q", status))
+		}
+	}
+	if !params.From.IsZero() && !params.To.IsZero() && params.To.Before(params.From) {
+		return newValidationError("to", "must not be before from")
+	}
+	if params.MinAmount != nil && params.MaxAmount != nil && *params.MaxAmount < *params.MinAmount {
+		return newValidationError("max_amount", "must not be less than min_amount")
+	}
+	return nil
+}
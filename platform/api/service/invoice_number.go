@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/invoicenumber"
+)
+
+// InvoiceNumbering is how an organization's invoices are numbered when they
+// are issued, e.g. prefix "ACME" with template "{PREFIX}-{YYYY}-{SEQ:5}"
+// gives ACME-2026-00042. Organizations that never set it use the default
+// template with a prefix taken from their name. NextNumber previews the
+// number the next invoice issued today would get.
+type InvoiceNumbering struct {
+	OrganizationID string     `json:"organization_id"`
+	Prefix         string     `json:"prefix"`
+	Template       string     `json:"template"`
+	NextNumber     string     `json:"next_number"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+type InvoiceNumberingInput struct {
+	Prefix   string `json:"prefix"`
+	Template string `json:"template"`
+}
+
+func (s *service) GetInvoiceNumbering(ctx context.Context, employerID string) (*InvoiceNumbering, error) {
+	organizationID, err := organizationForUser(ctx, s.db, employerID)
+	if err != nil {
+		return nil, err
+	}
+	return loadInvoiceNumbering(ctx, s.db, organizationID)
+}
+
+// SetInvoiceNumbering changes how invoices issued from now on are numbered.
+// Invoices already issued keep their numbers, and a template that restarts
+// over different periods starts new sequences rather than continuing the old.
+func (s *service) SetInvoiceNumbering(ctx context.Context, employerID string, input *InvoiceNumberingInput) (*InvoiceNumbering, error) {
+	if input == nil {
+		return nil, newValidationError("invoice_numbering", "is required")
+	}
+	prefix := strings.ToUpper(strings.TrimSpace(input.Prefix))
+	if !invoicenumber.ValidPrefix(prefix) {
+		return nil, newValidationError("prefix", "must be 1 to 12 letters, digits, dashes or underscores")
+	}
+	template := strings.TrimSpace(input.Template)
+	if template == "" {
+		template = invoicenumber.DefaultTemplate
+	}
+	if _, err := invoicenumber.Parse(template); err != nil {
+		return nil, newValidationError("template", strings.TrimPrefix(err.Error(), invoicenumber.ErrInvalidTemplate.Error()+": "))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := organizationForUser(ctx, tx, employerID)
+	if err != nil {
+		return nil, err
+	}
+	before, err := loadInvoiceNumbering(ctx, tx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO invoice_numbering (organization_id, prefix, template, created_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id) DO UPDATE
+		SET prefix = EXCLUDED.prefix,
+			template = EXCLUDED.template,
+			updated_by = $4,
+			updated_at = NOW()`,
+		organizationID, prefix, template, employerID,
+	); err != nil {
+		return nil, fmt.Errorf("error saving invoice numbering: %w", err)
+	}
+
+	after, err := loadInvoiceNumbering(ctx, tx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	action := AuditActionCreate
+	if before.UpdatedAt != nil {
+		action = AuditActionUpdate
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: organizationID,
+		Action:     action,
+		EntityType: AuditEntityInvoiceNumbering,
+		EntityID:   organizationID,
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// loadInvoiceNumbering returns the organization's numbering, or the default
+// for its name when it has not set one.
+func loadInvoiceNumbering(ctx context.Context, q querier, organizationID string) (*InvoiceNumbering, error) {
+	numbering := InvoiceNumbering{OrganizationID: organizationID}
+	var name string
+	var createdAt sql.NullTime
+	var updatedAt sql.NullTime
+	var prefix, template sql.NullString
+	err := q.QueryRowContext(ctx, `
+		SELECT o.name, n.prefix, n.template, n.created_at, n.updated_at
+		FROM organizations o
+		LEFT JOIN invoice_numbering n ON n.organization_id = o.id
+		WHERE o.id = $1`,
+		organizationID,
+	).Scan(&name, &prefix, &template, &createdAt, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("organization %s: %w", organizationID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching invoice numbering: %w", err)
+	}
+	numbering.Prefix = invoicenumber.PrefixFromName(name)
+	numbering.Template = invoicenumber.DefaultTemplate
+	if prefix.Valid {
+		numbering.Prefix = prefix.String
+		numbering.Template = template.String
+	}
+	if createdAt.Valid {
+		numbering.UpdatedAt = &createdAt.Time
+	}
+	if updatedAt.Valid {
+		numbering.UpdatedAt = &updatedAt.Time
+	}
+
+	parsed, err := invoicenumber.Parse(numbering.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invoice numbering of organization %s: %w", organizationID, err)
+	}
+	now := time.Now().UTC()
+	var last int64
+	err = q.QueryRowContext(ctx, `
+		SELECT last_number FROM invoice_number_sequences WHERE organization_id = $1 AND period = $2`,
+		organizationID, parsed.Period(now),
+	).Scan(&last)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error fetching invoice number sequence: %w", err)
+	}
+	numbering.NextNumber = parsed.Format(numbering.Prefix, now, last+1)
+	return &numbering, nil
+}
+
+// assignInvoiceNumber gives an invoice leaving draft the next number in its
+// organization's sequence for the period it is issued in. The increment
+// locks the sequence row until tx ends, so concurrent issues are numbered one
+// after another and a rolled back issue frees its number for the next,
+// leaving no gaps. Invoices that already have a number keep it.
+func assignInvoiceNumber(ctx context.Context, tx *sql.Tx, invoice *lockedInvoice, issuedAt time.Time) (string, error) {
+	var existing sql.NullString
+	if err := tx.QueryRowContext(ctx, `SELECT invoice_number FROM invoices WHERE id = $1`, invoice.ID).Scan(&existing); err != nil {
+		return "", fmt.Errorf("error fetching number of invoice %s: %w", invoice.ID, err)
+	}
+	if existing.Valid {
+		return existing.String, nil
+	}
+
+	numbering, err := loadInvoiceNumbering(ctx, tx, invoice.OrganizationID)
+	if err != nil {
+		return "", err
+	}
+	template, err := invoicenumber.Parse(numbering.Template)
+	if err != nil {
+		return "", fmt.Errorf("invoice numbering of organization %s: %w", invoice.OrganizationID, err)
+	}
+
+	var seq int64
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO invoice_number_sequences (organization_id, period, last_number)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (organization_id, period) DO UPDATE
+		SET last_number = invoice_number_sequences.last_number + 1,
+			updated_at = NOW()
+		RETURNING last_number`,
+		invoice.OrganizationID, template.Period(issuedAt),
+	).Scan(&seq); err != nil {
+		return "", fmt.Errorf("error allocating number for invoice %s: %w", invoice.ID, err)
+	}
+
+	number := template.Format(numbering.Prefix, issuedAt, seq)
+	if _, err := tx.ExecContext(ctx, `UPDATE invoices SET invoice_number = $2 WHERE id = $1`, invoice.ID, number); err != nil {
+		if isInvoiceNumberViolation(err) {
+			return "", fmt.Errorf("invoice number %s is already taken; change the invoice numbering so it cannot repeat earlier numbers: %w", number, ErrConflict)
+		}
+		return "", fmt.Errorf("error numbering invoice %s: %w", invoice.ID, err)
+	}
+	return number, nil
+}
+
+// isInvoiceNumberViolation reports whether err comes from the index that
+// keeps invoice numbers unique within an organization. Numbers only repeat
+// when the numbering is changed to one that renders earlier numbers again.
+func isInvoiceNumberViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_invoices_organization_number"
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper function to insert a draft invoice for the user's organization
+func insertDraftInvoice(t *testing.T, employerID string, amount int64) string {
+	invoiceID := generateID(InvoicePrefix)
+	_, err := db.Exec(`
+		INSERT INTO invoices (id, invoice_amount, subtotal_amount, currency, status, invoice_name, organization_id, created_by)
+		VALUES ($1, $2, $2, $3, $4, $5, $6, $7)`,
+		invoiceID, amount, DefaultCurrency, InvoiceStatusDraft, "Staffing", testOrganizationID(t, db, employerID), employerID,
+	)
+	require.NoError(t, err)
+	return invoiceID
+}
+
+func Test_InvoiceNumbering(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	year := time.Now().UTC().Year()
+
+	numbering, err := svc.GetInvoiceNumbering(ctx, employerID)
+	require.NoError(t, err)
+	assert.Equal(t, "EMPL", numbering.Prefix, "the default prefix comes from the organization's name")
+	assert.Equal(t, fmt.Sprintf("EMPL-%d-00001", year), numbering.NextNumber)
+	assert.Nil(t, numbering.UpdatedAt)
+
+	t.Run("drafts and voided drafts have no number", func(t *testing.T) {
+		invoiceID := insertDraftInvoice(t, employerID, 1000)
+		invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+		require.NoError(t, err)
+		assert.Empty(t, invoice.InvoiceNumber)
+
+		_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusVoid, "raised by mistake")
+		require.NoError(t, err)
+		invoice, err = svc.GetInvoice(ctx, employerID, invoiceID)
+		require.NoError(t, err)
+		assert.Empty(t, invoice.InvoiceNumber)
+	})
+
+	t.Run("concurrent issues get consecutive numbers", func(t *testing.T) {
+		const issues = 8
+		invoiceIDs := make([]string, issues)
+		for i := range invoiceIDs {
+			invoiceIDs[i] = insertDraftInvoice(t, employerID, 1000)
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, issues)
+		for i, invoiceID := range invoiceIDs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "")
+			}()
+		}
+		wg.Wait()
+
+		numbers := map[string]bool{}
+		for i, invoiceID := range invoiceIDs {
+			require.NoError(t, errs[i])
+			invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+			require.NoError(t, err)
+			numbers[invoice.InvoiceNumber] = true
+		}
+		for seq := 1; seq <= issues; seq++ {
+			assert.True(t, numbers[fmt.Sprintf("EMPL-%d-%05d", year, seq)], "missing number %d", seq)
+		}
+	})
+
+	t.Run("reissuing keeps the number", func(t *testing.T) {
+		invoiceID := insertDraftInvoice(t, employerID, 1000)
+		_, err := svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "")
+		require.NoError(t, err)
+		_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusDisputed, "hours wrong")
+		require.NoError(t, err)
+		_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "hours confirmed")
+		require.NoError(t, err)
+
+		invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("EMPL-%d-00009", year), invoice.InvoiceNumber)
+	})
+
+	invalid := []struct {
+		name  string
+		input InvoiceNumberingInput
+		field string
+	}{
+		{name: "no prefix", input: InvoiceNumberingInput{Template: "{PREFIX}-{SEQ}"}, field: "prefix"},
+		{name: "prefix with spaces", input: InvoiceNumberingInput{Prefix: "AC ME"}, field: "prefix"},
+		{name: "no sequence", input: InvoiceNumberingInput{Prefix: "ACME", Template: "{PREFIX}-{YYYY}"}, field: "template"},
+		{name: "unknown token", input: InvoiceNumberingInput{Prefix: "ACME", Template: "{PREFIX}-{DD}-{SEQ}"}, field: "template"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SetInvoiceNumbering(ctx, employerID, &tt.input)
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+
+	t.Run("a new template starts its own sequence", func(t *testing.T) {
+		now := time.Now().UTC()
+		numbering, err := svc.SetInvoiceNumbering(ctx, employerID, &InvoiceNumberingInput{Prefix: "acme", Template: "{PREFIX}/{YY}{MM}/{SEQ:3}"})
+		require.NoError(t, err)
+		assert.Equal(t, "ACME", numbering.Prefix)
+		assert.NotNil(t, numbering.UpdatedAt)
+		want := fmt.Sprintf("ACME/%s/001", now.Format("0601"))
+		assert.Equal(t, want, numbering.NextNumber)
+
+		invoiceID := insertDraftInvoice(t, employerID, 1000)
+		_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "")
+		require.NoError(t, err)
+		invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+		require.NoError(t, err)
+		assert.Equal(t, want, invoice.InvoiceNumber)
+
+		page, err := svc.FetchInvoices(ctx, employerID, InvoiceListParams{Statuses: []InvoiceStatus{InvoiceStatusIssued}, Limit: MaxInvoicePageSize})
+		require.NoError(t, err)
+		for _, inv := range page.Invoices {
+			assert.NotEmpty(t, inv.InvoiceNumber, "issued invoice %s is unnumbered", inv.ID)
+		}
+	})
+
+	t.Run("numbering belongs to the organization", func(t *testing.T) {
+		otherID := createTestUser(t, db, "Other")
+		numbering, err := svc.GetInvoiceNumbering(ctx, otherID)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("OTHE-%d-00001", year), numbering.NextNumber)
+	})
+}
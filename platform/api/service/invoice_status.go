@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusDraft         InvoiceStatus = "draft"
+	InvoiceStatusIssued        InvoiceStatus = "issued"
+	InvoiceStatusPartiallyPaid InvoiceStatus = "partially_paid"
+	InvoiceStatusPaid          InvoiceStatus = "paid"
+	InvoiceStatusVoid          InvoiceStatus = "void"
+	InvoiceStatusDisputed      InvoiceStatus = "disputed"
+	// InvoiceStatusCredited is an invoice whose charges have all been
+	// credited back by credit notes.
+	InvoiceStatusCredited InvoiceStatus = "credited"
+)
+
+// invoicePaymentTermsDays is how long after it is issued an invoice falls due.
+const invoicePaymentTermsDays = 30
+
+// overdueInvoiceStatuses are the statuses in which an invoice past its due
+// date is overdue. Disputed invoices are left alone until the dispute ends.
+var overdueInvoiceStatuses = []InvoiceStatus{InvoiceStatusIssued, InvoiceStatusPartiallyPaid}
+
+// invoiceTransitions lists the statuses each status may move to. Void and
+// credited are terminal; a paid invoice can still be credited in full, and
+// refunds take paid invoices back to partially paid or issued.
+var invoiceTransitions = map[InvoiceStatus][]InvoiceStatus{
+	InvoiceStatusDraft:         {InvoiceStatusIssued, InvoiceStatusVoid},
+	InvoiceStatusIssued:        {InvoiceStatusPartiallyPaid, InvoiceStatusPaid, InvoiceStatusDisputed, InvoiceStatusVoid, InvoiceStatusCredited},
+	InvoiceStatusPartiallyPaid: {InvoiceStatusPaid, InvoiceStatusIssued, InvoiceStatusDisputed, InvoiceStatusCredited},
+	InvoiceStatusDisputed:      {InvoiceStatusIssued, InvoiceStatusPartiallyPaid, InvoiceStatusPaid, InvoiceStatusVoid, InvoiceStatusCredited},
+	InvoiceStatusPaid:          {InvoiceStatusPartiallyPaid, InvoiceStatusIssued, InvoiceStatusCredited},
+	InvoiceStatusVoid:          {},
+	InvoiceStatusCredited:      {},
+}
+
+// manualInvoiceStatuses are the statuses TransitionInvoice may move an invoice
+// to: issuing, disputing, ending a dispute and voiding. Paid, partially paid
+// and credited follow from payments and credit notes, which also post them to
+// the ledger, so they are never set by hand.
+var manualInvoiceStatuses = []InvoiceStatus{InvoiceStatusIssued, InvoiceStatusDisputed, InvoiceStatusVoid}
+
+// Valid reports whether the status is part of the invoice lifecycle.
+func (s InvoiceStatus) Valid() bool {
+	_, ok := invoiceTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether moving from s to next is allowed.
+func (s InvoiceStatus) CanTransitionTo(next InvoiceStatus) bool {
+	return slices.Contains(invoiceTransitions[s], next)
+}
+
+// InvalidTransitionError is returned when an invoice cannot move to the
+// requested status from its current one.
+type InvalidTransitionError struct {
+	From    InvoiceStatus   `json:"from"`
+	To      InvoiceStatus   `json:"to"`
+	Allowed []InvoiceStatus `json:"allowed"`
+}
+
+func (e *InvalidTransitionError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("cannot move invoice from %s to %s: %s is final", e.From, e.To, e.From)
+	}
+	allowed := make([]string, len(e.Allowed))
+	for i, status := range e.Allowed {
+		allowed[i] = string(status)
+	}
+	return fmt.Sprintf("cannot move invoice from %s to %s: allowed next statuses are %s", e.From, e.To, strings.Join(allowed, ", "))
+}
+
+func (e *InvalidTransitionError) Unwrap() error {
+	return ErrConflict
+}
+
+// InvoiceTransition records a single status change on an invoice.
+type InvoiceTransition struct {
+	ID         string        `json:"id" db:"id"`
+	InvoiceID  string        `json:"invoice_id" db:"invoice_id"`
+	FromStatus InvoiceStatus `json:"from_status" db:"from_status"`
+	ToStatus   InvoiceStatus `json:"to_status" db:"to_status"`
+	Reason     string        `json:"reason" db:"reason"`
+	CreatedBy  string        `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+}
+
+func (s *service) TransitionInvoice(ctx context.Context, actorID string, invoiceID string, to InvoiceStatus, reason string) (*InvoiceTransition, error) {
+	if !to.Valid() {
+		return nil, newValidationError("status", fmt.Sprintf("unknown invoice status %q", to))
+	}
+	if !slices.Contains(manualInvoiceStatuses, to) {
+		return nil, newValidationError("status", fmt.Sprintf("%s is set by payments and credit notes, not by hand", to))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice, err := getInvoiceForUpdate(ctx, tx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeOrganization(ctx, tx, actorID, invoice.OrganizationID); err != nil {
+		return nil, fmt.Errorf("invoice %s: %w", invoiceID, err)
+	}
+	// Paid and partially paid invoices only reopen when their payments are
+	// refunded.
+	if to == InvoiceStatusIssued && (invoice.Status == InvoiceStatusPaid || invoice.Status == InvoiceStatusPartiallyPaid) {
+		return nil, fmt.Errorf("invoice %s is %s and only reopens when refunded: %w", invoiceID, invoice.Status, ErrConflict)
+	}
+
+	transition, err := transitionInvoice(ctx, tx, invoice, to, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transition, nil
+}
+
+func (s *service) ListInvoiceTransitions(ctx context.Context, employerID string, invoiceID string) ([]InvoiceTransition, error) {
+	if err := authorizeInvoice(ctx, s.db, employerID, invoiceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, invoice_id, from_status, to_status, COALESCE(reason, ''), created_by, created_at
+		FROM invoice_status_transitions
+		WHERE invoice_id = $1
+		ORDER BY created_at, id`,
+		invoiceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying invoice transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := []InvoiceTransition{}
+	for rows.Next() {
+		var t InvoiceTransition
+		if err := rows.Scan(&t.ID, &t.InvoiceID, &t.FromStatus, &t.ToStatus, &t.Reason, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning invoice transition row: %w", err)
+		}
+		transitions = append(transitions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invoice transition rows: %w", err)
+	}
+
+	return transitions, nil
+}
+
+// lockedInvoice is the subset of an invoice row needed to enforce the lifecycle.
+type lockedInvoice struct {
+	ID             string
+	Status         InvoiceStatus
+	Amount         money.Money
+	OrganizationID string
+	CreatedBy      string
+}
+
+func getInvoiceForUpdate(ctx context.Context, tx *sql.Tx, invoiceID string) (*lockedInvoice, error) {
+	var invoice lockedInvoice
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, status, invoice_amount, currency, organization_id, created_by FROM invoices WHERE id = $1 FOR UPDATE`,
+		invoiceID,
+	).Scan(&invoice.ID, &invoice.Status, &invoice.Amount.Amount, &invoice.Amount.Currency, &invoice.OrganizationID, &invoice.CreatedBy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("invoice %s: %w", invoiceID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching invoice with id %s: %w", invoiceID, err)
+	}
+	return &invoice, nil
+}
+
+// authorizeInvoice checks that the invoice exists and belongs to the user's
+// organization.
+func authorizeInvoice(ctx context.Context, q querier, userID string, invoiceID string) error {
+	var organizationID string
+	err := q.QueryRowContext(ctx, `SELECT organization_id FROM invoices WHERE id = $1`, invoiceID).Scan(&organizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("invoice %s: %w", invoiceID, ErrNotFound)
+		}
+		return fmt.Errorf("error fetching invoice with id %s: %w", invoiceID, err)
+	}
+	if err := authorizeOrganization(ctx, q, userID, organizationID); err != nil {
+		return fmt.Errorf("invoice %s: %w", invoiceID, err)
+	}
+	return nil
+}
+
+// transitionInvoice moves a locked invoice to a new status and records who did
+// it. All status changes must go through here.
+func transitionInvoice(ctx context.Context, tx *sql.Tx, invoice *lockedInvoice, to InvoiceStatus, actorID string, reason string) (*InvoiceTransition, error) {
+	if !invoice.Status.CanTransitionTo(to) {
+		return nil, &InvalidTransitionError{From: invoice.Status, To: to, Allowed: invoiceTransitions[invoice.Status]}
+	}
+	if to == InvoiceStatusVoid {
+		// Voiding reverses the whole invoice, which would count credited
+		// charges twice.
+		var credited bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM credit_notes WHERE invoice_id = $1)`, invoice.ID).Scan(&credited); err != nil {
+			return nil, fmt.Errorf("error checking credit notes of invoice %s: %w", invoice.ID, err)
+		}
+		if credited {
+			return nil, fmt.Errorf("invoice %s has credit notes and must be credited in full rather than voided: %w", invoice.ID, ErrConflict)
+		}
+		// Nor can it reverse a receivable that payments have already
+		// settled: the cash stays collected until it is refunded.
+		var collected int64
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(captured_amount - refunded_amount), 0) FROM payments WHERE invoice_id = $1 AND status = $2`,
+			invoice.ID, PaymentStatusSucceeded,
+		).Scan(&collected); err != nil {
+			return nil, fmt.Errorf("error summing payments of invoice %s: %w", invoice.ID, err)
+		}
+		if collected != 0 {
+			return nil, fmt.Errorf("invoice %s has %s collected and must be credited and refunded rather than voided: %w",
+				invoice.ID, money.New(collected, invoice.Amount.Currency), ErrConflict)
+		}
+	}
+
+	// Issuing starts the payment terms; an invoice reissued after a dispute
+	// keeps its original due date.
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE invoices
+		SET status = $1,
+			due_date = CASE WHEN $1 = $4 THEN COALESCE(due_date, CURRENT_DATE + $5::integer) ELSE due_date END,
+			updated_by = $2,
+			updated_at = NOW()
+		WHERE id = $3`,
+		to, actorID, invoice.ID, InvoiceStatusIssued, invoicePaymentTermsDays,
+	); err != nil {
+		return nil, fmt.Errorf("error updating invoice %s status: %w", invoice.ID, err)
+	}
+
+	transition := InvoiceTransition{
+		ID:         generateID(InvoiceTransitionPrefix),
+		InvoiceID:  invoice.ID,
+		FromStatus: invoice.Status,
+		ToStatus:   to,
+		Reason:     strings.TrimSpace(reason),
+		CreatedBy:  actorID,
+	}
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO invoice_status_transitions (id, invoice_id, from_status, to_status, reason, created_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING created_at`,
+		transition.ID, transition.InvoiceID, transition.FromStatus, transition.ToStatus, transition.Reason, transition.CreatedBy,
+	).Scan(&transition.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error recording invoice transition: %w", err)
+	}
+	if invoice.Status == InvoiceStatusDraft && to == InvoiceStatusIssued {
+		if _, err := assignInvoiceNumber(ctx, tx, invoice, transition.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: invoice.OrganizationID,
+		Action:     AuditActionTransition,
+		EntityType: AuditEntityInvoice,
+		EntityID:   invoice.ID,
+		Before:     map[string]InvoiceStatus{"status": invoice.Status},
+		After:      map[string]InvoiceStatus{"status": to},
+	}); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, invoice.OrganizationID, EventInvoiceStatusChanged, invoice.ID, transition); err != nil {
+		return nil, err
+	}
+	if eventType, ok := invoiceStatusEvents[to]; ok {
+		if err := recordEvent(ctx, tx, invoice.OrganizationID, eventType, invoice.ID, transition); err != nil {
+			return nil, err
+		}
+	}
+	if err := postInvoiceTransition(ctx, tx, invoice, to, actorID); err != nil {
+		return nil, err
+	}
+
+	invoice.Status = to
+	return &transition, nil
+}
+
+// InvoiceOverdue is the payload of invoice.overdue events.
+type InvoiceOverdue struct {
+	InvoiceID string        `json:"invoice_id"`
+	Status    InvoiceStatus `json:"status"`
+	DueDate   time.Time     `json:"due_date"`
+}
+
+// MarkOverdueInvoices flags unpaid invoices whose due date has passed and
+// records an invoice.overdue event for each. An invoice is only flagged once,
+// so it is safe to run repeatedly. It returns how many were flagged.
+func (s *service) MarkOverdueInvoices(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE invoices
+		SET overdue_at = NOW()
+		WHERE id IN (
+			SELECT id FROM invoices
+			WHERE due_date < CURRENT_DATE AND overdue_at IS NULL AND status = ANY($1)
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, organization_id, status, due_date`,
+		pq.Array(overdueInvoiceStatuses),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error flagging overdue invoices: %w", err)
+	}
+	type flagged struct {
+		organizationID string
+		overdue        InvoiceOverdue
+	}
+	var invoices []flagged
+	for rows.Next() {
+		var f flagged
+		if err := rows.Scan(&f.overdue.InvoiceID, &f.organizationID, &f.overdue.Status, &f.overdue.DueDate); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning overdue invoice: %w", err)
+		}
+		invoices = append(invoices, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating overdue invoices: %w", err)
+	}
+
+	for _, f := range invoices {
+		if err := recordEvent(ctx, tx, f.organizationID, EventInvoiceOverdue, f.overdue.InvoiceID, f.overdue); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(invoices), nil
+}
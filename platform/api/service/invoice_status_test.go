@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TransitionInvoice(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	otherEmployerID := createTestUser(t, db, "Other")
+	workerID := createTestUser(t, db, "Worker")
+
+	shift := createStaffedShift(t, svc, employerID, workerID)
+	input := runningShiftInput()
+	input.HourlyRate = 2000
+	shift, err := svc.UpdateShift(ctx, employerID, shift.ID, input)
+	require.NoError(t, err)
+	createApprovedTimesheet(t, svc, employerID, workerID, shift, 2*time.Hour)
+
+	result, err := svc.GenerateInvoices(ctx, BillingRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	require.Len(t, result.Invoices, 1)
+	invoiceID := result.Invoices[0].InvoiceID
+
+	invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusDraft, invoice.Status, "billing runs produce drafts")
+
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusDisputed, "")
+	var transitionErr *InvalidTransitionError
+	require.ErrorAs(t, err, &transitionErr, "a draft cannot be disputed before it is issued")
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, []InvoiceStatus{InvoiceStatusIssued, InvoiceStatusVoid}, transitionErr.Allowed)
+
+	_, err = svc.TransitionInvoice(ctx, otherEmployerID, invoiceID, InvoiceStatusIssued, "")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatus("refunded"), "")
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	issued, err := svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "sent to customer")
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusDraft, issued.FromStatus)
+	assert.Equal(t, InvoiceStatusIssued, issued.ToStatus)
+	assert.Equal(t, employerID, issued.CreatedBy)
+
+	for _, status := range []InvoiceStatus{InvoiceStatusPaid, InvoiceStatusPartiallyPaid, InvoiceStatusCredited} {
+		_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, status, "")
+		assert.ErrorAs(t, err, &validationErr, "%s only follows from payments and credit notes", status)
+	}
+
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusDisputed, "hours look wrong")
+	require.NoError(t, err)
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "hours confirmed")
+	require.NoError(t, err)
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusVoid, "")
+	require.NoError(t, err)
+
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "")
+	assert.ErrorAs(t, err, &transitionErr, "void invoices are final")
+
+	transitions, err := svc.ListInvoiceTransitions(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	require.Len(t, transitions, 4)
+	assert.Equal(t, "sent to customer", transitions[0].Reason)
+	assert.Equal(t, InvoiceStatusVoid, transitions[3].ToStatus)
+
+	invoice, err = svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusVoid, invoice.Status)
+
+	clearTestData(t, db)
+}
+
+func Test_InvoiceStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from     InvoiceStatus
+		to       InvoiceStatus
+		expected bool
+	}{
+		{from: InvoiceStatusDraft, to: InvoiceStatusIssued, expected: true},
+		{from: InvoiceStatusDraft, to: InvoiceStatusPaid, expected: false},
+		{from: InvoiceStatusIssued, to: InvoiceStatusPartiallyPaid, expected: true},
+		{from: InvoiceStatusPartiallyPaid, to: InvoiceStatusVoid, expected: false},
+		{from: InvoiceStatusDisputed, to: InvoiceStatusIssued, expected: true},
+		{from: InvoiceStatusPaid, to: InvoiceStatusPartiallyPaid, expected: true},
+		{from: InvoiceStatusPaid, to: InvoiceStatusIssued, expected: true},
+		{from: InvoiceStatusPaid, to: InvoiceStatusVoid, expected: false},
+		{from: InvoiceStatusPaid, to: InvoiceStatusCredited, expected: true},
+		{from: InvoiceStatusCredited, to: InvoiceStatusIssued, expected: false},
+		{from: InvoiceStatusVoid, to: InvoiceStatusDraft, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.from.CanTransitionTo(tt.to))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+func Test_GetInvoice(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	shift := createStaffedShift(t, svc, employerID, workerID)
+	input := runningShiftInput()
+	input.HourlyRate = 1800
+	shift, err := svc.UpdateShift(ctx, employerID, shift.ID, input)
+	require.NoError(t, err)
+	timesheet := createApprovedTimesheet(t, svc, employerID, workerID, shift, 6*time.Hour)
+
+	result, err := svc.GenerateInvoices(ctx, BillingRunInput{
+		PeriodStart:    today(),
+		PeriodEnd:      today(),
+		TaxBasisPoints: 825,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Invoices, 1)
+	invoiceID := result.Invoices[0].InvoiceID
+
+	t.Run("owner sees header and lines", func(t *testing.T) {
+		invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+		require.NoError(t, err)
+		require.Len(t, invoice.LineItems, 2)
+
+		labor := invoice.LineItems[0]
+		assert.Equal(t, LineItemKindLabor, labor.Kind)
+		assert.Equal(t, 6.0, labor.Quantity)
+		assert.Equal(t, money.New(1800, money.USD), labor.UnitPrice)
+		assert.Equal(t, money.New(10800, money.USD), labor.Amount)
+		assert.Equal(t, money.New(891, money.USD), labor.TaxAmount)
+		assert.Equal(t, shift.ID, labor.ShiftID)
+		assert.Equal(t, timesheet.ID, labor.TimesheetID)
+
+		fee := invoice.LineItems[1]
+		assert.Equal(t, LineItemKindPlatformFee, fee.Kind)
+		assert.Equal(t, money.New(1620, money.USD), fee.Amount)
+
+		sum := money.Zero(money.USD)
+		for _, line := range invoice.LineItems {
+			sum, err = money.Sum(money.USD, sum, line.Amount, line.TaxAmount)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, sum, invoice.InvoiceAmount)
+		assert.Equal(t, money.New(10800, money.USD), invoice.SubtotalAmount)
+		assert.Equal(t, money.New(1620, money.USD), invoice.PlatformFeeAmount)
+		assert.Equal(t, money.New(891, money.USD), invoice.TaxAmount)
+	})
+
+	t.Run("other employer is forbidden", func(t *testing.T) {
+		_, err := svc.GetInvoice(ctx, workerID, invoiceID)
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("unknown invoice", func(t *testing.T) {
+		_, err := svc.GetInvoice(ctx, employerID, "invoice_missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	clearTestData(t, db)
+}
+
+func Test_SeededInvoicesMatchTheirLines(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+
+	userID, err := svc.CreateUser(ctx, &User{
+		FirstName:   "John",
+		LastName:    "Doe",
+		Email:       "john.doe@example.com",
+		PhoneNumber: "1234567890",
+		CompanyName: "Test Company",
+	})
+	require.NoError(t, err)
+
+	var mismatched int
+	err = db.QueryRow(`
+		SELECT COUNT(*)
+		FROM invoices i
+		WHERE i.created_by = $1
+		AND i.invoice_amount <> (
+			SELECT COALESCE(SUM(l.amount + l.tax_amount), 0)
+			FROM invoice_line_items l
+			WHERE l.invoice_id = i.id
+		)`, userID).Scan(&mismatched)
+	require.NoError(t, err)
+	assert.Equal(t, 0, mismatched)
+
+	clearTestData(t, db)
+}
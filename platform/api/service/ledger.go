@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+// LedgerAccount is the code of an account in the chart of accounts.
+type LedgerAccount string
+
+const (
+	LedgerAccountCash               LedgerAccount = "cash"
+	LedgerAccountReceivable         LedgerAccount = "accounts_receivable"
+	LedgerAccountWagesPayable       LedgerAccount = "wages_payable"
+	LedgerAccountTaxPayable         LedgerAccount = "tax_payable"
+	LedgerAccountLaborRevenue       LedgerAccount = "labor_revenue"
+	LedgerAccountPlatformFeeRevenue LedgerAccount = "platform_fee_revenue"
+	LedgerAccountWorkerPay          LedgerAccount = "worker_pay"
+)
+
+type LedgerAccountType string
+
+const (
+	LedgerAccountTypeAsset     LedgerAccountType = "asset"
+	LedgerAccountTypeLiability LedgerAccountType = "liability"
+	LedgerAccountTypeRevenue   LedgerAccountType = "revenue"
+	LedgerAccountTypeExpense   LedgerAccountType = "expense"
+)
+
+// LedgerEntryType is the business event a journal entry records.
+type LedgerEntryType string
+
+const (
+	LedgerEntryInvoiceIssued   LedgerEntryType = "invoice_issued"
+	LedgerEntryInvoiceVoided   LedgerEntryType = "invoice_voided"
+	LedgerEntryCreditNote      LedgerEntryType = "credit_note_issued"
+	LedgerEntryPaymentCaptured LedgerEntryType = "payment_captured"
+	LedgerEntryPaymentRefunded LedgerEntryType = "payment_refunded"
+	LedgerEntryPayoutAccrued   LedgerEntryType = "payout_accrued"
+	LedgerEntryPayoutPaid      LedgerEntryType = "payout_paid"
+)
+
+// LedgerEntry is a journal entry: postings to accounts that sum to zero, with
+// debits positive and credits negative.
+type LedgerEntry struct {
+	ID          string          `json:"id" db:"id"`
+	Type        LedgerEntryType `json:"entry_type" db:"entry_type"`
+	ReferenceID string          `json:"reference_id" db:"reference_id"`
+	Description string          `json:"description" db:"description"`
+	Postings    []LedgerPosting `json:"postings"`
+	CreatedBy   string          `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+type LedgerPosting struct {
+	Account        LedgerAccount `json:"account" db:"account"`
+	Amount         money.Money   `json:"amount" db:"amount"`
+	OrganizationID string        `json:"organization_id,omitempty" db:"organization_id"`
+	WorkerID       string        `json:"worker_id,omitempty" db:"worker_id"`
+}
+
+// LedgerBalance is an account's postings added up. Balance is positive when
+// the account is on its normal side: debit for assets and expenses, credit
+// for liabilities and revenue.
+type LedgerBalance struct {
+	Account        LedgerAccount     `json:"account" db:"account"`
+	AccountType    LedgerAccountType `json:"account_type" db:"account_type"`
+	OrganizationID string            `json:"organization_id,omitempty" db:"organization_id"`
+	Debits         money.Money       `json:"debits" db:"debits"`
+	Credits        money.Money       `json:"credits" db:"credits"`
+	Balance        money.Money       `json:"balance" db:"balance"`
+}
+
+const (
+	defaultLedgerEntryLimit = 100
+	maxLedgerEntryLimit     = 500
+)
+
+// ledgerEntry is a journal entry waiting to be posted.
+type ledgerEntry struct {
+	Type LedgerEntryType
+	// Key names the event being recorded, so posting it again does nothing.
+	// Events that can legitimately repeat leave it empty.
+	Key         string
+	ReferenceID string
+	Description string
+	ActorID     string
+	Postings    []LedgerPosting
+}
+
+func debit(account LedgerAccount, amount money.Money, organizationID string) LedgerPosting {
+	return LedgerPosting{Account: account, Amount: amount, OrganizationID: organizationID}
+}
+
+func credit(account LedgerAccount, amount money.Money, organizationID string) LedgerPosting {
+	return LedgerPosting{Account: account, Amount: money.New(-amount.Amount, amount.Currency), OrganizationID: organizationID}
+}
+
+// postLedgerEntry records a journal entry in tx, alongside the change it
+// describes. Zero postings are dropped and an entry left with none is not
+// recorded. The database checks again at commit that every entry balances.
+func postLedgerEntry(ctx context.Context, tx *sql.Tx, entry ledgerEntry) error {
+	var postings []LedgerPosting
+	var currency money.Currency
+	var sum int64
+	for _, posting := range entry.Postings {
+		if posting.Amount.IsZero() {
+			continue
+		}
+		if currency == "" {
+			currency = posting.Amount.Currency
+		}
+		if posting.Amount.Currency != currency {
+			return fmt.Errorf("%s entry for %s mixes %s and %s: %w", entry.Type, entry.ReferenceID, currency, posting.Amount.Currency, money.ErrCurrencyMismatch)
+		}
+		sum += posting.Amount.Amount
+		postings = append(postings, posting)
+	}
+	if len(postings) == 0 {
+		return nil
+	}
+	if sum != 0 {
+		return fmt.Errorf("%s entry for %s is out of balance by %s", entry.Type, entry.ReferenceID, money.New(sum, currency))
+	}
+
+	entryID := generateID(LedgerEntryPrefix)
+	var key sql.NullString
+	if entry.Key != "" {
+		key = sql.NullString{String: entry.Key, Valid: true}
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO ledger_entries (id, entry_type, idempotency_key, reference_id, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (idempotency_key) DO NOTHING`,
+		entryID, entry.Type, key, entry.ReferenceID, entry.Description, entry.ActorID,
+	)
+	if err != nil {
+		return fmt.Errorf("error recording %s ledger entry: %w", entry.Type, err)
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("error recording %s ledger entry: %w", entry.Type, err)
+	} else if inserted == 0 {
+		return nil
+	}
+
+	for _, posting := range postings {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO ledger_postings (id, entry_id, account, amount, currency, organization_id, worker_id)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))`,
+			generateID(LedgerPostingPrefix), entryID, posting.Account, posting.Amount.Amount, posting.Amount.Currency,
+			posting.OrganizationID, posting.WorkerID,
+		); err != nil {
+			return fmt.Errorf("error posting to %s: %w", posting.Account, err)
+		}
+	}
+	return nil
+}
+
+// invoiceIssuanceEntry is the receivable an invoice creates, split into the
+// labor, fee and tax it bills. void reverses it.
+func invoiceIssuanceEntry(ctx context.Context, tx *sql.Tx, invoice *lockedInvoice, actorID string, void bool) (ledgerEntry, error) {
+	var name string
+	var fee, tax int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(invoice_number, invoice_name, id), platform_fee_amount, tax_amount FROM invoices WHERE id = $1`,
+		invoice.ID,
+	).Scan(&name, &fee, &tax); err != nil {
+		return ledgerEntry{}, fmt.Errorf("error fetching totals of invoice %s: %w", invoice.ID, err)
+	}
+	currency := invoice.Amount.Currency
+	// Whatever the total holds beyond fee and tax is labor, so the entry
+	// balances even for invoices older than the breakdown.
+	labor := invoice.Amount.Amount - fee - tax
+
+	entry := ledgerEntry{
+		Type:        LedgerEntryInvoiceIssued,
+		Key:         "invoice_issued:" + invoice.ID,
+		ReferenceID: invoice.ID,
+		Description: fmt.Sprintf("Invoice %s issued", name),
+		ActorID:     actorID,
+		Postings: []LedgerPosting{
+			debit(LedgerAccountReceivable, invoice.Amount, invoice.OrganizationID),
+			credit(LedgerAccountLaborRevenue, money.New(labor, currency), invoice.OrganizationID),
+			credit(LedgerAccountPlatformFeeRevenue, money.New(fee, currency), invoice.OrganizationID),
+			credit(LedgerAccountTaxPayable, money.New(tax, currency), invoice.OrganizationID),
+		},
+	}
+	if void {
+		entry.Type = LedgerEntryInvoiceVoided
+		entry.Key = "invoice_voided:" + invoice.ID
+		entry.Description = fmt.Sprintf("Invoice %s voided", name)
+		for i := range entry.Postings {
+			entry.Postings[i].Amount = money.New(-entry.Postings[i].Amount.Amount, currency)
+		}
+	}
+	return entry, nil
+}
+
+// postInvoiceTransition records what an invoice status change does to the
+// books: issuing a draft raises a receivable and voiding an issued invoice
+// reverses it. Other changes move no money.
+func postInvoiceTransition(ctx context.Context, tx *sql.Tx, invoice *lockedInvoice, to InvoiceStatus, actorID string) error {
+	var void bool
+	switch {
+	case invoice.Status == InvoiceStatusDraft && to == InvoiceStatusIssued:
+	case invoice.Status != InvoiceStatusDraft && to == InvoiceStatusVoid:
+		// Only what was posted can be reversed.
+		var posted bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM ledger_entries WHERE idempotency_key = $1)`,
+			"invoice_issued:"+invoice.ID,
+		).Scan(&posted); err != nil {
+			return fmt.Errorf("error checking ledger for invoice %s: %w", invoice.ID, err)
+		}
+		if !posted {
+			return nil
+		}
+		void = true
+	default:
+		return nil
+	}
+	entry, err := invoiceIssuanceEntry(ctx, tx, invoice, actorID, void)
+	if err != nil {
+		return err
+	}
+	return postLedgerEntry(ctx, tx, entry)
+}
+
+// creditNoteEntry takes what a credit note credits back off the invoice's
+// receivable and out of the revenue and tax it was booked to.
+func creditNoteEntry(note *CreditNote, actorID string) ledgerEntry {
+	return ledgerEntry{
+		Type:        LedgerEntryCreditNote,
+		Key:         "credit_note:" + note.ID,
+		ReferenceID: note.ID,
+		Description: fmt.Sprintf("Credit note against invoice %s: %s", note.InvoiceID, note.Reason),
+		ActorID:     actorID,
+		Postings: []LedgerPosting{
+			debit(LedgerAccountLaborRevenue, note.SubtotalAmount, note.OrganizationID),
+			debit(LedgerAccountPlatformFeeRevenue, note.PlatformFeeAmount, note.OrganizationID),
+			debit(LedgerAccountTaxPayable, note.TaxAmount, note.OrganizationID),
+			credit(LedgerAccountReceivable, note.Amount, note.OrganizationID),
+		},
+	}
+}
+
+// paymentEntry moves money collected against an invoice's receivable into
+// cash, or back out of it for a refund.
+func paymentEntry(payment *Payment, organizationID string, entryType LedgerEntryType, key string, amount money.Money) ledgerEntry {
+	entry := ledgerEntry{
+		Type:        entryType,
+		Key:         key,
+		ReferenceID: payment.ID,
+		Description: fmt.Sprintf("Payment captured for invoice %s", payment.InvoiceID),
+		ActorID:     PaymentsActor,
+		Postings: []LedgerPosting{
+			debit(LedgerAccountCash, amount, organizationID),
+			credit(LedgerAccountReceivable, amount, organizationID),
+		},
+	}
+	if entryType == LedgerEntryPaymentRefunded {
+		entry.Description = fmt.Sprintf("Payment refunded for invoice %s", payment.InvoiceID)
+		entry.Postings = []LedgerPosting{
+			debit(LedgerAccountReceivable, amount, organizationID),
+			credit(LedgerAccountCash, amount, organizationID),
+		}
+	}
+	return entry
+}
+
+// payoutEntry moves pay for a worker's hours between accounts, split by the
+// organization the hours were worked for.
+func payoutEntry(payout *Payout, entryType LedgerEntryType, byOrganization map[string]money.Money) ledgerEntry {
+	entry := ledgerEntry{
+		Type:        entryType,
+		ReferenceID: payout.ID,
+		ActorID:     PayoutsActor,
+	}
+	from, to := LedgerAccountWorkerPay, LedgerAccountWagesPayable
+	switch entryType {
+	case LedgerEntryPayoutAccrued:
+		entry.Description = fmt.Sprintf("Pay accrued for %s to %s", payout.PeriodStart.Format(time.DateOnly), payout.PeriodEnd.Format(time.DateOnly))
+	case LedgerEntryPayoutPaid:
+		entry.Key = "payout_paid:" + payout.ID
+		entry.Description = fmt.Sprintf("Payout for %s to %s paid", payout.PeriodStart.Format(time.DateOnly), payout.PeriodEnd.Format(time.DateOnly))
+		from, to = LedgerAccountWagesPayable, LedgerAccountCash
+	}
+	for _, organizationID := range slices.Sorted(maps.Keys(byOrganization)) {
+		amount := byOrganization[organizationID]
+		debitPosting := debit(from, amount, organizationID)
+		creditPosting := credit(to, amount, organizationID)
+		debitPosting.WorkerID, creditPosting.WorkerID = payout.WorkerID, payout.WorkerID
+		entry.Postings = append(entry.Postings, debitPosting, creditPosting)
+	}
+	return entry
+}
+
+// payoutAmountsByOrganization totals a payout's lines for each organization.
+func payoutAmountsByOrganization(lines []PayoutLine) (map[string]money.Money, error) {
+	totals := map[string]money.Money{}
+	for _, line := range lines {
+		total, ok := totals[line.OrganizationID]
+		if !ok {
+			total = money.Zero(line.Amount.Currency)
+		}
+		var err error
+		if totals[line.OrganizationID], err = total.Add(line.Amount); err != nil {
+			return nil, err
+		}
+	}
+	return totals, nil
+}
+
+// GetLedgerBalances returns the balance of each account for the employer's
+// organization.
+func (s *service) GetLedgerBalances(ctx context.Context, employerID string) ([]LedgerBalance, error) {
+	organizationID, err := organizationForUser(ctx, s.db, employerID)
+	if err != nil {
+		return nil, err
+	}
+	return queryLedgerBalances(ctx, s.db, `
+		SELECT account, account_type, organization_id, currency, debits, credits, balance
+		FROM ledger_employer_balances
+		WHERE organization_id = $1
+		ORDER BY account, currency`,
+		organizationID,
+	)
+}
+
+// ListLedgerAccountBalances returns the platform-wide balance of every account
+// and, after them, each organization's.
+func (s *service) ListLedgerAccountBalances(ctx context.Context) ([]LedgerBalance, error) {
+	accounts, err := queryLedgerBalances(ctx, s.db, `
+		SELECT account, account_type, '', currency, debits, credits, balance
+		FROM ledger_account_balances
+		ORDER BY account, currency`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	organizations, err := queryLedgerBalances(ctx, s.db, `
+		SELECT account, account_type, organization_id, currency, debits, credits, balance
+		FROM ledger_employer_balances
+		ORDER BY organization_id, account, currency`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return append(accounts, organizations...), nil
+}
+
+func queryLedgerBalances(ctx context.Context, q querier, query string, args ...interface{}) ([]LedgerBalance, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying ledger balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := []LedgerBalance{}
+	for rows.Next() {
+		var b LedgerBalance
+		var currency money.Currency
+		if err := rows.Scan(&b.Account, &b.AccountType, &b.OrganizationID, &currency,
+			&b.Debits.Amount, &b.Credits.Amount, &b.Balance.Amount); err != nil {
+			return nil, fmt.Errorf("error scanning ledger balance row: %w", err)
+		}
+		b.Debits.Currency, b.Credits.Currency, b.Balance.Currency = currency, currency, currency
+		balances = append(balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ledger balance rows: %w", err)
+	}
+	return balances, nil
+}
+
+// ListLedgerEntries returns the journal entries that post to the employer's
+// organization, newest first, with only the organization's postings.
+func (s *service) ListLedgerEntries(ctx context.Context, employerID string, limit int) ([]LedgerEntry, error) {
+	if limit <= 0 {
+		limit = defaultLedgerEntryLimit
+	}
+	if limit > maxLedgerEntryLimit {
+		return nil, newValidationError("limit", fmt.Sprintf("must be at most %d", maxLedgerEntryLimit))
+	}
+	organizationID, err := organizationForUser(ctx, s.db, employerID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT e.id, e.entry_type, e.reference_id, e.description, e.created_by, e.created_at,
+			p.account, p.amount, p.currency, COALESCE(p.worker_id, '')
+		FROM (
+			SELECT * FROM ledger_entries
+			WHERE id IN (SELECT entry_id FROM ledger_postings WHERE organization_id = $1)
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		) e
+		JOIN ledger_postings p ON p.entry_id = e.id AND p.organization_id = $1
+		ORDER BY e.created_at DESC, e.id DESC, p.account`,
+		organizationID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []LedgerEntry{}
+	for rows.Next() {
+		var entry LedgerEntry
+		var posting LedgerPosting
+		if err := rows.Scan(&entry.ID, &entry.Type, &entry.ReferenceID, &entry.Description, &entry.CreatedBy, &entry.CreatedAt,
+			&posting.Account, &posting.Amount.Amount, &posting.Amount.Currency, &posting.WorkerID); err != nil {
+			return nil, fmt.Errorf("error scanning ledger entry row: %w", err)
+		}
+		posting.OrganizationID = organizationID
+		if len(entries) == 0 || entries[len(entries)-1].ID != entry.ID {
+			entry.Postings = []LedgerPosting{}
+			entries = append(entries, entry)
+		}
+		last := &entries[len(entries)-1]
+		last.Postings = append(last.Postings, posting)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ledger entry rows: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payments"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payouts"
+)
+
+// Helper function to index ledger balances by account
+func ledgerBalances(balances []LedgerBalance) map[LedgerAccount]int64 {
+	indexed := map[LedgerAccount]int64{}
+	for _, balance := range balances {
+		indexed[balance.Account] = balance.Balance.Amount
+	}
+	return indexed
+}
+
+func Test_Ledger(t *testing.T) {
+	gateway := newTestGateway(t, "test-secret")
+	provider := payouts.NewFakeProvider(time.Hour)
+	svc := NewService(db, WithPaymentGateway(gateway), WithPayoutProvider(provider))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+	organizationID := testOrganizationID(t, db, employerID)
+
+	// New users are seeded with demo invoices; start from their ledger.
+	seeded, err := svc.GetLedgerBalances(ctx, employerID)
+	require.NoError(t, err)
+	opening := ledgerBalances(seeded)
+	assert.Positive(t, opening[LedgerAccountReceivable], "issued demo invoices are receivable")
+
+	// 4h at $25.00 plus the 15% platform fee is $115.00.
+	invoiceID := createIssuedInvoice(t, svc, employerID, workerID, 2500, 4*time.Hour)
+	payment, err := svc.CreatePayment(ctx, employerID, invoiceID, nil)
+	require.NoError(t, err)
+	captured := completePayment(t, svc, gateway, payment)
+
+	refund := payments.Event{Type: payments.EventRefundSucceeded, IntentID: captured.IntentID, Amount: money.New(2000, money.USD)}
+	payload, header, err := gateway.SignEvent(refund)
+	require.NoError(t, err)
+	require.NoError(t, svc.HandlePaymentWebhook(ctx, payload, header))
+
+	_, err = svc.GeneratePayouts(ctx, PayoutRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	_, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	payoutList, err := svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	require.Len(t, payoutList, 1)
+	require.NoError(t, provider.Settle(payoutList[0].ProviderTransferID))
+	_, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+
+	balances, err := svc.GetLedgerBalances(ctx, employerID)
+	require.NoError(t, err)
+	got := ledgerBalances(balances)
+	for _, balance := range balances {
+		assert.Equal(t, organizationID, balance.OrganizationID)
+	}
+	assert.Equal(t, opening[LedgerAccountReceivable]+2000, got[LedgerAccountReceivable], "the refund is owed again")
+	assert.Equal(t, opening[LedgerAccountLaborRevenue]+10000, got[LedgerAccountLaborRevenue])
+	assert.Equal(t, opening[LedgerAccountPlatformFeeRevenue]+1500, got[LedgerAccountPlatformFeeRevenue])
+	assert.Equal(t, int64(11500-2000-10000), got[LedgerAccountCash], "collected less refunded less paid out")
+	assert.Equal(t, int64(10000), got[LedgerAccountWorkerPay])
+	assert.Zero(t, got[LedgerAccountWagesPayable], "the payout has been paid")
+
+	entries, err := svc.ListLedgerEntries(ctx, employerID, 0)
+	require.NoError(t, err)
+	types := map[LedgerEntryType]int{}
+	for _, entry := range entries {
+		types[entry.Type]++
+		var sum int64
+		for _, posting := range entry.Postings {
+			sum += posting.Amount.Amount
+		}
+		assert.Zero(t, sum, "entry %s balances", entry.ID)
+	}
+	assert.Equal(t, 1, types[LedgerEntryPaymentCaptured])
+	assert.Equal(t, 1, types[LedgerEntryPaymentRefunded])
+	assert.Equal(t, 1, types[LedgerEntryPayoutAccrued])
+	assert.Equal(t, 1, types[LedgerEntryPayoutPaid])
+
+	t.Run("voiding reverses issuance", func(t *testing.T) {
+		_, err := svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusVoid, "")
+		require.Error(t, err, "paid invoices cannot be voided")
+
+		otherEmployerID := createTestUser(t, db, "Other Employer")
+		otherWorkerID := createTestUser(t, db, "Other Worker")
+		seeded, err := svc.GetLedgerBalances(ctx, otherEmployerID)
+		require.NoError(t, err)
+		opening := ledgerBalances(seeded)
+
+		voided := createIssuedInvoice(t, svc, otherEmployerID, otherWorkerID, 2500, 4*time.Hour)
+		issued, err := svc.GetLedgerBalances(ctx, otherEmployerID)
+		require.NoError(t, err)
+		assert.Equal(t, opening[LedgerAccountReceivable]+11500, ledgerBalances(issued)[LedgerAccountReceivable])
+
+		_, err = svc.TransitionInvoice(ctx, otherEmployerID, voided, InvoiceStatusVoid, "sent in error")
+		require.NoError(t, err)
+		reversed, err := svc.GetLedgerBalances(ctx, otherEmployerID)
+		require.NoError(t, err)
+		assert.Equal(t, opening[LedgerAccountReceivable], ledgerBalances(reversed)[LedgerAccountReceivable])
+		assert.Equal(t, opening[LedgerAccountLaborRevenue], ledgerBalances(reversed)[LedgerAccountLaborRevenue])
+	})
+
+	t.Run("invoices with payments cannot be voided", func(t *testing.T) {
+		payerID := createTestUser(t, db, "Paying Employer")
+		payerWorkerID := createTestUser(t, db, "Paying Worker")
+		seeded, err := svc.GetLedgerBalances(ctx, payerID)
+		require.NoError(t, err)
+		opening := ledgerBalances(seeded)
+
+		disputed := createIssuedInvoice(t, svc, payerID, payerWorkerID, 2500, 4*time.Hour)
+		deposit := money.New(4000, money.USD)
+		payment, err := svc.CreatePayment(ctx, payerID, disputed, &deposit)
+		require.NoError(t, err)
+		completePayment(t, svc, gateway, payment)
+		_, err = svc.TransitionInvoice(ctx, payerID, disputed, InvoiceStatusDisputed, "hours wrong")
+		require.NoError(t, err)
+
+		_, err = svc.TransitionInvoice(ctx, payerID, disputed, InvoiceStatusVoid, "")
+		assert.ErrorIs(t, err, ErrConflict, "the deposit has to be refunded first")
+
+		balances, err := svc.GetLedgerBalances(ctx, payerID)
+		require.NoError(t, err)
+		got := ledgerBalances(balances)
+		assert.Equal(t, opening[LedgerAccountReceivable]+11500-4000, got[LedgerAccountReceivable], "the receivable is not reversed")
+		assert.Equal(t, opening[LedgerAccountCash]+4000, got[LedgerAccountCash])
+		invoice, err := svc.GetInvoice(ctx, payerID, disputed)
+		require.NoError(t, err)
+		assert.Equal(t, InvoiceStatusDisputed, invoice.Status)
+	})
+
+	t.Run("platform-wide balances", func(t *testing.T) {
+		all, err := svc.ListLedgerAccountBalances(ctx)
+		require.NoError(t, err)
+		var debits, credits int64
+		for _, balance := range all {
+			if balance.OrganizationID == "" {
+				debits += balance.Debits.Amount
+				credits += balance.Credits.Amount
+			}
+		}
+		assert.Positive(t, debits)
+		assert.Equal(t, debits, credits, "the books balance")
+	})
+
+	_, err = svc.ListLedgerEntries(ctx, employerID, maxLedgerEntryLimit+1)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	clearTestData(t, db)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Location is a named place an organization staffs shifts at.
+type Location struct {
+	ID             string `json:"id" db:"id"`
+	OrganizationID string `json:"organization_id" db:"organization_id"`
+	Name           string `json:"name" db:"name"`
+	// Timezone is the IANA timezone the location's shifts are scheduled in.
+	Timezone  string    `json:"timezone" db:"timezone"`
+	CreatedBy string    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type LocationInput struct {
+	Name string `json:"name"`
+	// Timezone is an IANA timezone name; empty means DefaultTimezone.
+	Timezone string `json:"timezone"`
+}
+
+const locationColumns = `
+	id,
+	organization_id,
+	name,
+	timezone,
+	created_by,
+	created_at,
+	COALESCE(updated_at, created_at)`
+
+func scanLocation(row rowScanner) (*Location, error) {
+	var location Location
+	err := row.Scan(
+		&location.ID,
+		&location.OrganizationID,
+		&location.Name,
+		&location.Timezone,
+		&location.CreatedBy,
+		&location.CreatedAt,
+		&location.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func (s *service) CreateLocation(ctx context.Context, employerID string, input *LocationInput) (*Location, error) {
+	if input == nil {
+		return nil, newValidationError("body", "is required")
+	}
+	name, err := validateLocationName("name", input.Name)
+	if err != nil {
+		return nil, err
+	}
+	timezone, err := validateTimezone("timezone", input.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := organizationForUser(ctx, tx, employerID)
+	if err != nil {
+		return nil, err
+	}
+	location, created, err := ensureLocation(ctx, tx, organizationID, employerID, name, timezone)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, fmt.Errorf("location %q already exists: %w", name, ErrConflict)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return location, nil
+}
+
+func (s *service) ListLocations(ctx context.Context, employerID string) ([]Location, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+locationColumns+`
+		FROM locations
+		WHERE organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)
+		ORDER BY name, id`,
+		employerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying locations: %w", err)
+	}
+	defer rows.Close()
+
+	locations := []Location{}
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning location row: %w", err)
+		}
+		locations = append(locations, *location)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating location rows: %w", err)
+	}
+	return locations, nil
+}
+
+// ensureLocation returns the organization's location with the given name,
+// creating it in timezone if it does not exist yet, and reports whether it was
+// created. An existing location keeps its own timezone; an empty timezone
+// means DefaultTimezone.
+func ensureLocation(ctx context.Context, tx *sql.Tx, organizationID string, actorID string, name string, timezone string) (*Location, bool, error) {
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	location, err := scanLocation(tx.QueryRowContext(ctx, `
+		INSERT INTO locations (id, organization_id, name, timezone, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (organization_id, name) DO NOTHING
+		RETURNING`+locationColumns,
+		generateID(LocationPrefix), organizationID, name, timezone, actorID,
+	))
+	if err == nil {
+		if err := recordAudit(ctx, tx, auditRecord{
+			ActorID:    actorID,
+			EmployerID: organizationID,
+			Action:     AuditActionCreate,
+			EntityType: AuditEntityLocation,
+			EntityID:   location.ID,
+			After:      location,
+		}); err != nil {
+			return nil, false, err
+		}
+		return location, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("error creating location: %w", err)
+	}
+
+	location, err = scanLocation(tx.QueryRowContext(ctx, `
+		SELECT`+locationColumns+` FROM locations WHERE organization_id = $1 AND name = $2`,
+		organizationID, name,
+	))
+	if err != nil {
+		return nil, false, fmt.Errorf("error fetching location %q: %w", name, err)
+	}
+	return location, false, nil
+}
+
+func validateLocationName(field string, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", newValidationError(field, "is required")
+	}
+	if len(name) > 255 {
+		return "", newValidationError(field, "must be at most 255 characters")
+	}
+	return name, nil
+}
@@ -0,0 +1,478 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/notifications"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/outbox"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "pending"
+	NotificationStatusSent    NotificationStatus = "sent"
+	NotificationStatusFailed  NotificationStatus = "failed"
+)
+
+// NotificationPreference is the channels a user wants one kind of
+// notification on. Users who have not set one get email but not SMS.
+type NotificationPreference struct {
+	Type  notifications.Template `json:"type"`
+	Email bool                   `json:"email"`
+	SMS   bool                   `json:"sms"`
+}
+
+func defaultNotificationPreference(notificationType notifications.Template) NotificationPreference {
+	return NotificationPreference{Type: notificationType, Email: true}
+}
+
+// Notification is an entry in a user's delivery log. A notification is
+// retried with backoff until it is sent or runs out of attempts.
+type Notification struct {
+	ID            string                 `json:"id" db:"id"`
+	UserID        string                 `json:"user_id" db:"user_id"`
+	EventID       string                 `json:"event_id" db:"event_id"`
+	Type          notifications.Template `json:"type" db:"notification_type"`
+	Channel       notifications.Channel  `json:"channel" db:"channel"`
+	Recipient     string                 `json:"recipient" db:"recipient"`
+	Subject       string                 `json:"subject,omitempty" db:"subject"`
+	Body          string                 `json:"body" db:"body"`
+	Status        NotificationStatus     `json:"status" db:"status"`
+	Attempts      int                    `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time              `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     string                 `json:"last_error,omitempty" db:"last_error"`
+	SentAt        *time.Time             `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+}
+
+// maxNotifications bounds the delivery log returned for a user.
+const maxNotifications = 100
+
+const notificationColumns = ` id, user_id, event_id, notification_type, channel, recipient, COALESCE(subject, ''), body,
+	status, attempts, next_attempt_at, COALESCE(last_error, ''), sent_at, created_at`
+
+func scanNotification(row rowScanner) (*Notification, error) {
+	var notification Notification
+	var sentAt sql.NullTime
+	err := row.Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.EventID,
+		&notification.Type,
+		&notification.Channel,
+		&notification.Recipient,
+		&notification.Subject,
+		&notification.Body,
+		&notification.Status,
+		&notification.Attempts,
+		&notification.NextAttemptAt,
+		&notification.LastError,
+		&sentAt,
+		&notification.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	notification.SentAt = nullTimePtr(sentAt)
+	return &notification, nil
+}
+
+// GetNotificationPreferences returns the user's preference for every kind of
+// notification, filling in defaults for those they have not set.
+func (s *service) GetNotificationPreferences(ctx context.Context, userID string) ([]NotificationPreference, error) {
+	if userID == "" {
+		return nil, newValidationError("user_id", "is required")
+	}
+	return loadNotificationPreferences(ctx, s.db, userID)
+}
+
+// UpdateNotificationPreferences saves the given preferences and returns the
+// user's full set. Types left out keep their current setting.
+func (s *service) UpdateNotificationPreferences(ctx context.Context, userID string, preferences []NotificationPreference) ([]NotificationPreference, error) {
+	if userID == "" {
+		return nil, newValidationError("user_id", "is required")
+	}
+	if len(preferences) == 0 {
+		return nil, newValidationError("preferences", "is required")
+	}
+	for _, preference := range preferences {
+		if !preference.Type.Valid() {
+			return nil, newValidationError("type", fmt.Sprintf("unknown notification type %q", preference.Type))
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := loadNotificationPreferences(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, preference := range preferences {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO notification_preferences (user_id, notification_type, email, sms)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, notification_type)
+			DO UPDATE SET email = EXCLUDED.email, sms = EXCLUDED.sms, updated_at = NOW()`,
+			userID, preference.Type, preference.Email, preference.SMS,
+		); err != nil {
+			return nil, fmt.Errorf("error saving %s notification preference: %w", preference.Type, err)
+		}
+	}
+	after, err := loadNotificationPreferences(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    userID,
+		Action:     AuditActionUpdate,
+		EntityType: AuditEntityNotificationPreferences,
+		EntityID:   userID,
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// ListNotifications returns the user's most recent notifications, newest
+// first.
+func (s *service) ListNotifications(ctx context.Context, userID string) ([]Notification, error) {
+	if userID == "" {
+		return nil, newValidationError("user_id", "is required")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+notificationColumns+`
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`,
+		userID, maxNotifications,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notificationList := []Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning notification row: %w", err)
+		}
+		notificationList = append(notificationList, *notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notification rows: %w", err)
+	}
+	return notificationList, nil
+}
+
+// DispatchNotifications sends up to limit due notifications and returns how
+// many were attempted. Each one is claimed with SKIP LOCKED, so several
+// dispatchers can run at once without sending the same message twice.
+func (s *service) DispatchNotifications(ctx context.Context, limit int) (int, error) {
+	if s.notifier == nil {
+		return 0, errors.New("no notification sender is configured")
+	}
+
+	attempted := 0
+	for attempted < limit {
+		found, err := s.dispatchNextNotification(ctx)
+		if err != nil {
+			return attempted, err
+		}
+		if !found {
+			break
+		}
+		attempted++
+	}
+	return attempted, nil
+}
+
+func (s *service) dispatchNextNotification(ctx context.Context) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	notification, err := scanNotification(tx.QueryRowContext(ctx, `
+		SELECT`+notificationColumns+`
+		FROM notifications
+		WHERE status = $1 AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`,
+		NotificationStatusPending,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming notification: %w", err)
+	}
+
+	sendErr := s.notifier.Send(ctx, notifications.Message{
+		ID:      notification.ID,
+		Channel: notification.Channel,
+		To:      notification.Recipient,
+		Subject: notification.Subject,
+		Body:    notification.Body,
+	})
+	attempts := notification.Attempts + 1
+
+	status := NotificationStatusSent
+	var lastError string
+	if sendErr != nil {
+		lastError = sendErr.Error()
+		status = NotificationStatusPending
+		if attempts >= notifications.MaxAttempts {
+			status = NotificationStatusFailed
+		}
+		slog.WarnContext(ctx, "notification delivery failed",
+			"notification_id", notification.ID, "channel", notification.Channel, "attempts", attempts, "error", sendErr)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE notifications
+		SET status = $1,
+			attempts = $2,
+			next_attempt_at = NOW() + make_interval(secs => $3),
+			last_error = NULLIF($4, ''),
+			sent_at = CASE WHEN $1 = 'sent' THEN NOW() END,
+			updated_at = NOW()
+		WHERE id = $5`,
+		status, attempts, notifications.Backoff(attempts).Seconds(), lastError, notification.ID,
+	); err != nil {
+		return false, fmt.Errorf("error recording notification %s: %w", notification.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// notificationSink is the outbox sink that turns events into notifications
+// for the users they concern, on the channels each user has chosen. The
+// dispatcher sends them from there.
+type notificationSink struct {
+	db *sql.DB
+}
+
+var _ outbox.Sink = &notificationSink{}
+
+// NewNotificationSink returns the outbox sink that feeds email and SMS
+// notifications.
+func NewNotificationSink(db *sql.DB) outbox.Sink {
+	return &notificationSink{db: db}
+}
+
+func (n *notificationSink) Name() string {
+	return "notifications"
+}
+
+// notificationRecipient is a user to notify and the data for their template.
+type notificationRecipient struct {
+	userID string
+	email  string
+	phone  string
+	data   any
+}
+
+// Publish is safe to repeat: a user is only sent one message per event on
+// each channel.
+func (n *notificationSink) Publish(ctx context.Context, event outbox.Event) error {
+	var notificationType notifications.Template
+	var recipients []notificationRecipient
+	var err error
+	switch EventType(event.Type) {
+	case EventAssignmentAccepted:
+		notificationType = notifications.TemplateShiftAssigned
+		recipients, err = n.shiftAssignedRecipients(ctx, event)
+	case EventInvoiceIssued:
+		notificationType = notifications.TemplateInvoiceIssued
+		recipients, err = n.invoiceRecipients(ctx, event)
+	case EventInvoiceOverdue:
+		notificationType = notifications.TemplateInvoiceOverdue
+		recipients, err = n.invoiceRecipients(ctx, event)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range recipients {
+		preference, err := loadNotificationPreference(ctx, n.db, recipient.userID, notificationType)
+		if err != nil {
+			return err
+		}
+		addresses := map[notifications.Channel]string{}
+		if preference.Email && recipient.email != "" {
+			addresses[notifications.ChannelEmail] = recipient.email
+		}
+		if preference.SMS && recipient.phone != "" {
+			addresses[notifications.ChannelSMS] = recipient.phone
+		}
+		for _, channel := range notifications.Channels {
+			address, ok := addresses[channel]
+			if !ok {
+				continue
+			}
+			subject, body, err := notifications.Render(notificationType, channel, recipient.data)
+			if err != nil {
+				return err
+			}
+			if _, err := n.db.ExecContext(ctx, `
+				INSERT INTO notifications (id, user_id, event_id, notification_type, channel, recipient, subject, body)
+				VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+				ON CONFLICT (event_id, user_id, channel) DO NOTHING`,
+				generateID(NotificationPrefix), recipient.userID, event.DedupeKey, notificationType, channel, address, subject, body,
+			); err != nil {
+				return fmt.Errorf("error queueing notification: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// shiftAssignedRecipients notifies the worker of an accepted assignment.
+func (n *notificationSink) shiftAssignedRecipients(ctx context.Context, event outbox.Event) ([]notificationRecipient, error) {
+	var assignment ShiftAssignment
+	if err := json.Unmarshal(event.Payload, &assignment); err != nil {
+		return nil, fmt.Errorf("error decoding %s event: %w", event.Type, err)
+	}
+	shift, err := scanShift(n.db.QueryRowContext(ctx, `SELECT`+shiftColumns+` FROM shifts WHERE id = $1`, assignment.ShiftID))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching shift with id %s: %w", assignment.ShiftID, err)
+	}
+
+	recipient := notificationRecipient{userID: assignment.WorkerID}
+	var firstName string
+	if err := n.db.QueryRowContext(ctx, `
+		SELECT COALESCE(first_name, ''), COALESCE(email, ''), COALESCE(phone_number, '') FROM users WHERE id = $1`,
+		assignment.WorkerID,
+	).Scan(&firstName, &recipient.email, &recipient.phone); err != nil {
+		return nil, fmt.Errorf("error fetching user with id %s: %w", assignment.WorkerID, err)
+	}
+	recipient.data = notifications.ShiftAssignedData{
+		RecipientName: firstName,
+		ShiftName:     shift.ShiftName,
+		Location:      shift.Location,
+		StartsAt:      shift.StartsAtLocal,
+		EndsAt:        shift.EndsAtLocal,
+	}
+	return []notificationRecipient{recipient}, nil
+}
+
+// invoiceRecipients notifies the owners of the organization an invoice bills.
+func (n *notificationSink) invoiceRecipients(ctx context.Context, event outbox.Event) ([]notificationRecipient, error) {
+	var invoice notifications.InvoiceData
+	var organizationID string
+	var dueDate sql.NullTime
+	if err := n.db.QueryRowContext(ctx, `
+		SELECT id, COALESCE(invoice_name, ''), invoice_amount, currency, due_date, organization_id
+		FROM invoices
+		WHERE id = $1`,
+		event.AggregateID,
+	).Scan(&invoice.InvoiceID, &invoice.InvoiceName, &invoice.Amount.Amount, &invoice.Amount.Currency, &dueDate, &organizationID); err != nil {
+		return nil, fmt.Errorf("error fetching invoice with id %s: %w", event.AggregateID, err)
+	}
+	if dueDate.Valid {
+		invoice.DueDate = dueDate.Time
+	}
+
+	rows, err := n.db.QueryContext(ctx, `
+		SELECT u.id, COALESCE(u.first_name, ''), COALESCE(u.email, ''), COALESCE(u.phone_number, '')
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 AND m.role = $2
+		ORDER BY u.id`,
+		organizationID, MemberRoleOwner,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying organization owners: %w", err)
+	}
+	defer rows.Close()
+
+	var recipients []notificationRecipient
+	for rows.Next() {
+		var recipient notificationRecipient
+		data := invoice
+		if err := rows.Scan(&recipient.userID, &data.RecipientName, &recipient.email, &recipient.phone); err != nil {
+			return nil, fmt.Errorf("error scanning organization owner row: %w", err)
+		}
+		recipient.data = data
+		recipients = append(recipients, recipient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization owner rows: %w", err)
+	}
+	return recipients, nil
+}
+
+func loadNotificationPreferences(ctx context.Context, q querier, userID string) ([]NotificationPreference, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT notification_type, email, sms FROM notification_preferences WHERE user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	saved := map[notifications.Template]NotificationPreference{}
+	for rows.Next() {
+		var preference NotificationPreference
+		if err := rows.Scan(&preference.Type, &preference.Email, &preference.SMS); err != nil {
+			return nil, fmt.Errorf("error scanning notification preference row: %w", err)
+		}
+		saved[preference.Type] = preference
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notification preference rows: %w", err)
+	}
+
+	preferences := make([]NotificationPreference, 0, len(notifications.Templates))
+	for _, notificationType := range notifications.Templates {
+		preference, ok := saved[notificationType]
+		if !ok {
+			preference = defaultNotificationPreference(notificationType)
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
+
+func loadNotificationPreference(ctx context.Context, q querier, userID string, notificationType notifications.Template) (NotificationPreference, error) {
+	preference := NotificationPreference{Type: notificationType}
+	err := q.QueryRowContext(ctx, `
+		SELECT email, sms FROM notification_preferences WHERE user_id = $1 AND notification_type = $2`,
+		userID, notificationType,
+	).Scan(&preference.Email, &preference.SMS)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultNotificationPreference(notificationType), nil
+	}
+	if err != nil {
+		return preference, fmt.Errorf("error fetching %s notification preference: %w", notificationType, err)
+	}
+	return preference, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/notifications"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/outbox"
+)
+
+// Helper function to relay pending outbox events into notifications
+func relayNotificationEvents(t *testing.T) {
+	_, err := outbox.NewRelay(db, NewNotificationSink(db)).ProcessBatch(context.Background(), 1000)
+	require.NoError(t, err)
+}
+
+func Test_NotificationPreferences(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	userID := createTestUser(t, db, "Worker")
+
+	preferences, err := svc.GetNotificationPreferences(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, preferences, len(notifications.Templates))
+	for _, preference := range preferences {
+		assert.True(t, preference.Email, "email is on by default")
+		assert.False(t, preference.SMS, "sms is off by default")
+	}
+
+	saved, err := svc.UpdateNotificationPreferences(ctx, userID, []NotificationPreference{
+		{Type: notifications.TemplateShiftAssigned, Email: false, SMS: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, NotificationPreference{Type: notifications.TemplateShiftAssigned, SMS: true}, saved[0])
+	assert.True(t, saved[1].Email, "omitted types keep their defaults")
+
+	_, err = svc.UpdateNotificationPreferences(ctx, userID, []NotificationPreference{{Type: "shift_exploded"}})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	clearTestData(t, db)
+}
+
+func Test_NotificationDelivery(t *testing.T) {
+	capture := notifications.NewCaptureSender()
+	svc := NewService(db, WithNotificationSender(capture))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	_, err := svc.UpdateNotificationPreferences(ctx, workerID, []NotificationPreference{
+		{Type: notifications.TemplateShiftAssigned, Email: true, SMS: true},
+	})
+	require.NoError(t, err)
+
+	invoiceID := createIssuedInvoice(t, svc, employerID, workerID, 2500, 4*time.Hour)
+	relayNotificationEvents(t)
+	relayNotificationEvents(t)
+
+	workerNotifications, err := svc.ListNotifications(ctx, workerID)
+	require.NoError(t, err)
+	require.Len(t, workerNotifications, 2, "relaying twice does not queue duplicates")
+	employerNotifications, err := svc.ListNotifications(ctx, employerID)
+	require.NoError(t, err)
+	require.Len(t, employerNotifications, 1)
+	assert.Equal(t, notifications.TemplateInvoiceIssued, employerNotifications[0].Type)
+	assert.Equal(t, notifications.ChannelEmail, employerNotifications[0].Channel)
+	assert.Equal(t, NotificationStatusPending, employerNotifications[0].Status)
+
+	attempted, err := svc.DispatchNotifications(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempted)
+
+	messages := capture.Messages()
+	require.Len(t, messages, 3)
+	channels := map[notifications.Channel]int{}
+	for _, message := range messages {
+		channels[message.Channel]++
+	}
+	assert.Equal(t, 2, channels[notifications.ChannelEmail])
+	assert.Equal(t, 1, channels[notifications.ChannelSMS])
+
+	employerNotifications, err = svc.ListNotifications(ctx, employerID)
+	require.NoError(t, err)
+	assert.Equal(t, NotificationStatusSent, employerNotifications[0].Status)
+	assert.NotNil(t, employerNotifications[0].SentAt)
+	assert.Contains(t, employerNotifications[0].Body, invoiceID)
+
+	t.Run("overdue invoices notify the owners and retry failed sends", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE invoices SET due_date = CURRENT_DATE - 1 WHERE id = $1`, invoiceID)
+		require.NoError(t, err)
+		marked, err := svc.MarkOverdueInvoices(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, marked)
+		marked, err = svc.MarkOverdueInvoices(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, marked, "an invoice is only marked overdue once")
+		relayNotificationEvents(t)
+
+		capture.SetError(errors.New("mailbox full"))
+		attempted, err := svc.DispatchNotifications(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+
+		employerNotifications, err := svc.ListNotifications(ctx, employerID)
+		require.NoError(t, err)
+		var overdue *Notification
+		for i := range employerNotifications {
+			if employerNotifications[i].Type == notifications.TemplateInvoiceOverdue {
+				overdue = &employerNotifications[i]
+			}
+		}
+		require.NotNil(t, overdue)
+		assert.Equal(t, NotificationStatusPending, overdue.Status)
+		assert.Equal(t, 1, overdue.Attempts)
+		assert.Equal(t, "mailbox full", overdue.LastError)
+		assert.True(t, overdue.NextAttemptAt.After(time.Now()))
+
+		attempted, err = svc.DispatchNotifications(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted, "the retry is not due yet")
+
+		_, err = db.Exec(`UPDATE notifications SET next_attempt_at = NOW() WHERE id = $1`, overdue.ID)
+		require.NoError(t, err)
+		capture.SetError(nil)
+		attempted, err = svc.DispatchNotifications(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+		messages := capture.Messages()
+		assert.Equal(t, overdue.ID, messages[len(messages)-1].ID)
+	})
+	clearTestData(t, db)
+}
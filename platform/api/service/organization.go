@@ -0,0 +1,641 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
+)
+
+type MemberRole string
+
+const (
+	// MemberRoleOwner members manage who else belongs to the organization.
+	MemberRoleOwner  MemberRole = "owner"
+	MemberRoleMember MemberRole = "member"
+)
+
+func (r MemberRole) Valid() bool {
+	return r == MemberRoleOwner || r == MemberRoleMember
+}
+
+// Organization is an employer. Its members share its shifts, invoices and
+// locations.
+type Organization struct {
+	ID        string               `json:"id" db:"id"`
+	Name      string               `json:"name" db:"name"`
+	CreatedBy string               `json:"created_by" db:"created_by"`
+	CreatedAt time.Time            `json:"created_at" db:"created_at"`
+	Members   []OrganizationMember `json:"members"`
+}
+
+// OrganizationMember is a user's membership of an organization.
+type OrganizationMember struct {
+	OrganizationID string     `json:"organization_id" db:"organization_id"`
+	UserID         string     `json:"user_id" db:"user_id"`
+	FirstName      string     `json:"first_name" db:"first_name"`
+	LastName       string     `json:"last_name" db:"last_name"`
+	Email          string     `json:"email" db:"email"`
+	Role           MemberRole `json:"role" db:"role"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// OrganizationMemberInput invites an existing user to the caller's
+// organization.
+type OrganizationMemberInput struct {
+	Email string     `json:"email"`
+	Role  MemberRole `json:"role"`
+}
+
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "pending"
+	InvitationStatusAccepted InvitationStatus = "accepted"
+	InvitationStatusDeclined InvitationStatus = "declined"
+)
+
+// OrganizationInvitation asks a user to join an organization with Role. They
+// only become a member once they accept it.
+type OrganizationInvitation struct {
+	ID             string           `json:"id" db:"id"`
+	OrganizationID string           `json:"organization_id" db:"organization_id"`
+	UserID         string           `json:"user_id" db:"user_id"`
+	Role           MemberRole       `json:"role" db:"role"`
+	Status         InvitationStatus `json:"status" db:"status"`
+	CreatedBy      string           `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+const organizationInvitationColumns = `id, organization_id, user_id, role, status, created_by, created_at, COALESCE(updated_at, created_at)`
+
+func scanOrganizationInvitation(row rowScanner) (*OrganizationInvitation, error) {
+	var invitation OrganizationInvitation
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.OrganizationID,
+		&invitation.UserID,
+		&invitation.Role,
+		&invitation.Status,
+		&invitation.CreatedBy,
+		&invitation.CreatedAt,
+		&invitation.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+const organizationMemberColumns = `
+	m.organization_id,
+	m.user_id,
+	u.first_name,
+	u.last_name,
+	u.email,
+	m.role,
+	m.created_at`
+
+func scanOrganizationMember(row rowScanner) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := row.Scan(
+		&member.OrganizationID,
+		&member.UserID,
+		&member.FirstName,
+		&member.LastName,
+		&member.Email,
+		&member.Role,
+		&member.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetOrganization returns the caller's organization and its members.
+func (s *service) GetOrganization(ctx context.Context, userID string) (*Organization, error) {
+	organizationID, err := organizationForUser(ctx, s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var org Organization
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT id, name, created_by, created_at FROM organizations WHERE id = $1`,
+		organizationID,
+	).Scan(&org.ID, &org.Name, &org.CreatedBy, &org.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error fetching organization with id %s: %w", organizationID, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+organizationMemberColumns+`
+		FROM organization_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at, m.user_id`,
+		organizationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying organization members: %w", err)
+	}
+	defer rows.Close()
+
+	org.Members = []OrganizationMember{}
+	for rows.Next() {
+		member, err := scanOrganizationMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning organization member row: %w", err)
+		}
+		org.Members = append(org.Members, *member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization member rows: %w", err)
+	}
+	return &org, nil
+}
+
+// InviteOrganizationMember invites the user with the given email to the
+// owner's organization. Nothing changes for them until they accept.
+func (s *service) InviteOrganizationMember(ctx context.Context, actorID string, input *OrganizationMemberInput) (*OrganizationInvitation, error) {
+	if input == nil {
+		return nil, newValidationError("body", "is required")
+	}
+	email := strings.TrimSpace(input.Email)
+	if email == "" {
+		return nil, newValidationError("email", "is required")
+	}
+	if input.Role == "" {
+		input.Role = MemberRoleMember
+	}
+	if !input.Role.Valid() {
+		return nil, newValidationError("role", fmt.Sprintf("unknown role %q", input.Role))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := lockOrganizationAsOwner(ctx, tx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userID string
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE email = $1`, email).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user with email %s: %w", email, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching user with email %s: %w", email, err)
+	}
+	member, err := isOrganizationMember(ctx, tx, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, fmt.Errorf("user %s is already a member: %w", userID, ErrConflict)
+	}
+	var pending bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM organization_invitations WHERE organization_id = $1 AND user_id = $2 AND status = $3)`,
+		organizationID, userID, InvitationStatusPending,
+	).Scan(&pending); err != nil {
+		return nil, fmt.Errorf("error checking organization invitations: %w", err)
+	}
+	if pending {
+		return nil, fmt.Errorf("user %s is already invited: %w", userID, ErrConflict)
+	}
+
+	invitation, err := scanOrganizationInvitation(tx.QueryRowContext(ctx, `
+		INSERT INTO organization_invitations (id, organization_id, user_id, role, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+organizationInvitationColumns,
+		generateID(OrganizationInvitationPrefix), organizationID, userID, input.Role, InvitationStatusPending, actorID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating organization invitation: %w", err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: organizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityOrganizationInvitation,
+		EntityID:   invitation.ID,
+		After:      invitation,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return invitation, nil
+}
+
+// ListOrganizationInvitations returns the invitations waiting on the caller.
+func (s *service) ListOrganizationInvitations(ctx context.Context, userID string) ([]OrganizationInvitation, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+organizationInvitationColumns+`
+		FROM organization_invitations
+		WHERE user_id = $1 AND status = $2
+		ORDER BY created_at, id`,
+		userID, InvitationStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying organization invitations: %w", err)
+	}
+	defer rows.Close()
+
+	result := []OrganizationInvitation{}
+	for rows.Next() {
+		invitation, err := scanOrganizationInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning organization invitation row: %w", err)
+		}
+		result = append(result, *invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization invitation rows: %w", err)
+	}
+	return result, nil
+}
+
+// AcceptOrganizationInvitation moves the caller into the organization that
+// invited them. Users belong to one organization, so they leave their current
+// one, but only if it is theirs alone and holds no invoices, shifts or
+// locations; anything else they must wind down or hand over first.
+func (s *service) AcceptOrganizationInvitation(ctx context.Context, userID string, invitationID string) (*OrganizationMember, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invitation, err := lockPendingInvitation(ctx, tx, userID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM organizations WHERE id = $1 FOR UPDATE`, invitation.OrganizationID); err != nil {
+		return nil, fmt.Errorf("error locking organization %s: %w", invitation.OrganizationID, err)
+	}
+	if err := leaveEmptyOrganization(ctx, tx, userID, invitation.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role, created_by)
+		VALUES ($1, $2, $3, $4)`,
+		invitation.OrganizationID, userID, invitation.Role, invitation.CreatedBy,
+	); err != nil {
+		return nil, fmt.Errorf("error adding organization member: %w", err)
+	}
+	member, err := getOrganizationMember(ctx, tx, invitation.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    userID,
+		EmployerID: invitation.OrganizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityOrganizationMember,
+		EntityID:   userID,
+		After:      member,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := settleInvitation(ctx, tx, userID, invitation, InvitationStatusAccepted, AuditActionAccept); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return member, nil
+}
+
+// DeclineOrganizationInvitation turns down an invitation; the caller stays
+// where they are.
+func (s *service) DeclineOrganizationInvitation(ctx context.Context, userID string, invitationID string) (*OrganizationInvitation, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invitation, err := lockPendingInvitation(ctx, tx, userID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	declined, err := settleInvitation(ctx, tx, userID, invitation, InvitationStatusDeclined, AuditActionDecline)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return declined, nil
+}
+
+// RemoveOrganizationMember removes a user from the caller's organization.
+// Owners can remove anyone and members can remove themselves, but the last
+// owner cannot leave.
+func (s *service) RemoveOrganizationMember(ctx context.Context, actorID string, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, role, err := lockOrganization(ctx, tx, actorID)
+	if err != nil {
+		return err
+	}
+	if role != MemberRoleOwner && actorID != userID {
+		return fmt.Errorf("only owners can remove other members: %w", ErrForbidden)
+	}
+	member, err := getOrganizationMember(ctx, tx, organizationID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == MemberRoleOwner {
+		var owners int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2`,
+			organizationID, MemberRoleOwner,
+		).Scan(&owners); err != nil {
+			return fmt.Errorf("error counting organization owners: %w", err)
+		}
+		if owners == 1 {
+			return fmt.Errorf("cannot remove the last owner of organization %s: %w", organizationID, ErrConflict)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`,
+		organizationID, userID,
+	); err != nil {
+		return fmt.Errorf("error removing organization member %s: %w", userID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: organizationID,
+		Action:     AuditActionDelete,
+		EntityType: AuditEntityOrganizationMember,
+		EntityID:   userID,
+		Before:     member,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// lockPendingInvitation locks an invitation addressed to userID. Other
+// users' invitations are reported as not found.
+func lockPendingInvitation(ctx context.Context, tx *sql.Tx, userID string, invitationID string) (*OrganizationInvitation, error) {
+	invitation, err := scanOrganizationInvitation(tx.QueryRowContext(ctx, `
+		SELECT `+organizationInvitationColumns+`
+		FROM organization_invitations
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE`,
+		invitationID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("organization invitation %s: %w", invitationID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching organization invitation %s: %w", invitationID, err)
+	}
+	if invitation.Status != InvitationStatusPending {
+		return nil, fmt.Errorf("organization invitation %s is %s: %w", invitationID, invitation.Status, ErrConflict)
+	}
+	return invitation, nil
+}
+
+// settleInvitation records the invitee's answer to a pending invitation.
+func settleInvitation(ctx context.Context, tx *sql.Tx, userID string, invitation *OrganizationInvitation, status InvitationStatus, action AuditAction) (*OrganizationInvitation, error) {
+	settled, err := scanOrganizationInvitation(tx.QueryRowContext(ctx, `
+		UPDATE organization_invitations SET status = $1, updated_by = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING `+organizationInvitationColumns,
+		status, userID, invitation.ID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error updating organization invitation %s: %w", invitation.ID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    userID,
+		EmployerID: invitation.OrganizationID,
+		Action:     action,
+		EntityType: AuditEntityOrganizationInvitation,
+		EntityID:   invitation.ID,
+		Before:     invitation,
+		After:      settled,
+	}); err != nil {
+		return nil, err
+	}
+	return settled, nil
+}
+
+// leaveEmptyOrganization removes userID from the organization they belong
+// to, if any, ahead of joining organizationID. It fails with ErrConflict
+// unless they are its only member and it owns no invoices, shifts or
+// locations, so nobody's records are left without an organization.
+func leaveEmptyOrganization(ctx context.Context, tx *sql.Tx, userID string, organizationID string) error {
+	var currentID string
+	var members int
+	var owned bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			m.organization_id,
+			(SELECT COUNT(*) FROM organization_members o WHERE o.organization_id = m.organization_id),
+			EXISTS (SELECT 1 FROM invoices i WHERE i.organization_id = m.organization_id)
+				OR EXISTS (SELECT 1 FROM shifts s WHERE s.organization_id = m.organization_id)
+				OR EXISTS (SELECT 1 FROM locations l WHERE l.organization_id = m.organization_id)
+		FROM organization_members m
+		WHERE m.user_id = $1
+		FOR UPDATE OF m`,
+		userID,
+	).Scan(&currentID, &members, &owned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching organization of user %s: %w", userID, err)
+	}
+	if currentID == organizationID {
+		return fmt.Errorf("user %s is already a member: %w", userID, ErrConflict)
+	}
+	if members > 1 {
+		return fmt.Errorf("user %s belongs to another organization: %w", userID, ErrConflict)
+	}
+	if owned {
+		return fmt.Errorf("organization %s of user %s owns invoices, shifts or locations: %w", currentID, userID, ErrConflict)
+	}
+
+	previous, err := getOrganizationMember(ctx, tx, currentID, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`,
+		currentID, userID,
+	); err != nil {
+		return fmt.Errorf("error removing user %s from organization %s: %w", userID, currentID, err)
+	}
+	return recordAudit(ctx, tx, auditRecord{
+		ActorID:    userID,
+		EmployerID: currentID,
+		Action:     AuditActionDelete,
+		EntityType: AuditEntityOrganizationMember,
+		EntityID:   userID,
+		Before:     previous,
+	})
+}
+
+// createOrganization creates an organization owned by ownerID.
+func createOrganization(ctx context.Context, tx *sql.Tx, name string, ownerID string) (*Organization, error) {
+	org := Organization{
+		ID:        generateID(OrganizationPrefix),
+		Name:      strings.TrimSpace(name),
+		CreatedBy: ownerID,
+	}
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO organizations (id, name, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING created_at`,
+		org.ID, org.Name, ownerID,
+	).Scan(&org.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error creating organization: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role, created_by)
+		VALUES ($1, $2, $3, $2)`,
+		org.ID, ownerID, MemberRoleOwner,
+	); err != nil {
+		return nil, fmt.Errorf("error adding organization owner: %w", err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    ownerID,
+		EmployerID: org.ID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityOrganization,
+		EntityID:   org.ID,
+		After:      org,
+	}); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// organizationForUser returns the organization the user belongs to. Users
+// outside any organization cannot act as an employer.
+func organizationForUser(ctx context.Context, q querier, userID string) (string, error) {
+	var organizationID string
+	err := q.QueryRowContext(ctx, `
+		SELECT organization_id FROM organization_members WHERE user_id = $1`,
+		userID,
+	).Scan(&organizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("user %s does not belong to an organization: %w", userID, ErrForbidden)
+		}
+		return "", fmt.Errorf("error fetching organization for user %s: %w", userID, err)
+	}
+	return organizationID, nil
+}
+
+// authorizeOrganization checks that the user is a member of the organization
+// that owns a record.
+func authorizeOrganization(ctx context.Context, q querier, userID string, organizationID string) error {
+	member, err := isOrganizationMember(ctx, q, userID, organizationID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// authorizePlatform checks that the caller's token grants a permission only
+// platform staff hold. Those callers act for the platform, so the records
+// they touch are not limited to their own organization's.
+func authorizePlatform(ctx context.Context, permission middleware.Permission) error {
+	if !middleware.ClaimsFromContext(ctx).HasPermission(permission) {
+		return fmt.Errorf("%s is reserved for platform staff: %w", permission, ErrForbidden)
+	}
+	return nil
+}
+
+func isOrganizationMember(ctx context.Context, q querier, userID string, organizationID string) (bool, error) {
+	var member bool
+	if err := q.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM organization_members WHERE organization_id = $1 AND user_id = $2)`,
+		organizationID, userID,
+	).Scan(&member); err != nil {
+		return false, fmt.Errorf("error checking organization membership: %w", err)
+	}
+	return member, nil
+}
+
+// lockOrganization locks the user's organization row, serialising membership
+// changes, and returns it with the user's role.
+func lockOrganization(ctx context.Context, tx *sql.Tx, userID string) (string, MemberRole, error) {
+	var organizationID string
+	var role MemberRole
+	err := tx.QueryRowContext(ctx, `
+		SELECT o.id, m.role
+		FROM organization_members m
+		JOIN organizations o ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		FOR UPDATE OF o`,
+		userID,
+	).Scan(&organizationID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", fmt.Errorf("user %s does not belong to an organization: %w", userID, ErrForbidden)
+		}
+		return "", "", fmt.Errorf("error fetching organization for user %s: %w", userID, err)
+	}
+	return organizationID, role, nil
+}
+
+func lockOrganizationAsOwner(ctx context.Context, tx *sql.Tx, userID string) (string, error) {
+	organizationID, role, err := lockOrganization(ctx, tx, userID)
+	if err != nil {
+		return "", err
+	}
+	if role != MemberRoleOwner {
+		return "", fmt.Errorf("only owners can manage organization %s: %w", organizationID, ErrForbidden)
+	}
+	return organizationID, nil
+}
+
+func getOrganizationMember(ctx context.Context, q querier, organizationID string, userID string) (*OrganizationMember, error) {
+	member, err := scanOrganizationMember(q.QueryRowContext(ctx, `
+		SELECT`+organizationMemberColumns+`
+		FROM organization_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.organization_id = $1 AND m.user_id = $2`,
+		organizationID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("organization member %s: %w", userID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching organization member %s: %w", userID, err)
+	}
+	return member, nil
+}
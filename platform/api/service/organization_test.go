@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_OrganizationMembers(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+
+	ownerID, err := svc.CreateUser(ctx, &User{
+		FirstName:   "John",
+		LastName:    "Doe",
+		Email:       "john.doe@example.com",
+		PhoneNumber: "1234567890",
+		CompanyName: "Test Company",
+	})
+	require.NoError(t, err)
+	colleagueID := createTestUser(t, db, "Colleague")
+	outsiderID := createTestUser(t, db, "Outsider")
+
+	owner, err := svc.GetUserByID(ctx, ownerID)
+	require.NoError(t, err)
+	organizationID := owner.OrganizationID
+	require.NotEmpty(t, organizationID)
+
+	invitation, err := svc.InviteOrganizationMember(ctx, ownerID, &OrganizationMemberInput{Email: colleagueID + "@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, InvitationStatusPending, invitation.Status)
+	assert.NotEqual(t, organizationID, testOrganizationID(t, db, colleagueID), "inviting moves nobody")
+
+	_, err = svc.InviteOrganizationMember(ctx, ownerID, &OrganizationMemberInput{Email: colleagueID + "@example.com"})
+	assert.ErrorIs(t, err, ErrConflict, "one open invitation per user")
+
+	_, err = svc.InviteOrganizationMember(ctx, colleagueID, &OrganizationMemberInput{Email: outsiderID + "@example.com"})
+	assert.ErrorIs(t, err, ErrForbidden, "only owners can invite members")
+
+	_, err = svc.InviteOrganizationMember(ctx, ownerID, &OrganizationMemberInput{Email: "nobody@example.com"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = svc.AcceptOrganizationInvitation(ctx, outsiderID, invitation.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "only the invited user can accept")
+
+	invitations, err := svc.ListOrganizationInvitations(ctx, colleagueID)
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	assert.Equal(t, invitation.ID, invitations[0].ID)
+
+	member, err := svc.AcceptOrganizationInvitation(ctx, colleagueID, invitation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, MemberRoleMember, member.Role)
+	assert.Equal(t, organizationID, member.OrganizationID)
+
+	_, err = svc.AcceptOrganizationInvitation(ctx, colleagueID, invitation.ID)
+	assert.ErrorIs(t, err, ErrConflict, "invitations are answered once")
+
+	_, err = svc.InviteOrganizationMember(ctx, ownerID, &OrganizationMemberInput{Email: colleagueID + "@example.com"})
+	assert.ErrorIs(t, err, ErrConflict, "members need no invitation")
+
+	t.Run("colleagues share invoices and shifts", func(t *testing.T) {
+		colleague, err := svc.GetUserByID(ctx, colleagueID)
+		require.NoError(t, err)
+		assert.Equal(t, organizationID, colleague.OrganizationID)
+		assert.Equal(t, "Test Company", colleague.CompanyName)
+
+		ownerInvoices, err := svc.FetchInvoices(ctx, ownerID, InvoiceListParams{})
+		require.NoError(t, err)
+		colleagueInvoices, err := svc.FetchInvoices(ctx, colleagueID, InvoiceListParams{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, colleagueInvoices.Invoices)
+		assert.Equal(t, ownerInvoices.Invoices, colleagueInvoices.Invoices)
+
+		shift, err := svc.CreateShift(ctx, colleagueID, validShiftInput())
+		require.NoError(t, err)
+		assert.Equal(t, organizationID, shift.OrganizationID)
+		_, err = svc.GetShift(ctx, ownerID, shift.ID)
+		assert.NoError(t, err)
+		_, err = svc.GetShift(ctx, outsiderID, shift.ID)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		locations, err := svc.ListLocations(ctx, ownerID)
+		require.NoError(t, err)
+		var names []string
+		for _, location := range locations {
+			names = append(names, location.Name)
+		}
+		assert.Contains(t, names, shift.Location, "shift locations are shared")
+	})
+
+	t.Run("only owners remove others and the last owner stays", func(t *testing.T) {
+		err := svc.RemoveOrganizationMember(ctx, colleagueID, ownerID)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		err = svc.RemoveOrganizationMember(ctx, ownerID, ownerID)
+		assert.ErrorIs(t, err, ErrConflict)
+
+		err = svc.RemoveOrganizationMember(ctx, ownerID, colleagueID)
+		require.NoError(t, err)
+
+		org, err := svc.GetOrganization(ctx, ownerID)
+		require.NoError(t, err)
+		require.Len(t, org.Members, 1)
+		assert.Equal(t, ownerID, org.Members[0].UserID)
+
+		_, err = svc.FetchInvoices(ctx, colleagueID, InvoiceListParams{})
+		require.NoError(t, err)
+		_, err = svc.CreateShift(ctx, colleagueID, validShiftInput())
+		assert.ErrorIs(t, err, ErrForbidden, "former members no longer act for the organization")
+	})
+
+	clearTestData(t, db)
+}
+
+func Test_OrganizationInvitationKeepsRecordsWithTheirOrganization(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	ownerID := createTestUser(t, db, "Owner")
+	employerID := createTestUser(t, db, "Employer")
+	employerOrganizationID := testOrganizationID(t, db, employerID)
+
+	_, err := svc.CreateLocation(ctx, employerID, &LocationInput{Name: "Harbor Depot"})
+	require.NoError(t, err)
+
+	invitation, err := svc.InviteOrganizationMember(ctx, ownerID, &OrganizationMemberInput{Email: employerID + "@example.com"})
+	require.NoError(t, err)
+	_, err = svc.AcceptOrganizationInvitation(ctx, employerID, invitation.ID)
+	assert.ErrorIs(t, err, ErrConflict, "an organization with records of its own is not abandoned")
+	assert.Equal(t, employerOrganizationID, testOrganizationID(t, db, employerID), "the failed accept keeps the membership")
+
+	declined, err := svc.DeclineOrganizationInvitation(ctx, employerID, invitation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, InvitationStatusDeclined, declined.Status)
+	assert.Equal(t, employerOrganizationID, testOrganizationID(t, db, employerID))
+
+	invitations, err := svc.ListOrganizationInvitations(ctx, employerID)
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	clearTestData(t, db)
+}
+
+func Test_CreateLocation(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+
+	location, err := svc.CreateLocation(ctx, employerID, &LocationInput{Name: " Harbor Depot "})
+	require.NoError(t, err)
+	assert.Equal(t, "Harbor Depot", location.Name)
+	assert.Equal(t, testOrganizationID(t, db, employerID), location.OrganizationID)
+
+	_, err = svc.CreateLocation(ctx, employerID, &LocationInput{Name: "Harbor Depot"})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = svc.CreateLocation(ctx, employerID, &LocationInput{Name: ""})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	input := validShiftInput()
+	input.Location = "Harbor Depot"
+	shift, err := svc.CreateShift(ctx, employerID, input)
+	require.NoError(t, err)
+	assert.Equal(t, location.ID, shift.LocationID)
+
+	clearTestData(t, db)
+}
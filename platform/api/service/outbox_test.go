@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/outbox"
+)
+
+// flakySink fails until it is told to succeed, recording what it accepted.
+type flakySink struct {
+	fail     bool
+	received []outbox.Event
+}
+
+func (f *flakySink) Name() string {
+	return "flaky"
+}
+
+func (f *flakySink) Publish(ctx context.Context, event outbox.Event) error {
+	if f.fail {
+		return errors.New("sink unavailable")
+	}
+	f.received = append(f.received, event)
+	return nil
+}
+
+func Test_OutboxRelay(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+
+	shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+	_, err = svc.CancelShift(ctx, employerID, shift.ID)
+	require.NoError(t, err)
+	_, err = svc.CancelShift(ctx, employerID, shift.ID)
+	require.ErrorIs(t, err, ErrConflict)
+
+	var eventTypes []string
+	rows, err := db.Query(`SELECT event_type FROM outbox WHERE aggregate_id = $1 ORDER BY created_at`, shift.ID)
+	require.NoError(t, err)
+	for rows.Next() {
+		var eventType string
+		require.NoError(t, rows.Scan(&eventType))
+		eventTypes = append(eventTypes, eventType)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"shift.created", "shift.cancelled"}, eventTypes, "rolled back changes record no events")
+
+	bus := outbox.NewBus()
+	var busEvents []outbox.Event
+	bus.Subscribe(string(EventShiftCreated), func(ctx context.Context, event outbox.Event) error {
+		if event.AggregateID == shift.ID {
+			busEvents = append(busEvents, event)
+		}
+		return nil
+	})
+	flaky := &flakySink{fail: true}
+	relay := outbox.NewRelay(db, bus, flaky)
+
+	_, err = relay.ProcessBatch(ctx, 1000)
+	require.NoError(t, err)
+	require.Len(t, busEvents, 1)
+	assert.Equal(t, testOrganizationID(t, db, employerID), busEvents[0].EmployerID)
+	assert.Equal(t, busEvents[0].ID, busEvents[0].DedupeKey)
+
+	var publishedAt *string
+	var lastError string
+	require.NoError(t, db.QueryRow(`
+		SELECT published_at::text, last_error FROM outbox WHERE id = $1`, busEvents[0].ID,
+	).Scan(&publishedAt, &lastError))
+	assert.Nil(t, publishedAt, "the event stays pending until every sink accepts it")
+	assert.Contains(t, lastError, "sink unavailable")
+
+	_, err = db.Exec(`UPDATE outbox SET next_attempt_at = NOW() WHERE published_at IS NULL`)
+	require.NoError(t, err)
+	flaky.fail = false
+	_, err = relay.ProcessBatch(ctx, 1000)
+	require.NoError(t, err)
+	assert.Len(t, busEvents, 1, "sinks that accepted the event are not called again")
+	var flakyIDs []string
+	for _, event := range flaky.received {
+		flakyIDs = append(flakyIDs, event.ID)
+	}
+	assert.Contains(t, flakyIDs, busEvents[0].ID)
+
+	published, err := relay.ProcessBatch(ctx, 1000)
+	require.NoError(t, err)
+	assert.Zero(t, published)
+}
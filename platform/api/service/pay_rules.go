@@ -0,0 +1,654 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/payrules"
+)
+
+// PayRules is an organization's overtime, holiday, night differential and
+// minimum shift rules, applied to each worker's hours week by week. Zero
+// hours turn a rule off; organizations that never set rules pay and bill
+// every hour as regular time. Multipliers are basis points of the hourly
+// rate, so 15000 is time and a half.
+type PayRules struct {
+	OrganizationID        string  `json:"organization_id"`
+	DailyOvertimeHours    float64 `json:"daily_overtime_hours"`
+	DailyDoubleTimeHours  float64 `json:"daily_double_time_hours"`
+	WeeklyOvertimeHours   float64 `json:"weekly_overtime_hours"`
+	OvertimeBasisPoints   int64   `json:"overtime_bps"`
+	DoubleTimeBasisPoints int64   `json:"double_time_bps"`
+	HolidayBasisPoints    int64   `json:"holiday_bps"`
+	// Holidays are calendar days in each shift's own timezone.
+	Holidays []PayHoliday `json:"holidays"`
+	// NightStart and NightEnd are wall-clock "HH:MM" times bounding the night
+	// window, which wraps past midnight when NightEnd is earlier. Both are
+	// empty when there is no night differential.
+	NightStart                   string  `json:"night_start,omitempty"`
+	NightEnd                     string  `json:"night_end,omitempty"`
+	NightDifferentialBasisPoints int64   `json:"night_differential_bps"`
+	MinimumShiftHours            float64 `json:"minimum_shift_hours"`
+	// AbsorbMinimumShift pays workers the minimum shift guarantee without
+	// billing it to the organization. Only platform staff change it; see
+	// SetMinimumShiftAbsorption.
+	AbsorbMinimumShift bool       `json:"absorb_minimum_shift"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
+
+type PayHoliday struct {
+	Date time.Time `json:"date"`
+	Name string    `json:"name"`
+}
+
+// PayWeek is how a worker's hours in one week, Monday to Sunday, fall into
+// pay categories. It includes timesheets still awaiting approval.
+type PayWeek struct {
+	WorkerID  string             `json:"worker_id"`
+	WeekStart time.Time          `json:"week_start"`
+	Lines     []PayLine          `json:"lines"`
+	Totals    []PayCategoryTotal `json:"totals"`
+}
+
+// PayLine is the time one timesheet is paid and billed for in one category,
+// with the reasons the time fell in it.
+type PayLine struct {
+	TimesheetID           string            `json:"timesheet_id"`
+	ShiftID               string            `json:"shift_id"`
+	Category              payrules.Category `json:"category"`
+	MultiplierBasisPoints int64             `json:"multiplier_bps"`
+	BillableHours         float64           `json:"billable_hours"`
+	PayableHours          float64           `json:"payable_hours"`
+	Trace                 []string          `json:"trace"`
+}
+
+type PayCategoryTotal struct {
+	Category      payrules.Category `json:"category"`
+	BillableHours float64           `json:"billable_hours"`
+	PayableHours  float64           `json:"payable_hours"`
+}
+
+// maxPayHolidays bounds an organization's holiday calendar.
+const maxPayHolidays = 100
+
+func (s *service) GetPayRules(ctx context.Context, employerID string) (*PayRules, error) {
+	organizationID, err := organizationForUser(ctx, s.db, employerID)
+	if err != nil {
+		return nil, err
+	}
+	return loadPayRules(ctx, s.db, organizationID)
+}
+
+// SetPayRules replaces the organization's rules and holiday calendar. The new
+// rules apply to hours billed from then on; invoices already raised keep the
+// lines they were billed with.
+func (s *service) SetPayRules(ctx context.Context, employerID string, input *PayRules) (*PayRules, error) {
+	if input == nil {
+		return nil, newValidationError("pay_rules", "is required")
+	}
+	if len(input.Holidays) > maxPayHolidays {
+		return nil, newValidationError("holidays", fmt.Sprintf("must have at most %d entries", maxPayHolidays))
+	}
+	for i := range input.Holidays {
+		input.Holidays[i].Name = strings.TrimSpace(input.Holidays[i].Name)
+		input.Holidays[i].Date = localDate(input.Holidays[i].Date)
+		if input.Holidays[i].Name == "" {
+			return nil, newValidationError(fmt.Sprintf("holidays[%d].name", i), "is required")
+		}
+		if input.Holidays[i].Date.IsZero() {
+			return nil, newValidationError(fmt.Sprintf("holidays[%d].date", i), "is required")
+		}
+	}
+	sort.Slice(input.Holidays, func(i, j int) bool { return input.Holidays[i].Date.Before(input.Holidays[j].Date) })
+	if input.OvertimeBasisPoints == 0 {
+		input.OvertimeBasisPoints = payrules.DefaultOvertimeBasisPoints
+	}
+	if input.DoubleTimeBasisPoints == 0 {
+		input.DoubleTimeBasisPoints = payrules.DefaultDoubleTimeBasisPoints
+	}
+	if input.HolidayBasisPoints == 0 {
+		input.HolidayBasisPoints = payrules.DefaultHolidayBasisPoints
+	}
+	// These multipliers set what workers are paid out, so organizations may
+	// raise them above the platform's defaults but never cut below them.
+	for field, bps := range map[string][2]int64{
+		"overtime_bps":    {input.OvertimeBasisPoints, payrules.DefaultOvertimeBasisPoints},
+		"double_time_bps": {input.DoubleTimeBasisPoints, payrules.DefaultDoubleTimeBasisPoints},
+		"holiday_bps":     {input.HolidayBasisPoints, payrules.DefaultHolidayBasisPoints},
+	} {
+		if bps[0] < bps[1] {
+			return nil, newValidationError(field, fmt.Sprintf("must be at least %d", bps[1]))
+		}
+	}
+	if _, err := input.engineRules(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := organizationForUser(ctx, tx, employerID)
+	if err != nil {
+		return nil, err
+	}
+	before, err := loadPayRules(ctx, tx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if input.AbsorbMinimumShift != before.AbsorbMinimumShift {
+		return nil, fmt.Errorf("absorb_minimum_shift is set by platform staff: %w", ErrForbidden)
+	}
+
+	var nightStart, nightEnd sql.NullString
+	if input.NightStart != "" {
+		nightStart = sql.NullString{String: input.NightStart, Valid: true}
+		nightEnd = sql.NullString{String: input.NightEnd, Valid: true}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO pay_rules (organization_id, daily_overtime_minutes, daily_double_time_minutes, weekly_overtime_minutes,
+			overtime_bps, double_time_bps, holiday_bps, night_start, night_end, night_differential_bps,
+			minimum_shift_minutes, absorb_minimum_shift, created_by)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), $5, $6, $7, $8, $9, $10, NULLIF($11, 0), $12, $13)
+		ON CONFLICT (organization_id) DO UPDATE
+		SET daily_overtime_minutes = EXCLUDED.daily_overtime_minutes,
+			daily_double_time_minutes = EXCLUDED.daily_double_time_minutes,
+			weekly_overtime_minutes = EXCLUDED.weekly_overtime_minutes,
+			overtime_bps = EXCLUDED.overtime_bps,
+			double_time_bps = EXCLUDED.double_time_bps,
+			holiday_bps = EXCLUDED.holiday_bps,
+			night_start = EXCLUDED.night_start,
+			night_end = EXCLUDED.night_end,
+			night_differential_bps = EXCLUDED.night_differential_bps,
+			minimum_shift_minutes = EXCLUDED.minimum_shift_minutes,
+			absorb_minimum_shift = EXCLUDED.absorb_minimum_shift,
+			updated_by = $13,
+			updated_at = NOW()`,
+		organizationID, hoursToMinutes(input.DailyOvertimeHours), hoursToMinutes(input.DailyDoubleTimeHours),
+		hoursToMinutes(input.WeeklyOvertimeHours), input.OvertimeBasisPoints, input.DoubleTimeBasisPoints,
+		input.HolidayBasisPoints, nightStart, nightEnd, input.NightDifferentialBasisPoints,
+		hoursToMinutes(input.MinimumShiftHours), input.AbsorbMinimumShift, employerID,
+	); err != nil {
+		return nil, fmt.Errorf("error saving pay rules: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pay_rule_holidays WHERE organization_id = $1`, organizationID); err != nil {
+		return nil, fmt.Errorf("error clearing holidays: %w", err)
+	}
+	for _, holiday := range input.Holidays {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO pay_rule_holidays (organization_id, holiday_date, name) VALUES ($1, $2, $3)`,
+			organizationID, holiday.Date, holiday.Name,
+		); err != nil {
+			return nil, fmt.Errorf("error saving holiday %s: %w", holiday.Date.Format(time.DateOnly), err)
+		}
+	}
+
+	after, err := loadPayRules(ctx, tx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	action := AuditActionCreate
+	if before.UpdatedAt != nil {
+		action = AuditActionUpdate
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: organizationID,
+		Action:     action,
+		EntityType: AuditEntityPayRules,
+		EntityID:   organizationID,
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// SetMinimumShiftAbsorption sets whether the platform pays an organization's
+// minimum shift guarantees itself rather than billing them. Only platform
+// staff may, since absorbed guarantees are paid out of the platform's fee.
+func (s *service) SetMinimumShiftAbsorption(ctx context.Context, actorID string, organizationID string, absorb bool) (*PayRules, error) {
+	if err := authorizePlatform(ctx, middleware.PermissionMinimumShiftAbsorb); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM organizations WHERE id = $1)`, organizationID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error fetching organization %s: %w", organizationID, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("organization %s: %w", organizationID, ErrNotFound)
+	}
+	before, err := loadPayRules(ctx, tx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO pay_rules (organization_id, absorb_minimum_shift, created_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id) DO UPDATE
+		SET absorb_minimum_shift = EXCLUDED.absorb_minimum_shift,
+			updated_by = $3,
+			updated_at = NOW()`,
+		organizationID, absorb, actorID,
+	); err != nil {
+		return nil, fmt.Errorf("error saving pay rules: %w", err)
+	}
+
+	after, err := loadPayRules(ctx, tx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	action := AuditActionCreate
+	if before.UpdatedAt != nil {
+		action = AuditActionUpdate
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: organizationID,
+		Action:     action,
+		EntityType: AuditEntityPayRules,
+		EntityID:   organizationID,
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// EvaluatePayWeek classifies the worker's hours on the organization's shifts
+// in the week starting weekStart, which must be a Monday.
+func (s *service) EvaluatePayWeek(ctx context.Context, employerID string, workerID string, weekStart time.Time) (*PayWeek, error) {
+	weekStart = localDate(weekStart)
+	if weekStart.Weekday() != time.Monday {
+		return nil, newValidationError("week_start", "must be a Monday")
+	}
+	organizationID, err := organizationForUser(ctx, s.db, employerID)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := loadPayRules(ctx, s.db, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	engineRules, err := rules.engineRules()
+	if err != nil {
+		return nil, err
+	}
+
+	// Shifts are counted in their own timezone, so look a day either side of
+	// the week and keep what starts inside it locally. Earlier work that runs
+	// into the week still counts towards its thresholds.
+	candidates, err := loadWorkerPayWork(ctx, s.db, organizationID, workerID,
+		weekStart.AddDate(0, 0, -2), weekStart.AddDate(0, 0, 8),
+		[]TimesheetStatus{TimesheetStatusClockedOut, TimesheetStatusApproved})
+	if err != nil {
+		return nil, err
+	}
+	shiftIDs := map[string]string{}
+	var work []payrules.Work
+	for _, candidate := range candidates {
+		week := localDate(startOfWeek(candidate.timesheet.ClockInAt.In(candidate.location)))
+		if week.After(weekStart) {
+			continue
+		}
+		shiftIDs[candidate.timesheet.ID] = candidate.timesheet.ShiftID
+		work = append(work, candidate.engineWork(week.Before(weekStart)))
+	}
+	lines, err := payrules.Evaluate(engineRules, work)
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating pay rules: %w", err)
+	}
+
+	payWeek := &PayWeek{WorkerID: workerID, WeekStart: weekStart, Lines: []PayLine{}, Totals: []PayCategoryTotal{}}
+	totals := map[payrules.Category]*PayCategoryTotal{}
+	for _, line := range lines {
+		payLine := PayLine{
+			TimesheetID:           line.WorkID,
+			ShiftID:               shiftIDs[line.WorkID],
+			Category:              line.Category,
+			MultiplierBasisPoints: line.BasisPoints,
+			BillableHours:         billedHours(line.Billable),
+			PayableHours:          billedHours(line.Payable),
+			Trace:                 line.Trace,
+		}
+		payWeek.Lines = append(payWeek.Lines, payLine)
+		total, ok := totals[line.Category]
+		if !ok {
+			total = &PayCategoryTotal{Category: line.Category}
+			totals[line.Category] = total
+		}
+		total.BillableHours += payLine.BillableHours
+		total.PayableHours += payLine.PayableHours
+	}
+	for _, category := range payrules.Categories {
+		if total, ok := totals[category]; ok {
+			payWeek.Totals = append(payWeek.Totals, *total)
+		}
+	}
+	return payWeek, nil
+}
+
+// classifyTimesheets runs the organization's pay rules over rated
+// timesheets, worker by worker, and returns each timesheet's lines. The
+// worker's other approved timesheets in the same weeks count towards the
+// thresholds, so hours are classified the same however billing and payout
+// runs are cut.
+func classifyTimesheets(ctx context.Context, q querier, organizationID string, rated []billableTimesheet) (map[string][]payrules.Line, error) {
+	rules, err := loadPayRules(ctx, q, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	engineRules, err := rules.engineRules()
+	if err != nil {
+		return nil, err
+	}
+
+	var workerIDs []string
+	byWorker := map[string][]billableTimesheet{}
+	for _, b := range rated {
+		if _, ok := byWorker[b.timesheet.WorkerID]; !ok {
+			workerIDs = append(workerIDs, b.timesheet.WorkerID)
+		}
+		byWorker[b.timesheet.WorkerID] = append(byWorker[b.timesheet.WorkerID], b)
+	}
+
+	classified := map[string][]payrules.Line{}
+	for _, workerID := range workerIDs {
+		var from, to time.Time
+		included := map[string]bool{}
+		var work []payrules.Work
+		for _, b := range byWorker[workerID] {
+			included[b.timesheet.ID] = true
+			work = append(work, payWork{timesheet: b.timesheet, location: b.timezone}.engineWork(false))
+			week := startOfWeek(b.timesheet.ClockInAt.In(b.timezone))
+			if from.IsZero() || week.Before(from) {
+				from = week
+			}
+			if end := week.AddDate(0, 0, 7); end.After(to) {
+				to = end
+			}
+		}
+
+		// Start a day early so an overnight shift from the previous week
+		// counts towards the first day.
+		others, err := loadWorkerPayWork(ctx, q, organizationID, workerID, from.AddDate(0, 0, -1), to,
+			[]TimesheetStatus{TimesheetStatusApproved})
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range others {
+			if !included[other.timesheet.ID] {
+				work = append(work, other.engineWork(true))
+			}
+		}
+
+		lines, err := payrules.Evaluate(engineRules, work)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating pay rules for worker %s: %w", workerID, err)
+		}
+		for _, line := range lines {
+			classified[line.WorkID] = append(classified[line.WorkID], line)
+		}
+	}
+	return classified, nil
+}
+
+// payWork is a timesheet with the timezone of its shift.
+type payWork struct {
+	timesheet *Timesheet
+	location  *time.Location
+}
+
+// engineWork converts the timesheet into the time actually worked, leaving
+// out breaks.
+func (w payWork) engineWork(prior bool) payrules.Work {
+	work := payrules.Work{ID: w.timesheet.ID, Location: w.location, Prior: prior}
+	if w.timesheet.ClockOutAt == nil {
+		return work
+	}
+	breaks := append([]TimesheetBreak{}, w.timesheet.Breaks...)
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].StartedAt.Before(breaks[j].StartedAt) })
+
+	start, end := w.timesheet.ClockInAt, *w.timesheet.ClockOutAt
+	for _, b := range breaks {
+		if b.EndedAt == nil || !b.StartedAt.Before(end) || !b.EndedAt.After(start) {
+			continue
+		}
+		if b.StartedAt.After(start) {
+			work.Intervals = append(work.Intervals, payrules.Interval{Start: start, End: b.StartedAt})
+		}
+		start = *b.EndedAt
+	}
+	if start.Before(end) {
+		work.Intervals = append(work.Intervals, payrules.Interval{Start: start, End: end})
+	}
+	return work
+}
+
+// loadWorkerPayWork loads the worker's clocked out timesheets on the
+// organization's shifts that were clocked into between from and to.
+func loadWorkerPayWork(ctx context.Context, q querier, organizationID, workerID string, from, to time.Time, statuses []TimesheetStatus) ([]payWork, error) {
+	statusNames := make([]string, len(statuses))
+	for i, status := range statuses {
+		statusNames[i] = string(status)
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT t.id, t.shift_id, s.timezone
+		FROM timesheets t
+		JOIN shifts s ON t.shift_id = s.id
+		WHERE s.organization_id = $1
+		AND t.worker_id = $2
+		AND t.status = ANY($3)
+		AND t.clock_out_at IS NOT NULL
+		AND t.clock_in_at >= $4
+		AND t.clock_in_at < $5
+		ORDER BY t.clock_in_at, t.id`,
+		organizationID, workerID, pq.Array(statusNames), from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying worker timesheets: %w", err)
+	}
+
+	type candidate struct {
+		timesheetID, shiftID, timezone string
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.timesheetID, &c.shiftID, &c.timezone); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning worker timesheet row: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating worker timesheet rows: %w", err)
+	}
+
+	work := make([]payWork, 0, len(candidates))
+	for _, c := range candidates {
+		timesheet, err := getTimesheet(ctx, q, c.shiftID, c.timesheetID, false)
+		if err != nil {
+			return nil, err
+		}
+		location, err := loadTimezone(c.timezone)
+		if err != nil {
+			return nil, fmt.Errorf("shift %s has an invalid timezone: %w", c.shiftID, err)
+		}
+		work = append(work, payWork{timesheet: timesheet, location: location})
+	}
+	return work, nil
+}
+
+// loadPayRules returns the organization's rules, or rules that pay every
+// hour as regular time if it has never set any.
+func loadPayRules(ctx context.Context, q querier, organizationID string) (*PayRules, error) {
+	rules := PayRules{
+		OrganizationID:        organizationID,
+		OvertimeBasisPoints:   payrules.DefaultOvertimeBasisPoints,
+		DoubleTimeBasisPoints: payrules.DefaultDoubleTimeBasisPoints,
+		HolidayBasisPoints:    payrules.DefaultHolidayBasisPoints,
+		Holidays:              []PayHoliday{},
+	}
+	var dailyOvertime, dailyDoubleTime, weeklyOvertime, minimumShift sql.NullInt64
+	var nightStart, nightEnd sql.NullString
+	var createdAt time.Time
+	var updatedAt sql.NullTime
+	err := q.QueryRowContext(ctx, `
+		SELECT daily_overtime_minutes, daily_double_time_minutes, weekly_overtime_minutes,
+			overtime_bps, double_time_bps, holiday_bps,
+			to_char(night_start, 'HH24:MI'), to_char(night_end, 'HH24:MI'), night_differential_bps,
+			minimum_shift_minutes, absorb_minimum_shift, created_at, updated_at
+		FROM pay_rules
+		WHERE organization_id = $1`,
+		organizationID,
+	).Scan(&dailyOvertime, &dailyDoubleTime, &weeklyOvertime,
+		&rules.OvertimeBasisPoints, &rules.DoubleTimeBasisPoints, &rules.HolidayBasisPoints,
+		&nightStart, &nightEnd, &rules.NightDifferentialBasisPoints,
+		&minimumShift, &rules.AbsorbMinimumShift, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &rules, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pay rules: %w", err)
+	}
+	rules.DailyOvertimeHours = minutesToHours(dailyOvertime.Int64)
+	rules.DailyDoubleTimeHours = minutesToHours(dailyDoubleTime.Int64)
+	rules.WeeklyOvertimeHours = minutesToHours(weeklyOvertime.Int64)
+	rules.MinimumShiftHours = minutesToHours(minimumShift.Int64)
+	rules.NightStart = nightStart.String
+	rules.NightEnd = nightEnd.String
+	rules.UpdatedAt = &createdAt
+	if updatedAt.Valid {
+		rules.UpdatedAt = &updatedAt.Time
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT holiday_date, name FROM pay_rule_holidays WHERE organization_id = $1 ORDER BY holiday_date`,
+		organizationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying holidays: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var holiday PayHoliday
+		if err := rows.Scan(&holiday.Date, &holiday.Name); err != nil {
+			return nil, fmt.Errorf("error scanning holiday row: %w", err)
+		}
+		holiday.Date = localDate(holiday.Date)
+		rules.Holidays = append(rules.Holidays, holiday)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating holiday rows: %w", err)
+	}
+	return &rules, nil
+}
+
+// engineRules converts the rules for the pay rules engine, reporting any
+// problem as a validation error.
+func (p *PayRules) engineRules() (payrules.Rules, error) {
+	for field, hours := range map[string]float64{
+		"daily_overtime_hours":    p.DailyOvertimeHours,
+		"daily_double_time_hours": p.DailyDoubleTimeHours,
+		"weekly_overtime_hours":   p.WeeklyOvertimeHours,
+		"minimum_shift_hours":     p.MinimumShiftHours,
+	} {
+		if hours < 0 || hours > 7*24 || math.IsNaN(hours) {
+			return payrules.Rules{}, newValidationError(field, "must be between 0 and 168")
+		}
+	}
+	rules := payrules.Rules{
+		DailyOvertimeAfter:           minutesDuration(hoursToMinutes(p.DailyOvertimeHours)),
+		DailyDoubleTimeAfter:         minutesDuration(hoursToMinutes(p.DailyDoubleTimeHours)),
+		WeeklyOvertimeAfter:          minutesDuration(hoursToMinutes(p.WeeklyOvertimeHours)),
+		OvertimeBasisPoints:          p.OvertimeBasisPoints,
+		DoubleTimeBasisPoints:        p.DoubleTimeBasisPoints,
+		HolidayBasisPoints:           p.HolidayBasisPoints,
+		NightDifferentialBasisPoints: p.NightDifferentialBasisPoints,
+		MinimumShift:                 minutesDuration(hoursToMinutes(p.MinimumShiftHours)),
+		AbsorbMinimumShift:           p.AbsorbMinimumShift,
+	}
+	if (p.NightStart == "") != (p.NightEnd == "") {
+		return payrules.Rules{}, newValidationError("night_end", "night_start and night_end must be set together")
+	}
+	if p.NightStart != "" {
+		start, err := time.Parse(availabilityTimeLayout, p.NightStart)
+		if err != nil {
+			return payrules.Rules{}, newValidationError("night_start", "must be formatted as HH:MM")
+		}
+		end, err := time.Parse(availabilityTimeLayout, p.NightEnd)
+		if err != nil {
+			return payrules.Rules{}, newValidationError("night_end", "must be formatted as HH:MM")
+		}
+		rules.NightStart = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+		rules.NightEnd = time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute
+	}
+	for _, holiday := range p.Holidays {
+		rules.Holidays = append(rules.Holidays, payrules.Holiday{Date: holiday.Date, Name: holiday.Name})
+	}
+	if err := rules.Validate(); err != nil {
+		return payrules.Rules{}, newValidationError("pay_rules", strings.TrimPrefix(err.Error(), payrules.ErrInvalid.Error()+": "))
+	}
+	return rules, nil
+}
+
+// payCategoryLabel describes a non-regular category on an invoice line, e.g.
+// "overtime 1.5x" or "night differential +10%".
+func payCategoryLabel(category payrules.Category, bps int64) string {
+	switch category {
+	case payrules.CategoryNight:
+		return fmt.Sprintf("night differential +%s%%", formatBasisPoints(bps))
+	case payrules.CategoryMinimumShift:
+		return "minimum shift guarantee"
+	default:
+		return fmt.Sprintf("%s %gx", strings.ReplaceAll(string(category), "_", " "), float64(bps)/payrules.BasisPointsOne)
+	}
+}
+
+func hoursToMinutes(hours float64) int64 {
+	return int64(math.Round(hours * 60))
+}
+
+func minutesToHours(minutes int64) float64 {
+	return float64(minutes) / 60
+}
+
+func minutesDuration(minutes int64) time.Duration {
+	return time.Duration(minutes) * time.Minute
+}
+
+// billedHours is time in hours, to the whole minute as it is billed.
+func billedHours(d time.Duration) float64 {
+	return d.Truncate(time.Minute).Hours()
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/payrules"
+)
+
+func Test_PayRules(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+
+	rules, err := svc.GetPayRules(ctx, employerID)
+	require.NoError(t, err)
+	assert.Zero(t, rules.DailyOvertimeHours)
+	assert.Equal(t, int64(15000), rules.OvertimeBasisPoints)
+	assert.Nil(t, rules.UpdatedAt, "no rules have been set")
+
+	invalid := []struct {
+		name  string
+		rules PayRules
+		field string
+	}{
+		{name: "double time before overtime", rules: PayRules{DailyOvertimeHours: 10, DailyDoubleTimeHours: 8}, field: "pay_rules"},
+		{name: "night window without differential", rules: PayRules{NightStart: "22:00", NightEnd: "06:00"}, field: "pay_rules"},
+		{name: "malformed night window", rules: PayRules{NightStart: "10pm", NightEnd: "06:00", NightDifferentialBasisPoints: 1000}, field: "night_start"},
+		{name: "negative threshold", rules: PayRules{WeeklyOvertimeHours: -1}, field: "weekly_overtime_hours"},
+		{name: "unnamed holiday", rules: PayRules{Holidays: []PayHoliday{{Date: today()}}}, field: "holidays[0].name"},
+		{name: "overtime below the platform default", rules: PayRules{OvertimeBasisPoints: 12500}, field: "overtime_bps"},
+		{name: "holiday pay below the platform default", rules: PayRules{HolidayBasisPoints: 10000}, field: "holiday_bps"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SetPayRules(ctx, employerID, &tt.rules)
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+
+	saved, err := svc.SetPayRules(ctx, employerID, &PayRules{
+		DailyOvertimeHours:           8,
+		WeeklyOvertimeHours:          40,
+		NightStart:                   "22:00",
+		NightEnd:                     "06:00",
+		NightDifferentialBasisPoints: 1000,
+		MinimumShiftHours:            4,
+		Holidays:                     []PayHoliday{{Date: today().AddDate(0, 0, 30), Name: "Founders Day"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, saved.DailyOvertimeHours)
+	assert.Equal(t, int64(20000), saved.DoubleTimeBasisPoints, "multipliers default")
+	assert.Equal(t, "22:00", saved.NightStart)
+	require.Len(t, saved.Holidays, 1)
+	assert.Equal(t, today().AddDate(0, 0, 30), saved.Holidays[0].Date)
+	assert.NotNil(t, saved.UpdatedAt)
+
+	got, err := svc.GetPayRules(ctx, employerID)
+	require.NoError(t, err)
+	assert.Equal(t, saved, got)
+
+	t.Run("only platform staff absorb minimum shifts", func(t *testing.T) {
+		organizationID := testOrganizationID(t, db, employerID)
+		absorbed := *got
+		absorbed.AbsorbMinimumShift = true
+		_, err := svc.SetPayRules(contextWithRoles(employerID, "employer_admin"), employerID, &absorbed)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = svc.SetMinimumShiftAbsorption(contextWithRoles(employerID, "employer_admin"), employerID, organizationID, true)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		staffID := createTestUser(t, db, "Staff")
+		rules, err := svc.SetMinimumShiftAbsorption(contextWithRoles(staffID, "platform_admin"), staffID, organizationID, true)
+		require.NoError(t, err)
+		assert.True(t, rules.AbsorbMinimumShift)
+		assert.Equal(t, 8.0, rules.DailyOvertimeHours, "the other rules are kept")
+
+		// Employers saving the rules back leave the platform's choice alone.
+		_, err = svc.SetPayRules(ctx, employerID, rules)
+		require.NoError(t, err)
+	})
+	clearTestData(t, db)
+}
+
+func Test_BillingAppliesPayRules(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	_, err := svc.SetPayRules(ctx, employerID, &PayRules{
+		DailyOvertimeHours: 8,
+		Holidays:           []PayHoliday{{Date: today().AddDate(0, 0, 1), Name: "Founders Day"}},
+	})
+	require.NoError(t, err)
+
+	shift := createStaffedShift(t, svc, employerID, workerID)
+	input := runningShiftInput()
+	input.HourlyRate = 2000
+	shift, err = svc.UpdateShift(ctx, employerID, shift.ID, input)
+	require.NoError(t, err)
+	// 01:00 to 11:00 UTC today, in the shift's UTC timezone.
+	timesheet := createApprovedTimesheet(t, svc, employerID, workerID, shift, 10*time.Hour)
+
+	t.Run("pay week preview", func(t *testing.T) {
+		weekStart := localDate(startOfWeek(today()))
+		week, err := svc.EvaluatePayWeek(ctx, employerID, workerID, weekStart)
+		require.NoError(t, err)
+		assert.Equal(t, []PayCategoryTotal{
+			{Category: payrules.CategoryRegular, BillableHours: 8, PayableHours: 8},
+			{Category: payrules.CategoryOvertime, BillableHours: 2, PayableHours: 2},
+		}, week.Totals)
+		require.Len(t, week.Lines, 2)
+		assert.Equal(t, timesheet.ID, week.Lines[1].TimesheetID)
+		assert.Equal(t, shift.ID, week.Lines[1].ShiftID)
+		assert.Equal(t, []string{"2h over the 8h daily overtime threshold on " + today().Format(time.DateOnly)}, week.Lines[1].Trace)
+
+		_, err = svc.EvaluatePayWeek(ctx, employerID, workerID, weekStart.AddDate(0, 0, 1))
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	result, err := svc.GenerateInvoices(ctx, BillingRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	require.Len(t, result.Invoices, 1)
+	// 8h at $20.00 plus 2h at $30.00, before the 15% platform fee.
+	assert.Equal(t, money.New(22000, money.USD), result.Invoices[0].SubtotalAmount)
+
+	invoice, err := svc.GetInvoice(ctx, employerID, result.Invoices[0].InvoiceID)
+	require.NoError(t, err)
+	labor := map[payrules.Category]InvoiceLineItem{}
+	for _, line := range invoice.LineItems {
+		if line.Kind == LineItemKindLabor {
+			labor[line.PayCategory] = line
+		}
+	}
+	require.Len(t, labor, 2)
+	regular := labor[payrules.CategoryRegular]
+	assert.Equal(t, 8.0, regular.Quantity)
+	assert.Equal(t, money.New(16000, money.USD), regular.Amount)
+	overtime := labor[payrules.CategoryOvertime]
+	assert.Equal(t, 2.0, overtime.Quantity)
+	assert.Equal(t, int64(15000), overtime.MultiplierBasisPoints)
+	assert.Equal(t, money.New(3000, money.USD), overtime.UnitPrice)
+	assert.Equal(t, money.New(6000, money.USD), overtime.Amount)
+	assert.Equal(t, timesheet.ID, overtime.TimesheetID)
+	assert.Contains(t, overtime.Description, "(overtime 1.5x)")
+	assert.NotEmpty(t, overtime.Trace)
+	clearTestData(t, db)
+}
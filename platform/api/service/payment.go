@@ -0,0 +1,461 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payments"
+)
+
+// PaymentsActor is recorded as the actor of changes made by payment webhooks.
+const PaymentsActor = "system:payments"
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending   PaymentStatus = "pending"
+	PaymentStatusSucceeded PaymentStatus = "succeeded"
+	PaymentStatusFailed    PaymentStatus = "failed"
+)
+
+// Payment is an attempt to collect some or all of an invoice through the
+// payment gateway. ClientSecret is only returned when the payment is created.
+type Payment struct {
+	ID               string        `json:"id" db:"id"`
+	InvoiceID        string        `json:"invoice_id" db:"invoice_id"`
+	Provider         string        `json:"provider" db:"provider"`
+	ProviderIntentID string        `json:"provider_intent_id" db:"provider_intent_id"`
+	Amount           money.Money   `json:"amount" db:"amount"`
+	CapturedAmount   money.Money   `json:"captured_amount" db:"captured_amount"`
+	RefundedAmount   money.Money   `json:"refunded_amount" db:"refunded_amount"`
+	Status           PaymentStatus `json:"status" db:"status"`
+	ClientSecret     string        `json:"client_secret,omitempty"`
+	CreatedBy        string        `json:"created_by" db:"created_by"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+const paymentColumns = `id, invoice_id, provider, provider_intent_id, amount, captured_amount, refunded_amount, currency,
+	status, created_by, created_at, COALESCE(updated_at, created_at)`
+
+func scanPayment(row rowScanner) (*Payment, error) {
+	var p Payment
+	var currency money.Currency
+	err := row.Scan(
+		&p.ID,
+		&p.InvoiceID,
+		&p.Provider,
+		&p.ProviderIntentID,
+		&p.Amount.Amount,
+		&p.CapturedAmount.Amount,
+		&p.RefundedAmount.Amount,
+		&currency,
+		&p.Status,
+		&p.CreatedBy,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.Amount.Currency = currency
+	p.CapturedAmount.Currency = currency
+	p.RefundedAmount.Currency = currency
+	return &p, nil
+}
+
+// payableInvoiceStatuses are the statuses an invoice can take payment in.
+var payableInvoiceStatuses = []InvoiceStatus{InvoiceStatusIssued, InvoiceStatusPartiallyPaid, InvoiceStatusDisputed}
+
+// CreatePayment opens a payment intent for amount, or for the invoice's
+// outstanding balance when amount is nil.
+func (s *service) CreatePayment(ctx context.Context, employerID string, invoiceID string, amount *money.Money) (*Payment, error) {
+	if s.gateway == nil {
+		return nil, errors.New("no payment gateway is configured")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice, err := getInvoiceForUpdate(ctx, tx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeOrganization(ctx, tx, employerID, invoice.OrganizationID); err != nil {
+		return nil, fmt.Errorf("invoice %s: %w", invoiceID, err)
+	}
+	if !slices.Contains(payableInvoiceStatuses, invoice.Status) {
+		return nil, fmt.Errorf("invoice %s is %s and cannot take payment: %w", invoiceID, invoice.Status, ErrConflict)
+	}
+
+	balance, err := getInvoiceBalance(ctx, tx, invoice.ID, invoice.Amount)
+	if err != nil {
+		return nil, err
+	}
+	outstanding := balance.due
+	charge := outstanding
+	if amount != nil {
+		charge = *amount
+	}
+	if charge.Currency != outstanding.Currency {
+		return nil, newValidationError("amount", fmt.Sprintf("must be in %s", outstanding.Currency))
+	}
+	if charge.Amount <= 0 || charge.Amount > outstanding.Amount {
+		return nil, newValidationError("amount", fmt.Sprintf("must be positive and at most the outstanding %s", outstanding))
+	}
+
+	paymentID := generateID(PaymentPrefix)
+	intent, err := s.gateway.CreatePaymentIntent(ctx, payments.CreateIntentInput{
+		Amount:         charge,
+		Reference:      invoiceID,
+		IdempotencyKey: paymentID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating payment intent: %w", err)
+	}
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, `
+		INSERT INTO payments (id, invoice_id, provider, provider_intent_id, amount, currency, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+paymentColumns,
+		paymentID, invoiceID, s.gateway.Name(), intent.ID, charge.Amount, charge.Currency, PaymentStatusPending, employerID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error inserting payment: %w", err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: invoice.OrganizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityPayment,
+		EntityID:   payment.ID,
+		After:      payment,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	payment.ClientSecret = intent.ClientSecret
+	return payment, nil
+}
+
+// CapturePayment asks the gateway to collect amount, or the whole payment
+// when amount is nil, on a pending payment. The payment stays pending here:
+// it is settled by the success webhook the provider sends for the capture.
+func (s *service) CapturePayment(ctx context.Context, employerID string, paymentID string, amount *money.Money) (*Payment, error) {
+	if s.gateway == nil {
+		return nil, errors.New("no payment gateway is configured")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1 FOR UPDATE`, paymentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("payment %s: %w", paymentID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching payment %s: %w", paymentID, err)
+	}
+	if err := authorizeInvoice(ctx, tx, employerID, payment.InvoiceID); err != nil {
+		return nil, fmt.Errorf("payment %s: %w", paymentID, err)
+	}
+	if payment.Status != PaymentStatusPending {
+		return nil, fmt.Errorf("payment %s is %s and cannot be captured: %w", paymentID, payment.Status, ErrConflict)
+	}
+
+	capture := payment.Amount
+	if amount != nil {
+		capture = *amount
+	}
+	if capture.Currency != payment.Amount.Currency {
+		return nil, newValidationError("amount", fmt.Sprintf("must be in %s", payment.Amount.Currency))
+	}
+	if capture.Amount <= 0 || capture.Amount > payment.Amount.Amount {
+		return nil, newValidationError("amount", fmt.Sprintf("must be positive and at most the payment's %s", payment.Amount))
+	}
+
+	if _, err := s.gateway.Capture(ctx, payment.ProviderIntentID, capture); err != nil {
+		if errors.Is(err, payments.ErrInvalidState) {
+			return nil, fmt.Errorf("payment %s: %w: %w", paymentID, ErrConflict, err)
+		}
+		return nil, fmt.Errorf("error capturing payment %s: %w", paymentID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return payment, nil
+}
+
+func (s *service) ListInvoicePayments(ctx context.Context, employerID string, invoiceID string) ([]Payment, error) {
+	if err := authorizeInvoice(ctx, s.db, employerID, invoiceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE invoice_id = $1
+		ORDER BY created_at, id`,
+		invoiceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payments: %w", err)
+	}
+	defer rows.Close()
+
+	result := []Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment row: %w", err)
+		}
+		result = append(result, *payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payment rows: %w", err)
+	}
+	return result, nil
+}
+
+// HandlePaymentWebhook verifies and applies a payment provider webhook. Each
+// provider event is applied at most once, so redeliveries are harmless.
+func (s *service) HandlePaymentWebhook(ctx context.Context, payload []byte, header http.Header) error {
+	if s.gateway == nil {
+		return errors.New("no payment gateway is configured")
+	}
+	event, err := s.gateway.ParseWebhook(payload, header)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE provider = $1 AND provider_intent_id = $2
+		FOR UPDATE`,
+		s.gateway.Name(), event.IntentID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("payment for intent %s: %w", event.IntentID, ErrNotFound)
+		}
+		return fmt.Errorf("error fetching payment for intent %s: %w", event.IntentID, err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO payment_events (provider, provider_event_id, payment_id, event_type, amount)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`,
+		payment.Provider, event.ID, payment.ID, event.Type, event.Amount.Amount,
+	)
+	if err != nil {
+		return fmt.Errorf("error recording payment event: %w", err)
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("error recording payment event: %w", err)
+	} else if inserted == 0 {
+		slog.InfoContext(ctx, "ignoring duplicate payment event", "event_id", event.ID, "payment_id", payment.ID)
+		return nil
+	}
+
+	before := *payment
+
+	var organizationID string
+	if err := tx.QueryRowContext(ctx, `SELECT organization_id FROM invoices WHERE id = $1`, payment.InvoiceID).Scan(&organizationID); err != nil {
+		return fmt.Errorf("error fetching organization of invoice %s: %w", payment.InvoiceID, err)
+	}
+
+	if event.Amount.Currency != payment.Amount.Currency {
+		return fmt.Errorf("event %s in %s for payment in %s: %w", event.ID, event.Amount.Currency, payment.Amount.Currency, money.ErrCurrencyMismatch)
+	}
+
+	var action AuditAction
+	switch event.Type {
+	case payments.EventPaymentSucceeded:
+		if payment.Status != PaymentStatusPending {
+			slog.WarnContext(ctx, "ignoring success event for settled payment", "event_id", event.ID, "payment_id", payment.ID, "status", payment.Status)
+			break
+		}
+		action = AuditActionCapture
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE payments SET status = $1, captured_amount = $2, updated_by = $3, updated_at = NOW() WHERE id = $4`,
+			PaymentStatusSucceeded, event.Amount.Amount, PaymentsActor, payment.ID,
+		); err != nil {
+			return fmt.Errorf("error marking payment %s succeeded: %w", payment.ID, err)
+		}
+		payment.Status = PaymentStatusSucceeded
+		payment.CapturedAmount = event.Amount
+		if err := recordEvent(ctx, tx, organizationID, EventPaymentSucceeded, payment.ID, payment); err != nil {
+			return err
+		}
+		if err := postLedgerEntry(ctx, tx, paymentEntry(payment, organizationID, LedgerEntryPaymentCaptured, "payment_captured:"+payment.ID, event.Amount)); err != nil {
+			return err
+		}
+		if err := settleInvoice(ctx, tx, payment.InvoiceID, PaymentsActor, fmt.Sprintf("payment %s", payment.ID)); err != nil {
+			return err
+		}
+	case payments.EventPaymentFailed:
+		action = AuditActionFail
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE payments SET status = $1, updated_by = $2, updated_at = NOW() WHERE id = $3 AND status = $4`,
+			PaymentStatusFailed, PaymentsActor, payment.ID, PaymentStatusPending,
+		); err != nil {
+			return fmt.Errorf("error marking payment %s failed: %w", payment.ID, err)
+		}
+	case payments.EventRefundSucceeded:
+		action = AuditActionRefund
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE payments SET refunded_amount = refunded_amount + $1, updated_by = $2, updated_at = NOW() WHERE id = $3`,
+			event.Amount.Amount, PaymentsActor, payment.ID,
+		); err != nil {
+			return fmt.Errorf("error recording refund on payment %s: %w", payment.ID, err)
+		}
+		if event.RefundID != "" {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE payment_refunds SET status = $1, updated_by = $2, updated_at = NOW()
+				WHERE payment_id = $3 AND provider_refund_id = $4`,
+				RefundStatusSucceeded, PaymentsActor, payment.ID, event.RefundID,
+			); err != nil {
+				return fmt.Errorf("error marking refund %s succeeded: %w", event.RefundID, err)
+			}
+		}
+		key := fmt.Sprintf("payment_refunded:%s:%s", payment.Provider, event.ID)
+		if err := postLedgerEntry(ctx, tx, paymentEntry(payment, organizationID, LedgerEntryPaymentRefunded, key, event.Amount)); err != nil {
+			return err
+		}
+		refunded := PaymentRefunded{PaymentID: payment.ID, InvoiceID: payment.InvoiceID, ProviderRefundID: event.RefundID, Amount: event.Amount}
+		if err := recordEvent(ctx, tx, organizationID, EventPaymentRefunded, payment.ID, refunded); err != nil {
+			return err
+		}
+		if err := settleInvoice(ctx, tx, payment.InvoiceID, PaymentsActor, fmt.Sprintf("refund on payment %s", payment.ID)); err != nil {
+			return err
+		}
+	default:
+		slog.WarnContext(ctx, "ignoring unknown payment event type", "event_id", event.ID, "type", event.Type)
+	}
+
+	if action != "" {
+		after, err := scanPayment(tx.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, payment.ID))
+		if err != nil {
+			return fmt.Errorf("error fetching payment %s: %w", payment.ID, err)
+		}
+		if err := recordAudit(ctx, tx, auditRecord{
+			ActorID:    PaymentsActor,
+			EmployerID: organizationID,
+			Action:     action,
+			EntityType: AuditEntityPayment,
+			EntityID:   payment.ID,
+			Before:     before,
+			After:      after,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// settleInvoice moves an invoice to the status its balance calls for once
+// money is collected, refunded or credited against it: credited when nothing
+// is left to charge, paid when what is left is covered, partially paid when
+// some of it is, and issued again when everything collected was refunded.
+func settleInvoice(ctx context.Context, tx *sql.Tx, invoiceID string, actorID string, reason string) error {
+	invoice, err := getInvoiceForUpdate(ctx, tx, invoiceID)
+	if err != nil {
+		return err
+	}
+	balance, err := getInvoiceBalance(ctx, tx, invoice.ID, invoice.Amount)
+	if err != nil {
+		return err
+	}
+
+	var next InvoiceStatus
+	switch {
+	case balance.adjusted.Amount <= 0:
+		next = InvoiceStatusCredited
+	case balance.due.Amount <= 0:
+		next = InvoiceStatusPaid
+	case balance.paid.Amount > 0:
+		next = InvoiceStatusPartiallyPaid
+	case invoice.Status == InvoiceStatusPaid || invoice.Status == InvoiceStatusPartiallyPaid:
+		next = InvoiceStatusIssued
+	default:
+		return nil
+	}
+	if invoice.Status == next {
+		return nil
+	}
+	if !invoice.Status.CanTransitionTo(next) {
+		// The money has moved either way; flag it rather than rejecting the
+		// change, which for webhooks the provider would retry forever.
+		slog.WarnContext(ctx, "invoice balance changed in a status that cannot follow it",
+			"invoice_id", invoice.ID, "status", invoice.Status, "next", next, "reason", reason)
+		return nil
+	}
+	_, err = transitionInvoice(ctx, tx, invoice, next, actorID, reason)
+	return err
+}
+
+// invoiceBalance is where an invoice stands after credit notes and payments.
+type invoiceBalance struct {
+	// credited is the total of the invoice's credit notes and adjusted what
+	// remains to be charged.
+	credited money.Money
+	adjusted money.Money
+	// paid is everything captured and not refunded; due is adjusted less
+	// paid, and negative when credit is owed back to the customer.
+	paid money.Money
+	due  money.Money
+}
+
+func getInvoiceBalance(ctx context.Context, q querier, invoiceID string, total money.Money) (*invoiceBalance, error) {
+	var credited, paid int64
+	if err := q.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COALESCE(SUM(amount), 0) FROM credit_notes WHERE invoice_id = $1),
+			(SELECT COALESCE(SUM(captured_amount - refunded_amount), 0) FROM payments WHERE invoice_id = $1 AND status = $2)`,
+		invoiceID, PaymentStatusSucceeded,
+	).Scan(&credited, &paid); err != nil {
+		return nil, fmt.Errorf("error summing credit notes and payments for invoice %s: %w", invoiceID, err)
+	}
+
+	balance := invoiceBalance{
+		credited: money.New(credited, total.Currency),
+		paid:     money.New(paid, total.Currency),
+	}
+	var err error
+	if balance.adjusted, err = total.Sub(balance.credited); err != nil {
+		return nil, err
+	}
+	if balance.due, err = balance.adjusted.Sub(balance.paid); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
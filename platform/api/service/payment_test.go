@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payments"
+)
+
+// Helper function to bill a single approved timesheet and issue the resulting invoice
+func createIssuedInvoice(t *testing.T, svc Service, employerID, workerID string, hourlyRate int64, worked time.Duration) string {
+	ctx := context.Background()
+
+	shift := createStaffedShift(t, svc, employerID, workerID)
+	input := runningShiftInput()
+	input.HourlyRate = hourlyRate
+	shift, err := svc.UpdateShift(ctx, employerID, shift.ID, input)
+	require.NoError(t, err)
+	createApprovedTimesheet(t, svc, employerID, workerID, shift, worked)
+
+	result, err := svc.GenerateInvoices(ctx, BillingRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	require.Len(t, result.Invoices, 1)
+	invoiceID := result.Invoices[0].InvoiceID
+
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusIssued, "")
+	require.NoError(t, err)
+	return invoiceID
+}
+
+// Helper function to create a fake gateway signing with secret
+func newTestGateway(t *testing.T, secret string) *payments.FakeGateway {
+	gateway, err := payments.NewFakeGateway(secret)
+	require.NoError(t, err)
+	return gateway
+}
+
+// Helper function to complete a fake payment and deliver its webhook
+func completePayment(t *testing.T, svc Service, gateway *payments.FakeGateway, payment *Payment) payments.Event {
+	ctx := context.Background()
+
+	intent, err := gateway.Capture(ctx, payment.ProviderIntentID, money.Money{})
+	require.NoError(t, err)
+	event := payments.Event{Type: payments.EventPaymentSucceeded, IntentID: intent.ID, Amount: intent.CapturedAmount}
+	payload, header, err := gateway.SignEvent(event)
+	require.NoError(t, err)
+	require.NoError(t, svc.HandlePaymentWebhook(ctx, payload, header))
+	return event
+}
+
+func Test_PaymentSettlesInvoice(t *testing.T) {
+	gateway := newTestGateway(t, "test-secret")
+	svc := NewService(db, WithPaymentGateway(gateway))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	// 4h at $25.00 plus the 15% platform fee is $115.00.
+	invoiceID := createIssuedInvoice(t, svc, employerID, workerID, 2500, 4*time.Hour)
+
+	deposit := money.New(4000, money.USD)
+	first, err := svc.CreatePayment(ctx, employerID, invoiceID, &deposit)
+	require.NoError(t, err)
+	assert.Equal(t, PaymentStatusPending, first.Status)
+	assert.NotEmpty(t, first.ClientSecret)
+
+	event := completePayment(t, svc, gateway, first)
+	invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusPartiallyPaid, invoice.Status)
+
+	// Redelivering the same event must not count the payment twice.
+	payload, header, err := gateway.SignEvent(payments.Event{ID: "evt_replayed", Type: event.Type, IntentID: event.IntentID, Amount: event.Amount})
+	require.NoError(t, err)
+	require.NoError(t, svc.HandlePaymentWebhook(ctx, payload, header))
+	require.NoError(t, svc.HandlePaymentWebhook(ctx, payload, header))
+
+	tooMuch := money.New(7501, money.USD)
+	_, err = svc.CreatePayment(ctx, employerID, invoiceID, &tooMuch)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr, "cannot collect more than the outstanding balance")
+
+	rest, err := svc.CreatePayment(ctx, employerID, invoiceID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(7500, money.USD), rest.Amount)
+	completePayment(t, svc, gateway, rest)
+
+	invoice, err = svc.GetInvoice(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStatusPaid, invoice.Status)
+
+	transitions, err := svc.ListInvoiceTransitions(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	require.Len(t, transitions, 3)
+	assert.Equal(t, PaymentsActor, transitions[2].CreatedBy)
+
+	_, err = svc.CreatePayment(ctx, employerID, invoiceID, nil)
+	assert.ErrorIs(t, err, ErrConflict, "paid invoices take no further payments")
+
+	list, err := svc.ListInvoicePayments(ctx, employerID, invoiceID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, PaymentStatusSucceeded, list[0].Status)
+	assert.Empty(t, list[0].ClientSecret)
+
+	clearTestData(t, db)
+}
+
+func Test_CapturePaymentGoesThroughGateway(t *testing.T) {
+	gateway := newTestGateway(t, "test-secret")
+	svc := NewService(db, WithPaymentGateway(gateway))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+	otherID := createTestUser(t, db, "Other")
+
+	invoiceID := createIssuedInvoice(t, svc, employerID, workerID, 2500, 4*time.Hour)
+	payment, err := svc.CreatePayment(ctx, employerID, invoiceID, nil)
+	require.NoError(t, err)
+
+	_, err = svc.CapturePayment(ctx, otherID, payment.ID, nil)
+	assert.ErrorIs(t, err, ErrForbidden, "only the invoiced organization captures its payments")
+
+	tooMuch := money.New(11501, money.USD)
+	_, err = svc.CapturePayment(ctx, employerID, payment.ID, &tooMuch)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr, "cannot capture more than the payment")
+
+	captured, err := svc.CapturePayment(ctx, employerID, payment.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, PaymentStatusPending, captured.Status, "the webhook settles the capture")
+
+	_, err = svc.CapturePayment(ctx, employerID, payment.ID, nil)
+	assert.ErrorIs(t, err, ErrConflict, "the gateway refuses a second capture")
+
+	_, err = gateway.Capture(ctx, payment.ProviderIntentID, money.Money{})
+	assert.ErrorIs(t, err, payments.ErrInvalidState, "the intent was captured at the gateway")
+
+	clearTestData(t, db)
+}
+
+func Test_RefundReopensPaidInvoice(t *testing.T) {
+	gateway := newTestGateway(t, "test-secret")
+	svc := NewService(db, WithPaymentGateway(gateway))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	invoiceID := createIssuedInvoice(t, svc, employerID, workerID, 2500, 4*time.Hour)
+	payment, err := svc.CreatePayment(ctx, employerID, invoiceID, nil)
+	require.NoError(t, err)
+	captured := completePayment(t, svc, gateway, payment)
+
+	refund := func(amount int64) *InvoiceDetail {
+		payload, header, err := gateway.SignEvent(payments.Event{Type: payments.EventRefundSucceeded, IntentID: captured.IntentID, Amount: money.New(amount, money.USD)})
+		require.NoError(t, err)
+		require.NoError(t, svc.HandlePaymentWebhook(ctx, payload, header))
+		invoice, err := svc.GetInvoice(ctx, employerID, invoiceID)
+		require.NoError(t, err)
+		return invoice
+	}
+
+	assert.Equal(t, InvoiceStatusPartiallyPaid, refund(2000).Status, "part of the payment was refunded")
+	assert.Equal(t, InvoiceStatusIssued, refund(9500).Status, "the whole payment was refunded")
+
+	_, err = svc.TransitionInvoice(ctx, employerID, invoiceID, InvoiceStatusVoid, "")
+	require.NoError(t, err, "nothing collected is left on the invoice")
+	clearTestData(t, db)
+}
+
+func Test_PaymentWebhookRejectsBadSignature(t *testing.T) {
+	gateway := newTestGateway(t, "test-secret")
+	svc := NewService(db, WithPaymentGateway(gateway))
+
+	payload, header, err := newTestGateway(t, "wrong-secret").SignEvent(payments.Event{
+		Type:     payments.EventPaymentSucceeded,
+		IntentID: "fake_pi_unknown",
+		Amount:   money.New(100, money.USD),
+	})
+	require.NoError(t, err)
+
+	err = svc.HandlePaymentWebhook(context.Background(), payload, header)
+	assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+
+	_, err = payments.NewFakeGateway("")
+	assert.ErrorIs(t, err, payments.ErrMissingWebhookSecret, "an empty secret would accept anyone's signature")
+}
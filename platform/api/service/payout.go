@@ -0,0 +1,1095 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/backoff"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/payrules"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payouts"
+)
+
+// PayoutsActor is recorded as the actor of changes made by payout runs and
+// the payout processor.
+const PayoutsActor = "system:payouts"
+
+type PayoutStatus string
+
+const (
+	PayoutStatusPending    PayoutStatus = "pending"
+	PayoutStatusProcessing PayoutStatus = "processing"
+	PayoutStatusPaid       PayoutStatus = "paid"
+	PayoutStatusFailed     PayoutStatus = "failed"
+)
+
+// Payout is what the platform owes a worker for their approved hours in one
+// pay period. It stays pending, and later runs for the period amend it, until
+// the processor first tries to submit it to the payout provider. Submissions
+// the provider could not take are retried with backoff; Attempts counts them
+// and FailureReason says why the last one failed. Reissues counts how often
+// platform staff resubmitted it after the provider failed it.
+type Payout struct {
+	ID                 string       `json:"id" db:"id"`
+	WorkerID           string       `json:"worker_id" db:"worker_id"`
+	PeriodStart        time.Time    `json:"period_start" db:"period_start"`
+	PeriodEnd          time.Time    `json:"period_end" db:"period_end"`
+	Amount             money.Money  `json:"amount" db:"amount"`
+	Status             PayoutStatus `json:"status" db:"status"`
+	Provider           string       `json:"provider,omitempty" db:"provider"`
+	ProviderTransferID string       `json:"provider_transfer_id,omitempty" db:"provider_transfer_id"`
+	FailureReason      string       `json:"failure_reason,omitempty" db:"failure_reason"`
+	Attempts           int          `json:"attempts" db:"attempts"`
+	Reissues           int          `json:"reissues" db:"reissues"`
+	SubmittedAt        *time.Time   `json:"submitted_at,omitempty" db:"submitted_at"`
+	PaidAt             *time.Time   `json:"paid_at,omitempty" db:"paid_at"`
+	Lines              []PayoutLine `json:"lines,omitempty"`
+	CreatedAt          time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// PayoutLine is the pay for one timesheet's payable hours in one pay
+// category. HourlyRate is the base rate before the category's multiplier.
+type PayoutLine struct {
+	ID                    string            `json:"id,omitempty" db:"id"`
+	OrganizationID        string            `json:"organization_id" db:"organization_id"`
+	ShiftID               string            `json:"shift_id" db:"shift_id"`
+	TimesheetID           string            `json:"timesheet_id" db:"timesheet_id"`
+	Description           string            `json:"description" db:"description"`
+	Category              payrules.Category `json:"category" db:"pay_category"`
+	MultiplierBasisPoints int64             `json:"multiplier_bps" db:"multiplier_bps"`
+	Hours                 float64           `json:"hours" db:"hours"`
+	HourlyRate            money.Money       `json:"hourly_rate" db:"hourly_rate"`
+	Amount                money.Money       `json:"amount" db:"amount"`
+	Trace                 []string          `json:"trace,omitempty" db:"trace"`
+}
+
+// PayoutRunInput selects the pay period to pay out. Both dates are inclusive
+// calendar days in UTC.
+type PayoutRunInput struct {
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// WorkerID limits the run to a single worker when set.
+	WorkerID string
+}
+
+// GeneratedPayout summarises what a payout run did for one worker.
+type GeneratedPayout struct {
+	PayoutID       string      `json:"payout_id"`
+	WorkerID       string      `json:"worker_id"`
+	Created        bool        `json:"created"`
+	TimesheetCount int         `json:"timesheet_count"`
+	Amount         money.Money `json:"amount"`
+}
+
+type PayoutRunResult struct {
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Payouts     []GeneratedPayout `json:"payouts"`
+	// UnratedTimesheetIDs were left unpaid because neither the shift nor the
+	// employer's role rates define an hourly rate.
+	UnratedTimesheetIDs []string `json:"unrated_timesheet_ids"`
+	// LockedTimesheetIDs were left unpaid because the period's payout has
+	// already been sent to the payout provider and can no longer be amended.
+	LockedTimesheetIDs []string `json:"locked_timesheet_ids"`
+}
+
+// EarningStatus is where a worker's pay for some hours has got to.
+type EarningStatus string
+
+const (
+	// EarningStatusUnpaid hours are approved but not yet in a payout.
+	EarningStatusUnpaid     EarningStatus = "unpaid"
+	EarningStatusScheduled  EarningStatus = "scheduled"
+	EarningStatusProcessing EarningStatus = "processing"
+	EarningStatusPaid       EarningStatus = "paid"
+	EarningStatusFailed     EarningStatus = "failed"
+)
+
+// earningStatuses orders earnings totals.
+var earningStatuses = []EarningStatus{
+	EarningStatusPaid, EarningStatusProcessing, EarningStatusScheduled, EarningStatusUnpaid, EarningStatusFailed,
+}
+
+// Earnings is a worker's pay for approved hours worked between From and To,
+// whether or not it has been paid out yet.
+type Earnings struct {
+	WorkerID    string          `json:"worker_id"`
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	Lines       []EarningLine   `json:"lines"`
+	Totals      []EarningsTotal `json:"totals"`
+	TotalAmount money.Money     `json:"total_amount"`
+}
+
+// EarningLine is a payout line, or the line a timesheet will be paid on when
+// it is not in a payout yet.
+type EarningLine struct {
+	PayoutLine
+	WorkedOn time.Time     `json:"worked_on"`
+	PayoutID string        `json:"payout_id,omitempty"`
+	Status   EarningStatus `json:"status"`
+}
+
+type EarningsTotal struct {
+	Status EarningStatus `json:"status"`
+	Amount money.Money   `json:"amount"`
+}
+
+const (
+	// defaultEarningsDays is how far back earnings go without a from date.
+	defaultEarningsDays = 90
+	maxEarningsDays     = 366
+	// maxPayouts bounds a worker's payout history.
+	maxPayouts = 100
+	// Submissions the provider could not take are retried after a delay that
+	// doubles from payoutRetryInitial up to payoutRetryMax.
+	payoutRetryInitial = time.Minute
+	payoutRetryMax     = time.Hour
+)
+
+const payoutColumns = `id, worker_id, period_start, period_end, amount, currency, status, COALESCE(provider, ''),
+	COALESCE(provider_transfer_id, ''), COALESCE(failure_reason, ''), attempts, reissues, submitted_at, paid_at,
+	created_at, COALESCE(updated_at, created_at)`
+
+func scanPayout(row rowScanner) (*Payout, error) {
+	var p Payout
+	var submittedAt, paidAt sql.NullTime
+	err := row.Scan(
+		&p.ID,
+		&p.WorkerID,
+		&p.PeriodStart,
+		&p.PeriodEnd,
+		&p.Amount.Amount,
+		&p.Amount.Currency,
+		&p.Status,
+		&p.Provider,
+		&p.ProviderTransferID,
+		&p.FailureReason,
+		&p.Attempts,
+		&p.Reissues,
+		&submittedAt,
+		&paidAt,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.PeriodStart = localDate(p.PeriodStart)
+	p.PeriodEnd = localDate(p.PeriodEnd)
+	p.SubmittedAt = nullTimePtr(submittedAt)
+	p.PaidAt = nullTimePtr(paidAt)
+	return &p, nil
+}
+
+// GeneratePayouts pays out approved timesheet hours clocked in during the
+// period, producing one payout per worker. Each timesheet is paid at most
+// once, so re-running the same period only picks up timesheets approved since
+// the last run.
+func (s *service) GeneratePayouts(ctx context.Context, input PayoutRunInput) (*PayoutRunResult, error) {
+	periodStart := input.PeriodStart.UTC().Truncate(24 * time.Hour)
+	periodEnd := input.PeriodEnd.UTC().Truncate(24 * time.Hour)
+	if periodStart.IsZero() || periodEnd.IsZero() {
+		return nil, newValidationError("period", "start and end are required")
+	}
+	if periodEnd.Before(periodStart) {
+		return nil, newValidationError("period", "end must not be before start")
+	}
+
+	workerIDs, err := s.workersWithUnpaidHours(ctx, periodStart, periodEnd, input.WorkerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PayoutRunResult{
+		PeriodStart:         periodStart,
+		PeriodEnd:           periodEnd,
+		Payouts:             []GeneratedPayout{},
+		UnratedTimesheetIDs: []string{},
+		LockedTimesheetIDs:  []string{},
+	}
+	for _, workerID := range workerIDs {
+		if err := s.payWorker(ctx, workerID, periodStart, periodEnd, result); err != nil {
+			return nil, fmt.Errorf("error paying worker %s: %w", workerID, err)
+		}
+	}
+
+	slog.InfoContext(ctx, "payout run completed",
+		"period_start", periodStart.Format(time.DateOnly),
+		"period_end", periodEnd.Format(time.DateOnly),
+		"payouts", len(result.Payouts),
+		"unrated_timesheets", len(result.UnratedTimesheetIDs),
+		"locked_timesheets", len(result.LockedTimesheetIDs),
+	)
+	return result, nil
+}
+
+func (s *service) workersWithUnpaidHours(ctx context.Context, periodStart, periodEnd time.Time, workerID string) ([]string, error) {
+	query := `
+		SELECT DISTINCT worker_id
+		FROM timesheets
+		WHERE status = $1
+		AND payout_id IS NULL
+		AND clock_in_at >= $2
+		AND clock_in_at < $3`
+	args := []interface{}{TimesheetStatusApproved, periodStart, periodEnd.AddDate(0, 0, 1)}
+	if workerID != "" {
+		query += ` AND worker_id = $4`
+		args = append(args, workerID)
+	}
+	query += ` ORDER BY worker_id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying workers to pay: %w", err)
+	}
+	defer rows.Close()
+
+	var workerIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning worker row: %w", err)
+		}
+		workerIDs = append(workerIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating worker rows: %w", err)
+	}
+	return workerIDs, nil
+}
+
+// payWorker creates or amends the worker's payout for the period in a single
+// transaction.
+func (s *service) payWorker(ctx context.Context, workerID string, periodStart, periodEnd time.Time, result *PayoutRunResult) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise concurrent runs for the same worker and period.
+	lockKey := fmt.Sprintf("payouts:%s:%s:%s", workerID, periodStart.Format(time.DateOnly), periodEnd.Format(time.DateOnly))
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, lockKey); err != nil {
+		return fmt.Errorf("error acquiring payout lock: %w", err)
+	}
+
+	payable, unrated, err := loadPayableTimesheets(ctx, tx, workerID, periodStart, periodEnd, true)
+	if err != nil {
+		return err
+	}
+	result.UnratedTimesheetIDs = append(result.UnratedTimesheetIDs, unrated...)
+	if len(payable) == 0 {
+		return nil
+	}
+
+	var payoutID string
+	var status PayoutStatus
+	var sent bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, status, attempts > 0 OR submitted_at IS NOT NULL
+		FROM payouts
+		WHERE worker_id = $1 AND period_start = $2 AND period_end = $3
+		FOR UPDATE`,
+		workerID, periodStart, periodEnd,
+	).Scan(&payoutID, &status, &sent)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error fetching existing payout: %w", err)
+	}
+	created := errors.Is(err, sql.ErrNoRows)
+
+	// Once a transfer may exist for the payout its amount is fixed, since
+	// resubmitting it must not ask the provider for a different amount.
+	if !created && (status != PayoutStatusPending || sent) {
+		for _, group := range payable {
+			for _, p := range group.timesheets {
+				result.LockedTimesheetIDs = append(result.LockedTimesheetIDs, p.timesheet.ID)
+			}
+		}
+		return nil
+	}
+
+	var before *Payout
+	if created {
+		payoutID = generateID(PayoutPrefix)
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO payouts (id, worker_id, period_start, period_end, currency, status, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			payoutID, workerID, periodStart, periodEnd, DefaultCurrency, PayoutStatusPending, PayoutsActor,
+		); err != nil {
+			return fmt.Errorf("error creating payout: %w", err)
+		}
+	} else if before, err = getPayout(ctx, tx, payoutID); err != nil {
+		return err
+	}
+
+	timesheetCount := 0
+	var added []PayoutLine
+	for _, group := range payable {
+		classified, err := classifyTimesheets(ctx, tx, group.organizationID, group.timesheets)
+		if err != nil {
+			return err
+		}
+		for _, p := range group.timesheets {
+			lines, err := payoutLines(group.organizationID, p, classified[p.timesheet.ID])
+			if err != nil {
+				return fmt.Errorf("error pricing timesheet %s: %w", p.timesheet.ID, err)
+			}
+			for _, line := range lines {
+				if err := insertPayoutLine(ctx, tx, payoutID, line); err != nil {
+					return err
+				}
+				added = append(added, *line)
+			}
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE timesheets SET payout_id = $1, updated_by = $2, updated_at = NOW() WHERE id = $3`,
+				payoutID, PayoutsActor, p.timesheet.ID,
+			); err != nil {
+				return fmt.Errorf("error marking timesheet %s paid out: %w", p.timesheet.ID, err)
+			}
+			timesheetCount++
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payouts
+		SET amount = (SELECT COALESCE(SUM(amount), 0) FROM payout_lines WHERE payout_id = $1),
+			updated_by = $2,
+			updated_at = NOW()
+		WHERE id = $1`,
+		payoutID, PayoutsActor,
+	); err != nil {
+		return fmt.Errorf("error totalling payout: %w", err)
+	}
+	after, err := getPayout(ctx, tx, payoutID)
+	if err != nil {
+		return err
+	}
+	// Only the lines added by this run are accrued; earlier runs accrued the
+	// rest.
+	accrued, err := payoutAmountsByOrganization(added)
+	if err != nil {
+		return fmt.Errorf("error totalling payout %s: %w", payoutID, err)
+	}
+	if err := postLedgerEntry(ctx, tx, payoutEntry(after, LedgerEntryPayoutAccrued, accrued)); err != nil {
+		return err
+	}
+	action := AuditActionCreate
+	if !created {
+		action = AuditActionUpdate
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    PayoutsActor,
+		Action:     action,
+		EntityType: AuditEntityPayout,
+		EntityID:   payoutID,
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result.Payouts = append(result.Payouts, GeneratedPayout{
+		PayoutID:       payoutID,
+		WorkerID:       workerID,
+		Created:        created,
+		TimesheetCount: timesheetCount,
+		Amount:         after.Amount,
+	})
+	return nil
+}
+
+// ProcessPayouts submits up to limit pending payouts to the payout provider,
+// then checks up to limit submitted ones for the provider's outcome. It
+// returns how many payouts changed status. Payouts are claimed with SKIP
+// LOCKED, so several processors can run at once without paying twice.
+func (s *service) ProcessPayouts(ctx context.Context, limit int) (int, error) {
+	if s.payoutProvider == nil {
+		return 0, errors.New("no payout provider is configured")
+	}
+
+	changed := 0
+	for claimed := 0; claimed < limit; claimed++ {
+		found, submitted, err := s.submitNextPayout(ctx)
+		if err != nil {
+			return changed, err
+		}
+		if !found {
+			break
+		}
+		if submitted {
+			changed++
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM payouts
+		WHERE status = $1 AND provider = $2
+		ORDER BY submitted_at, id
+		LIMIT $3`,
+		PayoutStatusProcessing, s.payoutProvider.Name(), limit,
+	)
+	if err != nil {
+		return changed, fmt.Errorf("error querying submitted payouts: %w", err)
+	}
+	var payoutIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return changed, fmt.Errorf("error scanning payout row: %w", err)
+		}
+		payoutIDs = append(payoutIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return changed, fmt.Errorf("error iterating payout rows: %w", err)
+	}
+
+	for _, payoutID := range payoutIDs {
+		moved, err := s.syncPayout(ctx, payoutID)
+		if err != nil {
+			// One payout the provider cannot report on must not hold up the
+			// rest; it is checked again on the next run.
+			slog.ErrorContext(ctx, "failed to sync payout", "payout_id", payoutID, "error", err)
+			continue
+		}
+		if moved {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// submitNextPayout claims the next pending payout that is due and submits it,
+// reporting whether there was one and whether the provider took it.
+func (s *service) submitNextPayout(ctx context.Context) (bool, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payout, err := scanPayout(tx.QueryRowContext(ctx, `
+		SELECT `+payoutColumns+`
+		FROM payouts
+		WHERE status = $1 AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`,
+		PayoutStatusPending,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("error claiming payout: %w", err)
+	}
+
+	submitted := true
+	// Nothing to transfer, e.g. when every timesheet was under a minute.
+	if payout.Amount.IsZero() {
+		if err := setPayoutStatus(ctx, tx, payout, AuditActionPay, PayoutStatusPaid, "", "", ""); err != nil {
+			return false, false, err
+		}
+	} else {
+		// The idempotency key stays the same across retries, so a transfer
+		// created before a crash or a lost response is found again rather
+		// than paid twice.
+		transfer, err := s.payoutProvider.CreateTransfer(ctx, payouts.CreateTransferInput{
+			Amount:         payout.Amount,
+			RecipientID:    payout.WorkerID,
+			Reference:      payout.ID,
+			IdempotencyKey: payoutIdempotencyKey(payout),
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "payout submission failed", "payout_id", payout.ID, "attempts", payout.Attempts+1, "error", err)
+			if err := retryPayoutLater(ctx, tx, payout, err.Error()); err != nil {
+				return false, false, err
+			}
+			submitted = false
+		} else if err := setPayoutStatus(ctx, tx, payout, AuditActionSubmit, PayoutStatusProcessing,
+			s.payoutProvider.Name(), transfer.ID, ""); err != nil {
+			return false, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, submitted, nil
+}
+
+// retryPayoutLater leaves a payout the provider could not take pending, to be
+// submitted again after a backoff.
+func retryPayoutLater(ctx context.Context, tx *sql.Tx, payout *Payout, reason string) error {
+	attempts := payout.Attempts + 1
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payouts
+		SET attempts = $1,
+			next_attempt_at = NOW() + make_interval(secs => $2),
+			failure_reason = $3,
+			updated_by = $4,
+			updated_at = NOW()
+		WHERE id = $5`,
+		attempts, backoff.Exponential(attempts, payoutRetryInitial, payoutRetryMax).Seconds(), reason, PayoutsActor, payout.ID,
+	); err != nil {
+		return fmt.Errorf("error rescheduling payout %s: %w", payout.ID, err)
+	}
+	return nil
+}
+
+// payoutIdempotencyKey identifies one transfer for the payout. A reissue asks
+// for a new transfer, so it gets a key of its own.
+func payoutIdempotencyKey(payout *Payout) string {
+	if payout.Reissues == 0 {
+		return payout.ID
+	}
+	return fmt.Sprintf("%s:reissue:%d", payout.ID, payout.Reissues)
+}
+
+// RetryPayout queues a payout the provider failed, e.g. for closed bank
+// details the worker has since fixed, to be submitted again as a new
+// transfer. Its timesheets and the pay accrued for them stay with it until it
+// is paid. Only platform staff may retry payouts.
+func (s *service) RetryPayout(ctx context.Context, actorID string, payoutID string) (*Payout, error) {
+	if err := authorizePlatform(ctx, middleware.PermissionPayoutsManage); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payout, err := scanPayout(tx.QueryRowContext(ctx, `SELECT `+payoutColumns+` FROM payouts WHERE id = $1 FOR UPDATE`, payoutID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("payout %s: %w", payoutID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching payout %s: %w", payoutID, err)
+	}
+	if payout.Status != PayoutStatusFailed {
+		return nil, fmt.Errorf("payout %s is %s; only failed payouts can be retried: %w", payoutID, payout.Status, ErrConflict)
+	}
+
+	after, err := scanPayout(tx.QueryRowContext(ctx, `
+		UPDATE payouts
+		SET status = $1,
+			provider = NULL,
+			provider_transfer_id = NULL,
+			failure_reason = NULL,
+			attempts = 0,
+			next_attempt_at = NOW(),
+			reissues = reissues + 1,
+			updated_by = $2,
+			updated_at = NOW()
+		WHERE id = $3
+		RETURNING `+payoutColumns,
+		PayoutStatusPending, actorID, payoutID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error reissuing payout %s: %w", payoutID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		Action:     AuditActionReissue,
+		EntityType: AuditEntityPayout,
+		EntityID:   payoutID,
+		Before:     payout,
+		After:      after,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+// syncPayout applies the provider's outcome for a submitted payout, reporting
+// whether its status changed.
+func (s *service) syncPayout(ctx context.Context, payoutID string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payout, err := scanPayout(tx.QueryRowContext(ctx, `
+		SELECT `+payoutColumns+`
+		FROM payouts
+		WHERE id = $1 AND status = $2
+		FOR UPDATE SKIP LOCKED`,
+		payoutID, PayoutStatusProcessing,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Settled or claimed by another processor since it was listed.
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming payout %s: %w", payoutID, err)
+	}
+
+	transfer, err := s.payoutProvider.GetTransfer(ctx, payout.ProviderTransferID)
+	switch {
+	case errors.Is(err, payouts.ErrTransferNotFound):
+		// The provider has no record of the transfer, as happens when the
+		// fake provider restarts, so nothing was paid. Queue the payout to be
+		// submitted again; the idempotency key finds the transfer if it does
+		// turn up.
+		slog.WarnContext(ctx, "payout transfer not found; resubmitting", "payout_id", payoutID, "transfer_id", payout.ProviderTransferID)
+		err = setPayoutStatus(ctx, tx, payout, AuditActionUpdate, PayoutStatusPending, "", "", "transfer not found at the payout provider")
+	case err != nil:
+		return false, fmt.Errorf("error fetching transfer for payout %s: %w", payoutID, err)
+	case transfer.Status == payouts.TransferStatusPaid:
+		err = setPayoutStatus(ctx, tx, payout, AuditActionPay, PayoutStatusPaid, payout.Provider, payout.ProviderTransferID, "")
+	case transfer.Status == payouts.TransferStatusFailed:
+		err = setPayoutStatus(ctx, tx, payout, AuditActionFail, PayoutStatusFailed, payout.Provider, payout.ProviderTransferID, transfer.FailureReason)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// setPayoutStatus moves a locked payout to status and audits the change.
+func setPayoutStatus(ctx context.Context, tx *sql.Tx, payout *Payout, action AuditAction, status PayoutStatus, provider, transferID, failureReason string) error {
+	after, err := scanPayout(tx.QueryRowContext(ctx, `
+		UPDATE payouts
+		SET status = $1,
+			provider = NULLIF($2, ''),
+			provider_transfer_id = NULLIF($3, ''),
+			failure_reason = NULLIF($4, ''),
+			submitted_at = CASE WHEN $1 = 'processing' THEN NOW() ELSE submitted_at END,
+			paid_at = CASE WHEN $1 = 'paid' THEN NOW() END,
+			updated_by = $5,
+			updated_at = NOW()
+		WHERE id = $6
+		RETURNING `+payoutColumns,
+		status, provider, transferID, failureReason, PayoutsActor, payout.ID,
+	))
+	if err != nil {
+		return fmt.Errorf("error updating payout %s: %w", payout.ID, err)
+	}
+	if status == PayoutStatusPaid {
+		lines, err := getPayoutLines(ctx, tx, payout.ID)
+		if err != nil {
+			return err
+		}
+		paid, err := payoutAmountsByOrganization(lines)
+		if err != nil {
+			return fmt.Errorf("error totalling payout %s: %w", payout.ID, err)
+		}
+		if err := postLedgerEntry(ctx, tx, payoutEntry(after, LedgerEntryPayoutPaid, paid)); err != nil {
+			return err
+		}
+	}
+	return recordAudit(ctx, tx, auditRecord{
+		ActorID:    PayoutsActor,
+		Action:     action,
+		EntityType: AuditEntityPayout,
+		EntityID:   payout.ID,
+		Before:     payout,
+		After:      after,
+	})
+}
+
+// ListPayouts returns the worker's payouts with their lines, newest period
+// first.
+func (s *service) ListPayouts(ctx context.Context, workerID string) ([]Payout, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+payoutColumns+`
+		FROM payouts
+		WHERE worker_id = $1
+		ORDER BY period_start DESC, id DESC
+		LIMIT $2`,
+		workerID, maxPayouts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payouts: %w", err)
+	}
+
+	payoutList := []Payout{}
+	for rows.Next() {
+		payout, err := scanPayout(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning payout row: %w", err)
+		}
+		payoutList = append(payoutList, *payout)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payout rows: %w", err)
+	}
+
+	for i := range payoutList {
+		if payoutList[i].Lines, err = getPayoutLines(ctx, s.db, payoutList[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return payoutList, nil
+}
+
+// GetEarnings returns the worker's pay for approved hours clocked in between
+// from and to, inclusive. Hours not yet in a payout are priced as the next
+// payout run would price them. A zero to means today, and a zero from means
+// defaultEarningsDays before to.
+func (s *service) GetEarnings(ctx context.Context, workerID string, from, to time.Time) (*Earnings, error) {
+	to = to.UTC().Truncate(24 * time.Hour)
+	if to.IsZero() {
+		to = today()
+	}
+	from = from.UTC().Truncate(24 * time.Hour)
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -defaultEarningsDays)
+	}
+	if to.Before(from) {
+		return nil, newValidationError("to", "must not be before from")
+	}
+	if to.Sub(from) > maxEarningsDays*24*time.Hour {
+		return nil, newValidationError("from", fmt.Sprintf("must be at most %d days before to", maxEarningsDays))
+	}
+
+	earnings := &Earnings{
+		WorkerID:    workerID,
+		From:        from,
+		To:          to,
+		Lines:       []EarningLine{},
+		Totals:      []EarningsTotal{},
+		TotalAmount: money.Zero(DefaultCurrency),
+	}
+
+	paid, err := getWorkerPayoutLines(ctx, s.db, workerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	earnings.Lines = append(earnings.Lines, paid...)
+
+	payable, _, err := loadPayableTimesheets(ctx, s.db, workerID, from, to, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range payable {
+		classified, err := classifyTimesheets(ctx, s.db, group.organizationID, group.timesheets)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range group.timesheets {
+			lines, err := payoutLines(group.organizationID, p, classified[p.timesheet.ID])
+			if err != nil {
+				return nil, fmt.Errorf("error pricing timesheet %s: %w", p.timesheet.ID, err)
+			}
+			for _, line := range lines {
+				earnings.Lines = append(earnings.Lines, EarningLine{
+					PayoutLine: *line,
+					WorkedOn:   localDate(p.timesheet.ClockInAt.In(p.timezone)),
+					Status:     EarningStatusUnpaid,
+				})
+			}
+		}
+	}
+	sort.SliceStable(earnings.Lines, func(i, j int) bool { return earnings.Lines[i].WorkedOn.Before(earnings.Lines[j].WorkedOn) })
+
+	totals := map[EarningStatus]money.Money{}
+	for _, line := range earnings.Lines {
+		total, ok := totals[line.Status]
+		if !ok {
+			total = money.Zero(DefaultCurrency)
+		}
+		if totals[line.Status], err = total.Add(line.Amount); err != nil {
+			return nil, fmt.Errorf("error totalling earnings: %w", err)
+		}
+		if earnings.TotalAmount, err = earnings.TotalAmount.Add(line.Amount); err != nil {
+			return nil, fmt.Errorf("error totalling earnings: %w", err)
+		}
+	}
+	for _, status := range earningStatuses {
+		if total, ok := totals[status]; ok {
+			earnings.Totals = append(earnings.Totals, EarningsTotal{Status: status, Amount: total})
+		}
+	}
+	return earnings, nil
+}
+
+// payableGroup is a worker's rated timesheets on one organization's shifts,
+// which are classified under that organization's pay rules.
+type payableGroup struct {
+	organizationID string
+	timesheets     []billableTimesheet
+}
+
+// loadPayableTimesheets loads the worker's approved timesheets that are not in
+// a payout yet, grouped by organization, and resolves the hourly rate for
+// each one. lock locks the timesheets for a payout run.
+func loadPayableTimesheets(ctx context.Context, q querier, workerID string, from, to time.Time, lock bool) ([]payableGroup, []string, error) {
+	query := `
+		SELECT t.id, t.shift_id, s.organization_id, s.shift_name, s.timezone, COALESCE(s.hourly_rate, br.hourly_rate, 0)
+		FROM timesheets t
+		JOIN shifts s ON t.shift_id = s.id
+		LEFT JOIN billing_rates br ON br.employer_id = s.organization_id AND br.role = s.role
+		WHERE t.worker_id = $1
+		AND t.status = $2
+		AND t.payout_id IS NULL
+		AND t.clock_in_at >= $3
+		AND t.clock_in_at < $4
+		ORDER BY s.organization_id, t.clock_in_at, t.id`
+	if lock {
+		query += ` FOR UPDATE OF t`
+	}
+	rows, err := q.QueryContext(ctx, query, workerID, TimesheetStatusApproved, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying payable timesheets: %w", err)
+	}
+
+	type rated struct {
+		timesheetID    string
+		shiftID        string
+		organizationID string
+		shiftName      string
+		timezone       string
+		hourlyRate     int64
+	}
+	var candidates []rated
+	for rows.Next() {
+		var r rated
+		if err := rows.Scan(&r.timesheetID, &r.shiftID, &r.organizationID, &r.shiftName, &r.timezone, &r.hourlyRate); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("error scanning payable timesheet row: %w", err)
+		}
+		candidates = append(candidates, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating payable timesheet rows: %w", err)
+	}
+
+	var groups []payableGroup
+	var unrated []string
+	for _, c := range candidates {
+		if c.hourlyRate <= 0 {
+			unrated = append(unrated, c.timesheetID)
+			continue
+		}
+		timesheet, err := getTimesheet(ctx, q, c.shiftID, c.timesheetID, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		timezone, err := loadTimezone(c.timezone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("shift %s has an invalid timezone: %w", c.shiftID, err)
+		}
+		if len(groups) == 0 || groups[len(groups)-1].organizationID != c.organizationID {
+			groups = append(groups, payableGroup{organizationID: c.organizationID})
+		}
+		group := &groups[len(groups)-1]
+		group.timesheets = append(group.timesheets, billableTimesheet{
+			timesheet:  timesheet,
+			shiftName:  c.shiftName,
+			hourlyRate: money.New(c.hourlyRate, DefaultCurrency),
+			timezone:   timezone,
+		})
+	}
+	return groups, unrated, nil
+}
+
+// payoutLines prices a timesheet's payable time in each pay category at its
+// hourly rate. Workers are paid for the guaranteed minimum shift even when
+// the organization is not billed for it.
+func payoutLines(organizationID string, p billableTimesheet, lines []payrules.Line) ([]*PayoutLine, error) {
+	description := fmt.Sprintf("%s on %s", p.shiftName, p.timesheet.ClockInAt.In(p.timezone).Format(time.DateOnly))
+	var priced []*PayoutLine
+	for _, line := range lines {
+		if line.Payable < time.Minute {
+			continue
+		}
+		amount, err := billableAmount(line.Payable, p.hourlyRate, line.BasisPoints)
+		if err != nil {
+			return nil, err
+		}
+		payoutLine := &PayoutLine{
+			OrganizationID:        organizationID,
+			ShiftID:               p.timesheet.ShiftID,
+			TimesheetID:           p.timesheet.ID,
+			Description:           description,
+			Category:              line.Category,
+			MultiplierBasisPoints: line.BasisPoints,
+			Hours:                 billedHours(line.Payable),
+			HourlyRate:            p.hourlyRate,
+			Amount:                amount,
+			Trace:                 line.Trace,
+		}
+		if line.Category != payrules.CategoryRegular {
+			payoutLine.Description += fmt.Sprintf(" (%s)", payCategoryLabel(line.Category, line.BasisPoints))
+		}
+		priced = append(priced, payoutLine)
+	}
+	return priced, nil
+}
+
+func insertPayoutLine(ctx context.Context, tx *sql.Tx, payoutID string, line *PayoutLine) error {
+	var trace []byte
+	if line.Trace != nil {
+		var err error
+		if trace, err = json.Marshal(line.Trace); err != nil {
+			return fmt.Errorf("error encoding payout line trace: %w", err)
+		}
+	}
+	line.ID = generateID(PayoutLinePrefix)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO payout_lines (id, payout_id, organization_id, shift_id, timesheet_id, description,
+			pay_category, multiplier_bps, hours, hourly_rate, amount, trace)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		line.ID, payoutID, line.OrganizationID, line.ShiftID, line.TimesheetID, line.Description,
+		line.Category, line.MultiplierBasisPoints, line.Hours, line.HourlyRate.Amount, line.Amount.Amount, trace,
+	); err != nil {
+		return fmt.Errorf("error inserting payout line for timesheet %s: %w", line.TimesheetID, err)
+	}
+	return nil
+}
+
+func getPayout(ctx context.Context, q querier, payoutID string) (*Payout, error) {
+	payout, err := scanPayout(q.QueryRowContext(ctx, `SELECT `+payoutColumns+` FROM payouts WHERE id = $1`, payoutID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("payout %s: %w", payoutID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching payout %s: %w", payoutID, err)
+	}
+	if payout.Lines, err = getPayoutLines(ctx, q, payoutID); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+const payoutLineColumns = `pl.id, pl.organization_id, pl.shift_id, pl.timesheet_id, pl.description, pl.pay_category,
+	pl.multiplier_bps, pl.hours, pl.hourly_rate, pl.amount, p.currency, pl.trace`
+
+// scanPayoutLine scans payoutLineColumns followed by any extra columns.
+func scanPayoutLine(row rowScanner, extra ...interface{}) (*PayoutLine, error) {
+	var line PayoutLine
+	var currency money.Currency
+	var trace []byte
+	dest := []interface{}{
+		&line.ID,
+		&line.OrganizationID,
+		&line.ShiftID,
+		&line.TimesheetID,
+		&line.Description,
+		&line.Category,
+		&line.MultiplierBasisPoints,
+		&line.Hours,
+		&line.HourlyRate.Amount,
+		&line.Amount.Amount,
+		&currency,
+		&trace,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if trace != nil {
+		if err := json.Unmarshal(trace, &line.Trace); err != nil {
+			return nil, fmt.Errorf("error decoding trace of payout line %s: %w", line.ID, err)
+		}
+	}
+	line.HourlyRate.Currency = currency
+	line.Amount.Currency = currency
+	return &line, nil
+}
+
+func getPayoutLines(ctx context.Context, q querier, payoutID string) ([]PayoutLine, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+payoutLineColumns+`
+		FROM payout_lines pl
+		JOIN payouts p ON pl.payout_id = p.id
+		JOIN timesheets t ON pl.timesheet_id = t.id
+		WHERE pl.payout_id = $1
+		ORDER BY t.clock_in_at, pl.timesheet_id, pl.multiplier_bps, pl.pay_category`,
+		payoutID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payout lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []PayoutLine{}
+	for rows.Next() {
+		line, err := scanPayoutLine(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payout line row: %w", err)
+		}
+		lines = append(lines, *line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payout line rows: %w", err)
+	}
+	return lines, nil
+}
+
+// getWorkerPayoutLines returns the lines of the worker's payouts for hours
+// clocked in between from and to, inclusive, as earnings.
+func getWorkerPayoutLines(ctx context.Context, q querier, workerID string, from, to time.Time) ([]EarningLine, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+payoutLineColumns+`, (t.clock_in_at AT TIME ZONE s.timezone)::date, p.id, p.status
+		FROM payout_lines pl
+		JOIN payouts p ON pl.payout_id = p.id
+		JOIN timesheets t ON pl.timesheet_id = t.id
+		JOIN shifts s ON t.shift_id = s.id
+		WHERE p.worker_id = $1
+		AND t.clock_in_at >= $2
+		AND t.clock_in_at < $3
+		ORDER BY t.clock_in_at, pl.timesheet_id, pl.multiplier_bps, pl.pay_category`,
+		workerID, from, to.AddDate(0, 0, 1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payout lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []EarningLine
+	for rows.Next() {
+		var earning EarningLine
+		var status PayoutStatus
+		line, err := scanPayoutLine(rows, &earning.WorkedOn, &earning.PayoutID, &status)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payout line row: %w", err)
+		}
+		earning.PayoutLine = *line
+		earning.WorkedOn = localDate(earning.WorkedOn)
+		earning.Status = earningStatus(status)
+		lines = append(lines, earning)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payout line rows: %w", err)
+	}
+	return lines, nil
+}
+
+// earningStatus is what a payout's status means for the hours in it.
+func earningStatus(status PayoutStatus) EarningStatus {
+	if status == PayoutStatusPending {
+		return EarningStatusScheduled
+	}
+	return EarningStatus(status)
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/payrules"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payouts"
+)
+
+// createRatedTimesheet approves worked hours on a new shift paying $20.00 an hour.
+func createRatedTimesheet(t *testing.T, svc Service, employerID, workerID string, worked time.Duration) *Timesheet {
+	shift := createStaffedShift(t, svc, employerID, workerID)
+	input := runningShiftInput()
+	input.HourlyRate = 2000
+	shift, err := svc.UpdateShift(context.Background(), employerID, shift.ID, input)
+	require.NoError(t, err)
+	return createApprovedTimesheet(t, svc, employerID, workerID, shift, worked)
+}
+
+func Test_Payouts(t *testing.T) {
+	provider := payouts.NewFakeProvider(time.Hour)
+	svc := NewService(db, WithPayoutProvider(provider))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+	otherWorkerID := createTestUser(t, db, "Other")
+
+	_, err := svc.SetPayRules(ctx, employerID, &PayRules{DailyOvertimeHours: 8})
+	require.NoError(t, err)
+	timesheet := createRatedTimesheet(t, svc, employerID, workerID, 10*time.Hour)
+	createRatedTimesheet(t, svc, employerID, otherWorkerID, 4*time.Hour)
+
+	t.Run("approved hours are unpaid until a payout run", func(t *testing.T) {
+		earnings, err := svc.GetEarnings(ctx, workerID, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		// 8h at $20.00 plus 2h at $30.00.
+		assert.Equal(t, money.New(22000, money.USD), earnings.TotalAmount)
+		assert.Equal(t, []EarningsTotal{{Status: EarningStatusUnpaid, Amount: money.New(22000, money.USD)}}, earnings.Totals)
+		require.Len(t, earnings.Lines, 2)
+		assert.Equal(t, today(), earnings.Lines[0].WorkedOn)
+		assert.Empty(t, earnings.Lines[0].PayoutID)
+
+		_, err = svc.GetEarnings(ctx, workerID, today(), today().AddDate(0, 0, -1))
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	result, err := svc.GeneratePayouts(ctx, PayoutRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	require.Len(t, result.Payouts, 2)
+	amounts := map[string]money.Money{}
+	for _, payout := range result.Payouts {
+		assert.True(t, payout.Created)
+		assert.Equal(t, 1, payout.TimesheetCount)
+		amounts[payout.WorkerID] = payout.Amount
+	}
+	assert.Equal(t, money.New(22000, money.USD), amounts[workerID])
+	assert.Equal(t, money.New(8000, money.USD), amounts[otherWorkerID])
+
+	rerun, err := svc.GeneratePayouts(ctx, PayoutRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	assert.Empty(t, rerun.Payouts, "timesheets are paid out at most once")
+
+	payoutList, err := svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	require.Len(t, payoutList, 1)
+	payout := payoutList[0]
+	assert.Equal(t, PayoutStatusPending, payout.Status)
+	require.Len(t, payout.Lines, 2)
+	overtime := payout.Lines[1]
+	assert.Equal(t, payrules.CategoryOvertime, overtime.Category)
+	assert.Equal(t, timesheet.ID, overtime.TimesheetID)
+	assert.Equal(t, 2.0, overtime.Hours)
+	assert.Equal(t, money.New(2000, money.USD), overtime.HourlyRate)
+	assert.Equal(t, money.New(6000, money.USD), overtime.Amount)
+	assert.Contains(t, overtime.Description, "(overtime 1.5x)")
+
+	processed, err := svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, processed, "both payouts are submitted and neither has settled")
+
+	payoutList, err = svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	assert.Equal(t, PayoutStatusProcessing, payoutList[0].Status)
+	assert.Equal(t, "fake", payoutList[0].Provider)
+	assert.NotNil(t, payoutList[0].SubmittedAt)
+	otherPayouts, err := svc.ListPayouts(ctx, otherWorkerID)
+	require.NoError(t, err)
+	require.Len(t, otherPayouts, 1)
+
+	require.NoError(t, provider.Settle(payoutList[0].ProviderTransferID))
+	require.NoError(t, provider.Fail(otherPayouts[0].ProviderTransferID, "account closed"))
+	processed, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, processed)
+
+	payoutList, err = svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	assert.Equal(t, PayoutStatusPaid, payoutList[0].Status)
+	assert.NotNil(t, payoutList[0].PaidAt)
+	otherPayouts, err = svc.ListPayouts(ctx, otherWorkerID)
+	require.NoError(t, err)
+	assert.Equal(t, PayoutStatusFailed, otherPayouts[0].Status)
+	assert.Equal(t, "account closed", otherPayouts[0].FailureReason)
+
+	t.Run("failed payouts are reissued by platform staff", func(t *testing.T) {
+		failed := otherPayouts[0]
+		_, err := svc.RetryPayout(contextWithRoles(otherWorkerID, "worker"), otherWorkerID, failed.ID)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		staffID := createTestUser(t, db, "Staff")
+		staffCtx := contextWithRoles(staffID, "platform_admin")
+		_, err = svc.RetryPayout(staffCtx, staffID, payout.ID)
+		assert.ErrorIs(t, err, ErrConflict, "paid payouts cannot be retried")
+
+		retried, err := svc.RetryPayout(staffCtx, staffID, failed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, PayoutStatusPending, retried.Status)
+		assert.Equal(t, 1, retried.Reissues)
+		assert.Empty(t, retried.ProviderTransferID)
+		assert.Equal(t, failed.Amount, retried.Amount, "the payout keeps its timesheets")
+
+		processed, err := svc.ProcessPayouts(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, processed)
+		otherPayouts, err := svc.ListPayouts(ctx, otherWorkerID)
+		require.NoError(t, err)
+		assert.Equal(t, PayoutStatusProcessing, otherPayouts[0].Status)
+		assert.NotEqual(t, failed.ProviderTransferID, otherPayouts[0].ProviderTransferID, "a reissue is a new transfer")
+
+		require.NoError(t, provider.Settle(otherPayouts[0].ProviderTransferID))
+		_, err = svc.ProcessPayouts(ctx, 10)
+		require.NoError(t, err)
+		otherPayouts, err = svc.ListPayouts(ctx, otherWorkerID)
+		require.NoError(t, err)
+		assert.Equal(t, PayoutStatusPaid, otherPayouts[0].Status)
+		assert.Empty(t, otherPayouts[0].FailureReason)
+	})
+
+	earnings, err := svc.GetEarnings(ctx, workerID, today(), today())
+	require.NoError(t, err)
+	assert.Equal(t, []EarningsTotal{{Status: EarningStatusPaid, Amount: money.New(22000, money.USD)}}, earnings.Totals)
+	require.Len(t, earnings.Lines, 2)
+	assert.Equal(t, payout.ID, earnings.Lines[0].PayoutID)
+
+	_, err = NewService(db).ProcessPayouts(ctx, 10)
+	assert.Error(t, err, "payouts need a provider")
+	clearTestData(t, db)
+}
+
+// unreachableProvider fails to create transfers until it is reachable again.
+type unreachableProvider struct {
+	*payouts.FakeProvider
+	unreachable bool
+}
+
+func (p *unreachableProvider) GetTransfer(ctx context.Context, transferID string) (*payouts.Transfer, error) {
+	if p.unreachable {
+		return nil, errors.New("connection refused")
+	}
+	return p.FakeProvider.GetTransfer(ctx, transferID)
+}
+
+func (p *unreachableProvider) CreateTransfer(ctx context.Context, input payouts.CreateTransferInput) (*payouts.Transfer, error) {
+	if p.unreachable {
+		return nil, errors.New("connection refused")
+	}
+	return p.FakeProvider.CreateTransfer(ctx, input)
+}
+
+func Test_PayoutSubmissionRetries(t *testing.T) {
+	provider := &unreachableProvider{FakeProvider: payouts.NewFakeProvider(time.Hour), unreachable: true}
+	svc := NewService(db, WithPayoutProvider(provider))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+	createRatedTimesheet(t, svc, employerID, workerID, 4*time.Hour)
+
+	_, err := svc.GeneratePayouts(ctx, PayoutRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	processed, err := svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+
+	payoutList, err := svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	require.Len(t, payoutList, 1)
+	assert.Equal(t, PayoutStatusPending, payoutList[0].Status, "the payout is retried rather than failed")
+	assert.Equal(t, 1, payoutList[0].Attempts)
+	assert.Equal(t, "connection refused", payoutList[0].FailureReason)
+
+	processed, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, processed, "the retry is not due yet")
+
+	provider.unreachable = false
+	_, err = db.Exec(`UPDATE payouts SET next_attempt_at = NOW() WHERE id = $1`, payoutList[0].ID)
+	require.NoError(t, err)
+	processed, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	payoutList, err = svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	assert.Equal(t, PayoutStatusProcessing, payoutList[0].Status)
+	assert.Empty(t, payoutList[0].FailureReason)
+
+	provider.unreachable = true
+	_, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err, "a payout the provider cannot report on is skipped")
+	clearTestData(t, db)
+}
+
+func Test_PayoutLostTransfer(t *testing.T) {
+	svc := NewService(db, WithPayoutProvider(payouts.NewFakeProvider(time.Hour)))
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+	createRatedTimesheet(t, svc, employerID, workerID, 4*time.Hour)
+
+	_, err := svc.GeneratePayouts(ctx, PayoutRunInput{PeriodStart: today(), PeriodEnd: today()})
+	require.NoError(t, err)
+	_, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	submitted, err := svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	require.Len(t, submitted, 1)
+	require.Equal(t, PayoutStatusProcessing, submitted[0].Status)
+
+	// A restarted fake provider has forgotten every transfer.
+	restarted := payouts.NewFakeProvider(time.Hour)
+	svc = NewService(db, WithPayoutProvider(restarted))
+	processed, err := svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	payoutList, err := svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	assert.Equal(t, PayoutStatusPending, payoutList[0].Status)
+	assert.Empty(t, payoutList[0].ProviderTransferID)
+
+	_, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	payoutList, err = svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	assert.Equal(t, PayoutStatusProcessing, payoutList[0].Status)
+	require.NoError(t, restarted.Settle(payoutList[0].ProviderTransferID))
+	_, err = svc.ProcessPayouts(ctx, 10)
+	require.NoError(t, err)
+	payoutList, err = svc.ListPayouts(ctx, workerID)
+	require.NoError(t, err)
+	assert.Equal(t, PayoutStatusPaid, payoutList[0].Status)
+	assert.Equal(t, submitted[0].Amount, payoutList[0].Amount)
+	clearTestData(t, db)
+}
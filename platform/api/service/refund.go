@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+)
+
+// Refund returns money collected by a payment. It stays pending until the
+// provider's webhook confirms it, which is when the payment's refunded amount
+// and the invoice's balance change.
+type Refund struct {
+	ID               string       `json:"id" db:"id"`
+	PaymentID        string       `json:"payment_id" db:"payment_id"`
+	InvoiceID        string       `json:"invoice_id" db:"invoice_id"`
+	ProviderRefundID string       `json:"provider_refund_id" db:"provider_refund_id"`
+	Amount           money.Money  `json:"amount" db:"amount"`
+	Reason           string       `json:"reason" db:"reason"`
+	Status           RefundStatus `json:"status" db:"status"`
+	CreatedBy        string       `json:"created_by" db:"created_by"`
+	CreatedAt        time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+type RefundInput struct {
+	// Amount defaults to all the credit the invoice owes back, up to what the
+	// payment collected.
+	Amount *money.Money `json:"amount"`
+	Reason string       `json:"reason"`
+}
+
+// PaymentRefunded is the payload of payment.refunded events.
+type PaymentRefunded struct {
+	PaymentID        string      `json:"payment_id"`
+	InvoiceID        string      `json:"invoice_id"`
+	ProviderRefundID string      `json:"provider_refund_id,omitempty"`
+	Amount           money.Money `json:"amount"`
+}
+
+const refundColumns = `r.id, r.payment_id, p.invoice_id, r.provider_refund_id, r.amount, r.currency, r.reason, r.status,
+	r.created_by, r.created_at, COALESCE(r.updated_at, r.created_at)`
+
+func scanRefund(row rowScanner) (*Refund, error) {
+	var r Refund
+	err := row.Scan(
+		&r.ID,
+		&r.PaymentID,
+		&r.InvoiceID,
+		&r.ProviderRefundID,
+		&r.Amount.Amount,
+		&r.Amount.Currency,
+		&r.Reason,
+		&r.Status,
+		&r.CreatedBy,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// RefundPayment asks the gateway to return part of what a payment collected.
+// Only credit the invoice owes back can be refunded, so a credit note has to
+// reduce the invoice first; refunds still awaiting the provider count against
+// it. Like credit notes, refunds are issued by platform staff.
+func (s *service) RefundPayment(ctx context.Context, actorID string, paymentID string, input *RefundInput) (*Refund, error) {
+	if err := authorizePlatform(ctx, middleware.PermissionRefundsWrite); err != nil {
+		return nil, err
+	}
+	if s.gateway == nil {
+		return nil, errors.New("no payment gateway is configured")
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" {
+		return nil, newValidationError("reason", "is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1 FOR UPDATE`, paymentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("payment %s: %w", paymentID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching payment %s: %w", paymentID, err)
+	}
+	invoice, err := getInvoiceForUpdate(ctx, tx, payment.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != PaymentStatusSucceeded {
+		return nil, fmt.Errorf("payment %s is %s and has nothing to refund: %w", paymentID, payment.Status, ErrConflict)
+	}
+
+	var pendingOnPayment, pendingOnInvoice int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(r.amount) FILTER (WHERE r.payment_id = $1), 0),
+			COALESCE(SUM(r.amount), 0)
+		FROM payment_refunds r
+		JOIN payments p ON r.payment_id = p.id
+		WHERE p.invoice_id = $2 AND r.status = $3`,
+		payment.ID, invoice.ID, RefundStatusPending,
+	).Scan(&pendingOnPayment, &pendingOnInvoice); err != nil {
+		return nil, fmt.Errorf("error summing pending refunds for invoice %s: %w", invoice.ID, err)
+	}
+	balance, err := getInvoiceBalance(ctx, tx, invoice.ID, invoice.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	refundable := min(
+		payment.CapturedAmount.Amount-payment.RefundedAmount.Amount-pendingOnPayment,
+		-balance.due.Amount-pendingOnInvoice,
+	)
+	if refundable <= 0 {
+		return nil, newValidationError("amount", fmt.Sprintf("nothing is refundable: invoice %s owes no credit that payment %s can return", invoice.ID, payment.ID))
+	}
+	amount := money.New(refundable, payment.Amount.Currency)
+	if input.Amount != nil {
+		amount = *input.Amount
+	}
+	if amount.Currency != payment.Amount.Currency {
+		return nil, newValidationError("amount", fmt.Sprintf("must be in %s", payment.Amount.Currency))
+	}
+	if amount.Amount <= 0 || amount.Amount > refundable {
+		return nil, newValidationError("amount", fmt.Sprintf("must be positive and at most the refundable %s", money.New(refundable, amount.Currency)))
+	}
+
+	providerRefund, err := s.gateway.Refund(ctx, payment.ProviderIntentID, amount)
+	if err != nil {
+		return nil, fmt.Errorf("error refunding payment %s: %w", payment.ID, err)
+	}
+
+	refundID := generateID(RefundPrefix)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO payment_refunds (id, payment_id, provider_refund_id, amount, currency, reason, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		refundID, payment.ID, providerRefund.ID, amount.Amount, amount.Currency, reason, RefundStatusPending, actorID,
+	); err != nil {
+		return nil, fmt.Errorf("error inserting refund: %w", err)
+	}
+	refund, err := getRefund(ctx, tx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: invoice.OrganizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityRefund,
+		EntityID:   refund.ID,
+		After:      refund,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return refund, nil
+}
+
+// ListInvoiceRefunds returns the refunds of every payment on the invoice,
+// oldest first.
+func (s *service) ListInvoiceRefunds(ctx context.Context, employerID string, invoiceID string) ([]Refund, error) {
+	if err := authorizeInvoice(ctx, s.db, employerID, invoiceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+refundColumns+`
+		FROM payment_refunds r
+		JOIN payments p ON r.payment_id = p.id
+		WHERE p.invoice_id = $1
+		ORDER BY r.created_at, r.id`,
+		invoiceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := []Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning refund row: %w", err)
+		}
+		refunds = append(refunds, *refund)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating refund rows: %w", err)
+	}
+	return refunds, nil
+}
+
+func getRefund(ctx context.Context, q querier, refundID string) (*Refund, error) {
+	refund, err := scanRefund(q.QueryRowContext(ctx, `
+		SELECT `+refundColumns+`
+		FROM payment_refunds r
+		JOIN payments p ON r.payment_id = p.id
+		WHERE r.id = $1`,
+		refundID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refund %s: %w", refundID, err)
+	}
+	return refund, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+type SearchResultKind string
+
+const (
+	SearchResultInvoice SearchResultKind = "invoice"
+	SearchResultShift   SearchResultKind = "shift"
+)
+
+// SearchResult is a single ranked match. Snippet is HTML: the matched text is
+// escaped and its matched terms are wrapped in <mark> tags, so it can be
+// rendered as markup. Title is plain text.
+type SearchResult struct {
+	Kind    SearchResultKind `json:"kind"`
+	ID      string           `json:"id"`
+	Title   string           `json:"title"`
+	Snippet string           `json:"snippet"`
+	Rank    float64          `json:"rank"`
+}
+
+// searchHeadlineOptions configures ts_headline for result snippets.
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2"
+
+// escapeHTMLSQL wraps a text expression in SQL that HTML escapes it. Snippet
+// text is escaped before ts_headline adds its <mark> tags, so those are the
+// only markup a snippet contains.
+func escapeHTMLSQL(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// Search runs a web-style full-text query (quoted phrases, "or" and -negation)
+// over the invoices and shifts of the employer's organization, including shift
+// names, locations and descriptions, and returns the best matches first.
+func (s *service) Search(ctx context.Context, employerID string, query string, limit int) ([]SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, newValidationError("q", "is required")
+	}
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit < 0 || limit > MaxSearchLimit {
+		return nil, newValidationError("limit", fmt.Sprintf("must be between 1 and %d", MaxSearchLimit))
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH q AS (SELECT websearch_to_tsquery('english', $2::text) AS query)
+		SELECT kind, id, title, snippet, rank
+		FROM (
+			SELECT
+				$3::text AS kind,
+				i.id,
+				COALESCE(i.invoice_name, '') AS title,
+				ts_headline('english', `+escapeHTMLSQL(`concat_ws(' ', i.invoice_name, (
+					SELECT string_agg(concat_ws(' ', s.shift_name, s.location), ', ')
+					FROM shifts s
+					WHERE s.id = i.shift_id
+					OR s.id IN (SELECT l.shift_id FROM invoice_line_items l WHERE l.invoice_id = i.id)
+				))`)+`, q.query, $5::text) AS snippet,
+				ts_rank_cd(i.search_vector, q.query) AS rank
+			FROM invoices i, q
+			WHERE i.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1) AND i.search_vector @@ q.query
+			UNION ALL
+			SELECT
+				$4::text AS kind,
+				s.id,
+				s.shift_name AS title,
+				ts_headline('english', `+escapeHTMLSQL(`concat_ws(' ', s.shift_name, s.location, s.shift_description)`)+`, q.query, $5::text) AS snippet,
+				ts_rank_cd(s.search_vector, q.query) AS rank
+			FROM shifts s, q
+			WHERE s.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1) AND s.search_vector @@ q.query
+		) matches
+		ORDER BY rank DESC, kind, id
+		LIMIT $6`,
+		employerID, query, SearchResultInvoice, SearchResultShift, searchHeadlineOptions, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error searching: %w", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.Kind, &r.ID, &r.Title, &r.Snippet, &r.Rank); err != nil {
+			return nil, fmt.Errorf("error scanning search result row: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search result rows: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Search(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	otherEmployerID := createTestUser(t, db, "Other")
+
+	input := validShiftInput()
+	input.ShiftName = "Forklift Loading"
+	input.Location = "Harbor Depot"
+	input.ShiftDescription = "Unload containers at the dock"
+	shift, err := svc.CreateShift(ctx, employerID, input)
+	require.NoError(t, err)
+
+	input.ShiftName = "Forklift Training"
+	_, err = svc.CreateShift(ctx, otherEmployerID, input)
+	require.NoError(t, err)
+
+	invoiceID := generateID(InvoicePrefix)
+	_, err = db.Exec(`
+		INSERT INTO invoices (id, invoice_amount, status, shift_id, invoice_name, organization_id, created_by)
+		VALUES ($1, 0, $2, $3, $4, $5, $6)`,
+		invoiceID, InvoiceStatusIssued, shift.ID, "October staffing", shift.OrganizationID, employerID,
+	)
+	require.NoError(t, err)
+
+	t.Run("matches shift location and invoices billing it", func(t *testing.T) {
+		results, err := svc.Search(ctx, employerID, "harbor", 0)
+		require.NoError(t, err)
+		require.Len(t, results, 2, "only the employer's own records are searched")
+
+		kinds := map[SearchResultKind]string{}
+		for _, r := range results {
+			kinds[r.Kind] = r.ID
+			assert.Contains(t, r.Snippet, "<mark>Harbor</mark>")
+			assert.Positive(t, r.Rank)
+		}
+		assert.Equal(t, shift.ID, kinds[SearchResultShift])
+		assert.Equal(t, invoiceID, kinds[SearchResultInvoice])
+	})
+
+	t.Run("stems description words", func(t *testing.T) {
+		results, err := svc.Search(ctx, employerID, "unloading container -october", 0)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, shift.ID, results[0].ID)
+	})
+
+	t.Run("renamed shifts are found under their new name", func(t *testing.T) {
+		input.ShiftName = "Reefer Unloading"
+		_, err := svc.UpdateShift(ctx, employerID, shift.ID, input)
+		require.NoError(t, err)
+
+		page, err := svc.FetchInvoices(ctx, employerID, InvoiceListParams{Search: "reefer"})
+		require.NoError(t, err)
+		require.Len(t, page.Invoices, 1)
+		assert.Equal(t, invoiceID, page.Invoices[0].ID)
+	})
+
+	t.Run("escapes markup in snippets", func(t *testing.T) {
+		input.ShiftName = "Pallet Sorting"
+		input.ShiftDescription = `<img src=x onerror="alert(1)"> Sort & stack`
+		_, err := svc.CreateShift(ctx, employerID, input)
+		require.NoError(t, err)
+
+		results, err := svc.Search(ctx, employerID, "pallet", 0)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Contains(t, results[0].Snippet, "<mark>Pallet</mark>")
+		assert.Contains(t, results[0].Snippet, "&lt;img")
+		assert.NotContains(t, results[0].Snippet, "<img")
+	})
+
+	t.Run("requires a query", func(t *testing.T) {
+		_, err := svc.Search(ctx, employerID, "  ", 0)
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	clearTestData(t, db)
+}
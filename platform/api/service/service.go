@@ -6,9 +6,18 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/segmentio/ksuid"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/money"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/notifications"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payments"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/payouts"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/webhooks"
 )
 
 type Prefix string
@@ -17,6 +26,33 @@ const (
 	UserPrefix    Prefix = "user_"
 	ShiftPrefix   Prefix = "shift_"
 	InvoicePrefix Prefix = "invoice_"
+
+	AssignmentPrefix       Prefix = "assignment_"
+	TimesheetPrefix        Prefix = "timesheet_"
+	TimesheetVersionPrefix Prefix = "tsversion_"
+	BreakPrefix            Prefix = "break_"
+	BillingRatePrefix      Prefix = "rate_"
+	LineItemPrefix         Prefix = "line_"
+
+	InvoiceTransitionPrefix      Prefix = "invtransition_"
+	PaymentPrefix                Prefix = "payment_"
+	WebhookEndpointPrefix        Prefix = "webhook_"
+	WebhookDeliveryPrefix        Prefix = "whdelivery_"
+	AuditPrefix                  Prefix = "audit_"
+	OrganizationPrefix           Prefix = "org_"
+	OrganizationInvitationPrefix Prefix = "orginvite_"
+	LocationPrefix               Prefix = "location_"
+	CertificationPrefix          Prefix = "cert_"
+	AvailabilityPrefix           Prefix = "availability_"
+	ShiftSeriesPrefix            Prefix = "series_"
+	NotificationPrefix           Prefix = "notif_"
+	PayoutPrefix                 Prefix = "payout_"
+	PayoutLinePrefix             Prefix = "payoutline_"
+	LedgerEntryPrefix            Prefix = "journal_"
+	LedgerPostingPrefix          Prefix = "posting_"
+	CreditNotePrefix             Prefix = "cn_"
+	CreditNoteLinePrefix         Prefix = "cnline_"
+	RefundPrefix                 Prefix = "refund_"
 )
 
 type User struct {
@@ -26,60 +62,220 @@ type User struct {
 	Email       string `json:"email" db:"email"`
 	CompanyName string `json:"company_name" db:"company_name"`
 	PhoneNumber string `json:"phone_number" db:"phone_number"`
+	// OrganizationID is the employer the user belongs to, if any.
+	OrganizationID string `json:"organization_id,omitempty" db:"organization_id"`
 }
 
 type Shift struct {
-	ID               string    `json:"id" db:"id"`
-	StartDate        time.Time `json:"start_date" db:"start_date"`
-	EndDate          time.Time `json:"end_date" db:"end_date"`
-	Location         string    `json:"location" db:"location"`
-	ShiftName        string    `json:"shift_name" db:"shift_name"`
-	ShiftsFilled     time.Time `json:"shifts_filled" db:"shifts_filled"`
-	ShiftDescription string    `json:"shift_description" db:"shift_description"`
-	CreatedBy        string    `json:"created_by" db:"created_by"`
-	UpdatedBy        string    `json:"updated_by" db:"updated_by"`
-	CreatedAt        time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+	ID string `json:"id" db:"id"`
+	// StartsAt and EndsAt are in UTC; the Local variants are the same instants
+	// in the shift's Timezone, which is its location's.
+	StartsAt         time.Time   `json:"starts_at" db:"starts_at"`
+	EndsAt           time.Time   `json:"ends_at" db:"ends_at"`
+	StartsAtLocal    time.Time   `json:"starts_at_local"`
+	EndsAtLocal      time.Time   `json:"ends_at_local"`
+	Timezone         string      `json:"timezone" db:"timezone"`
+	Location         string      `json:"location" db:"location"`
+	LocationID       string      `json:"location_id" db:"location_id"`
+	OrganizationID   string      `json:"organization_id" db:"organization_id"`
+	ShiftName        string      `json:"shift_name" db:"shift_name"`
+	ShiftsFilled     int         `json:"shifts_filled" db:"shifts_filled"`
+	Headcount        int         `json:"headcount" db:"headcount"`
+	Status           ShiftStatus `json:"status" db:"status"`
+	Role             string      `json:"role" db:"role"`
+	HourlyRate       int64       `json:"hourly_rate" db:"hourly_rate"`
+	ShiftDescription string      `json:"shift_description" db:"shift_description"`
+	// SeriesID and OccurrenceDate are set on shifts materialized from a series.
+	SeriesID       string     `json:"series_id,omitempty" db:"series_id"`
+	OccurrenceDate *time.Time `json:"occurrence_date,omitempty" db:"occurrence_date"`
+	CreatedBy      string     `json:"created_by" db:"created_by"`
+	UpdatedBy      string     `json:"updated_by" db:"updated_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type Invoice struct {
-	ID            string    `json:"id" db:"id"`
-	StartDate     time.Time `json:"start_date" db:"start_date"`
-	EndDate       time.Time `json:"end_date" db:"end_date"`
-	InvoiceAmount float64   `json:"invoice_amount" db:"invoice_amount"`
-	Status        string    `json:"status" db:"status"`
-	UserID        string    `json:"user_id" db:"user_id"`
-	ShiftID       string    `json:"shift_id" db:"shift_id"`
-	CreatedBy     string    `json:"created_by" db:"created_by"`
-	UpdatedBy     string    `json:"updated_by" db:"updated_by"`
-	InvoiceName   string    `json:"invoice_name" db:"invoice_name"`
+	ID            string      `json:"id" db:"id"`
+	StartDate     time.Time   `json:"start_date" db:"start_date"`
+	EndDate       time.Time   `json:"end_date" db:"end_date"`
+	InvoiceAmount money.Money `json:"invoice_amount" db:"invoice_amount"`
+	// Breakdown of InvoiceAmount, kept in sync with the invoice's line items.
+	SubtotalAmount    money.Money   `json:"subtotal_amount" db:"subtotal_amount"`
+	PlatformFeeAmount money.Money   `json:"platform_fee_amount" db:"platform_fee_amount"`
+	TaxAmount         money.Money   `json:"tax_amount" db:"tax_amount"`
+	Status            InvoiceStatus `json:"status" db:"status"`
+	UserID            string        `json:"user_id" db:"user_id"`
+	ShiftID           string        `json:"shift_id" db:"shift_id"`
+	OrganizationID    string        `json:"organization_id" db:"organization_id"`
+	CreatedBy         string        `json:"created_by" db:"created_by"`
+	UpdatedBy         string        `json:"updated_by" db:"updated_by"`
+	InvoiceName       string        `json:"invoice_name" db:"invoice_name"`
+	// InvoiceNumber is the organization's sequential number for the invoice,
+	// assigned when it is issued. Drafts have none.
+	InvoiceNumber string    `json:"invoice_number,omitempty" db:"invoice_number"`
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type InvoiceResponse struct {
-	ID            string    `json:"id" db:"id"`
-	StartDate     time.Time `json:"start_date" db:"start_date"`
-	EndDate       time.Time `json:"end_date" db:"end_date"`
-	InvoiceAmount float64   `json:"invoice_amount" db:"invoice_amount"`
-	Status        string    `json:"status" db:"status"`
-	InvoiceName   string    `json:"invoice_name" db:"invoice_name"`
+	ID            string        `json:"id" db:"id"`
+	StartDate     time.Time     `json:"start_date" db:"start_date"`
+	EndDate       time.Time     `json:"end_date" db:"end_date"`
+	InvoiceAmount money.Money   `json:"invoice_amount" db:"invoice_amount"`
+	Status        InvoiceStatus `json:"status" db:"status"`
+	InvoiceName   string        `json:"invoice_name" db:"invoice_name"`
+	InvoiceNumber string        `json:"invoice_number,omitempty" db:"invoice_number"`
+}
+
+// Option configures an optional dependency of the service.
+type Option func(*service)
+
+// WithPaymentGateway sets the provider used to collect invoice payments.
+func WithPaymentGateway(gateway payments.Gateway) Option {
+	return func(s *service) {
+		s.gateway = gateway
+	}
+}
+
+// WithWebhookClient sets the client used to deliver outbound webhooks.
+func WithWebhookClient(client *webhooks.Client) Option {
+	return func(s *service) {
+		s.webhooks = client
+	}
+}
+
+// WithNotificationSender sets the sender used to deliver email and SMS
+// notifications.
+func WithNotificationSender(sender notifications.Sender) Option {
+	return func(s *service) {
+		s.notifier = sender
+	}
+}
+
+// WithPayoutProvider sets the provider used to pay workers.
+func WithPayoutProvider(provider payouts.Provider) Option {
+	return func(s *service) {
+		s.payoutProvider = provider
+	}
 }
 
-func NewService(db *sql.DB) Service {
-	return &service{
-		db: db,
+func NewService(db *sql.DB, opts ...Option) Service {
+	s := &service{
+		db:       db,
+		webhooks: webhooks.NewClient(webhooks.NewHTTPClient(webhookTimeout)),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 type Service interface {
-	FetchInvoices(ctx context.Context, userId string, searchTerm string) ([]InvoiceResponse, error)
+	FetchInvoices(ctx context.Context, userId string, params InvoiceListParams) (*InvoicePage, error)
 	CreateUser(ctx context.Context, user *User) (string, error)
 	GetUserByID(ctx context.Context, userID string) (*User, error)
+
+	GetOrganization(ctx context.Context, userID string) (*Organization, error)
+	InviteOrganizationMember(ctx context.Context, actorID string, input *OrganizationMemberInput) (*OrganizationInvitation, error)
+	ListOrganizationInvitations(ctx context.Context, userID string) ([]OrganizationInvitation, error)
+	AcceptOrganizationInvitation(ctx context.Context, userID string, invitationID string) (*OrganizationMember, error)
+	DeclineOrganizationInvitation(ctx context.Context, userID string, invitationID string) (*OrganizationInvitation, error)
+	RemoveOrganizationMember(ctx context.Context, actorID string, userID string) error
+	CreateLocation(ctx context.Context, employerID string, input *LocationInput) (*Location, error)
+	ListLocations(ctx context.Context, employerID string) ([]Location, error)
+
+	CreateShift(ctx context.Context, employerID string, input *ShiftInput) (*Shift, error)
+	UpdateShift(ctx context.Context, employerID string, shiftID string, input *ShiftInput) (*Shift, error)
+	CancelShift(ctx context.Context, employerID string, shiftID string) (*Shift, error)
+	GetShift(ctx context.Context, employerID string, shiftID string) (*Shift, error)
+	ListShifts(ctx context.Context, employerID string, filter ShiftFilter) ([]Shift, error)
+
+	CreateShiftSeries(ctx context.Context, employerID string, input *ShiftSeriesInput) (*ShiftSeries, error)
+	GetShiftSeries(ctx context.Context, employerID string, seriesID string) (*ShiftSeries, error)
+	ListShiftSeries(ctx context.Context, employerID string) ([]ShiftSeries, error)
+	UpdateFollowingShifts(ctx context.Context, employerID string, shiftID string, input *ShiftSeriesInput) (*ShiftSeries, error)
+	MaterializeShiftSeries(ctx context.Context) (int, error)
+
+	ListOpenShifts(ctx context.Context) ([]Shift, error)
+	ApplyToShift(ctx context.Context, workerID string, shiftID string) (*ShiftAssignment, error)
+	WithdrawAssignment(ctx context.Context, workerID string, assignmentID string) (*ShiftAssignment, error)
+	ListWorkerAssignments(ctx context.Context, workerID string) ([]ShiftAssignment, error)
+	ListShiftAssignments(ctx context.Context, employerID string, shiftID string) ([]ShiftAssignment, error)
+	AcceptAssignment(ctx context.Context, employerID string, assignmentID string) (*ShiftAssignment, error)
+	DeclineAssignment(ctx context.Context, employerID string, assignmentID string) (*ShiftAssignment, error)
+
+	GetWorkerProfile(ctx context.Context, workerID string) (*WorkerProfile, error)
+	UpsertWorkerProfile(ctx context.Context, workerID string, input *WorkerProfileInput) (*WorkerProfile, error)
+	DeleteWorkerProfile(ctx context.Context, workerID string) error
+	GetAssignedWorkerProfile(ctx context.Context, employerID string, workerID string) (*WorkerProfile, error)
+
+	ClockIn(ctx context.Context, workerID string, shiftID string) (*Timesheet, error)
+	StartBreak(ctx context.Context, workerID string, shiftID string, timesheetID string) (*Timesheet, error)
+	EndBreak(ctx context.Context, workerID string, shiftID string, timesheetID string) (*Timesheet, error)
+	ClockOut(ctx context.Context, workerID string, shiftID string, timesheetID string) (*Timesheet, error)
+	ListShiftTimesheets(ctx context.Context, userID string, shiftID string) ([]Timesheet, error)
+	GetTimesheetVersions(ctx context.Context, userID string, shiftID string, timesheetID string) ([]TimesheetVersion, error)
+	CorrectTimesheet(ctx context.Context, employerID string, shiftID string, timesheetID string, input *TimesheetCorrection) (*Timesheet, error)
+	ApproveTimesheet(ctx context.Context, employerID string, shiftID string, timesheetID string) (*Timesheet, error)
+
+	SetBillingRate(ctx context.Context, employerID string, role string, hourlyRate int64) (*BillingRate, error)
+	GetInvoiceNumbering(ctx context.Context, employerID string) (*InvoiceNumbering, error)
+	SetInvoiceNumbering(ctx context.Context, employerID string, input *InvoiceNumberingInput) (*InvoiceNumbering, error)
+	GetPayRules(ctx context.Context, employerID string) (*PayRules, error)
+	SetPayRules(ctx context.Context, employerID string, input *PayRules) (*PayRules, error)
+	SetMinimumShiftAbsorption(ctx context.Context, actorID string, organizationID string, absorb bool) (*PayRules, error)
+	EvaluatePayWeek(ctx context.Context, employerID string, workerID string, weekStart time.Time) (*PayWeek, error)
+	GenerateInvoices(ctx context.Context, input BillingRunInput) (*BillingRunResult, error)
+	GetInvoice(ctx context.Context, employerID string, invoiceID string) (*InvoiceDetail, error)
+	TransitionInvoice(ctx context.Context, actorID string, invoiceID string, to InvoiceStatus, reason string) (*InvoiceTransition, error)
+	ListInvoiceTransitions(ctx context.Context, employerID string, invoiceID string) ([]InvoiceTransition, error)
+	MarkOverdueInvoices(ctx context.Context) (int, error)
+
+	GeneratePayouts(ctx context.Context, input PayoutRunInput) (*PayoutRunResult, error)
+	ProcessPayouts(ctx context.Context, limit int) (int, error)
+	ListPayouts(ctx context.Context, workerID string) ([]Payout, error)
+	RetryPayout(ctx context.Context, actorID string, payoutID string) (*Payout, error)
+	GetEarnings(ctx context.Context, workerID string, from, to time.Time) (*Earnings, error)
+
+	GetLedgerBalances(ctx context.Context, employerID string) ([]LedgerBalance, error)
+	ListLedgerAccountBalances(ctx context.Context) ([]LedgerBalance, error)
+	ListLedgerEntries(ctx context.Context, employerID string, limit int) ([]LedgerEntry, error)
+
+	Search(ctx context.Context, employerID string, query string, limit int) ([]SearchResult, error)
+
+	CreatePayment(ctx context.Context, employerID string, invoiceID string, amount *money.Money) (*Payment, error)
+	ListInvoicePayments(ctx context.Context, employerID string, invoiceID string) ([]Payment, error)
+	CapturePayment(ctx context.Context, employerID string, paymentID string, amount *money.Money) (*Payment, error)
+	HandlePaymentWebhook(ctx context.Context, payload []byte, header http.Header) error
+	RefundPayment(ctx context.Context, actorID string, paymentID string, input *RefundInput) (*Refund, error)
+	ListInvoiceRefunds(ctx context.Context, employerID string, invoiceID string) ([]Refund, error)
+	CreateCreditNote(ctx context.Context, actorID string, invoiceID string, input *CreditNoteInput) (*CreditNote, error)
+	ListCreditNotes(ctx context.Context, employerID string, invoiceID string) ([]CreditNote, error)
+
+	CreateWebhookEndpoint(ctx context.Context, employerID string, input *WebhookEndpointInput) (*WebhookEndpoint, error)
+	ListWebhookEndpoints(ctx context.Context, employerID string) ([]WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, employerID string, endpointID string) error
+	ListWebhookDeliveries(ctx context.Context, employerID string, endpointID string) ([]WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, employerID string, deliveryID string) (*WebhookDelivery, error)
+	DispatchWebhooks(ctx context.Context, limit int) (int, error)
+
+	GetNotificationPreferences(ctx context.Context, userID string) ([]NotificationPreference, error)
+	UpdateNotificationPreferences(ctx context.Context, userID string, preferences []NotificationPreference) ([]NotificationPreference, error)
+	ListNotifications(ctx context.Context, userID string) ([]Notification, error)
+	DispatchNotifications(ctx context.Context, limit int) (int, error)
+
+	ListAuditLog(ctx context.Context, employerID string, filter AuditFilter) ([]AuditEntry, error)
 }
 
+// webhookTimeout bounds each outbound webhook request.
+const webhookTimeout = 10 * time.Second
+
 type service struct {
-	db *sql.DB
+	db             *sql.DB
+	gateway        payments.Gateway
+	webhooks       *webhooks.Client
+	notifier       notifications.Sender
+	payoutProvider payouts.Provider
 }
 
 var _ Service = &service{}
@@ -90,15 +286,47 @@ func (s *service) CreateUser(ctx context.Context, user *User) (string, error) {
 	userID := generateID(UserPrefix)
 	// todo: create onboarding flow to collect the following user information: first name, last name, email, phone_number
 
-	_, err := s.db.Exec(`
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
 		INSERT INTO users (id, first_name, last_name, email, phone_number, company_name, created_by)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`, userID, user.FirstName, user.LastName, user.Email, user.PhoneNumber, user.CompanyName, userID)
 	if err != nil {
 		return "", fmt.Errorf("error creating user: %w", err)
 	}
+	// Users signing up through the hook are employers, each founding their own
+	// organization; an owner invites colleagues to it afterwards.
+	organizationName := user.CompanyName
+	if strings.TrimSpace(organizationName) == "" {
+		organizationName = user.FirstName + " " + user.LastName
+	}
+	org, err := createOrganization(ctx, tx, organizationName, userID)
+	if err != nil {
+		return "", err
+	}
+	created := *user
+	created.ID = userID
+	created.OrganizationID = org.ID
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    userID,
+		EmployerID: org.ID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityUser,
+		EntityID:   userID,
+		After:      created,
+	}); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
-	err = s.initializeData(ctx, userID)
+	err = s.initializeData(ctx, userID, org.ID)
 	if err != nil {
 		return "", fmt.Errorf("error initializing data: %w", err)
 	}
@@ -106,18 +334,25 @@ func (s *service) CreateUser(ctx context.Context, user *User) (string, error) {
 	return userID, nil
 }
 
+// GetUserByID returns the user along with the organization they belong to.
+// CompanyName is the organization's name when they have one.
 func (s *service) GetUserByID(ctx context.Context, userID string) (*User, error) {
 	var user User
 	err := s.db.QueryRowContext(ctx, `
-	               SELECT id, first_name, last_name, phone_number
-	               FROM users 
-	               WHERE id = $1`,
+	               SELECT u.id, u.first_name, u.last_name, u.phone_number,
+	                      COALESCE(o.name, u.company_name), COALESCE(o.id, '')
+	               FROM users u
+	               LEFT JOIN organization_members m ON m.user_id = u.id
+	               LEFT JOIN organizations o ON m.organization_id = o.id
+	               WHERE u.id = $1`,
 		userID,
 	).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
 		&user.PhoneNumber,
+		&user.CompanyName,
+		&user.OrganizationID,
 	)
 
 	if err != nil {
@@ -130,57 +365,105 @@ func (s *service) GetUserByID(ctx context.Context, userID string) (*User, error)
 	return &user, nil
 }
 
-func (s *service) FetchInvoices(ctx context.Context, userId string, searchTerm string) ([]InvoiceResponse, error) {
-	var query string
-	var args []interface{}
-	var invoices []InvoiceResponse
+// FetchInvoices returns a page of the invoices of the user's organization in
+// keyset order, so pages stay stable while new invoices are added.
+func (s *service) FetchInvoices(ctx context.Context, userId string, params InvoiceListParams) (*InvoicePage, error) {
+	if userId == "" {
+		return nil, newValidationError("user_id", "is required")
+	}
+	if err := normalizeInvoiceListParams(&params); err != nil {
+		return nil, err
+	}
+	sortColumn := invoiceSortColumns[params.Sort]
 
 	// Base query
-	query = `
-		SELECT 
+	query := `
+		SELECT
 			i.id,
 			i.invoice_amount,
-			s.start_date,
-			s.end_date,
+			i.currency,
+			COALESCE(i.period_start, (s.starts_at AT TIME ZONE s.timezone)::date),
+			COALESCE(i.period_end, (s.ends_at AT TIME ZONE s.timezone)::date),
 			i.status,
-			i.invoice_name
+			i.invoice_name,
+			COALESCE(i.invoice_number, '')
 		FROM invoices i
-		JOIN shifts s ON i.shift_id = s.id
-		WHERE i.created_by = $1
-		AND s.created_by = $1` // Removed the semicolon here
-	args = append(args, userId)
+		LEFT JOIN shifts s ON i.shift_id = s.id
+		WHERE i.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)`
+	args := []interface{}{userId}
+	addFilter := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		query += fmt.Sprintf(" AND "+clause, len(args))
+	}
 
-	// If search term is provided, add it to the query
-	if searchTerm != "" {
-		query += ` AND invoice_name ILIKE $2`
-		args = append(args, "%"+searchTerm+"%")
+	// If search term is provided, match it against the invoice and its shifts
+	if params.Search != "" {
+		addFilter(`i.search_vector @@ websearch_to_tsquery('english', $%d)`, params.Search)
+	}
+	if len(params.Statuses) > 0 {
+		statuses := make([]string, len(params.Statuses))
+		for i, status := range params.Statuses {
+			statuses[i] = string(status)
+		}
+		addFilter(`i.status = ANY($%d)`, pq.Array(statuses))
+	}
+	if !params.From.IsZero() {
+		addFilter(`COALESCE(i.period_start, (s.starts_at AT TIME ZONE s.timezone)::date) >= $%d`, params.From)
+	}
+	if !params.To.IsZero() {
+		addFilter(`COALESCE(i.period_end, (s.ends_at AT TIME ZONE s.timezone)::date) <= $%d`, params.To)
+	}
+	if params.MinAmount != nil {
+		addFilter(`i.invoice_amount >= $%d`, *params.MinAmount)
+	}
+	if params.MaxAmount != nil {
+		addFilter(`i.invoice_amount <= $%d`, *params.MaxAmount)
 	}
 
-	// Add the semicolon at the very end if needed
-	query += `;`
+	direction, comparison := "ASC", ">"
+	if params.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if params.Cursor != "" {
+		cursor, err := decodeInvoiceCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != params.Sort || cursor.Descending != params.Descending {
+			return nil, newValidationError("cursor", "was issued for a different sort order")
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		query += fmt.Sprintf(` AND (%s, i.id) %s ($%d, $%d)`, sortColumn, comparison, len(args)-1, len(args))
+	}
+
+	// Fetch one extra row to learn whether there is another page.
+	args = append(args, params.Limit+1)
+	query += fmt.Sprintf(` ORDER BY %s %s, i.id %s LIMIT $%d`, sortColumn, direction, direction, len(args))
 
 	// Execute the query
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying invoices: %w", err)
 	}
 	defer rows.Close()
 
 	// Iterate over the rows
+	invoices := []InvoiceResponse{}
 	for rows.Next() {
 		var inv InvoiceResponse
 		err := rows.Scan(
 			&inv.ID,
-			&inv.InvoiceAmount,
+			&inv.InvoiceAmount.Amount,
+			&inv.InvoiceAmount.Currency,
 			&inv.StartDate,
 			&inv.EndDate,
 			&inv.Status,
 			&inv.InvoiceName,
+			&inv.InvoiceNumber,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning invoice row: %w", err)
 		}
-		// inv.InvoiceAmount = float64(amountCents) / 100 // Convert cents to dollars
 		invoices = append(invoices, inv)
 	}
 
@@ -189,10 +472,21 @@ func (s *service) FetchInvoices(ctx context.Context, userId string, searchTerm s
 		return nil, fmt.Errorf("error iterating invoice rows: %w", err)
 	}
 
-	return invoices, nil
+	page := &InvoicePage{Invoices: invoices}
+	if len(invoices) > params.Limit {
+		page.Invoices = invoices[:params.Limit]
+		last := &page.Invoices[params.Limit-1]
+		page.NextCursor = encodeInvoiceCursor(invoiceCursor{
+			Sort:       params.Sort,
+			Descending: params.Descending,
+			Value:      invoiceSortValue(params.Sort, last),
+			ID:         last.ID,
+		})
+	}
+	return page, nil
 }
 
-func (s *service) initializeData(ctx context.Context, employerID string) error {
+func (s *service) initializeData(ctx context.Context, employerID string, organizationID string) error {
 	// Start a transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -214,16 +508,33 @@ func (s *service) initializeData(ctx context.Context, employerID string) error {
 
 	// Create a shift
 	shiftID := generateID(ShiftPrefix)
+	locationID := generateID(LocationPrefix)
 	_, err = tx.Exec(`
-		INSERT INTO shifts (id, worker_id, start_date, end_date, location, shift_name, shifts_filled, shift_description, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, shiftID, userID, time.Now(), time.Now().AddDate(0, 0, 7), "Main Street", "Day Shift", 4, "Regular day shift", employerID)
+		INSERT INTO locations (id, organization_id, name, created_by)
+		VALUES ($1, $2, $3, $4)
+	`, locationID, organizationID, "Main Street", employerID)
+	if err != nil {
+		return fmt.Errorf("failed to insert location: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO shifts (id, starts_at, ends_at, timezone, location, location_id, shift_name, shifts_filled, headcount, shift_description, organization_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, shiftID, time.Now(), time.Now().AddDate(0, 0, 7), DefaultTimezone, "Main Street", locationID, "Day Shift", 1, 4, "Regular day shift", organizationID, employerID)
 	if err != nil {
 		return fmt.Errorf("failed to insert shift: %w", err)
 	}
 
+	// Staff the shift with the worker
+	_, err = tx.Exec(`
+		INSERT INTO shift_assignments (id, shift_id, worker_id, status, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`, generateID(AssignmentPrefix), shiftID, userID, AssignmentStatusAccepted, employerID)
+	if err != nil {
+		return fmt.Errorf("failed to insert shift assignment: %w", err)
+	}
+
 	// Create 10 invoices
-	err = generateInvoices(tx, shiftID, employerID)
+	err = generateInvoices(ctx, tx, shiftID, employerID, organizationID)
 	if err != nil {
 		return fmt.Errorf("failed to generate invoices: %w", err)
 	}
@@ -241,7 +552,7 @@ func generateID(prefix Prefix) string {
 	return fmt.Sprintf("%s%s", prefix, ksuid.New().String())
 }
 
-func generateInvoices(tx *sql.Tx, shiftID, employerID string) error {
+func generateInvoices(ctx context.Context, tx *sql.Tx, shiftID, employerID, organizationID string) error {
 	shiftNames := []string{
 		"Morning Shift",
 		"Afternoon Shift",
@@ -258,20 +569,53 @@ func generateInvoices(tx *sql.Tx, shiftID, employerID string) error {
 	for i := 0; i < 10; i++ {
 		invoiceID := generateID(InvoicePrefix)
 		randomShiftName := shiftNames[rand.Intn(len(shiftNames))]
-		randomAmount := rand.Intn(90001) + 10000 // Random number between 10000 and 100000
-		status := "paid"
+		randomAmount := money.New(int64(rand.Intn(90001)+10000), DefaultCurrency) // Random amount between $100 and $1000
+		status := InvoiceStatusPaid
 		if i%3 == 0 {
-			status = "unpaid"
+			status = InvoiceStatusIssued
 		}
 
 		_, err := tx.Exec(`
-			INSERT INTO invoices (id, invoice_amount, status, shift_id, invoice_name, created_by)
-			VALUES ($1, $2, $3, $4, $5, $6)
-		`, invoiceID, randomAmount, status, shiftID, randomShiftName, employerID)
+			INSERT INTO invoices (id, invoice_amount, currency, status, shift_id, invoice_name, organization_id, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, invoiceID, randomAmount.Amount, randomAmount.Currency, status, shiftID, randomShiftName, organizationID, employerID)
 
 		if err != nil {
 			return fmt.Errorf("failed to insert invoice %d: %w", i+1, err)
 		}
+
+		err = insertLineItem(ctx, tx, invoiceID, employerID, &InvoiceLineItem{
+			Kind:        LineItemKindLabor,
+			Description: randomShiftName,
+			Quantity:    1,
+			UnitPrice:   randomAmount,
+			Amount:      randomAmount,
+			ShiftID:     shiftID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to insert line item for invoice %d: %w", i+1, err)
+		}
+		if _, err := recalculateInvoiceTotals(ctx, tx, invoiceID, employerID); err != nil {
+			return fmt.Errorf("failed to total invoice %d: %w", i+1, err)
+		}
+		invoice, err := getInvoiceForUpdate(ctx, tx, invoiceID)
+		if err != nil {
+			return fmt.Errorf("failed to lock invoice %d: %w", i+1, err)
+		}
+		if _, err := assignInvoiceNumber(ctx, tx, invoice, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to number invoice %d: %w", i+1, err)
+		}
+		// Paid demo invoices have no payments behind them, so only the
+		// outstanding ones reach the ledger.
+		if status == InvoiceStatusIssued {
+			entry, err := invoiceIssuanceEntry(ctx, tx, invoice, employerID, false)
+			if err != nil {
+				return fmt.Errorf("failed to post invoice %d: %w", i+1, err)
+			}
+			if err := postLedgerEntry(ctx, tx, entry); err != nil {
+				return fmt.Errorf("failed to post invoice %d: %w", i+1, err)
+			}
+		}
 	}
 
 	return nil
@@ -7,8 +7,12 @@ import (
 	"os"
 	"testing"
 
+	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/middleware"
 	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 )
 
@@ -199,7 +203,7 @@ func Test_FetchInvoices(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Fetch invoices
-			invoices, err := svc.FetchInvoices(context.Background(), tt.userID, tt.searchTerm)
+			page, err := svc.FetchInvoices(context.Background(), tt.userID, InvoiceListParams{Search: tt.searchTerm})
 
 			// Check error expectations
 			if tt.expectedError {
@@ -208,8 +212,10 @@ func Test_FetchInvoices(t *testing.T) {
 			}
 
 			// Verify successful fetch
-			assert.NoError(t, err)
+			require.NoError(t, err)
+			invoices := page.Invoices
 			assert.Len(t, invoices, tt.expectedCount)
+			assert.Empty(t, page.NextCursor)
 
 			// Run custom validation if provided
 			if tt.validateResult != nil {
@@ -221,12 +227,169 @@ func Test_FetchInvoices(t *testing.T) {
 	clearTestData(t, db)
 }
 
+func Test_FetchInvoicesPagination(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+
+	userID, err := svc.CreateUser(ctx, &User{
+		FirstName:   "John",
+		LastName:    "Doe",
+		Email:       "john.doe@example.com",
+		PhoneNumber: "1234567890",
+		CompanyName: "Test Company",
+	})
+	require.NoError(t, err)
+
+	params := InvoiceListParams{Sort: InvoiceSortAmount, Descending: true, Limit: 4}
+	var seen []InvoiceResponse
+	pages := 0
+	for {
+		page, err := svc.FetchInvoices(ctx, userID, params)
+		require.NoError(t, err)
+		pages++
+		seen = append(seen, page.Invoices...)
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	assert.Equal(t, 3, pages)
+	require.Len(t, seen, 10)
+	ids := map[string]bool{}
+	for i, inv := range seen {
+		ids[inv.ID] = true
+		if i > 0 {
+			assert.LessOrEqual(t, inv.InvoiceAmount.Amount, seen[i-1].InvoiceAmount.Amount)
+		}
+	}
+	assert.Len(t, ids, 10, "no invoice appears on two pages")
+
+	t.Run("cursor is tied to its sort order", func(t *testing.T) {
+		first, err := svc.FetchInvoices(ctx, userID, InvoiceListParams{Limit: 1})
+		require.NoError(t, err)
+		_, err = svc.FetchInvoices(ctx, userID, InvoiceListParams{Sort: InvoiceSortStatus, Cursor: first.NextCursor})
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("filters by status and amount", func(t *testing.T) {
+		page, err := svc.FetchInvoices(ctx, userID, InvoiceListParams{Statuses: []InvoiceStatus{InvoiceStatusIssued}})
+		require.NoError(t, err)
+		assert.Len(t, page.Invoices, 4)
+
+		minAmount := seen[4].InvoiceAmount.Amount
+		page, err = svc.FetchInvoices(ctx, userID, InvoiceListParams{MinAmount: &minAmount})
+		require.NoError(t, err)
+		for _, inv := range page.Invoices {
+			assert.GreaterOrEqual(t, inv.InvoiceAmount.Amount, minAmount)
+		}
+	})
+
+	clearTestData(t, db)
+}
+
+// Helper function to insert a bare user, owning an organization of their own,
+// without the demo data CreateUser seeds
+func createTestUser(t *testing.T, db *sql.DB, firstName string) string {
+	userID := generateID(UserPrefix)
+	_, err := db.Exec(`
+		INSERT INTO users (id, first_name, last_name, email, phone_number, company_name, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, userID, firstName, "Test", userID+"@example.com", "1234567890", "", userID)
+	assert.NoError(t, err)
+	organizationID := generateID(OrganizationPrefix)
+	_, err = db.Exec(`INSERT INTO organizations (id, name, created_by) VALUES ($1, $2, $3)`,
+		organizationID, firstName+" Test", userID)
+	assert.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role, created_by)
+		VALUES ($1, $2, $3, $2)
+	`, organizationID, userID, MemberRoleOwner)
+	assert.NoError(t, err)
+	return userID
+}
+
+// Helper function to look up the organization a user belongs to
+func testOrganizationID(t *testing.T, db *sql.DB, userID string) string {
+	var organizationID string
+	err := db.QueryRow(`SELECT organization_id FROM organization_members WHERE user_id = $1`, userID).Scan(&organizationID)
+	require.NoError(t, err)
+	return organizationID
+}
+
+// Helper function to act with the given roles, as the JWT middleware would
+func contextWithRoles(userID string, roles ...string) context.Context {
+	return context.WithValue(context.Background(), jwtmiddleware.ContextKey{}, &validator.ValidatedClaims{
+		CustomClaims: &middleware.CustomClaims{DBUserId: userID, Roles: roles},
+	})
+}
+
 // Helper function to clear test data
 func clearTestData(t *testing.T, db *sql.DB) {
-	_, err := db.Exec(`DELETE FROM invoices`)
+	// The audit log rejects deletes; truncating bypasses its row trigger.
+	_, err := db.Exec(`TRUNCATE audit_log`)
+	assert.NoError(t, err)
+	// So are ledger postings, which reference organizations and users.
+	_, err = db.Exec(`TRUNCATE ledger_postings, ledger_entries`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM notifications`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM notification_preferences`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM outbox`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM webhook_deliveries`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM webhook_endpoints`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM payment_refunds`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM credit_note_lines`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM credit_notes`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM payment_events`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM payments`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM invoice_line_items`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM payout_lines`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`UPDATE timesheets SET payout_id = NULL`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM payouts`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM timesheets`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM shift_assignments`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM worker_profiles`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM billing_rates`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM pay_rule_holidays`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM pay_rules`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM invoices`)
 	assert.NoError(t, err)
 	_, err = db.Exec(`DELETE FROM shifts`)
 	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM shift_series`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM locations`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM invoice_number_sequences`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM invoice_numbering`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM organization_invitations`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM organization_members`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM organizations`)
+	assert.NoError(t, err)
 	_, err = db.Exec(`DELETE FROM users`)
 	assert.NoError(t, err)
 }
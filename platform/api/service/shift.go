@@ -0,0 +1,477 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type ShiftStatus string
+
+const (
+	ShiftStatusOpen      ShiftStatus = "open"
+	ShiftStatusCancelled ShiftStatus = "cancelled"
+)
+
+// maxShiftHeadcount caps how many workers a single shift can ask for.
+const maxShiftHeadcount = 500
+
+// ShiftInput holds the employer-editable fields of a shift.
+type ShiftInput struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Location string    `json:"location"`
+	// Timezone is used when Location does not exist yet; existing locations
+	// keep their own.
+	Timezone         string `json:"timezone"`
+	ShiftName        string `json:"shift_name"`
+	ShiftDescription string `json:"shift_description"`
+	Headcount        int    `json:"headcount"`
+	// Role is used to look up the employer's billing rate when HourlyRate is not set.
+	Role string `json:"role"`
+	// HourlyRate is the negotiated rate for this shift in cents; zero means use the role rate.
+	HourlyRate int64 `json:"hourly_rate"`
+}
+
+// ShiftFilter narrows the shifts returned by ListShifts. From and To are
+// inclusive UTC dates. Zero values are ignored.
+type ShiftFilter struct {
+	Status ShiftStatus
+	From   time.Time
+	To     time.Time
+}
+
+const shiftColumns = `
+	id,
+	starts_at,
+	ends_at,
+	timezone,
+	location,
+	location_id,
+	organization_id,
+	shift_name,
+	shifts_filled,
+	headcount,
+	status,
+	COALESCE(role, ''),
+	COALESCE(hourly_rate, 0),
+	COALESCE(shift_description, ''),
+	COALESCE(series_id, ''),
+	occurrence_date,
+	created_by,
+	COALESCE(updated_by, ''),
+	created_at,
+	COALESCE(updated_at, created_at)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShift(row rowScanner) (*Shift, error) {
+	var shift Shift
+	var occurrenceDate sql.NullTime
+	err := row.Scan(
+		&shift.ID,
+		&shift.StartsAt,
+		&shift.EndsAt,
+		&shift.Timezone,
+		&shift.Location,
+		&shift.LocationID,
+		&shift.OrganizationID,
+		&shift.ShiftName,
+		&shift.ShiftsFilled,
+		&shift.Headcount,
+		&shift.Status,
+		&shift.Role,
+		&shift.HourlyRate,
+		&shift.ShiftDescription,
+		&shift.SeriesID,
+		&occurrenceDate,
+		&shift.CreatedBy,
+		&shift.UpdatedBy,
+		&shift.CreatedAt,
+		&shift.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	shift.OccurrenceDate = nullTimePtr(occurrenceDate)
+	loc, err := loadTimezone(shift.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("shift %s has an invalid timezone: %w", shift.ID, err)
+	}
+	shift.StartsAt, shift.EndsAt = shift.StartsAt.UTC(), shift.EndsAt.UTC()
+	shift.StartsAtLocal, shift.EndsAtLocal = shift.StartsAt.In(loc), shift.EndsAt.In(loc)
+	return &shift, nil
+}
+
+func (s *service) CreateShift(ctx context.Context, employerID string, input *ShiftInput) (*Shift, error) {
+	if employerID == "" {
+		return nil, newValidationError("employer_id", "is required")
+	}
+	if err := validateShiftInput(input); err != nil {
+		return nil, err
+	}
+	if input.StartsAt.Before(time.Now()) {
+		return nil, newValidationError("starts_at", "cannot be in the past")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := organizationForUser(ctx, tx, employerID)
+	if err != nil {
+		return nil, err
+	}
+	location, _, err := ensureLocation(ctx, tx, organizationID, employerID, strings.TrimSpace(input.Location), strings.TrimSpace(input.Timezone))
+	if err != nil {
+		return nil, err
+	}
+
+	shiftID := generateID(ShiftPrefix)
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO shifts (id, starts_at, ends_at, timezone, location, location_id, shift_name, shifts_filled, headcount, status, role, hourly_rate, shift_description, organization_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 0, $8, $9, NULLIF($10, ''), NULLIF($11, 0), $12, $13, $14)
+		RETURNING`+shiftColumns,
+		shiftID, input.StartsAt, input.EndsAt, location.Timezone, location.Name, location.ID, strings.TrimSpace(input.ShiftName),
+		input.Headcount, ShiftStatusOpen, strings.TrimSpace(input.Role), input.HourlyRate, input.ShiftDescription, organizationID, employerID,
+	)
+	shift, err := scanShift(row)
+	if err != nil {
+		return nil, fmt.Errorf("error creating shift: %w", err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: organizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityShift,
+		EntityID:   shift.ID,
+		After:      shift,
+	}); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, organizationID, EventShiftCreated, shift.ID, shift); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return shift, nil
+}
+
+func (s *service) UpdateShift(ctx context.Context, employerID string, shiftID string, input *ShiftInput) (*Shift, error) {
+	if err := validateShiftInput(input); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getShiftForUpdate(ctx, tx, employerID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == ShiftStatusCancelled {
+		return nil, fmt.Errorf("shift %s is cancelled: %w", shiftID, ErrConflict)
+	}
+	location, _, err := ensureLocation(ctx, tx, current.OrganizationID, employerID, strings.TrimSpace(input.Location), strings.TrimSpace(input.Timezone))
+	if err != nil {
+		return nil, err
+	}
+	shift, err := updateShift(ctx, tx, current, employerID, input, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return shift, nil
+}
+
+func (s *service) CancelShift(ctx context.Context, employerID string, shiftID string) (*Shift, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getShiftForUpdate(ctx, tx, employerID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == ShiftStatusCancelled {
+		return nil, fmt.Errorf("shift %s is already cancelled: %w", shiftID, ErrConflict)
+	}
+	shift, err := cancelShift(ctx, tx, current, employerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return shift, nil
+}
+
+func (s *service) GetShift(ctx context.Context, employerID string, shiftID string) (*Shift, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT`+shiftColumns+` FROM shifts WHERE id = $1`, shiftID)
+	shift, err := scanShift(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("shift %s: %w", shiftID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching shift with id %s: %w", shiftID, err)
+	}
+	if err := authorizeOrganization(ctx, s.db, employerID, shift.OrganizationID); err != nil {
+		return nil, fmt.Errorf("shift %s: %w", shiftID, err)
+	}
+
+	return shift, nil
+}
+
+func (s *service) ListShifts(ctx context.Context, employerID string, filter ShiftFilter) ([]Shift, error) {
+	if employerID == "" {
+		return nil, newValidationError("employer_id", "is required")
+	}
+
+	query := `SELECT` + shiftColumns + `
+		FROM shifts
+		WHERE organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)`
+	args := []interface{}{employerID}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(` AND status = $%d`, len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(` AND ends_at >= $%d`, len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To.AddDate(0, 0, 1))
+		query += fmt.Sprintf(` AND starts_at < $%d`, len(args))
+	}
+	query += ` ORDER BY starts_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying shifts: %w", err)
+	}
+	defer rows.Close()
+
+	return collectShifts(rows)
+}
+
+// getShiftForUpdate locks the shift row for the rest of the transaction and
+// checks that the employer belongs to the organization that owns it.
+func getShiftForUpdate(ctx context.Context, tx *sql.Tx, employerID string, shiftID string) (*Shift, error) {
+	row := tx.QueryRowContext(ctx, `SELECT`+shiftColumns+` FROM shifts WHERE id = $1 FOR UPDATE`, shiftID)
+	shift, err := scanShift(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("shift %s: %w", shiftID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching shift with id %s: %w", shiftID, err)
+	}
+	if err := authorizeOrganization(ctx, tx, employerID, shift.OrganizationID); err != nil {
+		return nil, fmt.Errorf("shift %s: %w", shiftID, err)
+	}
+
+	return shift, nil
+}
+
+// updateShift applies input to a shift locked by getShiftForUpdate, recording
+// the change. Moving the shift re-checks the schedule of every worker already
+// accepted onto it.
+func updateShift(ctx context.Context, tx *sql.Tx, current *Shift, actorID string, input *ShiftInput, location *Location) (*Shift, error) {
+	if input.Headcount < current.ShiftsFilled {
+		return nil, newValidationError("headcount", fmt.Sprintf("cannot be lower than the %d workers already filled", current.ShiftsFilled))
+	}
+	if !input.StartsAt.Equal(current.StartsAt) || !input.EndsAt.Equal(current.EndsAt) || location.Timezone != current.Timezone {
+		moved := *current
+		moved.StartsAt, moved.EndsAt, moved.Timezone = input.StartsAt, input.EndsAt, location.Timezone
+		if err := checkAcceptedWorkers(ctx, tx, &moved); err != nil {
+			return nil, err
+		}
+	}
+
+	row := tx.QueryRowContext(ctx, `
+		UPDATE shifts
+		SET starts_at = $1,
+			ends_at = $2,
+			timezone = $3,
+			location = $4,
+			location_id = $5,
+			shift_name = $6,
+			shift_description = $7,
+			headcount = $8,
+			role = NULLIF($9, ''),
+			hourly_rate = NULLIF($10, 0),
+			updated_by = $11,
+			updated_at = NOW()
+		WHERE id = $12
+		RETURNING`+shiftColumns,
+		input.StartsAt, input.EndsAt, location.Timezone, location.Name, location.ID, strings.TrimSpace(input.ShiftName),
+		input.ShiftDescription, input.Headcount, strings.TrimSpace(input.Role), input.HourlyRate, actorID, current.ID,
+	)
+	shift, err := scanShift(row)
+	if isOverlapViolation(err) {
+		return nil, fmt.Errorf("shift %s would double-book a worker already on it: %w", current.ID, ErrConflict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating shift %s: %w", current.ID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: shift.OrganizationID,
+		Action:     AuditActionUpdate,
+		EntityType: AuditEntityShift,
+		EntityID:   shift.ID,
+		Before:     current,
+		After:      shift,
+	}); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, shift.OrganizationID, EventShiftUpdated, shift.ID, shift); err != nil {
+		return nil, err
+	}
+	return shift, nil
+}
+
+// checkAcceptedWorkers locks each worker accepted onto shift, in a fixed
+// order, and checks the shift against the rest of their schedule.
+func checkAcceptedWorkers(ctx context.Context, tx *sql.Tx, shift *Shift) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT worker_id FROM shift_assignments WHERE shift_id = $1 AND status = $2 ORDER BY worker_id`,
+		shift.ID, AssignmentStatusAccepted,
+	)
+	if err != nil {
+		return fmt.Errorf("error querying workers on shift %s: %w", shift.ID, err)
+	}
+	var workerIDs []string
+	for rows.Next() {
+		var workerID string
+		if err := rows.Scan(&workerID); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning worker row: %w", err)
+		}
+		workerIDs = append(workerIDs, workerID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating worker rows: %w", err)
+	}
+
+	for _, workerID := range workerIDs {
+		if err := lockWorker(ctx, tx, workerID); err != nil {
+			return err
+		}
+		if err := checkScheduleConflicts(ctx, tx, workerID, shift); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelShift cancels a shift locked by getShiftForUpdate, recording the change.
+func cancelShift(ctx context.Context, tx *sql.Tx, current *Shift, actorID string) (*Shift, error) {
+	row := tx.QueryRowContext(ctx, `
+		UPDATE shifts
+		SET status = $1,
+			cancelled_at = NOW(),
+			updated_by = $2,
+			updated_at = NOW()
+		WHERE id = $3
+		RETURNING`+shiftColumns,
+		ShiftStatusCancelled, actorID, current.ID,
+	)
+	shift, err := scanShift(row)
+	if err != nil {
+		return nil, fmt.Errorf("error cancelling shift %s: %w", current.ID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: shift.OrganizationID,
+		Action:     AuditActionCancel,
+		EntityType: AuditEntityShift,
+		EntityID:   shift.ID,
+		Before:     current,
+		After:      shift,
+	}); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, shift.OrganizationID, EventShiftCancelled, shift.ID, shift); err != nil {
+		return nil, err
+	}
+	return shift, nil
+}
+
+func collectShifts(rows *sql.Rows) ([]Shift, error) {
+	shifts := []Shift{}
+	for rows.Next() {
+		shift, err := scanShift(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning shift row: %w", err)
+		}
+		shifts = append(shifts, *shift)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shift rows: %w", err)
+	}
+	return shifts, nil
+}
+
+func validateShiftInput(input *ShiftInput) error {
+	if input == nil {
+		return newValidationError("shift", "is required")
+	}
+	if input.StartsAt.IsZero() {
+		return newValidationError("starts_at", "is required")
+	}
+	if input.EndsAt.IsZero() {
+		return newValidationError("ends_at", "is required")
+	}
+	if !input.EndsAt.After(input.StartsAt) {
+		return newValidationError("ends_at", "must be after starts_at")
+	}
+	if _, err := validateLocationName("location", input.Location); err != nil {
+		return err
+	}
+	if _, err := validateTimezone("timezone", input.Timezone); err != nil {
+		return err
+	}
+	name := strings.TrimSpace(input.ShiftName)
+	if name == "" {
+		return newValidationError("shift_name", "is required")
+	}
+	if len(name) > 255 {
+		return newValidationError("shift_name", "must be at most 255 characters")
+	}
+	if input.Headcount < 1 || input.Headcount > maxShiftHeadcount {
+		return newValidationError("headcount", fmt.Sprintf("must be between 1 and %d", maxShiftHeadcount))
+	}
+	if input.HourlyRate < 0 {
+		return newValidationError("hourly_rate", "must not be negative")
+	}
+
+	return nil
+}
+
+// today returns midnight UTC of the current day, matching DATE columns.
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,635 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/rrule"
+)
+
+// SchedulerActor is recorded as the actor of shifts materialized by the
+// background scheduler.
+const SchedulerActor = "system:scheduler"
+
+const (
+	// defaultShiftSeriesHorizonDays is how far ahead occurrences are
+	// materialized when a series does not say.
+	defaultShiftSeriesHorizonDays = 28
+	maxShiftSeriesHorizonDays     = 365
+)
+
+// ShiftSeries is a recurring shift: a template and an iCalendar RRULE whose
+// occurrences are materialized as ordinary shifts HorizonDays ahead. The rule
+// is expanded in the series' Timezone, so occurrences keep the template's
+// local start and end times across DST changes.
+type ShiftSeries struct {
+	ID             string `json:"id" db:"id"`
+	OrganizationID string `json:"organization_id" db:"organization_id"`
+	RRule          string `json:"rrule" db:"rrule"`
+	// StartsAt and EndsAt are the first occurrence's, in UTC; the Local
+	// variants are the same instants in Timezone.
+	StartsAt      time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt        time.Time `json:"ends_at" db:"ends_at"`
+	StartsAtLocal time.Time `json:"starts_at_local"`
+	EndsAtLocal   time.Time `json:"ends_at_local"`
+	Timezone      string    `json:"timezone" db:"timezone"`
+	// ExceptionDates are local dates the rule produces that get no shift.
+	ExceptionDates      []time.Time `json:"exception_dates" db:"exception_dates"`
+	HorizonDays         int         `json:"horizon_days" db:"horizon_days"`
+	MaterializedThrough *time.Time  `json:"materialized_through" db:"materialized_through"`
+	Location            string      `json:"location" db:"location"`
+	LocationID          string      `json:"location_id" db:"location_id"`
+	ShiftName           string      `json:"shift_name" db:"shift_name"`
+	ShiftDescription    string      `json:"shift_description" db:"shift_description"`
+	Headcount           int         `json:"headcount" db:"headcount"`
+	Role                string      `json:"role" db:"role"`
+	HourlyRate          int64       `json:"hourly_rate" db:"hourly_rate"`
+	CreatedBy           string      `json:"created_by" db:"created_by"`
+	UpdatedBy           string      `json:"updated_by" db:"updated_by"`
+	CreatedAt           time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// ShiftSeriesInput describes a series. The shift's times are those of the
+// first occurrence.
+type ShiftSeriesInput struct {
+	ShiftInput
+	RRule string `json:"rrule"`
+	// ExceptionDates are local dates to skip.
+	ExceptionDates []time.Time `json:"exception_dates"`
+	// HorizonDays is how far ahead to materialize occurrences; zero means the default.
+	HorizonDays int `json:"horizon_days"`
+}
+
+const shiftSeriesColumns = `
+	id,
+	organization_id,
+	rrule,
+	starts_at,
+	ends_at,
+	timezone,
+	exception_dates,
+	horizon_days,
+	materialized_through,
+	location,
+	location_id,
+	shift_name,
+	COALESCE(shift_description, ''),
+	headcount,
+	COALESCE(role, ''),
+	COALESCE(hourly_rate, 0),
+	created_by,
+	COALESCE(updated_by, ''),
+	created_at,
+	COALESCE(updated_at, created_at)`
+
+func scanShiftSeries(row rowScanner) (*ShiftSeries, error) {
+	var series ShiftSeries
+	var exceptionDates []string
+	var materializedThrough sql.NullTime
+	err := row.Scan(
+		&series.ID,
+		&series.OrganizationID,
+		&series.RRule,
+		&series.StartsAt,
+		&series.EndsAt,
+		&series.Timezone,
+		pq.Array(&exceptionDates),
+		&series.HorizonDays,
+		&materializedThrough,
+		&series.Location,
+		&series.LocationID,
+		&series.ShiftName,
+		&series.ShiftDescription,
+		&series.Headcount,
+		&series.Role,
+		&series.HourlyRate,
+		&series.CreatedBy,
+		&series.UpdatedBy,
+		&series.CreatedAt,
+		&series.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	series.ExceptionDates = make([]time.Time, 0, len(exceptionDates))
+	for _, value := range exceptionDates {
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exception date %q: %w", value, err)
+		}
+		series.ExceptionDates = append(series.ExceptionDates, date)
+	}
+	series.MaterializedThrough = nullTimePtr(materializedThrough)
+	loc, err := loadTimezone(series.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("shift series %s has an invalid timezone: %w", series.ID, err)
+	}
+	series.StartsAt, series.EndsAt = series.StartsAt.UTC(), series.EndsAt.UTC()
+	series.StartsAtLocal, series.EndsAtLocal = series.StartsAt.In(loc), series.EndsAt.In(loc)
+	return &series, nil
+}
+
+// occurrences returns the local start of every occurrence on the local dates
+// from through through, skipping exception dates.
+func (series *ShiftSeries) occurrences(rule *rrule.Rule, from, through time.Time) []time.Time {
+	loc := series.StartsAtLocal.Location()
+	window := rule.Between(series.StartsAtLocal, startOfLocalDay(from, loc), startOfLocalDay(through.AddDate(0, 0, 1), loc).Add(-time.Nanosecond))
+	starts := make([]time.Time, 0, len(window))
+	for _, start := range window {
+		if !slices.ContainsFunc(series.ExceptionDates, localDate(start).Equal) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// occurrenceEnd returns when the occurrence starting at start ends: at the
+// template's local end time, as many days after start as the template spans.
+func (series *ShiftSeries) occurrenceEnd(start time.Time) time.Time {
+	days := int(localDate(series.EndsAtLocal).Sub(localDate(series.StartsAtLocal)).Hours() / 24)
+	templateEnd := series.EndsAtLocal
+	end := time.Date(start.Year(), start.Month(), start.Day()+days,
+		templateEnd.Hour(), templateEnd.Minute(), templateEnd.Second(), templateEnd.Nanosecond(), start.Location())
+	if !end.After(start) {
+		// A start pushed forward by a DST gap can overtake the local end time.
+		end = start.Add(series.EndsAt.Sub(series.StartsAt))
+	}
+	return end
+}
+
+func (s *service) CreateShiftSeries(ctx context.Context, employerID string, input *ShiftSeriesInput) (*ShiftSeries, error) {
+	if employerID == "" {
+		return nil, newValidationError("employer_id", "is required")
+	}
+	if err := validateShiftSeriesInput(input); err != nil {
+		return nil, err
+	}
+	if input.StartsAt.Before(time.Now()) {
+		return nil, newValidationError("starts_at", "cannot be in the past")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := organizationForUser(ctx, tx, employerID)
+	if err != nil {
+		return nil, err
+	}
+	series, err := insertShiftSeries(ctx, tx, organizationID, employerID, input)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := materializeShiftSeries(ctx, tx, series, employerID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return series, nil
+}
+
+func (s *service) GetShiftSeries(ctx context.Context, employerID string, seriesID string) (*ShiftSeries, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT`+shiftSeriesColumns+` FROM shift_series WHERE id = $1`, seriesID)
+	series, err := scanShiftSeries(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("shift series %s: %w", seriesID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching shift series with id %s: %w", seriesID, err)
+	}
+	if err := authorizeOrganization(ctx, s.db, employerID, series.OrganizationID); err != nil {
+		return nil, fmt.Errorf("shift series %s: %w", seriesID, err)
+	}
+
+	return series, nil
+}
+
+func (s *service) ListShiftSeries(ctx context.Context, employerID string) ([]ShiftSeries, error) {
+	if employerID == "" {
+		return nil, newValidationError("employer_id", "is required")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+shiftSeriesColumns+`
+		FROM shift_series
+		WHERE organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)
+		ORDER BY starts_at, id`,
+		employerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying shift series: %w", err)
+	}
+	defer rows.Close()
+
+	seriesList := []ShiftSeries{}
+	for rows.Next() {
+		series, err := scanShiftSeries(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning shift series row: %w", err)
+		}
+		seriesList = append(seriesList, *series)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shift series rows: %w", err)
+	}
+	return seriesList, nil
+}
+
+// UpdateFollowingShifts edits a series from one of its occurrences onwards.
+// The original series is ended the day before the occurrence and a new series
+// takes over from there: its existing occurrences are moved onto the new
+// template, those the new rule no longer produces are cancelled and any new
+// ones are materialized. An empty RRule keeps the current rule. Editing just
+// the one occurrence is an ordinary UpdateShift.
+func (s *service) UpdateFollowingShifts(ctx context.Context, employerID string, shiftID string, input *ShiftSeriesInput) (*ShiftSeries, error) {
+	if input == nil {
+		return nil, newValidationError("shift", "is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getShiftForUpdate(ctx, tx, employerID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	if current.SeriesID == "" {
+		return nil, fmt.Errorf("shift %s is not part of a series: %w", shiftID, ErrConflict)
+	}
+	if current.StartsAt.Before(time.Now()) {
+		return nil, fmt.Errorf("shift %s has already started: %w", shiftID, ErrConflict)
+	}
+	series, err := scanShiftSeries(tx.QueryRowContext(ctx, `
+		SELECT`+shiftSeriesColumns+` FROM shift_series WHERE id = $1 FOR UPDATE`,
+		current.SeriesID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching shift series with id %s: %w", current.SeriesID, err)
+	}
+	rule, err := rrule.Parse(series.RRule)
+	if err != nil {
+		return nil, fmt.Errorf("shift series %s has an invalid rule: %w", series.ID, err)
+	}
+	splitOn := *current.OccurrenceDate
+	splitAt := startOfLocalDay(splitOn, series.StartsAtLocal.Location())
+
+	next := *input
+	if next.RRule == "" {
+		// The new series continues the old rule. A COUNT carries over only the
+		// occurrences that are left.
+		continued := *rule
+		if continued.Count > 0 {
+			continued.Count -= len(rule.Between(series.StartsAtLocal, series.StartsAtLocal, splitAt.Add(-time.Second)))
+		}
+		next.RRule = continued.String()
+	}
+	if next.ExceptionDates == nil {
+		next.ExceptionDates = series.ExceptionDates
+	}
+	if next.HorizonDays == 0 {
+		next.HorizonDays = series.HorizonDays
+	}
+	if err := validateShiftSeriesInput(&next); err != nil {
+		return nil, err
+	}
+	if next.StartsAt.Before(time.Now()) {
+		return nil, newValidationError("starts_at", "cannot be in the past")
+	}
+	if next.StartsAt.Before(splitAt) {
+		return nil, newValidationError("starts_at", "cannot be before the occurrence being edited")
+	}
+
+	rule.Count = 0
+	rule.Until = splitAt.Add(-time.Second)
+	ended, err := scanShiftSeries(tx.QueryRowContext(ctx, `
+		UPDATE shift_series
+		SET rrule = $1,
+			updated_by = $2,
+			updated_at = NOW()
+		WHERE id = $3
+		RETURNING`+shiftSeriesColumns,
+		rule.String(), employerID, series.ID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error ending shift series %s: %w", series.ID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: ended.OrganizationID,
+		Action:     AuditActionUpdate,
+		EntityType: AuditEntityShiftSeries,
+		EntityID:   ended.ID,
+		Before:     series,
+		After:      ended,
+	}); err != nil {
+		return nil, err
+	}
+
+	successor, err := insertShiftSeries(ctx, tx, series.OrganizationID, employerID, &next)
+	if err != nil {
+		return nil, err
+	}
+	if err := moveOccurrences(ctx, tx, series.ID, successor, splitOn, employerID); err != nil {
+		return nil, err
+	}
+	if _, err := materializeShiftSeries(ctx, tx, successor, employerID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return successor, nil
+}
+
+// MaterializeShiftSeries creates the shifts every series is due to have within
+// its horizon and returns how many were created. It is run periodically so
+// that horizons keep rolling forward.
+func (s *service) MaterializeShiftSeries(ctx context.Context) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id
+		FROM shift_series
+		WHERE materialized_through IS NULL
+		OR materialized_through < (NOW() AT TIME ZONE timezone)::date + horizon_days
+		ORDER BY id`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error querying shift series to materialize: %w", err)
+	}
+	var seriesIDs []string
+	for rows.Next() {
+		var seriesID string
+		if err := rows.Scan(&seriesID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning shift series id: %w", err)
+		}
+		seriesIDs = append(seriesIDs, seriesID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating shift series rows: %w", err)
+	}
+
+	created := 0
+	for _, seriesID := range seriesIDs {
+		n, err := s.materializeShiftSeriesByID(ctx, seriesID)
+		if err != nil {
+			return created, err
+		}
+		created += n
+	}
+	return created, nil
+}
+
+func (s *service) materializeShiftSeriesByID(ctx context.Context, seriesID string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	series, err := scanShiftSeries(tx.QueryRowContext(ctx, `
+		SELECT`+shiftSeriesColumns+` FROM shift_series WHERE id = $1 FOR UPDATE`,
+		seriesID,
+	))
+	if err != nil {
+		return 0, fmt.Errorf("error fetching shift series with id %s: %w", seriesID, err)
+	}
+	created, err := materializeShiftSeries(ctx, tx, series, SchedulerActor)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
+// insertShiftSeries creates a series from validated input and records it. The
+// series takes its location's timezone.
+func insertShiftSeries(ctx context.Context, tx *sql.Tx, organizationID string, actorID string, input *ShiftSeriesInput) (*ShiftSeries, error) {
+	location, _, err := ensureLocation(ctx, tx, organizationID, actorID, strings.TrimSpace(input.Location), strings.TrimSpace(input.Timezone))
+	if err != nil {
+		return nil, err
+	}
+	horizonDays := input.HorizonDays
+	if horizonDays == 0 {
+		horizonDays = defaultShiftSeriesHorizonDays
+	}
+	exceptionDates := []string{}
+	for _, date := range normalizeExceptionDates(input.ExceptionDates) {
+		exceptionDates = append(exceptionDates, date.Format(time.DateOnly))
+	}
+
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO shift_series (id, organization_id, rrule, starts_at, ends_at, timezone, exception_dates, horizon_days, location, location_id, shift_name, shift_description, headcount, role, hourly_rate, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), NULLIF($15, 0), $16)
+		RETURNING`+shiftSeriesColumns,
+		generateID(ShiftSeriesPrefix), organizationID, input.RRule, input.StartsAt, input.EndsAt, location.Timezone,
+		pq.Array(exceptionDates), horizonDays, location.Name, location.ID, strings.TrimSpace(input.ShiftName),
+		input.ShiftDescription, input.Headcount, strings.TrimSpace(input.Role), input.HourlyRate, actorID,
+	)
+	series, err := scanShiftSeries(row)
+	if err != nil {
+		return nil, fmt.Errorf("error creating shift series: %w", err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    actorID,
+		EmployerID: organizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityShiftSeries,
+		EntityID:   series.ID,
+		After:      series,
+	}); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// materializeShiftSeries creates the missing occurrences of a locked series
+// from now up to its horizon. Occurrences that already exist, including
+// cancelled ones, are left alone, so it is safe to run repeatedly.
+func materializeShiftSeries(ctx context.Context, tx *sql.Tx, series *ShiftSeries, actorID string) (int, error) {
+	rule, err := rrule.Parse(series.RRule)
+	if err != nil {
+		return 0, fmt.Errorf("shift series %s has an invalid rule: %w", series.ID, err)
+	}
+	now := time.Now()
+	localToday := localDate(now.In(series.StartsAtLocal.Location()))
+	from := localToday
+	if series.MaterializedThrough != nil && !series.MaterializedThrough.Before(from) {
+		from = series.MaterializedThrough.AddDate(0, 0, 1)
+	}
+	through := localToday.AddDate(0, 0, series.HorizonDays)
+
+	created := 0
+	for _, start := range series.occurrences(rule, from, through) {
+		if start.Before(now) {
+			continue
+		}
+		occurrenceDate := localDate(start)
+		row := tx.QueryRowContext(ctx, `
+			INSERT INTO shifts (id, starts_at, ends_at, timezone, location, location_id, shift_name, shifts_filled, headcount, status, role, hourly_rate, shift_description, organization_id, series_id, occurrence_date, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, 0, $8, $9, NULLIF($10, ''), NULLIF($11, 0), $12, $13, $14, $15, $16)
+			ON CONFLICT (series_id, occurrence_date) DO NOTHING
+			RETURNING`+shiftColumns,
+			generateID(ShiftPrefix), start, series.occurrenceEnd(start), series.Timezone, series.Location, series.LocationID,
+			series.ShiftName, series.Headcount, ShiftStatusOpen, series.Role, series.HourlyRate, series.ShiftDescription,
+			series.OrganizationID, series.ID, occurrenceDate, actorID,
+		)
+		shift, err := scanShift(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error materializing shift series %s on %s: %w", series.ID, occurrenceDate.Format(time.DateOnly), err)
+		}
+		if err := recordAudit(ctx, tx, auditRecord{
+			ActorID:    actorID,
+			EmployerID: series.OrganizationID,
+			Action:     AuditActionCreate,
+			EntityType: AuditEntityShift,
+			EntityID:   shift.ID,
+			After:      shift,
+		}); err != nil {
+			return 0, err
+		}
+		if err := recordEvent(ctx, tx, series.OrganizationID, EventShiftCreated, shift.ID, shift); err != nil {
+			return 0, err
+		}
+		created++
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE shift_series SET materialized_through = $1 WHERE id = $2`,
+		through, series.ID,
+	); err != nil {
+		return 0, fmt.Errorf("error updating shift series %s: %w", series.ID, err)
+	}
+	series.MaterializedThrough = &through
+	return created, nil
+}
+
+// moveOccurrences hands the occurrences of a series on or after splitOn over
+// to its successor. Open occurrences the successor's rule still produces take
+// on its template, the rest are cancelled. Cancelled occurrences move too when
+// the rule still produces them, so they are not materialized again.
+func moveOccurrences(ctx context.Context, tx *sql.Tx, seriesID string, successor *ShiftSeries, splitOn time.Time, actorID string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT`+shiftColumns+`
+		FROM shifts
+		WHERE series_id = $1 AND occurrence_date >= $2
+		ORDER BY occurrence_date
+		FOR UPDATE`,
+		seriesID, splitOn,
+	)
+	if err != nil {
+		return fmt.Errorf("error querying occurrences of shift series %s: %w", seriesID, err)
+	}
+	occurrences, err := collectShifts(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	rule, err := rrule.Parse(successor.RRule)
+	if err != nil {
+		return fmt.Errorf("shift series %s has an invalid rule: %w", successor.ID, err)
+	}
+	last := *occurrences[len(occurrences)-1].OccurrenceDate
+	starts := map[time.Time]time.Time{}
+	for _, start := range successor.occurrences(rule, localDate(successor.StartsAtLocal), last) {
+		starts[localDate(start)] = start
+	}
+	location := &Location{ID: successor.LocationID, Name: successor.Location, Timezone: successor.Timezone}
+
+	for i := range occurrences {
+		current := &occurrences[i]
+		start, kept := starts[localDate(*current.OccurrenceDate)]
+		if !kept {
+			if current.Status == ShiftStatusOpen {
+				if _, err := cancelShift(ctx, tx, current, actorID); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE shifts SET series_id = $1 WHERE id = $2`, successor.ID, current.ID); err != nil {
+			return fmt.Errorf("error moving shift %s to series %s: %w", current.ID, successor.ID, err)
+		}
+		if current.Status != ShiftStatusOpen {
+			continue
+		}
+		if _, err := updateShift(ctx, tx, current, actorID, &ShiftInput{
+			StartsAt:         start,
+			EndsAt:           successor.occurrenceEnd(start),
+			ShiftName:        successor.ShiftName,
+			ShiftDescription: successor.ShiftDescription,
+			Headcount:        successor.Headcount,
+			Role:             successor.Role,
+			HourlyRate:       successor.HourlyRate,
+		}, location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateShiftSeriesInput(input *ShiftSeriesInput) error {
+	if input == nil {
+		return newValidationError("shift", "is required")
+	}
+	if err := validateShiftInput(&input.ShiftInput); err != nil {
+		return err
+	}
+	if strings.TrimSpace(input.RRule) == "" {
+		return newValidationError("rrule", "is required")
+	}
+	rule, err := rrule.Parse(input.RRule)
+	if err != nil {
+		return newValidationError("rrule", err.Error())
+	}
+	// Stored in canonical form so a later edit can rewrite it.
+	input.RRule = rule.String()
+	if input.HorizonDays < 0 || input.HorizonDays > maxShiftSeriesHorizonDays {
+		return newValidationError("horizon_days", fmt.Sprintf("must be between 1 and %d", maxShiftSeriesHorizonDays))
+	}
+	for _, date := range input.ExceptionDates {
+		if date.IsZero() {
+			return newValidationError("exception_dates", "must be valid dates")
+		}
+	}
+	return nil
+}
+
+// normalizeExceptionDates reduces dates to the calendar date they were given
+// on, as midnight UTC to match occurrence dates, and sorts and deduplicates them.
+func normalizeExceptionDates(dates []time.Time) []time.Time {
+	normalized := make([]time.Time, 0, len(dates))
+	for _, date := range dates {
+		normalized = append(normalized, localDate(date))
+	}
+	slices.SortFunc(normalized, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(normalized, func(a, b time.Time) bool { return a.Equal(b) })
+}
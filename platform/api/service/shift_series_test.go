@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/rrule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validShiftSeriesInput() *ShiftSeriesInput {
+	return &ShiftSeriesInput{
+		ShiftInput:  *validShiftInput(),
+		RRule:       "FREQ=DAILY",
+		HorizonDays: 6,
+	}
+}
+
+func Test_CreateShiftSeries(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	outsiderID := createTestUser(t, db, "Outsider")
+
+	input := validShiftSeriesInput()
+	skipped := localDate(input.StartsAt).AddDate(0, 0, 2)
+	input.ExceptionDates = append(input.ExceptionDates, skipped)
+
+	series, err := svc.CreateShiftSeries(ctx, employerID, input)
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=DAILY", series.RRule)
+	assert.Equal(t, DefaultTimezone, series.Timezone)
+	require.NotNil(t, series.MaterializedThrough)
+	assert.True(t, series.MaterializedThrough.Equal(today().AddDate(0, 0, 6)))
+
+	shifts, err := svc.ListShifts(ctx, employerID, ShiftFilter{})
+	require.NoError(t, err)
+	// Tomorrow through six days out, less the exception.
+	require.Len(t, shifts, 5)
+	for _, shift := range shifts {
+		assert.Equal(t, series.ID, shift.SeriesID)
+		require.NotNil(t, shift.OccurrenceDate)
+		assert.True(t, localDate(shift.StartsAtLocal).Equal(*shift.OccurrenceDate))
+		assert.Equal(t, 9, shift.StartsAtLocal.Hour())
+		assert.True(t, shift.EndsAt.Equal(shift.StartsAt.Add(8*time.Hour)))
+		assert.False(t, shift.OccurrenceDate.Equal(skipped), "exception dates are skipped")
+		assert.Equal(t, input.Headcount, shift.Headcount)
+		assert.Equal(t, series.LocationID, shift.LocationID)
+	}
+
+	created, err := svc.MaterializeShiftSeries(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, created, "materializing again creates nothing")
+
+	_, err = svc.GetShiftSeries(ctx, outsiderID, series.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	seriesList, err := svc.ListShiftSeries(ctx, employerID)
+	require.NoError(t, err)
+	require.Len(t, seriesList, 1)
+	require.Len(t, seriesList[0].ExceptionDates, 1)
+	assert.True(t, seriesList[0].ExceptionDates[0].Equal(skipped))
+
+	t.Run("cancelled occurrences are not recreated", func(t *testing.T) {
+		_, err := svc.CancelShift(ctx, employerID, shifts[0].ID)
+		require.NoError(t, err)
+		_, err = db.Exec(`UPDATE shift_series SET materialized_through = NULL WHERE id = $1`, series.ID)
+		require.NoError(t, err)
+
+		created, err := svc.MaterializeShiftSeries(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, created)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		input := validShiftSeriesInput()
+		input.RRule = "FREQ=HOURLY"
+		_, err := svc.CreateShiftSeries(ctx, employerID, input)
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	clearTestData(t, db)
+}
+
+func Test_UpdateFollowingShifts(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+
+	series, err := svc.CreateShiftSeries(ctx, employerID, validShiftSeriesInput())
+	require.NoError(t, err)
+	shifts, err := svc.ListShifts(ctx, employerID, ShiftFilter{})
+	require.NoError(t, err)
+	require.Len(t, shifts, 6)
+
+	// Edit this occurrence only: it keeps its place in the series.
+	moved := validShiftInput()
+	moved.StartsAt = shifts[1].StartsAt
+	moved.EndsAt = shifts[1].EndsAt
+	moved.ShiftName = "Inventory Count"
+	single, err := svc.UpdateShift(ctx, employerID, shifts[1].ID, moved)
+	require.NoError(t, err)
+	assert.Equal(t, series.ID, single.SeriesID)
+	assert.Equal(t, "Inventory Count", single.ShiftName)
+
+	// Edit this and following from the third occurrence: every other day, larger crew.
+	input := validShiftSeriesInput()
+	input.StartsAt = shifts[2].StartsAt
+	input.EndsAt = shifts[2].StartsAt.Add(4 * time.Hour)
+	input.RRule = "FREQ=DAILY;INTERVAL=2"
+	input.Headcount = 5
+	successor, err := svc.UpdateFollowingShifts(ctx, employerID, shifts[2].ID, input)
+	require.NoError(t, err)
+	assert.NotEqual(t, series.ID, successor.ID)
+
+	ended, err := svc.GetShiftSeries(ctx, employerID, series.ID)
+	require.NoError(t, err)
+	// The original series ends at the last second of the day before the split.
+	assert.Equal(t, "FREQ=DAILY;UNTIL="+shifts[1].OccurrenceDate.Format("20060102")+"T235959Z", ended.RRule)
+
+	after, err := svc.ListShifts(ctx, employerID, ShiftFilter{})
+	require.NoError(t, err)
+	byID := map[string]Shift{}
+	for _, shift := range after {
+		byID[shift.ID] = shift
+	}
+	assert.Equal(t, series.ID, byID[shifts[0].ID].SeriesID, "earlier occurrences stay behind")
+	assert.Equal(t, "Inventory Count", byID[shifts[1].ID].ShiftName)
+	for i, original := range shifts[2:] {
+		shift := byID[original.ID]
+		if i%2 == 0 {
+			assert.Equal(t, successor.ID, shift.SeriesID)
+			assert.Equal(t, ShiftStatusOpen, shift.Status)
+			assert.Equal(t, 5, shift.Headcount)
+			assert.True(t, shift.EndsAt.Equal(shift.StartsAt.Add(4*time.Hour)))
+		} else {
+			assert.Equal(t, series.ID, shift.SeriesID)
+			assert.Equal(t, ShiftStatusCancelled, shift.Status, "dates the new rule skips are cancelled")
+		}
+	}
+
+	t.Run("one-off shifts have no series", func(t *testing.T) {
+		shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+		require.NoError(t, err)
+		_, err = svc.UpdateFollowingShifts(ctx, employerID, shift.ID, validShiftSeriesInput())
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	clearTestData(t, db)
+}
+
+func Test_ShiftSeriesOccurrencesAcrossDST(t *testing.T) {
+	loc, err := loadTimezone("America/New_York")
+	require.NoError(t, err)
+
+	// US daylight saving time ends on 1 November 2026.
+	start := time.Date(2026, time.October, 30, 22, 0, 0, 0, loc)
+	series := &ShiftSeries{
+		StartsAt:      start.UTC(),
+		EndsAt:        start.Add(9 * time.Hour).UTC(),
+		StartsAtLocal: start,
+		EndsAtLocal:   start.Add(9 * time.Hour),
+		Timezone:      "America/New_York",
+	}
+	rule, err := rrule.Parse("FREQ=DAILY")
+	require.NoError(t, err)
+
+	starts := series.occurrences(rule, localDate(start), localDate(start).AddDate(0, 0, 3))
+	require.Len(t, starts, 4)
+	for _, occurrence := range starts {
+		end := series.occurrenceEnd(occurrence)
+		assert.Equal(t, 22, occurrence.Hour(), "starts at the same local time")
+		assert.Equal(t, 7, end.Hour(), "ends at the same local time")
+	}
+	// The overnight shift spanning the change is an hour longer.
+	assert.Equal(t, 10*time.Hour, series.occurrenceEnd(starts[1]).Sub(starts[1]))
+	assert.Equal(t, 9*time.Hour, series.occurrenceEnd(starts[2]).Sub(starts[2]))
+}
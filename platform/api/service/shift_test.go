@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validShiftInput() *ShiftInput {
+	start := today().AddDate(0, 0, 1).Add(9 * time.Hour)
+	return &ShiftInput{
+		StartsAt:         start,
+		EndsAt:           start.Add(8 * time.Hour),
+		Location:         "Main Street Warehouse",
+		ShiftName:        "Morning Picking",
+		ShiftDescription: "Pick and pack orders",
+		Headcount:        3,
+	}
+}
+
+func Test_CreateShift(t *testing.T) {
+	svc := NewService(db)
+	employerID := createTestUser(t, db, "Employer")
+
+	tests := []struct {
+		name          string
+		employerID    string
+		input         func() *ShiftInput
+		expectedError bool
+		errorField    string
+	}{
+		{
+			name:       "successful shift creation",
+			employerID: employerID,
+			input:      validShiftInput,
+		},
+		{
+			name:       "ends before it starts",
+			employerID: employerID,
+			input: func() *ShiftInput {
+				input := validShiftInput()
+				input.EndsAt = input.StartsAt.Add(-time.Hour)
+				return input
+			},
+			expectedError: true,
+			errorField:    "ends_at",
+		},
+		{
+			name:       "starts in the past",
+			employerID: employerID,
+			input: func() *ShiftInput {
+				input := validShiftInput()
+				input.StartsAt = time.Now().Add(-time.Hour)
+				return input
+			},
+			expectedError: true,
+			errorField:    "starts_at",
+		},
+		{
+			name:       "unknown timezone",
+			employerID: employerID,
+			input: func() *ShiftInput {
+				input := validShiftInput()
+				input.Timezone = "Mars/Olympus_Mons"
+				return input
+			},
+			expectedError: true,
+			errorField:    "timezone",
+		},
+		{
+			name:       "missing location",
+			employerID: employerID,
+			input: func() *ShiftInput {
+				input := validShiftInput()
+				input.Location = "   "
+				return input
+			},
+			expectedError: true,
+			errorField:    "location",
+		},
+		{
+			name:       "zero headcount",
+			employerID: employerID,
+			input: func() *ShiftInput {
+				input := validShiftInput()
+				input.Headcount = 0
+				return input
+			},
+			expectedError: true,
+			errorField:    "headcount",
+		},
+		{
+			name:          "empty employer ID",
+			employerID:    "",
+			input:         validShiftInput,
+			expectedError: true,
+			errorField:    "employer_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shift, err := svc.CreateShift(context.Background(), tt.employerID, tt.input())
+
+			if tt.expectedError {
+				var validationErr *ValidationError
+				assert.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, tt.errorField, validationErr.Field)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotEmpty(t, shift.ID)
+			assert.Equal(t, ShiftStatusOpen, shift.Status)
+			assert.Equal(t, 3, shift.Headcount)
+			assert.Equal(t, 0, shift.ShiftsFilled)
+			assert.Equal(t, tt.employerID, shift.CreatedBy)
+		})
+	}
+
+	clearTestData(t, db)
+}
+
+func Test_UpdateShift(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	otherEmployerID := createTestUser(t, db, "Other")
+
+	shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+
+	t.Run("owner can update", func(t *testing.T) {
+		input := validShiftInput()
+		input.ShiftName = "Evening Picking"
+		input.Headcount = 5
+
+		updated, err := svc.UpdateShift(ctx, employerID, shift.ID, input)
+		require.NoError(t, err)
+		assert.Equal(t, "Evening Picking", updated.ShiftName)
+		assert.Equal(t, 5, updated.Headcount)
+		assert.Equal(t, employerID, updated.UpdatedBy)
+	})
+
+	t.Run("other employer is forbidden", func(t *testing.T) {
+		_, err := svc.UpdateShift(ctx, otherEmployerID, shift.ID, validShiftInput())
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("unknown shift", func(t *testing.T) {
+		_, err := svc.UpdateShift(ctx, employerID, "shift_missing", validShiftInput())
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	clearTestData(t, db)
+}
+
+func Test_CancelShift(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+
+	shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+
+	_, err = svc.CancelShift(ctx, generateID(UserPrefix), shift.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	cancelled, err := svc.CancelShift(ctx, employerID, shift.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ShiftStatusCancelled, cancelled.Status)
+
+	_, err = svc.CancelShift(ctx, employerID, shift.ID)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = svc.UpdateShift(ctx, employerID, shift.ID, validShiftInput())
+	assert.ErrorIs(t, err, ErrConflict)
+
+	clearTestData(t, db)
+}
+
+func Test_ListShifts(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+
+	first, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+
+	later := validShiftInput()
+	later.StartsAt = today().AddDate(0, 0, 10)
+	later.EndsAt = later.StartsAt.Add(time.Hour)
+	second, err := svc.CreateShift(ctx, employerID, later)
+	require.NoError(t, err)
+
+	_, err = svc.CreateShift(ctx, createTestUser(t, db, "Other"), validShiftInput())
+	require.NoError(t, err)
+
+	_, err = svc.CancelShift(ctx, employerID, first.ID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		filter      ShiftFilter
+		expectedIDs []string
+	}{
+		{
+			name:        "all shifts for employer",
+			filter:      ShiftFilter{},
+			expectedIDs: []string{first.ID, second.ID},
+		},
+		{
+			name:        "open shifts only",
+			filter:      ShiftFilter{Status: ShiftStatusOpen},
+			expectedIDs: []string{second.ID},
+		},
+		{
+			name:        "shifts starting after a date",
+			filter:      ShiftFilter{From: today().Add(5 * 24 * time.Hour)},
+			expectedIDs: []string{second.ID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shifts, err := svc.ListShifts(ctx, employerID, tt.filter)
+			require.NoError(t, err)
+
+			var ids []string
+			for _, shift := range shifts {
+				ids = append(ids, shift.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+
+	clearTestData(t, db)
+}
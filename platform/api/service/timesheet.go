@@ -0,0 +1,701 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type TimesheetStatus string
+
+const (
+	TimesheetStatusClockedIn  TimesheetStatus = "clocked_in"
+	TimesheetStatusClockedOut TimesheetStatus = "clocked_out"
+	TimesheetStatusApproved   TimesheetStatus = "approved"
+)
+
+// maxTimesheetDuration guards against corrections that would bill an absurd number of hours.
+const maxTimesheetDuration = 24 * time.Hour
+
+// clockInLeadTime is how long before a shift starts workers may clock in.
+const clockInLeadTime = 30 * time.Minute
+
+type TimesheetBreak struct {
+	ID        string     `json:"id" db:"id"`
+	StartedAt time.Time  `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time `json:"ended_at" db:"ended_at"`
+}
+
+// Timesheet records the hours a worker actually spent on a shift.
+type Timesheet struct {
+	ID           string           `json:"id" db:"id"`
+	ShiftID      string           `json:"shift_id" db:"shift_id"`
+	AssignmentID string           `json:"assignment_id" db:"assignment_id"`
+	WorkerID     string           `json:"worker_id" db:"worker_id"`
+	Status       TimesheetStatus  `json:"status" db:"status"`
+	ClockInAt    time.Time        `json:"clock_in_at" db:"clock_in_at"`
+	ClockOutAt   *time.Time       `json:"clock_out_at" db:"clock_out_at"`
+	Breaks       []TimesheetBreak `json:"breaks"`
+	Version      int              `json:"version" db:"version"`
+	ApprovedBy   string           `json:"approved_by" db:"approved_by"`
+	ApprovedAt   *time.Time       `json:"approved_at" db:"approved_at"`
+	CreatedAt    time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// WorkedDuration is the time between clock in and clock out minus any breaks.
+// It is zero while the worker is still clocked in.
+func (t *Timesheet) WorkedDuration() time.Duration {
+	if t.ClockOutAt == nil {
+		return 0
+	}
+	worked := t.ClockOutAt.Sub(t.ClockInAt)
+	for _, b := range t.Breaks {
+		if b.EndedAt != nil {
+			worked -= b.EndedAt.Sub(b.StartedAt)
+		}
+	}
+	return worked
+}
+
+// TimesheetVersion is an immutable snapshot of a timesheet after one edit.
+type TimesheetVersion struct {
+	Version    int              `json:"version" db:"version"`
+	Status     TimesheetStatus  `json:"status" db:"status"`
+	ClockInAt  time.Time        `json:"clock_in_at" db:"clock_in_at"`
+	ClockOutAt *time.Time       `json:"clock_out_at" db:"clock_out_at"`
+	Breaks     []TimesheetBreak `json:"breaks" db:"breaks"`
+	Reason     string           `json:"reason" db:"reason"`
+	CreatedBy  string           `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time        `json:"created_at" db:"created_at"`
+}
+
+type BreakInput struct {
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+// TimesheetCorrection replaces the recorded times of a timesheet. Version must
+// match the current version so two employers cannot overwrite each other.
+type TimesheetCorrection struct {
+	ClockInAt  time.Time    `json:"clock_in_at"`
+	ClockOutAt time.Time    `json:"clock_out_at"`
+	Breaks     []BreakInput `json:"breaks"`
+	Reason     string       `json:"reason"`
+	Version    int          `json:"version"`
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const timesheetColumns = `
+	id,
+	shift_id,
+	assignment_id,
+	worker_id,
+	status,
+	clock_in_at,
+	clock_out_at,
+	version,
+	COALESCE(approved_by, ''),
+	approved_at,
+	created_at,
+	COALESCE(updated_at, created_at)`
+
+func scanTimesheet(row rowScanner) (*Timesheet, error) {
+	var timesheet Timesheet
+	var clockOutAt, approvedAt sql.NullTime
+	err := row.Scan(
+		&timesheet.ID,
+		&timesheet.ShiftID,
+		&timesheet.AssignmentID,
+		&timesheet.WorkerID,
+		&timesheet.Status,
+		&timesheet.ClockInAt,
+		&clockOutAt,
+		&timesheet.Version,
+		&timesheet.ApprovedBy,
+		&approvedAt,
+		&timesheet.CreatedAt,
+		&timesheet.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	timesheet.ClockOutAt = nullTimePtr(clockOutAt)
+	timesheet.ApprovedAt = nullTimePtr(approvedAt)
+	timesheet.Breaks = []TimesheetBreak{}
+	return &timesheet, nil
+}
+
+func (s *service) ClockIn(ctx context.Context, workerID string, shiftID string) (*Timesheet, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var assignmentID, organizationID string
+	var shiftStatus ShiftStatus
+	var startsAt, endsAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT a.id, s.status, s.starts_at, s.ends_at, s.organization_id
+		FROM shift_assignments a
+		JOIN shifts s ON a.shift_id = s.id
+		WHERE a.shift_id = $1
+		AND a.worker_id = $2
+		AND a.status = $3
+		FOR UPDATE OF a`,
+		shiftID, workerID, AssignmentStatusAccepted,
+	).Scan(&assignmentID, &shiftStatus, &startsAt, &endsAt, &organizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("worker is not assigned to shift %s: %w", shiftID, ErrForbidden)
+		}
+		return nil, fmt.Errorf("error fetching assignment: %w", err)
+	}
+	if shiftStatus != ShiftStatusOpen {
+		return nil, fmt.Errorf("shift %s is %s: %w", shiftID, shiftStatus, ErrConflict)
+	}
+
+	now := time.Now().UTC()
+	if now.Before(startsAt.Add(-clockInLeadTime)) || !now.Before(endsAt) {
+		return nil, newValidationError("clock_in_at", "can only clock in while the shift is running")
+	}
+
+	var open int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM timesheets WHERE assignment_id = $1 AND status = $2`,
+		assignmentID, TimesheetStatusClockedIn,
+	).Scan(&open); err != nil {
+		return nil, fmt.Errorf("error checking open timesheets: %w", err)
+	}
+	if open > 0 {
+		return nil, fmt.Errorf("worker is already clocked in to shift %s: %w", shiftID, ErrConflict)
+	}
+
+	timesheetID := generateID(TimesheetPrefix)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO timesheets (id, shift_id, assignment_id, worker_id, status, clock_in_at, version, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, 0, $7)`,
+		timesheetID, shiftID, assignmentID, workerID, TimesheetStatusClockedIn, now, workerID,
+	); err != nil {
+		return nil, fmt.Errorf("error clocking in: %w", err)
+	}
+
+	timesheet, err := recordTimesheetVersion(ctx, tx, timesheetID, workerID, "clock in")
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    workerID,
+		EmployerID: organizationID,
+		Action:     AuditActionClockIn,
+		EntityType: AuditEntityTimesheet,
+		EntityID:   timesheetID,
+		After:      timesheet,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return timesheet, nil
+}
+
+func (s *service) StartBreak(ctx context.Context, workerID string, shiftID string, timesheetID string) (*Timesheet, error) {
+	return s.updateOwnTimesheet(ctx, workerID, shiftID, timesheetID, AuditActionStartBreak, "start break", func(tx *sql.Tx, timesheet *Timesheet, now time.Time) error {
+		if openBreak(timesheet) != nil {
+			return fmt.Errorf("worker is already on a break: %w", ErrConflict)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO timesheet_breaks (id, timesheet_id, started_at)
+			VALUES ($1, $2, $3)`,
+			generateID(BreakPrefix), timesheet.ID, now,
+		); err != nil {
+			return fmt.Errorf("error starting break: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *service) EndBreak(ctx context.Context, workerID string, shiftID string, timesheetID string) (*Timesheet, error) {
+	return s.updateOwnTimesheet(ctx, workerID, shiftID, timesheetID, AuditActionEndBreak, "end break", func(tx *sql.Tx, timesheet *Timesheet, now time.Time) error {
+		current := openBreak(timesheet)
+		if current == nil {
+			return fmt.Errorf("worker is not on a break: %w", ErrConflict)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE timesheet_breaks SET ended_at = $1 WHERE id = $2`,
+			now, current.ID,
+		); err != nil {
+			return fmt.Errorf("error ending break: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *service) ClockOut(ctx context.Context, workerID string, shiftID string, timesheetID string) (*Timesheet, error) {
+	return s.updateOwnTimesheet(ctx, workerID, shiftID, timesheetID, AuditActionClockOut, "clock out", func(tx *sql.Tx, timesheet *Timesheet, now time.Time) error {
+		// Clocking out ends any break the worker forgot to close.
+		if current := openBreak(timesheet); current != nil {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE timesheet_breaks SET ended_at = $1 WHERE id = $2`,
+				now, current.ID,
+			); err != nil {
+				return fmt.Errorf("error ending break: %w", err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE timesheets
+			SET status = $1, clock_out_at = $2
+			WHERE id = $3`,
+			TimesheetStatusClockedOut, now, timesheet.ID,
+		); err != nil {
+			return fmt.Errorf("error clocking out: %w", err)
+		}
+		return nil
+	})
+}
+
+// updateOwnTimesheet applies a worker-initiated change to a timesheet that is
+// still clocked in and records the result as a new version.
+func (s *service) updateOwnTimesheet(
+	ctx context.Context,
+	workerID, shiftID, timesheetID string,
+	action AuditAction,
+	reason string,
+	apply func(tx *sql.Tx, timesheet *Timesheet, now time.Time) error,
+) (*Timesheet, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	timesheet, err := getTimesheet(ctx, tx, shiftID, timesheetID, true)
+	if err != nil {
+		return nil, err
+	}
+	if timesheet.WorkerID != workerID {
+		return nil, fmt.Errorf("timesheet %s: %w", timesheetID, ErrForbidden)
+	}
+	if timesheet.Status != TimesheetStatusClockedIn {
+		return nil, fmt.Errorf("timesheet %s is %s: %w", timesheetID, timesheet.Status, ErrConflict)
+	}
+
+	if err := apply(tx, timesheet, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	before := timesheet
+	timesheet, err = recordTimesheetVersion(ctx, tx, timesheetID, workerID, reason)
+	if err != nil {
+		return nil, err
+	}
+	var organizationID string
+	if err := tx.QueryRowContext(ctx, `SELECT organization_id FROM shifts WHERE id = $1`, shiftID).Scan(&organizationID); err != nil {
+		return nil, fmt.Errorf("error fetching shift with id %s: %w", shiftID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    workerID,
+		EmployerID: organizationID,
+		Action:     action,
+		EntityType: AuditEntityTimesheet,
+		EntityID:   timesheetID,
+		Before:     before,
+		After:      timesheet,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return timesheet, nil
+}
+
+// ListShiftTimesheets returns every timesheet on the shift to members of its
+// organization and only their own timesheets to a worker.
+func (s *service) ListShiftTimesheets(ctx context.Context, userID string, shiftID string) ([]Timesheet, error) {
+	var organizationID string
+	err := s.db.QueryRowContext(ctx, `SELECT organization_id FROM shifts WHERE id = $1`, shiftID).Scan(&organizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("shift %s: %w", shiftID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching shift with id %s: %w", shiftID, err)
+	}
+
+	member, err := isOrganizationMember(ctx, s.db, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT` + timesheetColumns + ` FROM timesheets WHERE shift_id = $1`
+	args := []interface{}{shiftID}
+	if !member {
+		query += ` AND worker_id = $2`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY clock_in_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying timesheets: %w", err)
+	}
+	defer rows.Close()
+
+	timesheets := []Timesheet{}
+	for rows.Next() {
+		timesheet, err := scanTimesheet(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning timesheet row: %w", err)
+		}
+		timesheets = append(timesheets, *timesheet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating timesheet rows: %w", err)
+	}
+
+	for i := range timesheets {
+		if timesheets[i].Breaks, err = getTimesheetBreaks(ctx, s.db, timesheets[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return timesheets, nil
+}
+
+func (s *service) GetTimesheetVersions(ctx context.Context, userID string, shiftID string, timesheetID string) ([]TimesheetVersion, error) {
+	timesheet, err := getTimesheet(ctx, s.db, shiftID, timesheetID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTimesheetAccess(ctx, userID, timesheet); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT version, status, clock_in_at, clock_out_at, breaks, reason, created_by, created_at
+		FROM timesheet_versions
+		WHERE timesheet_id = $1
+		ORDER BY version`,
+		timesheetID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying timesheet versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []TimesheetVersion{}
+	for rows.Next() {
+		var version TimesheetVersion
+		var clockOutAt sql.NullTime
+		var breaks []byte
+		if err := rows.Scan(
+			&version.Version,
+			&version.Status,
+			&version.ClockInAt,
+			&clockOutAt,
+			&breaks,
+			&version.Reason,
+			&version.CreatedBy,
+			&version.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning timesheet version row: %w", err)
+		}
+		version.ClockOutAt = nullTimePtr(clockOutAt)
+		if err := json.Unmarshal(breaks, &version.Breaks); err != nil {
+			return nil, fmt.Errorf("error decoding timesheet version breaks: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating timesheet version rows: %w", err)
+	}
+
+	return versions, nil
+}
+
+// CorrectTimesheet lets the employer overwrite the recorded times, for example
+// when a worker forgot to clock out.
+func (s *service) CorrectTimesheet(ctx context.Context, employerID string, shiftID string, timesheetID string, input *TimesheetCorrection) (*Timesheet, error) {
+	if err := validateTimesheetCorrection(input); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	shift, err := getShiftForUpdate(ctx, tx, employerID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	timesheet, err := getTimesheet(ctx, tx, shiftID, timesheetID, true)
+	if err != nil {
+		return nil, err
+	}
+	if timesheet.Status == TimesheetStatusApproved {
+		return nil, fmt.Errorf("timesheet %s is already approved: %w", timesheetID, ErrConflict)
+	}
+	if timesheet.Version != input.Version {
+		return nil, fmt.Errorf("timesheet %s is at version %d, not %d: %w", timesheetID, timesheet.Version, input.Version, ErrConflict)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE timesheets
+		SET status = $1, clock_in_at = $2, clock_out_at = $3
+		WHERE id = $4`,
+		TimesheetStatusClockedOut, input.ClockInAt.UTC(), input.ClockOutAt.UTC(), timesheetID,
+	); err != nil {
+		return nil, fmt.Errorf("error correcting timesheet %s: %w", timesheetID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM timesheet_breaks WHERE timesheet_id = $1`, timesheetID); err != nil {
+		return nil, fmt.Errorf("error clearing timesheet breaks: %w", err)
+	}
+	for _, b := range input.Breaks {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO timesheet_breaks (id, timesheet_id, started_at, ended_at)
+			VALUES ($1, $2, $3, $4)`,
+			generateID(BreakPrefix), timesheetID, b.StartedAt.UTC(), b.EndedAt.UTC(),
+		); err != nil {
+			return nil, fmt.Errorf("error inserting timesheet break: %w", err)
+		}
+	}
+
+	before := timesheet
+	timesheet, err = recordTimesheetVersion(ctx, tx, timesheetID, employerID, strings.TrimSpace(input.Reason))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: shift.OrganizationID,
+		Action:     AuditActionCorrect,
+		EntityType: AuditEntityTimesheet,
+		EntityID:   timesheetID,
+		Before:     before,
+		After:      timesheet,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return timesheet, nil
+}
+
+// ApproveTimesheet locks a clocked out timesheet so its hours can be billed.
+func (s *service) ApproveTimesheet(ctx context.Context, employerID string, shiftID string, timesheetID string) (*Timesheet, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	shift, err := getShiftForUpdate(ctx, tx, employerID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	timesheet, err := getTimesheet(ctx, tx, shiftID, timesheetID, true)
+	if err != nil {
+		return nil, err
+	}
+	if timesheet.Status != TimesheetStatusClockedOut {
+		return nil, fmt.Errorf("cannot approve a %s timesheet: %w", timesheet.Status, ErrConflict)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE timesheets
+		SET status = $1, approved_by = $2, approved_at = NOW()
+		WHERE id = $3`,
+		TimesheetStatusApproved, employerID, timesheetID,
+	); err != nil {
+		return nil, fmt.Errorf("error approving timesheet %s: %w", timesheetID, err)
+	}
+
+	before := timesheet
+	timesheet, err = recordTimesheetVersion(ctx, tx, timesheetID, employerID, "approve")
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: shift.OrganizationID,
+		Action:     AuditActionApprove,
+		EntityType: AuditEntityTimesheet,
+		EntityID:   timesheetID,
+		Before:     before,
+		After:      timesheet,
+	}); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, shift.OrganizationID, EventTimesheetApproved, timesheetID, timesheet); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return timesheet, nil
+}
+
+func (s *service) checkTimesheetAccess(ctx context.Context, userID string, timesheet *Timesheet) error {
+	if timesheet.WorkerID == userID {
+		return nil
+	}
+	_, err := s.GetShift(ctx, userID, timesheet.ShiftID)
+	return err
+}
+
+func getTimesheet(ctx context.Context, q querier, shiftID string, timesheetID string, forUpdate bool) (*Timesheet, error) {
+	query := `SELECT` + timesheetColumns + ` FROM timesheets WHERE id = $1 AND shift_id = $2`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	timesheet, err := scanTimesheet(q.QueryRowContext(ctx, query, timesheetID, shiftID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("timesheet %s: %w", timesheetID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching timesheet with id %s: %w", timesheetID, err)
+	}
+	if timesheet.Breaks, err = getTimesheetBreaks(ctx, q, timesheetID); err != nil {
+		return nil, err
+	}
+	return timesheet, nil
+}
+
+func getTimesheetBreaks(ctx context.Context, q querier, timesheetID string) ([]TimesheetBreak, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, started_at, ended_at
+		FROM timesheet_breaks
+		WHERE timesheet_id = $1
+		ORDER BY started_at`,
+		timesheetID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying timesheet breaks: %w", err)
+	}
+	defer rows.Close()
+
+	breaks := []TimesheetBreak{}
+	for rows.Next() {
+		var b TimesheetBreak
+		var endedAt sql.NullTime
+		if err := rows.Scan(&b.ID, &b.StartedAt, &endedAt); err != nil {
+			return nil, fmt.Errorf("error scanning timesheet break row: %w", err)
+		}
+		b.EndedAt = nullTimePtr(endedAt)
+		breaks = append(breaks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating timesheet break rows: %w", err)
+	}
+	return breaks, nil
+}
+
+// recordTimesheetVersion bumps the version of the timesheet and stores a
+// snapshot of its current state alongside who changed it and why.
+func recordTimesheetVersion(ctx context.Context, tx *sql.Tx, timesheetID string, actorID string, reason string) (*Timesheet, error) {
+	timesheet, err := scanTimesheet(tx.QueryRowContext(ctx, `
+		UPDATE timesheets
+		SET version = version + 1, updated_by = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING`+timesheetColumns,
+		actorID, timesheetID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error versioning timesheet %s: %w", timesheetID, err)
+	}
+	if timesheet.Breaks, err = getTimesheetBreaks(ctx, tx, timesheetID); err != nil {
+		return nil, err
+	}
+
+	breaks, err := json.Marshal(timesheet.Breaks)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding timesheet breaks: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO timesheet_versions (id, timesheet_id, version, status, clock_in_at, clock_out_at, breaks, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		generateID(TimesheetVersionPrefix), timesheetID, timesheet.Version, timesheet.Status,
+		timesheet.ClockInAt, timesheet.ClockOutAt, breaks, reason, actorID,
+	); err != nil {
+		return nil, fmt.Errorf("error recording timesheet version: %w", err)
+	}
+
+	return timesheet, nil
+}
+
+func openBreak(timesheet *Timesheet) *TimesheetBreak {
+	for i := range timesheet.Breaks {
+		if timesheet.Breaks[i].EndedAt == nil {
+			return &timesheet.Breaks[i]
+		}
+	}
+	return nil
+}
+
+func validateTimesheetCorrection(input *TimesheetCorrection) error {
+	if input == nil {
+		return newValidationError("timesheet", "is required")
+	}
+	if strings.TrimSpace(input.Reason) == "" {
+		return newValidationError("reason", "is required")
+	}
+	if input.ClockInAt.IsZero() {
+		return newValidationError("clock_in_at", "is required")
+	}
+	if input.ClockOutAt.IsZero() {
+		return newValidationError("clock_out_at", "is required")
+	}
+	if !input.ClockOutAt.After(input.ClockInAt) {
+		return newValidationError("clock_out_at", "must be after clock_in_at")
+	}
+	if input.ClockOutAt.Sub(input.ClockInAt) > maxTimesheetDuration {
+		return newValidationError("clock_out_at", "timesheet cannot be longer than 24 hours")
+	}
+
+	breaks := append([]BreakInput(nil), input.Breaks...)
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].StartedAt.Before(breaks[j].StartedAt) })
+	for i, b := range breaks {
+		if !b.EndedAt.After(b.StartedAt) {
+			return newValidationError("breaks", "each break must end after it starts")
+		}
+		if b.StartedAt.Before(input.ClockInAt) || b.EndedAt.After(input.ClockOutAt) {
+			return newValidationError("breaks", "breaks must fall between clock_in_at and clock_out_at")
+		}
+		if i > 0 && b.StartedAt.Before(breaks[i-1].EndedAt) {
+			return newValidationError("breaks", "breaks must not overlap")
+		}
+	}
+
+	return nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper function to build a shift that workers can clock in to straight away
+func runningShiftInput() *ShiftInput {
+	input := validShiftInput()
+	input.StartsAt = time.Now().Add(time.Minute).Truncate(time.Second)
+	input.EndsAt = input.StartsAt.Add(8 * time.Hour)
+	return input
+}
+
+// Helper function to create a running shift with the worker accepted onto it
+func createStaffedShift(t *testing.T, svc Service, employerID, workerID string) *Shift {
+	ctx := context.Background()
+	shift, err := svc.CreateShift(ctx, employerID, runningShiftInput())
+	require.NoError(t, err)
+	assignment, err := svc.ApplyToShift(ctx, workerID, shift.ID)
+	require.NoError(t, err)
+	_, err = svc.AcceptAssignment(ctx, employerID, assignment.ID)
+	require.NoError(t, err)
+
+	return shift
+}
+
+func Test_TimesheetWorkflow(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+	strangerID := createTestUser(t, db, "Stranger")
+	shift := createStaffedShift(t, svc, employerID, workerID)
+
+	_, err := svc.ClockIn(ctx, strangerID, shift.ID)
+	assert.ErrorIs(t, err, ErrForbidden, "unassigned workers cannot clock in")
+
+	timesheet, err := svc.ClockIn(ctx, workerID, shift.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TimesheetStatusClockedIn, timesheet.Status)
+	assert.Equal(t, 1, timesheet.Version)
+
+	_, err = svc.ClockIn(ctx, workerID, shift.ID)
+	assert.ErrorIs(t, err, ErrConflict, "cannot clock in twice")
+
+	timesheet, err = svc.StartBreak(ctx, workerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+	require.Len(t, timesheet.Breaks, 1)
+	assert.Nil(t, timesheet.Breaks[0].EndedAt)
+
+	_, err = svc.StartBreak(ctx, workerID, shift.ID, timesheet.ID)
+	assert.ErrorIs(t, err, ErrConflict, "cannot start a second break")
+
+	timesheet, err = svc.EndBreak(ctx, workerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, timesheet.Breaks[0].EndedAt)
+
+	timesheet, err = svc.ClockOut(ctx, workerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TimesheetStatusClockedOut, timesheet.Status)
+	assert.NotNil(t, timesheet.ClockOutAt)
+	assert.Equal(t, 4, timesheet.Version)
+
+	_, err = svc.ClockOut(ctx, workerID, shift.ID, timesheet.ID)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	clockIn := timesheet.ClockInAt.Add(-8 * time.Hour)
+	correction := &TimesheetCorrection{
+		ClockInAt:  clockIn,
+		ClockOutAt: clockIn.Add(8 * time.Hour),
+		Breaks: []BreakInput{
+			{StartedAt: clockIn.Add(4 * time.Hour), EndedAt: clockIn.Add(4*time.Hour + 30*time.Minute)},
+		},
+		Reason:  "worker forgot to clock in on arrival",
+		Version: 1,
+	}
+	_, err = svc.CorrectTimesheet(ctx, employerID, shift.ID, timesheet.ID, correction)
+	assert.ErrorIs(t, err, ErrConflict, "stale versions are rejected")
+
+	correction.Version = timesheet.Version
+	_, err = svc.CorrectTimesheet(ctx, workerID, shift.ID, timesheet.ID, correction)
+	assert.ErrorIs(t, err, ErrForbidden, "only the employer can correct")
+
+	corrected, err := svc.CorrectTimesheet(ctx, employerID, shift.ID, timesheet.ID, correction)
+	require.NoError(t, err)
+	assert.Equal(t, 5, corrected.Version)
+	assert.Equal(t, 7*time.Hour+30*time.Minute, corrected.WorkedDuration())
+
+	approved, err := svc.ApproveTimesheet(ctx, employerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TimesheetStatusApproved, approved.Status)
+	assert.Equal(t, employerID, approved.ApprovedBy)
+
+	correction.Version = approved.Version
+	_, err = svc.CorrectTimesheet(ctx, employerID, shift.ID, timesheet.ID, correction)
+	assert.ErrorIs(t, err, ErrConflict, "approved timesheets are locked")
+
+	versions, err := svc.GetTimesheetVersions(ctx, workerID, shift.ID, timesheet.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 6)
+	assert.Equal(t, "worker forgot to clock in on arrival", versions[4].Reason)
+	assert.Equal(t, employerID, versions[4].CreatedBy)
+
+	_, err = svc.GetTimesheetVersions(ctx, strangerID, shift.ID, timesheet.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	employerView, err := svc.ListShiftTimesheets(ctx, employerID, shift.ID)
+	require.NoError(t, err)
+	assert.Len(t, employerView, 1)
+
+	strangerView, err := svc.ListShiftTimesheets(ctx, strangerID, shift.ID)
+	require.NoError(t, err)
+	assert.Empty(t, strangerView)
+
+	clearTestData(t, db)
+}
+
+func Test_ValidateTimesheetCorrection(t *testing.T) {
+	clockIn := time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC)
+	clockOut := clockIn.Add(8 * time.Hour)
+
+	tests := []struct {
+		name       string
+		input      *TimesheetCorrection
+		errorField string
+	}{
+		{
+			name:  "valid correction",
+			input: &TimesheetCorrection{ClockInAt: clockIn, ClockOutAt: clockOut, Reason: "fix"},
+		},
+		{
+			name:       "missing reason",
+			input:      &TimesheetCorrection{ClockInAt: clockIn, ClockOutAt: clockOut},
+			errorField: "reason",
+		},
+		{
+			name:       "clock out before clock in",
+			input:      &TimesheetCorrection{ClockInAt: clockOut, ClockOutAt: clockIn, Reason: "fix"},
+			errorField: "clock_out_at",
+		},
+		{
+			name:       "longer than a day",
+			input:      &TimesheetCorrection{ClockInAt: clockIn, ClockOutAt: clockIn.Add(25 * time.Hour), Reason: "fix"},
+			errorField: "clock_out_at",
+		},
+		{
+			name: "break outside the timesheet",
+			input: &TimesheetCorrection{
+				ClockInAt:  clockIn,
+				ClockOutAt: clockOut,
+				Breaks:     []BreakInput{{StartedAt: clockOut, EndedAt: clockOut.Add(time.Hour)}},
+				Reason:     "fix",
+			},
+			errorField: "breaks",
+		},
+		{
+			name: "overlapping breaks",
+			input: &TimesheetCorrection{
+				ClockInAt:  clockIn,
+				ClockOutAt: clockOut,
+				Breaks: []BreakInput{
+					{StartedAt: clockIn.Add(2 * time.Hour), EndedAt: clockIn.Add(3 * time.Hour)},
+					{StartedAt: clockIn.Add(150 * time.Minute), EndedAt: clockIn.Add(4 * time.Hour)},
+				},
+				Reason: "fix",
+			},
+			errorField: "breaks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTimesheetCorrection(tt.input)
+			if tt.errorField == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.errorField, validationErr.Field)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+	// Embed the timezone database so locations resolve on hosts without zoneinfo.
+	_ "time/tzdata"
+)
+
+// DefaultTimezone is used for locations created without a timezone.
+const DefaultTimezone = "UTC"
+
+var timezones sync.Map
+
+// loadTimezone resolves an IANA timezone name, caching the result.
+func loadTimezone(name string) (*time.Location, error) {
+	if loc, ok := timezones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	timezones.Store(name, loc)
+	return loc, nil
+}
+
+// validateTimezone checks that name is an IANA timezone, defaulting an empty
+// one to DefaultTimezone.
+func validateTimezone(field string, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return DefaultTimezone, nil
+	}
+	// LoadLocation also accepts "Local", which depends on the host.
+	if _, err := loadTimezone(name); err != nil || name == "Local" {
+		return "", newValidationError(field, "must be an IANA timezone such as America/New_York")
+	}
+	return name, nil
+}
+
+// localDate returns the calendar date t falls on in its own location, as
+// midnight UTC to match DATE columns.
+func localDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// startOfLocalDay returns midnight at the start of date in loc.
+func startOfLocalDay(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
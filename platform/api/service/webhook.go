@@ -0,0 +1,555 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/outbox"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/webhooks"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookEndpoint is a URL an employer organization has registered for event
+// notifications. Secret is only returned when the endpoint is created.
+type WebhookEndpoint struct {
+	ID             string      `json:"id" db:"id"`
+	OrganizationID string      `json:"organization_id" db:"employer_id"`
+	URL            string      `json:"url" db:"url"`
+	EventTypes     []EventType `json:"event_types" db:"event_types"`
+	Description    string      `json:"description" db:"description"`
+	Active         bool        `json:"active" db:"active"`
+	Secret         string      `json:"secret,omitempty"`
+	CreatedBy      string      `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+}
+
+type WebhookEndpointInput struct {
+	URL         string      `json:"url"`
+	EventTypes  []EventType `json:"event_types"`
+	Description string      `json:"description"`
+}
+
+// WebhookEvent is the JSON body sent to endpoints.
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      EventType   `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDelivery is an entry in an endpoint's delivery log. A delivery is
+// retried with backoff until it succeeds or runs out of attempts.
+type WebhookDelivery struct {
+	ID             string                `json:"id" db:"id"`
+	EndpointID     string                `json:"endpoint_id" db:"endpoint_id"`
+	EventID        string                `json:"event_id" db:"event_id"`
+	EventType      EventType             `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      string                `json:"last_error,omitempty" db:"last_error"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+}
+
+// maxWebhookDeliveries bounds the delivery log returned for an endpoint.
+const maxWebhookDeliveries = 100
+
+const webhookEndpointColumns = ` id, employer_id, url, event_types, COALESCE(description, ''), active, created_by, created_at`
+
+func scanWebhookEndpoint(row rowScanner) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	var eventTypes []string
+	err := row.Scan(
+		&endpoint.ID,
+		&endpoint.OrganizationID,
+		&endpoint.URL,
+		pq.Array(&eventTypes),
+		&endpoint.Description,
+		&endpoint.Active,
+		&endpoint.CreatedBy,
+		&endpoint.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, eventType := range eventTypes {
+		endpoint.EventTypes = append(endpoint.EventTypes, EventType(eventType))
+	}
+	return &endpoint, nil
+}
+
+const webhookDeliveryColumns = ` id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_status_code, COALESCE(last_error, ''), delivered_at, created_at`
+
+func scanWebhookDelivery(row rowScanner) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var payload []byte
+	var statusCode sql.NullInt32
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.EndpointID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&statusCode,
+		&delivery.LastError,
+		&deliveredAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if statusCode.Valid {
+		code := int(statusCode.Int32)
+		delivery.LastStatusCode = &code
+	}
+	delivery.DeliveredAt = nullTimePtr(deliveredAt)
+	return &delivery, nil
+}
+
+func (s *service) CreateWebhookEndpoint(ctx context.Context, employerID string, input *WebhookEndpointInput) (*WebhookEndpoint, error) {
+	if employerID == "" {
+		return nil, newValidationError("employer_id", "is required")
+	}
+	if err := validateWebhookEndpointInput(input); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	eventTypes := make([]string, len(input.EventTypes))
+	for i, eventType := range input.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	organizationID, err := organizationForUser(ctx, tx, employerID)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := scanWebhookEndpoint(tx.QueryRowContext(ctx, `
+		INSERT INTO webhook_endpoints (id, employer_id, url, secret, event_types, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING`+webhookEndpointColumns,
+		generateID(WebhookEndpointPrefix), organizationID, strings.TrimSpace(input.URL), secret, pq.Array(eventTypes),
+		strings.TrimSpace(input.Description), employerID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook endpoint: %w", err)
+	}
+	// Recorded before the secret is attached so it never reaches the log.
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: organizationID,
+		Action:     AuditActionCreate,
+		EntityType: AuditEntityWebhookEndpoint,
+		EntityID:   endpoint.ID,
+		After:      endpoint,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	endpoint.Secret = secret
+	return endpoint, nil
+}
+
+func (s *service) ListWebhookEndpoints(ctx context.Context, employerID string) ([]WebhookEndpoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE employer_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1) AND active
+		ORDER BY created_at, id`,
+		employerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook endpoint row: %w", err)
+		}
+		endpoints = append(endpoints, *endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook endpoint rows: %w", err)
+	}
+	return endpoints, nil
+}
+
+// DeleteWebhookEndpoint deactivates the endpoint and abandons its pending
+// deliveries. The endpoint and its delivery log are kept for reference.
+func (s *service) DeleteWebhookEndpoint(ctx context.Context, employerID string, endpointID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getWebhookEndpoint(ctx, tx, employerID, endpointID, true)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := scanWebhookEndpoint(tx.QueryRowContext(ctx, `
+		UPDATE webhook_endpoints SET active = FALSE, updated_by = $1, updated_at = NOW() WHERE id = $2
+		RETURNING`+webhookEndpointColumns,
+		employerID, endpointID,
+	))
+	if err != nil {
+		return fmt.Errorf("error deactivating webhook endpoint %s: %w", endpointID, err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: current.OrganizationID,
+		Action:     AuditActionDelete,
+		EntityType: AuditEntityWebhookEndpoint,
+		EntityID:   endpointID,
+		Before:     current,
+		After:      endpoint,
+	}); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, last_error = 'endpoint deleted', updated_at = NOW()
+		WHERE endpoint_id = $2 AND status = $3`,
+		WebhookDeliveryStatusFailed, endpointID, WebhookDeliveryStatusPending,
+	); err != nil {
+		return fmt.Errorf("error abandoning deliveries for webhook endpoint %s: %w", endpointID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the endpoint's most recent deliveries, newest
+// first.
+func (s *service) ListWebhookDeliveries(ctx context.Context, employerID string, endpointID string) ([]WebhookDelivery, error) {
+	if _, err := getWebhookEndpoint(ctx, s.db, employerID, endpointID, false); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT`+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`,
+		endpointID, maxWebhookDeliveries,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery row: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook delivery rows: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RedeliverWebhook queues the delivery's event to be sent again right away as
+// a new delivery, leaving the original's history untouched.
+func (s *service) RedeliverWebhook(ctx context.Context, employerID string, deliveryID string) (*WebhookDelivery, error) {
+	original, err := scanWebhookDelivery(s.db.QueryRowContext(ctx, `
+		SELECT`+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = $1`,
+		deliveryID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook delivery %s: %w", deliveryID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching webhook delivery with id %s: %w", deliveryID, err)
+	}
+	endpoint, err := getWebhookEndpoint(ctx, s.db, employerID, original.EndpointID, false)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.Active {
+		return nil, fmt.Errorf("webhook endpoint %s has been deleted: %w", endpoint.ID, ErrConflict)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	delivery, err := scanWebhookDelivery(tx.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING`+webhookDeliveryColumns,
+		generateID(WebhookDeliveryPrefix), original.EndpointID, original.EventID, original.EventType, []byte(original.Payload),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook delivery: %w", err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    employerID,
+		EmployerID: endpoint.OrganizationID,
+		Action:     AuditActionRedeliver,
+		EntityType: AuditEntityWebhookDelivery,
+		EntityID:   delivery.ID,
+		Before:     original,
+		After:      delivery,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return delivery, nil
+}
+
+// DispatchWebhooks sends up to limit due deliveries and returns how many were
+// attempted. Each delivery is claimed with SKIP LOCKED, so several dispatchers
+// can run at once without sending the same delivery twice.
+func (s *service) DispatchWebhooks(ctx context.Context, limit int) (int, error) {
+	attempted := 0
+	for attempted < limit {
+		found, err := s.dispatchNextWebhook(ctx)
+		if err != nil {
+			return attempted, err
+		}
+		if !found {
+			break
+		}
+		attempted++
+	}
+	return attempted, nil
+}
+
+func (s *service) dispatchNextWebhook(ctx context.Context) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var delivery webhooks.Delivery
+	var attempts int
+	err = tx.QueryRowContext(ctx, `
+		SELECT d.id, d.event_type, d.payload, d.attempts, e.url, e.secret
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.status = $1 AND d.next_attempt_at <= NOW()
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT 1
+		FOR UPDATE OF d SKIP LOCKED`,
+		WebhookDeliveryStatusPending,
+	).Scan(&delivery.ID, &delivery.EventType, &delivery.Payload, &attempts, &delivery.URL, &delivery.Secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming webhook delivery: %w", err)
+	}
+
+	// The row stays locked while we wait on the endpoint; the client's timeout
+	// bounds how long that can be.
+	statusCode, sendErr := s.webhooks.Send(ctx, delivery)
+	attempts++
+
+	status := WebhookDeliveryStatusSucceeded
+	var lastError string
+	if sendErr != nil {
+		lastError = sendErr.Error()
+		status = WebhookDeliveryStatusPending
+		if attempts >= webhooks.MaxAttempts {
+			status = WebhookDeliveryStatusFailed
+		}
+		slog.WarnContext(ctx, "webhook delivery failed",
+			"delivery_id", delivery.ID, "attempts", attempts, "status_code", statusCode, "error", sendErr)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1,
+			attempts = $2,
+			next_attempt_at = NOW() + make_interval(secs => $3),
+			last_status_code = NULLIF($4, 0),
+			last_error = NULLIF($5, ''),
+			delivered_at = CASE WHEN $1 = 'succeeded' THEN NOW() END,
+			updated_at = NOW()
+		WHERE id = $6`,
+		status, attempts, webhooks.Backoff(attempts).Seconds(), statusCode, lastError, delivery.ID,
+	); err != nil {
+		return false, fmt.Errorf("error recording webhook delivery %s: %w", delivery.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// webhookSink is the outbox sink that queues a delivery of each event for
+// the employer's subscribed endpoints. The dispatcher sends them from there.
+type webhookSink struct {
+	db *sql.DB
+}
+
+var _ outbox.Sink = &webhookSink{}
+
+// NewWebhookSink returns the outbox sink that feeds outbound webhooks.
+func NewWebhookSink(db *sql.DB) outbox.Sink {
+	return &webhookSink{db: db}
+}
+
+func (w *webhookSink) Name() string {
+	return "webhooks"
+}
+
+// Publish is safe to repeat: endpoints that already have a delivery for the
+// event are skipped.
+func (w *webhookSink) Publish(ctx context.Context, event outbox.Event) error {
+	if event.EmployerID == "" {
+		return nil
+	}
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT e.id
+		FROM webhook_endpoints e
+		WHERE e.employer_id = $1
+			AND e.active
+			AND ($2 = ANY(e.event_types) OR $3 = ANY(e.event_types))
+			AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.endpoint_id = e.id AND d.event_id = $4)`,
+		event.EmployerID, event.Type, EventAll, event.DedupeKey,
+	)
+	if err != nil {
+		return fmt.Errorf("error querying webhook endpoints: %w", err)
+	}
+	var endpointIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning webhook endpoint row: %w", err)
+		}
+		endpointIDs = append(endpointIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating webhook endpoint rows: %w", err)
+	}
+	if len(endpointIDs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(WebhookEvent{
+		ID:        event.DedupeKey,
+		Type:      EventType(event.Type),
+		CreatedAt: event.CreatedAt.UTC(),
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding %s webhook event: %w", event.Type, err)
+	}
+
+	for _, endpointID := range endpointIDs {
+		if _, err := w.db.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload)
+			VALUES ($1, $2, $3, $4, $5)`,
+			generateID(WebhookDeliveryPrefix), endpointID, event.DedupeKey, event.Type, payload,
+		); err != nil {
+			return fmt.Errorf("error queueing webhook delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+func getWebhookEndpoint(ctx context.Context, q querier, employerID string, endpointID string, forUpdate bool) (*WebhookEndpoint, error) {
+	query := `SELECT` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	endpoint, err := scanWebhookEndpoint(q.QueryRowContext(ctx, query, endpointID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook endpoint %s: %w", endpointID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching webhook endpoint with id %s: %w", endpointID, err)
+	}
+	if err := authorizeOrganization(ctx, q, employerID, endpoint.OrganizationID); err != nil {
+		return nil, fmt.Errorf("webhook endpoint %s: %w", endpointID, err)
+	}
+	return endpoint, nil
+}
+
+func validateWebhookEndpointInput(input *WebhookEndpointInput) error {
+	if input == nil {
+		return newValidationError("body", "is required")
+	}
+	endpointURL, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || endpointURL.Host == "" || endpointURL.Scheme != "https" {
+		return newValidationError("url", "must be an absolute https URL")
+	}
+	if len(input.EventTypes) == 0 {
+		return newValidationError("event_types", "must include at least one event type")
+	}
+	for _, eventType := range input.EventTypes {
+		if !eventType.Valid() {
+			return newValidationError("event_types", fmt.Sprintf("unknown event type %q", eventType))
+		}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
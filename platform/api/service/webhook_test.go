@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/signature"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/outbox"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/webhooks"
+)
+
+// Helper function to relay pending outbox events into webhook deliveries
+func relayWebhookEvents(t *testing.T) {
+	_, err := outbox.NewRelay(db, NewWebhookSink(db)).ProcessBatch(context.Background(), 1000)
+	require.NoError(t, err)
+}
+
+func Test_WebhookDelivery(t *testing.T) {
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	otherEmployerID := createTestUser(t, db, "Other")
+
+	var mu sync.Mutex
+	var secret string
+	var received []WebhookEvent
+	status := http.StatusInternalServerError
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		payload, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, signature.Verify([]byte(secret), r.Header.Get(webhooks.SignatureHeader), payload, time.Now()))
+		var event WebhookEvent
+		assert.NoError(t, json.Unmarshal(payload, &event))
+		received = append(received, event)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	// The test server listens on loopback, which the default client refuses.
+	svc := NewService(db, WithWebhookClient(webhooks.NewClient(server.Client())))
+
+	var validationErr *ValidationError
+	for _, endpointURL := range []string{"ftp://example.com", "http://example.com/hooks"} {
+		_, err := svc.CreateWebhookEndpoint(ctx, employerID, &WebhookEndpointInput{URL: endpointURL, EventTypes: []EventType{EventShiftCreated}})
+		assert.ErrorAs(t, err, &validationErr, endpointURL)
+	}
+	_, err := svc.CreateWebhookEndpoint(ctx, employerID, &WebhookEndpointInput{URL: server.URL, EventTypes: []EventType{"shift.exploded"}})
+	assert.ErrorAs(t, err, &validationErr)
+
+	endpoint, err := svc.CreateWebhookEndpoint(ctx, employerID, &WebhookEndpointInput{
+		URL:        server.URL,
+		EventTypes: []EventType{EventShiftCreated, EventShiftCancelled},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, endpoint.Secret)
+	secret = endpoint.Secret
+
+	shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+	_, err = svc.UpdateShift(ctx, employerID, shift.ID, validShiftInput())
+	require.NoError(t, err, "shift.updated is not subscribed to")
+	relayWebhookEvents(t)
+
+	deliveries, err := svc.ListWebhookDeliveries(ctx, employerID, endpoint.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, EventShiftCreated, deliveries[0].EventType)
+	assert.Equal(t, WebhookDeliveryStatusPending, deliveries[0].Status)
+
+	_, err = svc.ListWebhookDeliveries(ctx, otherEmployerID, endpoint.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// The endpoint fails the first attempt, so the delivery is retried later.
+	attempted, err := svc.DispatchWebhooks(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+	deliveries, err = svc.ListWebhookDeliveries(ctx, employerID, endpoint.ID)
+	require.NoError(t, err)
+	assert.Equal(t, WebhookDeliveryStatusPending, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	require.NotNil(t, deliveries[0].LastStatusCode)
+	assert.Equal(t, http.StatusInternalServerError, *deliveries[0].LastStatusCode)
+	assert.Equal(t, "endpoint responded with 500", deliveries[0].LastError)
+	assert.True(t, deliveries[0].NextAttemptAt.After(deliveries[0].CreatedAt))
+
+	attempted, err = svc.DispatchWebhooks(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, attempted, "the retry is not due yet")
+
+	_, err = db.Exec(`UPDATE webhook_deliveries SET next_attempt_at = NOW() WHERE id = $1`, deliveries[0].ID)
+	require.NoError(t, err)
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	attempted, err = svc.DispatchWebhooks(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+
+	deliveries, err = svc.ListWebhookDeliveries(ctx, employerID, endpoint.ID)
+	require.NoError(t, err)
+	assert.Equal(t, WebhookDeliveryStatusSucceeded, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+
+	mu.Lock()
+	require.Len(t, received, 2)
+	assert.Equal(t, received[0].ID, received[1].ID, "retries resend the same event")
+	assert.Equal(t, EventShiftCreated, received[1].Type)
+	mu.Unlock()
+
+	redelivery, err := svc.RedeliverWebhook(ctx, employerID, deliveries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, deliveries[0].EventID, redelivery.EventID)
+	assert.Equal(t, WebhookDeliveryStatusPending, redelivery.Status)
+	_, err = svc.RedeliverWebhook(ctx, otherEmployerID, deliveries[0].ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	require.NoError(t, svc.DeleteWebhookEndpoint(ctx, employerID, endpoint.ID))
+	endpoints, err := svc.ListWebhookEndpoints(ctx, employerID)
+	require.NoError(t, err)
+	assert.Empty(t, endpoints)
+	deliveries, err = svc.ListWebhookDeliveries(ctx, employerID, endpoint.ID)
+	require.NoError(t, err)
+	assert.Equal(t, WebhookDeliveryStatusFailed, deliveries[0].Status, "pending deliveries are abandoned")
+
+	_, err = svc.CancelShift(ctx, employerID, shift.ID)
+	require.NoError(t, err)
+	relayWebhookEvents(t)
+	deliveries, err = svc.ListWebhookDeliveries(ctx, employerID, endpoint.ID)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2, "deleted endpoints receive no new events")
+}
+
+func Test_WebhookInvoiceEvents(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	workerID := createTestUser(t, db, "Worker")
+
+	endpoint, err := svc.CreateWebhookEndpoint(ctx, employerID, &WebhookEndpointInput{
+		URL:        "https://example.com/hooks",
+		EventTypes: []EventType{EventAll},
+	})
+	require.NoError(t, err)
+
+	invoiceID := createIssuedInvoice(t, svc, employerID, workerID, 2500, 4*time.Hour)
+	relayWebhookEvents(t)
+
+	deliveries, err := svc.ListWebhookDeliveries(ctx, employerID, endpoint.ID)
+	require.NoError(t, err)
+	var eventTypes []EventType
+	for _, delivery := range deliveries {
+		eventTypes = append(eventTypes, delivery.EventType)
+	}
+	assert.Contains(t, eventTypes, EventShiftCreated)
+	assert.NotContains(t, eventTypes, EventShiftFilled, "one of three workers does not fill the shift")
+	assert.Contains(t, eventTypes, EventTimesheetApproved)
+	assert.Contains(t, eventTypes, EventInvoiceCreated)
+	assert.Contains(t, eventTypes, EventInvoiceStatusChanged)
+	assert.Contains(t, eventTypes, EventInvoiceIssued)
+
+	var issued WebhookEvent
+	for _, delivery := range deliveries {
+		if delivery.EventType == EventInvoiceIssued {
+			require.NoError(t, json.Unmarshal(delivery.Payload, &issued))
+		}
+	}
+	data, ok := issued.Data.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, invoiceID, data["invoice_id"])
+	assert.Equal(t, string(InvoiceStatusIssued), data["to_status"])
+}
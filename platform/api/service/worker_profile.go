@@ -0,0 +1,432 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WorkerProfile describes what a worker can do and when and where they are
+// willing to work.
+type WorkerProfile struct {
+	UserID               string                `json:"user_id" db:"user_id"`
+	Skills               []string              `json:"skills" db:"skills"`
+	Certifications       []WorkerCertification `json:"certifications"`
+	PreferredLocationIDs []string              `json:"preferred_location_ids" db:"preferred_location_ids"`
+	// MaxTravelKm is how far the worker will travel to a shift; nil means no limit.
+	MaxTravelKm  *int                 `json:"max_travel_km" db:"max_travel_km"`
+	Availability []AvailabilityWindow `json:"availability"`
+	CreatedAt    time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// WorkerCertification is a qualification the worker holds. ExpiresOn is nil
+// for certifications that do not expire.
+type WorkerCertification struct {
+	ID        string     `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Issuer    string     `json:"issuer,omitempty" db:"issuer"`
+	ExpiresOn *time.Time `json:"expires_on" db:"expires_on"`
+	Expired   bool       `json:"expired"`
+}
+
+// AvailabilityWindow is a weekly recurring window the worker can work in.
+// Times are wall-clock "HH:MM" and a window cannot span midnight.
+type AvailabilityWindow struct {
+	Weekday   time.Weekday `json:"weekday" db:"weekday"`
+	StartTime string       `json:"start_time" db:"start_time"`
+	EndTime   string       `json:"end_time" db:"end_time"`
+}
+
+type WorkerCertificationInput struct {
+	Name      string     `json:"name"`
+	Issuer    string     `json:"issuer"`
+	ExpiresOn *time.Time `json:"expires_on"`
+}
+
+// WorkerProfileInput replaces the whole profile.
+type WorkerProfileInput struct {
+	Skills               []string                   `json:"skills"`
+	Certifications       []WorkerCertificationInput `json:"certifications"`
+	PreferredLocationIDs []string                   `json:"preferred_location_ids"`
+	MaxTravelKm          *int                       `json:"max_travel_km"`
+	Availability         []AvailabilityWindow       `json:"availability"`
+}
+
+const (
+	maxWorkerSkills         = 50
+	maxWorkerCertifications = 50
+	availabilityTimeLayout  = "15:04"
+)
+
+func (s *service) GetWorkerProfile(ctx context.Context, workerID string) (*WorkerProfile, error) {
+	return getWorkerProfile(ctx, s.db, workerID)
+}
+
+// UpsertWorkerProfile creates the worker's profile or replaces it entirely.
+func (s *service) UpsertWorkerProfile(ctx context.Context, workerID string, input *WorkerProfileInput) (*WorkerProfile, error) {
+	if workerID == "" {
+		return nil, newValidationError("worker_id", "is required")
+	}
+	if err := normalizeWorkerProfileInput(input); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if len(input.PreferredLocationIDs) > 0 {
+		var known int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM locations WHERE id = ANY($1)`,
+			pq.Array(input.PreferredLocationIDs),
+		).Scan(&known); err != nil {
+			return nil, fmt.Errorf("error checking preferred locations: %w", err)
+		}
+		if known != len(input.PreferredLocationIDs) {
+			return nil, newValidationError("preferred_location_ids", "must only contain known locations")
+		}
+	}
+
+	before, err := getWorkerProfileForUpdate(ctx, tx, workerID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO worker_profiles (user_id, skills, preferred_location_ids, max_travel_km, created_by)
+		VALUES ($1, $2, $3, $4, $1)
+		ON CONFLICT (user_id) DO UPDATE
+		SET skills = EXCLUDED.skills,
+			preferred_location_ids = EXCLUDED.preferred_location_ids,
+			max_travel_km = EXCLUDED.max_travel_km,
+			updated_by = $1,
+			updated_at = NOW()`,
+		workerID, pq.Array(input.Skills), pq.Array(input.PreferredLocationIDs), input.MaxTravelKm,
+	); err != nil {
+		return nil, fmt.Errorf("error saving worker profile: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM worker_certifications WHERE user_id = $1`, workerID); err != nil {
+		return nil, fmt.Errorf("error clearing worker certifications: %w", err)
+	}
+	for _, certification := range input.Certifications {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO worker_certifications (id, user_id, name, issuer, expires_on)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5)`,
+			generateID(CertificationPrefix), workerID, certification.Name, certification.Issuer, certification.ExpiresOn,
+		); err != nil {
+			return nil, fmt.Errorf("error inserting worker certification: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM worker_availability WHERE user_id = $1`, workerID); err != nil {
+		return nil, fmt.Errorf("error clearing worker availability: %w", err)
+	}
+	for _, window := range input.Availability {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO worker_availability (id, user_id, weekday, start_time, end_time)
+			VALUES ($1, $2, $3, $4, $5)`,
+			generateID(AvailabilityPrefix), workerID, int(window.Weekday), window.StartTime, window.EndTime,
+		); err != nil {
+			return nil, fmt.Errorf("error inserting worker availability: %w", err)
+		}
+	}
+
+	profile, err := getWorkerProfile(ctx, tx, workerID)
+	if err != nil {
+		return nil, err
+	}
+	action := AuditActionCreate
+	if before != nil {
+		action = AuditActionUpdate
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    workerID,
+		Action:     action,
+		EntityType: AuditEntityWorkerProfile,
+		EntityID:   workerID,
+		Before:     before,
+		After:      profile,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return profile, nil
+}
+
+func (s *service) DeleteWorkerProfile(ctx context.Context, workerID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getWorkerProfileForUpdate(ctx, tx, workerID)
+	if err != nil {
+		return err
+	}
+	// Certifications and availability cascade.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM worker_profiles WHERE user_id = $1`, workerID); err != nil {
+		return fmt.Errorf("error deleting worker profile: %w", err)
+	}
+	if err := recordAudit(ctx, tx, auditRecord{
+		ActorID:    workerID,
+		Action:     AuditActionDelete,
+		EntityType: AuditEntityWorkerProfile,
+		EntityID:   workerID,
+		Before:     before,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetAssignedWorkerProfile returns the profile of a worker who has applied to
+// or been accepted for one of the employer organization's shifts. Other
+// workers' profiles are not visible to employers.
+func (s *service) GetAssignedWorkerProfile(ctx context.Context, employerID string, workerID string) (*WorkerProfile, error) {
+	var assigned bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM shift_assignments a
+			JOIN shifts s ON a.shift_id = s.id
+			JOIN organization_members m ON m.organization_id = s.organization_id
+			WHERE a.worker_id = $1 AND m.user_id = $2 AND a.status IN ($3, $4)
+		)`,
+		workerID, employerID, AssignmentStatusApplied, AssignmentStatusAccepted,
+	).Scan(&assigned); err != nil {
+		return nil, fmt.Errorf("error checking assignments of worker %s: %w", workerID, err)
+	}
+	if !assigned {
+		return nil, fmt.Errorf("worker %s: %w", workerID, ErrForbidden)
+	}
+	return getWorkerProfile(ctx, s.db, workerID)
+}
+
+func getWorkerProfile(ctx context.Context, q querier, workerID string) (*WorkerProfile, error) {
+	return loadWorkerProfile(ctx, q, workerID, `
+		SELECT user_id, skills, preferred_location_ids, max_travel_km, created_at, COALESCE(updated_at, created_at)
+		FROM worker_profiles
+		WHERE user_id = $1`)
+}
+
+func getWorkerProfileForUpdate(ctx context.Context, tx *sql.Tx, workerID string) (*WorkerProfile, error) {
+	return loadWorkerProfile(ctx, tx, workerID, `
+		SELECT user_id, skills, preferred_location_ids, max_travel_km, created_at, COALESCE(updated_at, created_at)
+		FROM worker_profiles
+		WHERE user_id = $1
+		FOR UPDATE`)
+}
+
+func loadWorkerProfile(ctx context.Context, q querier, workerID string, query string) (*WorkerProfile, error) {
+	var profile WorkerProfile
+	var maxTravelKm sql.NullInt64
+	err := q.QueryRowContext(ctx, query, workerID).Scan(
+		&profile.UserID,
+		pq.Array(&profile.Skills),
+		pq.Array(&profile.PreferredLocationIDs),
+		&maxTravelKm,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("worker profile %s: %w", workerID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching worker profile %s: %w", workerID, err)
+	}
+	if maxTravelKm.Valid {
+		km := int(maxTravelKm.Int64)
+		profile.MaxTravelKm = &km
+	}
+	if profile.Skills == nil {
+		profile.Skills = []string{}
+	}
+	if profile.PreferredLocationIDs == nil {
+		profile.PreferredLocationIDs = []string{}
+	}
+
+	if profile.Certifications, err = loadWorkerCertifications(ctx, q, workerID); err != nil {
+		return nil, err
+	}
+	if profile.Availability, err = loadWorkerAvailability(ctx, q, workerID); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func loadWorkerCertifications(ctx context.Context, q querier, workerID string) ([]WorkerCertification, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, name, COALESCE(issuer, ''), expires_on
+		FROM worker_certifications
+		WHERE user_id = $1
+		ORDER BY name, id`,
+		workerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying worker certifications: %w", err)
+	}
+	defer rows.Close()
+
+	certifications := []WorkerCertification{}
+	for rows.Next() {
+		var certification WorkerCertification
+		var expiresOn sql.NullTime
+		if err := rows.Scan(&certification.ID, &certification.Name, &certification.Issuer, &expiresOn); err != nil {
+			return nil, fmt.Errorf("error scanning worker certification row: %w", err)
+		}
+		if expiresOn.Valid {
+			certification.ExpiresOn = &expiresOn.Time
+			certification.Expired = expiresOn.Time.Before(today())
+		}
+		certifications = append(certifications, certification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating worker certification rows: %w", err)
+	}
+	return certifications, nil
+}
+
+func loadWorkerAvailability(ctx context.Context, q querier, workerID string) ([]AvailabilityWindow, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT weekday, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI')
+		FROM worker_availability
+		WHERE user_id = $1
+		ORDER BY weekday, start_time`,
+		workerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying worker availability: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []AvailabilityWindow{}
+	for rows.Next() {
+		var window AvailabilityWindow
+		if err := rows.Scan(&window.Weekday, &window.StartTime, &window.EndTime); err != nil {
+			return nil, fmt.Errorf("error scanning worker availability row: %w", err)
+		}
+		windows = append(windows, window)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating worker availability rows: %w", err)
+	}
+	return windows, nil
+}
+
+// normalizeWorkerProfileInput validates the input and trims and de-duplicates
+// its lists in place.
+func normalizeWorkerProfileInput(input *WorkerProfileInput) error {
+	if input == nil {
+		return newValidationError("body", "is required")
+	}
+
+	skills := make([]string, 0, len(input.Skills))
+	for _, skill := range input.Skills {
+		skill = strings.TrimSpace(skill)
+		if skill == "" {
+			return newValidationError("skills", "must not contain blank skills")
+		}
+		if len(skill) > 100 {
+			return newValidationError("skills", "must each be at most 100 characters")
+		}
+		if !slices.Contains(skills, skill) {
+			skills = append(skills, skill)
+		}
+	}
+	if len(skills) > maxWorkerSkills {
+		return newValidationError("skills", fmt.Sprintf("must have at most %d entries", maxWorkerSkills))
+	}
+	input.Skills = skills
+
+	if len(input.Certifications) > maxWorkerCertifications {
+		return newValidationError("certifications", fmt.Sprintf("must have at most %d entries", maxWorkerCertifications))
+	}
+	for i := range input.Certifications {
+		certification := &input.Certifications[i]
+		certification.Name = strings.TrimSpace(certification.Name)
+		certification.Issuer = strings.TrimSpace(certification.Issuer)
+		if certification.Name == "" {
+			return newValidationError(fmt.Sprintf("certifications[%d].name", i), "is required")
+		}
+		if certification.ExpiresOn != nil {
+			expiresOn := certification.ExpiresOn.UTC().Truncate(24 * time.Hour)
+			certification.ExpiresOn = &expiresOn
+		}
+	}
+
+	locationIDs := make([]string, 0, len(input.PreferredLocationIDs))
+	for _, id := range input.PreferredLocationIDs {
+		id = strings.TrimSpace(id)
+		if id != "" && !slices.Contains(locationIDs, id) {
+			locationIDs = append(locationIDs, id)
+		}
+	}
+	input.PreferredLocationIDs = locationIDs
+
+	if input.MaxTravelKm != nil && *input.MaxTravelKm < 0 {
+		return newValidationError("max_travel_km", "must not be negative")
+	}
+
+	return validateAvailability(input.Availability)
+}
+
+// validateAvailability checks each window is well formed and that no two
+// windows on the same day overlap.
+func validateAvailability(windows []AvailabilityWindow) error {
+	type parsedWindow struct {
+		weekday    time.Weekday
+		start, end time.Time
+	}
+	parsed := make([]parsedWindow, len(windows))
+	for i, window := range windows {
+		field := fmt.Sprintf("availability[%d]", i)
+		if window.Weekday < time.Sunday || window.Weekday > time.Saturday {
+			return newValidationError(field+".weekday", "must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		start, err := time.Parse(availabilityTimeLayout, window.StartTime)
+		if err != nil {
+			return newValidationError(field+".start_time", "must be formatted as HH:MM")
+		}
+		end, err := time.Parse(availabilityTimeLayout, window.EndTime)
+		if err != nil {
+			return newValidationError(field+".end_time", "must be formatted as HH:MM")
+		}
+		if !end.After(start) {
+			return newValidationError(field+".end_time", "must be after start_time")
+		}
+		parsed[i] = parsedWindow{weekday: window.Weekday, start: start, end: end}
+	}
+
+	sort.Slice(parsed, func(i, j int) bool {
+		if parsed[i].weekday != parsed[j].weekday {
+			return parsed[i].weekday < parsed[j].weekday
+		}
+		return parsed[i].start.Before(parsed[j].start)
+	})
+	for i := 1; i < len(parsed); i++ {
+		if parsed[i].weekday == parsed[i-1].weekday && parsed[i].start.Before(parsed[i-1].end) {
+			return newValidationError("availability", fmt.Sprintf("has overlapping windows on %s", parsed[i].weekday))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WorkerProfile(t *testing.T) {
+	svc := NewService(db)
+	ctx := context.Background()
+	employerID := createTestUser(t, db, "Employer")
+	otherEmployerID := createTestUser(t, db, "Other")
+	workerID := createTestUser(t, db, "Worker")
+
+	shift, err := svc.CreateShift(ctx, employerID, validShiftInput())
+	require.NoError(t, err)
+
+	_, err = svc.GetWorkerProfile(ctx, workerID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	expiresOn := today().AddDate(1, 0, 0)
+	maxTravel := 25
+	profile, err := svc.UpsertWorkerProfile(ctx, workerID, &WorkerProfileInput{
+		Skills: []string{" forklift ", "picking", "forklift"},
+		Certifications: []WorkerCertificationInput{
+			{Name: "Forklift Operator", Issuer: "OSHA", ExpiresOn: &expiresOn},
+			{Name: "First Aid"},
+		},
+		PreferredLocationIDs: []string{shift.LocationID},
+		MaxTravelKm:          &maxTravel,
+		Availability: []AvailabilityWindow{
+			{Weekday: 1, StartTime: "08:00", EndTime: "12:00"},
+			{Weekday: 1, StartTime: "13:00", EndTime: "17:30"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"forklift", "picking"}, profile.Skills)
+	require.Len(t, profile.Certifications, 2)
+	assert.Equal(t, "First Aid", profile.Certifications[0].Name)
+	assert.Nil(t, profile.Certifications[0].ExpiresOn)
+	assert.False(t, profile.Certifications[1].Expired)
+	assert.Equal(t, []string{shift.LocationID}, profile.PreferredLocationIDs)
+	require.NotNil(t, profile.MaxTravelKm)
+	assert.Equal(t, 25, *profile.MaxTravelKm)
+	assert.Equal(t, "17:30", profile.Availability[1].EndTime)
+
+	t.Run("replacing the profile drops omitted entries", func(t *testing.T) {
+		updated, err := svc.UpsertWorkerProfile(ctx, workerID, &WorkerProfileInput{Skills: []string{"packing"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"packing"}, updated.Skills)
+		assert.Empty(t, updated.Certifications)
+		assert.Empty(t, updated.Availability)
+		assert.Nil(t, updated.MaxTravelKm)
+	})
+
+	t.Run("unknown preferred location", func(t *testing.T) {
+		_, err := svc.UpsertWorkerProfile(ctx, workerID, &WorkerProfileInput{PreferredLocationIDs: []string{"location_missing"}})
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "preferred_location_ids", validationErr.Field)
+	})
+
+	t.Run("employers only see workers on their shifts", func(t *testing.T) {
+		_, err := svc.GetAssignedWorkerProfile(ctx, employerID, workerID)
+		assert.ErrorIs(t, err, ErrForbidden, "the worker has not applied yet")
+
+		_, err = svc.ApplyToShift(ctx, workerID, shift.ID)
+		require.NoError(t, err)
+
+		seen, err := svc.GetAssignedWorkerProfile(ctx, employerID, workerID)
+		require.NoError(t, err)
+		assert.Equal(t, workerID, seen.UserID)
+
+		_, err = svc.GetAssignedWorkerProfile(ctx, otherEmployerID, workerID)
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	require.NoError(t, svc.DeleteWorkerProfile(ctx, workerID))
+	_, err = svc.GetWorkerProfile(ctx, workerID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, svc.DeleteWorkerProfile(ctx, workerID), ErrNotFound)
+
+	clearTestData(t, db)
+}
+
+func Test_ValidateAvailability(t *testing.T) {
+	tests := []struct {
+		name       string
+		windows    []AvailabilityWindow
+		errorField string
+	}{
+		{
+			name: "separate windows",
+			windows: []AvailabilityWindow{
+				{Weekday: 1, StartTime: "08:00", EndTime: "12:00"},
+				{Weekday: 1, StartTime: "12:00", EndTime: "16:00"},
+				{Weekday: 2, StartTime: "08:00", EndTime: "12:00"},
+			},
+		},
+		{
+			name:       "unknown weekday",
+			windows:    []AvailabilityWindow{{Weekday: 7, StartTime: "08:00", EndTime: "12:00"}},
+			errorField: "availability[0].weekday",
+		},
+		{
+			name:       "malformed time",
+			windows:    []AvailabilityWindow{{Weekday: 1, StartTime: "8am", EndTime: "12:00"}},
+			errorField: "availability[0].start_time",
+		},
+		{
+			name:       "spans midnight",
+			windows:    []AvailabilityWindow{{Weekday: 5, StartTime: "22:00", EndTime: "02:00"}},
+			errorField: "availability[0].end_time",
+		},
+		{
+			name: "overlapping windows",
+			windows: []AvailabilityWindow{
+				{Weekday: 3, StartTime: "13:00", EndTime: "17:00"},
+				{Weekday: 3, StartTime: "08:00", EndTime: "14:00"},
+			},
+			errorField: "availability",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAvailability(tt.windows)
+			if tt.errorField == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.errorField, validationErr.Field)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package webhooks delivers signed event notifications to endpoints registered
+// by employers. Payloads are signed with lib/signature, so receivers verify
+// them the same way we verify inbound provider webhooks.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/backoff"
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/signature"
+)
+
+const (
+	SignatureHeader = "Webhook-Signature"
+	IDHeader        = "Webhook-Id"
+	EventHeader     = "Webhook-Event"
+)
+
+// MaxAttempts is how many times a delivery is tried before it is marked failed.
+const MaxAttempts = 8
+
+const (
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+	// maxDrainedBody bounds how much of a response is read and thrown away.
+	maxDrainedBody = 64 << 10
+)
+
+// ErrPrivateAddress is returned when an endpoint resolves to an address
+// inside our own network, such as loopback, private or link-local ranges.
+var ErrPrivateAddress = errors.New("webhook endpoint resolves to a non-public address")
+
+// Backoff is how long to wait before redelivering after the given failed
+// attempt: 30s, doubling up to 6h.
+func Backoff(attempt int) time.Duration {
+	return backoff.Exponential(attempt, initialBackoff, maxBackoff)
+}
+
+// Delivery is a single request to an endpoint. ID stays the same across
+// retries so receivers can deduplicate.
+type Delivery struct {
+	ID        string
+	EventType string
+	URL       string
+	Secret    string
+	Payload   []byte
+}
+
+type Client struct {
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewClient sends deliveries with httpClient. Redirects are never followed,
+// so an endpoint cannot bounce a delivery somewhere it was not registered
+// for; they count as failed deliveries.
+func NewClient(httpClient *http.Client) *Client {
+	noRedirects := *httpClient
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Client{httpClient: &noRedirects, now: time.Now}
+}
+
+// NewHTTPClient returns an HTTP client that only connects to public
+// addresses. The check runs on the address actually dialed, after DNS
+// resolution, so a hostname cannot be pointed at an internal service.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   rejectPrivateAddress,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed in place of the endpoint, hiding its address.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+	if !IsPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not routable
+// on the internet either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr reports whether addr is reachable on the public internet
+// rather than only from inside our network.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsUnspecified() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// Send posts the signed payload and returns the response status. Any status
+// outside 2xx is returned as an error alongside the code. The response body
+// is discarded, so nothing the endpoint returns is stored.
+func (c *Client) Send(ctx context.Context, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, delivery.ID)
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(SignatureHeader, signature.Sign([]byte(delivery.Secret), c.now(), delivery.Payload))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	// Drain a little so the connection can be reused, but no more: an
+	// endpoint that streams forever must not hold up the dispatcher.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rasha-hantash/fullstack-traba-copy-cat/platform/api/lib/signature"
+)
+
+func Test_Backoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(0))
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, 6*time.Hour, Backoff(MaxAttempts+10))
+}
+
+func Test_Send(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "whdelivery_1", r.Header.Get(IDHeader))
+		assert.Equal(t, "invoice.paid", r.Header.Get(EventHeader))
+		assert.NoError(t, signature.Verify([]byte("whsec_test"), r.Header.Get(SignatureHeader), payload, time.Now()))
+		w.WriteHeader(status)
+		w.Write([]byte("nope"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client())
+	delivery := Delivery{
+		ID:        "whdelivery_1",
+		EventType: "invoice.paid",
+		URL:       server.URL,
+		Secret:    "whsec_test",
+		Payload:   []byte(`{"id":"evt_1"}`),
+	}
+
+	code, err := client.Send(context.Background(), delivery)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	status = http.StatusInternalServerError
+	code, err = client.Send(context.Background(), delivery)
+	assert.EqualError(t, err, "endpoint responded with 500", "the response body is not kept")
+	assert.Equal(t, http.StatusInternalServerError, code)
+}
+
+func Test_SendStopsReadingEndlessResponses(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(make([]byte, 2*maxDrainedBody))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	code, err := NewClient(server.Client()).Send(context.Background(), Delivery{ID: "whdelivery_1", URL: server.URL, Secret: "whsec_test"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Less(t, time.Since(start), 5*time.Second, "the rest of the response is not waited for")
+}
+
+func Test_SendDoesNotFollowRedirects(t *testing.T) {
+	var followed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			followed = true
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	code, err := NewClient(server.Client()).Send(context.Background(), Delivery{ID: "whdelivery_1", URL: server.URL, Secret: "whsec_test"})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusTemporaryRedirect, code)
+	assert.False(t, followed)
+}
+
+func Test_HTTPClientRejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached a loopback address")
+	}))
+	defer server.Close()
+
+	client := NewClient(NewHTTPClient(time.Second))
+	_, err := client.Send(context.Background(), Delivery{ID: "whdelivery_1", URL: server.URL, Secret: "whsec_test"})
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{addr: "93.184.216.34", expected: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", expected: true},
+		{addr: "127.0.0.1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "::1"},
+		{addr: "fe80::1"},
+		{addr: "fd00::1"},
+		{addr: "::ffff:127.0.0.1"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, IsPublicAddr(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_shifts_created_by;
+
+ALTER TABLE shifts DROP CONSTRAINT IF EXISTS shifts_status_check;
+ALTER TABLE shifts DROP CONSTRAINT IF EXISTS shifts_date_range_check;
+ALTER TABLE shifts DROP CONSTRAINT IF EXISTS shifts_headcount_check;
+
+ALTER TABLE shifts DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE shifts DROP COLUMN IF EXISTS status;
+ALTER TABLE shifts DROP COLUMN IF EXISTS headcount;
+
+ALTER TABLE shifts ALTER COLUMN worker_id SET NOT NULL;
@@ -0,0 +1,15 @@
+-- Shifts are now created by employers through the API, so a worker is no
+-- longer required up front and each shift carries its own headcount and status.
+ALTER TABLE shifts ALTER COLUMN worker_id DROP NOT NULL;
+
+ALTER TABLE shifts ADD COLUMN headcount INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE shifts ADD COLUMN status VARCHAR(255) NOT NULL DEFAULT 'open';
+ALTER TABLE shifts ADD COLUMN cancelled_at TIMESTAMP;
+
+UPDATE shifts SET headcount = GREATEST(shifts_filled, 1);
+
+ALTER TABLE shifts ADD CONSTRAINT shifts_headcount_check CHECK (headcount > 0);
+ALTER TABLE shifts ADD CONSTRAINT shifts_date_range_check CHECK (end_date >= start_date);
+ALTER TABLE shifts ADD CONSTRAINT shifts_status_check CHECK (status IN ('open', 'cancelled'));
+
+CREATE INDEX idx_shifts_created_by ON shifts(created_by);
@@ -0,0 +1,13 @@
+ALTER TABLE shifts DROP CONSTRAINT IF EXISTS shifts_filled_check;
+
+ALTER TABLE shifts ADD COLUMN worker_id VARCHAR(255) REFERENCES users(id);
+
+UPDATE shifts s
+SET worker_id = (
+    SELECT a.worker_id FROM shift_assignments a
+    WHERE a.shift_id = s.id AND a.status = 'accepted'
+    ORDER BY a.created_at
+    LIMIT 1
+);
+
+DROP TABLE IF EXISTS shift_assignments;
@@ -0,0 +1,34 @@
+-- Workers apply to shifts and employers accept or decline them, so a shift
+-- can now be staffed by as many workers as its headcount allows.
+CREATE TABLE shift_assignments (
+    id VARCHAR(255) PRIMARY KEY,
+    shift_id VARCHAR(255) NOT NULL,
+    worker_id VARCHAR(255) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (shift_id) REFERENCES shifts(id),
+    FOREIGN KEY (worker_id) REFERENCES users(id),
+    CONSTRAINT shift_assignments_status_check CHECK (status IN ('applied', 'accepted', 'declined', 'withdrawn'))
+);
+
+CREATE UNIQUE INDEX idx_shift_assignments_shift_worker ON shift_assignments(shift_id, worker_id);
+CREATE INDEX idx_shift_assignments_worker_id ON shift_assignments(worker_id);
+
+-- Carry the hard-wired worker of existing shifts over as accepted assignments.
+INSERT INTO shift_assignments (id, shift_id, worker_id, status, created_by)
+SELECT 'assignment_' || uuid_generate_v4(), id, worker_id, 'accepted', created_by
+FROM shifts
+WHERE worker_id IS NOT NULL;
+
+UPDATE shifts s
+SET shifts_filled = (
+    SELECT COUNT(*) FROM shift_assignments a
+    WHERE a.shift_id = s.id AND a.status = 'accepted'
+);
+
+ALTER TABLE shifts ADD CONSTRAINT shifts_filled_check CHECK (shifts_filled >= 0 AND shifts_filled <= headcount);
+
+ALTER TABLE shifts DROP COLUMN worker_id;
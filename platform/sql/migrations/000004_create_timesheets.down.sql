@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS timesheet_versions;
+DROP TABLE IF EXISTS timesheet_breaks;
+DROP TABLE IF EXISTS timesheets;
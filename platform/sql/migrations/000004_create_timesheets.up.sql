@@ -0,0 +1,54 @@
+CREATE TABLE timesheets (
+    id VARCHAR(255) PRIMARY KEY,
+    shift_id VARCHAR(255) NOT NULL,
+    assignment_id VARCHAR(255) NOT NULL,
+    worker_id VARCHAR(255) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    clock_in_at TIMESTAMP NOT NULL,
+    clock_out_at TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    approved_by VARCHAR(255),
+    approved_at TIMESTAMP,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (shift_id) REFERENCES shifts(id),
+    FOREIGN KEY (assignment_id) REFERENCES shift_assignments(id),
+    FOREIGN KEY (worker_id) REFERENCES users(id),
+    CONSTRAINT timesheets_status_check CHECK (status IN ('clocked_in', 'clocked_out', 'approved')),
+    CONSTRAINT timesheets_clock_range_check CHECK (clock_out_at IS NULL OR clock_out_at > clock_in_at)
+);
+
+CREATE INDEX idx_timesheets_shift_id ON timesheets(shift_id);
+CREATE INDEX idx_timesheets_worker_id ON timesheets(worker_id);
+-- A worker can only be clocked in once per shift at a time.
+CREATE UNIQUE INDEX idx_timesheets_open_per_assignment ON timesheets(assignment_id) WHERE status = 'clocked_in';
+
+CREATE TABLE timesheet_breaks (
+    id VARCHAR(255) PRIMARY KEY,
+    timesheet_id VARCHAR(255) NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (timesheet_id) REFERENCES timesheets(id) ON DELETE CASCADE,
+    CONSTRAINT timesheet_breaks_range_check CHECK (ended_at IS NULL OR ended_at > started_at)
+);
+
+CREATE INDEX idx_timesheet_breaks_timesheet_id ON timesheet_breaks(timesheet_id);
+
+-- Every change to a timesheet is snapshotted here so edits can be audited.
+CREATE TABLE timesheet_versions (
+    id VARCHAR(255) PRIMARY KEY,
+    timesheet_id VARCHAR(255) NOT NULL,
+    version INTEGER NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    clock_in_at TIMESTAMP NOT NULL,
+    clock_out_at TIMESTAMP,
+    breaks JSONB NOT NULL DEFAULT '[]',
+    reason TEXT NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (timesheet_id) REFERENCES timesheets(id) ON DELETE CASCADE,
+    UNIQUE (timesheet_id, version)
+);
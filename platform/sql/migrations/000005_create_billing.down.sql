@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_timesheets_unbilled;
+ALTER TABLE timesheets DROP COLUMN IF EXISTS invoice_id;
+
+DROP INDEX IF EXISTS idx_invoices_employer_period;
+DELETE FROM invoices WHERE shift_id IS NULL;
+ALTER TABLE invoices DROP COLUMN IF EXISTS platform_fee_amount;
+ALTER TABLE invoices DROP COLUMN IF EXISTS subtotal_amount;
+ALTER TABLE invoices DROP COLUMN IF EXISTS period_end;
+ALTER TABLE invoices DROP COLUMN IF EXISTS period_start;
+ALTER TABLE invoices ALTER COLUMN shift_id SET NOT NULL;
+
+DROP TABLE IF EXISTS billing_rates;
+
+ALTER TABLE shifts DROP CONSTRAINT IF EXISTS shifts_hourly_rate_check;
+ALTER TABLE shifts DROP COLUMN IF EXISTS hourly_rate;
+ALTER TABLE shifts DROP COLUMN IF EXISTS role;
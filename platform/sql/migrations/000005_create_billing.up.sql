@@ -0,0 +1,34 @@
+-- Shifts can carry their own negotiated hourly rate (in cents) or fall back to
+-- the employer's rate for the shift's role.
+ALTER TABLE shifts ADD COLUMN role VARCHAR(255);
+ALTER TABLE shifts ADD COLUMN hourly_rate INTEGER;
+ALTER TABLE shifts ADD CONSTRAINT shifts_hourly_rate_check CHECK (hourly_rate IS NULL OR hourly_rate > 0);
+
+CREATE TABLE billing_rates (
+    id VARCHAR(255) PRIMARY KEY,
+    employer_id VARCHAR(255) NOT NULL,
+    role VARCHAR(255) NOT NULL,
+    hourly_rate INTEGER NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    UNIQUE (employer_id, role),
+    CONSTRAINT billing_rates_hourly_rate_check CHECK (hourly_rate > 0)
+);
+
+-- Invoices produced by the billing engine cover a whole billing period rather
+-- than a single shift.
+ALTER TABLE invoices ALTER COLUMN shift_id DROP NOT NULL;
+ALTER TABLE invoices ADD COLUMN period_start DATE;
+ALTER TABLE invoices ADD COLUMN period_end DATE;
+ALTER TABLE invoices ADD COLUMN subtotal_amount INTEGER;
+ALTER TABLE invoices ADD COLUMN platform_fee_amount INTEGER;
+
+-- One invoice per employer per billing period keeps billing runs idempotent.
+CREATE UNIQUE INDEX idx_invoices_employer_period ON invoices(created_by, period_start, period_end)
+    WHERE period_start IS NOT NULL;
+
+-- A timesheet is billed at most once.
+ALTER TABLE timesheets ADD COLUMN invoice_id VARCHAR(255) REFERENCES invoices(id);
+CREATE INDEX idx_timesheets_unbilled ON timesheets(status, clock_in_at) WHERE invoice_id IS NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE invoices ALTER COLUMN platform_fee_amount DROP NOT NULL;
+ALTER TABLE invoices ALTER COLUMN platform_fee_amount DROP DEFAULT;
+ALTER TABLE invoices ALTER COLUMN subtotal_amount DROP NOT NULL;
+ALTER TABLE invoices ALTER COLUMN subtotal_amount DROP DEFAULT;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tax_amount;
+
+DROP TABLE IF EXISTS invoice_line_items;
@@ -0,0 +1,48 @@
+-- Amounts are in cents. quantity is hours for labor lines and 1 for flat charges.
+CREATE TABLE invoice_line_items (
+    id VARCHAR(255) PRIMARY KEY,
+    invoice_id VARCHAR(255) NOT NULL,
+    kind VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    quantity NUMERIC(12, 4) NOT NULL,
+    unit_price INTEGER NOT NULL,
+    amount INTEGER NOT NULL,
+    tax_rate_bps INTEGER NOT NULL DEFAULT 0,
+    tax_amount INTEGER NOT NULL DEFAULT 0,
+    shift_id VARCHAR(255),
+    timesheet_id VARCHAR(255),
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE,
+    FOREIGN KEY (shift_id) REFERENCES shifts(id),
+    FOREIGN KEY (timesheet_id) REFERENCES timesheets(id),
+    CONSTRAINT invoice_line_items_kind_check CHECK (kind IN ('labor', 'platform_fee')),
+    CONSTRAINT invoice_line_items_tax_rate_check CHECK (tax_rate_bps >= 0)
+);
+
+CREATE INDEX idx_invoice_line_items_invoice_id ON invoice_line_items(invoice_id);
+-- A timesheet can only appear on one line.
+CREATE UNIQUE INDEX idx_invoice_line_items_timesheet_id ON invoice_line_items(timesheet_id) WHERE timesheet_id IS NOT NULL;
+
+ALTER TABLE invoices ADD COLUMN tax_amount INTEGER NOT NULL DEFAULT 0;
+
+-- Give invoices created before line items existed a line for their labor and,
+-- for billing engine invoices, a line for the platform fee.
+INSERT INTO invoice_line_items (id, invoice_id, kind, description, quantity, unit_price, amount, shift_id, created_by)
+SELECT 'line_' || uuid_generate_v4(), i.id, 'labor', COALESCE(i.invoice_name, 'Shift'), 1,
+    COALESCE(i.subtotal_amount, i.invoice_amount), COALESCE(i.subtotal_amount, i.invoice_amount), i.shift_id, i.created_by
+FROM invoices i;
+
+INSERT INTO invoice_line_items (id, invoice_id, kind, description, quantity, unit_price, amount, created_by)
+SELECT 'line_' || uuid_generate_v4(), i.id, 'platform_fee', 'Platform fee', 1, i.platform_fee_amount, i.platform_fee_amount, i.created_by
+FROM invoices i
+WHERE i.platform_fee_amount > 0;
+
+UPDATE invoices
+SET subtotal_amount = COALESCE(subtotal_amount, invoice_amount),
+    platform_fee_amount = COALESCE(platform_fee_amount, 0);
+
+ALTER TABLE invoices ALTER COLUMN subtotal_amount SET DEFAULT 0;
+ALTER TABLE invoices ALTER COLUMN subtotal_amount SET NOT NULL;
+ALTER TABLE invoices ALTER COLUMN platform_fee_amount SET DEFAULT 0;
+ALTER TABLE invoices ALTER COLUMN platform_fee_amount SET NOT NULL;
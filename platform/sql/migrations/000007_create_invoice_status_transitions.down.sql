@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_invoices_status;
+DROP TABLE IF EXISTS invoice_status_transitions;
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_status_check;
+ALTER TABLE invoices ALTER COLUMN status DROP DEFAULT;
+
+UPDATE invoices SET status = CASE status
+    WHEN 'paid' THEN 'paid'
+    ELSE 'unpaid'
+END;
@@ -0,0 +1,30 @@
+-- Normalise the free-form statuses written so far onto the invoice lifecycle.
+UPDATE invoices SET status = CASE status
+    WHEN 'paid' THEN 'paid'
+    WHEN 'approved' THEN 'paid'
+    WHEN 'unpaid' THEN 'issued'
+    WHEN 'pending' THEN 'issued'
+    WHEN 'rejected' THEN 'disputed'
+    ELSE 'draft'
+END;
+
+-- Billing engine invoices that were still being amended become drafts.
+UPDATE invoices SET status = 'draft' WHERE period_start IS NOT NULL AND status = 'issued';
+
+ALTER TABLE invoices ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check
+    CHECK (status IN ('draft', 'issued', 'partially_paid', 'paid', 'void', 'disputed'));
+
+CREATE TABLE invoice_status_transitions (
+    id VARCHAR(255) PRIMARY KEY,
+    invoice_id VARCHAR(255) NOT NULL,
+    from_status VARCHAR(255) NOT NULL,
+    to_status VARCHAR(255) NOT NULL,
+    reason TEXT,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_invoice_status_transitions_invoice_id ON invoice_status_transitions(invoice_id, created_at);
+CREATE INDEX idx_invoices_status ON invoices(status);
@@ -0,0 +1,12 @@
+ALTER TABLE invoice_line_items DROP CONSTRAINT invoice_line_items_currency_check;
+ALTER TABLE invoice_line_items DROP COLUMN currency;
+ALTER TABLE invoice_line_items ALTER COLUMN tax_amount TYPE INTEGER;
+ALTER TABLE invoice_line_items ALTER COLUMN amount TYPE INTEGER;
+ALTER TABLE invoice_line_items ALTER COLUMN unit_price TYPE INTEGER;
+
+ALTER TABLE invoices DROP CONSTRAINT invoices_currency_check;
+ALTER TABLE invoices DROP COLUMN currency;
+ALTER TABLE invoices ALTER COLUMN tax_amount TYPE INTEGER;
+ALTER TABLE invoices ALTER COLUMN platform_fee_amount TYPE INTEGER;
+ALTER TABLE invoices ALTER COLUMN subtotal_amount TYPE INTEGER;
+ALTER TABLE invoices ALTER COLUMN invoice_amount TYPE INTEGER;
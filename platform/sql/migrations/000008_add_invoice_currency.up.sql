@@ -0,0 +1,14 @@
+-- Amounts are whole minor units of the row's ISO 4217 currency. Widen them so
+-- large invoices cannot overflow, and record the currency alongside.
+ALTER TABLE invoices ALTER COLUMN invoice_amount TYPE BIGINT;
+ALTER TABLE invoices ALTER COLUMN subtotal_amount TYPE BIGINT;
+ALTER TABLE invoices ALTER COLUMN platform_fee_amount TYPE BIGINT;
+ALTER TABLE invoices ALTER COLUMN tax_amount TYPE BIGINT;
+ALTER TABLE invoices ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE invoices ADD CONSTRAINT invoices_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+
+ALTER TABLE invoice_line_items ALTER COLUMN unit_price TYPE BIGINT;
+ALTER TABLE invoice_line_items ALTER COLUMN amount TYPE BIGINT;
+ALTER TABLE invoice_line_items ALTER COLUMN tax_amount TYPE BIGINT;
+ALTER TABLE invoice_line_items ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE invoice_line_items ADD CONSTRAINT invoice_line_items_currency_check CHECK (currency ~ '^[A-Z]{3}$');
@@ -0,0 +1,14 @@
+DROP TRIGGER shifts_search_vector_refresh ON shifts;
+DROP FUNCTION shifts_search_vector_refresh();
+DROP TRIGGER invoice_line_items_search_vector_refresh ON invoice_line_items;
+DROP FUNCTION invoice_line_items_search_vector_refresh();
+DROP TRIGGER invoices_search_vector_refresh ON invoices;
+DROP FUNCTION invoices_search_vector_refresh();
+
+DROP INDEX idx_invoices_search_vector;
+ALTER TABLE invoices DROP COLUMN search_vector;
+DROP FUNCTION invoice_search_vector(VARCHAR, VARCHAR, VARCHAR);
+DROP AGGREGATE tsvector_agg(tsvector);
+
+DROP INDEX idx_shifts_search_vector;
+ALTER TABLE shifts DROP COLUMN search_vector;
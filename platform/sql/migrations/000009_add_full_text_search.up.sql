@@ -0,0 +1,72 @@
+-- Shift text is weighted name and location over description.
+ALTER TABLE shifts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(shift_name, '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(location, '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(shift_description, '')), 'C')
+) STORED;
+CREATE INDEX idx_shifts_search_vector ON shifts USING GIN (search_vector);
+
+CREATE AGGREGATE tsvector_agg(tsvector) (SFUNC = tsvector_concat, STYPE = tsvector, INITCOND = '');
+
+-- An invoice is found by its own name and by the text of every shift it bills,
+-- whether through shift_id or through its line items.
+CREATE FUNCTION invoice_search_vector(p_invoice_id VARCHAR, p_invoice_name VARCHAR, p_shift_id VARCHAR)
+RETURNS tsvector LANGUAGE sql STABLE AS $$
+    SELECT setweight(to_tsvector('english', COALESCE(p_invoice_name, '')), 'A') || COALESCE((
+        SELECT tsvector_agg(s.search_vector)
+        FROM shifts s
+        WHERE s.id = p_shift_id
+        OR s.id IN (SELECT l.shift_id FROM invoice_line_items l WHERE l.invoice_id = p_invoice_id)
+    ), ''::tsvector)
+$$;
+
+ALTER TABLE invoices ADD COLUMN search_vector tsvector NOT NULL DEFAULT ''::tsvector;
+UPDATE invoices SET search_vector = invoice_search_vector(id, invoice_name, shift_id);
+CREATE INDEX idx_invoices_search_vector ON invoices USING GIN (search_vector);
+
+CREATE FUNCTION invoices_search_vector_refresh() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_vector := invoice_search_vector(NEW.id, NEW.invoice_name, NEW.shift_id);
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER invoices_search_vector_refresh
+    BEFORE INSERT OR UPDATE OF invoice_name, shift_id ON invoices
+    FOR EACH ROW EXECUTE FUNCTION invoices_search_vector_refresh();
+
+CREATE FUNCTION invoice_line_items_search_vector_refresh() RETURNS trigger LANGUAGE plpgsql AS $$
+DECLARE
+    line invoice_line_items%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        line := OLD;
+    ELSE
+        line := NEW;
+    END IF;
+    IF line.shift_id IS NOT NULL THEN
+        UPDATE invoices
+        SET search_vector = invoice_search_vector(id, invoice_name, shift_id)
+        WHERE id = line.invoice_id;
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER invoice_line_items_search_vector_refresh
+    AFTER INSERT OR DELETE ON invoice_line_items
+    FOR EACH ROW EXECUTE FUNCTION invoice_line_items_search_vector_refresh();
+
+CREATE FUNCTION shifts_search_vector_refresh() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE invoices
+    SET search_vector = invoice_search_vector(id, invoice_name, shift_id)
+    WHERE shift_id = NEW.id
+    OR id IN (SELECT l.invoice_id FROM invoice_line_items l WHERE l.shift_id = NEW.id);
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER shifts_search_vector_refresh
+    AFTER UPDATE OF shift_name, location, shift_description ON shifts
+    FOR EACH ROW EXECUTE FUNCTION shifts_search_vector_refresh();
@@ -0,0 +1,36 @@
+-- Amounts are minor units of currency, which always matches the invoice's.
+CREATE TABLE payments (
+    id VARCHAR(255) PRIMARY KEY,
+    invoice_id VARCHAR(255) NOT NULL,
+    provider VARCHAR(255) NOT NULL,
+    provider_intent_id VARCHAR(255) NOT NULL,
+    amount BIGINT NOT NULL,
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    refunded_amount BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(255) NOT NULL DEFAULT 'pending',
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id),
+    UNIQUE (provider, provider_intent_id),
+    CONSTRAINT payments_status_check CHECK (status IN ('pending', 'succeeded', 'failed')),
+    CONSTRAINT payments_amount_check CHECK (amount > 0),
+    CONSTRAINT payments_captured_amount_check CHECK (captured_amount >= 0 AND captured_amount <= amount),
+    CONSTRAINT payments_refunded_amount_check CHECK (refunded_amount >= 0 AND refunded_amount <= captured_amount)
+);
+
+CREATE INDEX idx_payments_invoice_id ON payments(invoice_id);
+
+-- Provider webhook events already applied, so redelivered events are ignored.
+CREATE TABLE payment_events (
+    provider VARCHAR(255) NOT NULL,
+    provider_event_id VARCHAR(255) NOT NULL,
+    payment_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, provider_event_id),
+    FOREIGN KEY (payment_id) REFERENCES payments(id)
+);
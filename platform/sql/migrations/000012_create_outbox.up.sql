@@ -0,0 +1,24 @@
+-- Domain events written in the same transaction as the change they describe
+-- and published to sinks by the outbox relay. published_sinks records which
+-- sinks have accepted the event so retries only go to the ones that failed.
+CREATE TABLE outbox (
+    id VARCHAR(255) PRIMARY KEY,
+    dedupe_key VARCHAR(255) NOT NULL UNIQUE,
+    event_type VARCHAR(255) NOT NULL,
+    aggregate_type VARCHAR(255) NOT NULL,
+    aggregate_id VARCHAR(255) NOT NULL,
+    employer_id VARCHAR(255),
+    payload JSONB NOT NULL,
+    published_sinks TEXT[] NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_due ON outbox(next_attempt_at) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_aggregate ON outbox(aggregate_type, aggregate_id);
+
+-- The webhook sink skips endpoints that already have a delivery for an event.
+CREATE INDEX idx_webhook_deliveries_event_id ON webhook_deliveries(event_id);
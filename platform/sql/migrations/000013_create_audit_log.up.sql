@@ -0,0 +1,28 @@
+-- One row per service mutation. employer_id is whose log the entry appears in,
+-- which differs from actor_id for worker and system actions.
+CREATE TABLE audit_log (
+    id VARCHAR(255) PRIMARY KEY,
+    actor_id VARCHAR(255) NOT NULL,
+    employer_id VARCHAR(255),
+    action VARCHAR(255) NOT NULL,
+    entity_type VARCHAR(255) NOT NULL,
+    entity_id VARCHAR(255) NOT NULL,
+    before JSONB,
+    after JSONB,
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_employer_id ON audit_log(employer_id, created_at);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at);
+
+-- The log is append-only.
+CREATE FUNCTION audit_log_immutable() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$;
+
+CREATE TRIGGER audit_log_immutable
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
@@ -0,0 +1,41 @@
+-- employer_id goes back to holding the user who founded the organization.
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_immutable;
+UPDATE audit_log a SET employer_id = o.created_by
+FROM organizations o
+WHERE o.id = a.employer_id;
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_immutable;
+
+UPDATE outbox ob SET employer_id = o.created_by
+FROM organizations o
+WHERE o.id = ob.employer_id;
+
+ALTER TABLE webhook_endpoints DROP CONSTRAINT IF EXISTS webhook_endpoints_employer_id_fkey;
+UPDATE webhook_endpoints e SET employer_id = o.created_by
+FROM organizations o
+WHERE o.id = e.employer_id;
+ALTER TABLE webhook_endpoints ADD CONSTRAINT webhook_endpoints_employer_id_fkey
+    FOREIGN KEY (employer_id) REFERENCES users(id);
+
+UPDATE billing_rates br SET employer_id = o.created_by
+FROM organizations o
+WHERE o.id = br.employer_id;
+
+-- Billing runs create invoices as the system; give them back to an employer.
+UPDATE invoices i SET created_by = o.created_by
+FROM organizations o
+WHERE o.id = i.organization_id AND i.created_by = 'system:billing';
+
+DROP INDEX IF EXISTS idx_invoices_organization_period;
+CREATE UNIQUE INDEX idx_invoices_employer_period ON invoices(created_by, period_start, period_end)
+    WHERE period_start IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_invoices_organization_id;
+ALTER TABLE invoices DROP COLUMN IF EXISTS organization_id;
+
+ALTER TABLE shifts DROP COLUMN IF EXISTS location_id;
+DROP INDEX IF EXISTS idx_shifts_organization_id;
+ALTER TABLE shifts DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS locations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
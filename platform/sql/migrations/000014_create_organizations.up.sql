@@ -0,0 +1,102 @@
+-- Employers are organizations that users belong to, so colleagues share
+-- shifts, invoices and locations. A user belongs to at most one organization.
+CREATE TABLE organizations (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+    organization_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL UNIQUE,
+    role VARCHAR(255) NOT NULL DEFAULT 'member',
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT organization_members_role_check CHECK (role IN ('owner', 'member'))
+);
+
+-- Every existing employer becomes the owner of an organization of their own.
+-- Colleagues are not merged by company name, which is free text.
+INSERT INTO organizations (id, name, created_by)
+SELECT 'org_' || uuid_generate_v4(), COALESCE(NULLIF(u.company_name, ''), u.first_name || ' ' || u.last_name), u.id
+FROM users u
+WHERE u.company_name <> ''
+OR EXISTS (SELECT 1 FROM shifts s WHERE s.created_by = u.id)
+OR EXISTS (SELECT 1 FROM invoices i WHERE i.created_by = u.id)
+OR EXISTS (SELECT 1 FROM billing_rates br WHERE br.employer_id = u.id)
+OR EXISTS (SELECT 1 FROM webhook_endpoints e WHERE e.employer_id = u.id);
+
+INSERT INTO organization_members (organization_id, user_id, role, created_by)
+SELECT id, created_by, 'owner', created_by FROM organizations;
+
+-- Named places an organization staffs shifts at.
+CREATE TABLE locations (
+    id VARCHAR(255) PRIMARY KEY,
+    organization_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    UNIQUE (organization_id, name),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id)
+);
+
+ALTER TABLE shifts ADD COLUMN organization_id VARCHAR(255) REFERENCES organizations(id);
+UPDATE shifts s SET organization_id = m.organization_id
+FROM organization_members m
+WHERE m.user_id = s.created_by;
+ALTER TABLE shifts ALTER COLUMN organization_id SET NOT NULL;
+CREATE INDEX idx_shifts_organization_id ON shifts(organization_id);
+
+INSERT INTO locations (id, organization_id, name, created_by)
+SELECT 'location_' || uuid_generate_v4(), organization_id, location, MIN(created_by)
+FROM shifts
+GROUP BY organization_id, location;
+
+-- shifts.location keeps the location's name for display and search.
+ALTER TABLE shifts ADD COLUMN location_id VARCHAR(255) REFERENCES locations(id);
+UPDATE shifts s SET location_id = l.id
+FROM locations l
+WHERE l.organization_id = s.organization_id AND l.name = s.location;
+ALTER TABLE shifts ALTER COLUMN location_id SET NOT NULL;
+
+ALTER TABLE invoices ADD COLUMN organization_id VARCHAR(255) REFERENCES organizations(id);
+UPDATE invoices i SET organization_id = m.organization_id
+FROM organization_members m
+WHERE m.user_id = i.created_by;
+ALTER TABLE invoices ALTER COLUMN organization_id SET NOT NULL;
+CREATE INDEX idx_invoices_organization_id ON invoices(organization_id);
+
+DROP INDEX idx_invoices_employer_period;
+CREATE UNIQUE INDEX idx_invoices_organization_period ON invoices(organization_id, period_start, period_end)
+    WHERE period_start IS NOT NULL;
+
+-- employer_id elsewhere now holds the employer's organization.
+UPDATE billing_rates br SET employer_id = m.organization_id
+FROM organization_members m
+WHERE m.user_id = br.employer_id;
+
+ALTER TABLE webhook_endpoints DROP CONSTRAINT webhook_endpoints_employer_id_fkey;
+UPDATE webhook_endpoints e SET employer_id = m.organization_id
+FROM organization_members m
+WHERE m.user_id = e.employer_id;
+ALTER TABLE webhook_endpoints ADD CONSTRAINT webhook_endpoints_employer_id_fkey
+    FOREIGN KEY (employer_id) REFERENCES organizations(id);
+
+UPDATE outbox o SET employer_id = m.organization_id
+FROM organization_members m
+WHERE m.user_id = o.employer_id;
+
+-- Re-keying the log is the one rewrite it allows; entries are otherwise untouched.
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_immutable;
+UPDATE audit_log a SET employer_id = m.organization_id
+FROM organization_members m
+WHERE m.user_id = a.employer_id;
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_immutable;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS worker_availability;
+DROP TABLE IF EXISTS worker_certifications;
+DROP TABLE IF EXISTS worker_profiles;
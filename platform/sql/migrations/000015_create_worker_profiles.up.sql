@@ -0,0 +1,41 @@
+-- What a worker brings to a shift and when they can work. Skills and preferred
+-- locations are small sets that are always read and replaced whole.
+CREATE TABLE worker_profiles (
+    user_id VARCHAR(255) PRIMARY KEY,
+    skills TEXT[] NOT NULL DEFAULT '{}',
+    preferred_location_ids TEXT[] NOT NULL DEFAULT '{}',
+    max_travel_km INTEGER,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT worker_profiles_max_travel_check CHECK (max_travel_km IS NULL OR max_travel_km >= 0)
+);
+
+CREATE TABLE worker_certifications (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    issuer VARCHAR(255),
+    expires_on DATE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES worker_profiles(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_worker_certifications_user_id ON worker_certifications(user_id);
+
+-- Recurring weekly windows; weekday follows Go's time.Weekday (0 is Sunday).
+CREATE TABLE worker_availability (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    weekday SMALLINT NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES worker_profiles(user_id) ON DELETE CASCADE,
+    CONSTRAINT worker_availability_weekday_check CHECK (weekday BETWEEN 0 AND 6),
+    CONSTRAINT worker_availability_range_check CHECK (end_time > start_time)
+);
+
+CREATE INDEX idx_worker_availability_user_id ON worker_availability(user_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_shifts_series_occurrence;
+ALTER TABLE shifts DROP CONSTRAINT IF EXISTS shifts_series_occurrence_check;
+ALTER TABLE shifts DROP COLUMN IF EXISTS occurrence_date;
+ALTER TABLE shifts DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS shift_series;
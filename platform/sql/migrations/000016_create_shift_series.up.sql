@@ -0,0 +1,41 @@
+-- A shift series is a template plus an RFC 5545 recurrence rule. Occurrences
+-- are materialized as ordinary shifts up to horizon_days ahead, so staffing,
+-- timesheets and billing never need to know about the rule.
+CREATE TABLE shift_series (
+    id VARCHAR(255) PRIMARY KEY,
+    organization_id VARCHAR(255) NOT NULL,
+    rrule TEXT NOT NULL,
+    starts_on DATE NOT NULL,
+    -- Days each occurrence spans beyond its start date.
+    duration_days INTEGER NOT NULL DEFAULT 0,
+    -- Dates the rule would produce that must not get a shift.
+    exception_dates DATE[] NOT NULL DEFAULT '{}',
+    horizon_days INTEGER NOT NULL DEFAULT 28,
+    materialized_through DATE,
+    location VARCHAR(255) NOT NULL,
+    location_id VARCHAR(255) NOT NULL,
+    shift_name VARCHAR(255) NOT NULL,
+    shift_description TEXT,
+    headcount INTEGER NOT NULL,
+    role VARCHAR(255),
+    hourly_rate INTEGER,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    CONSTRAINT shift_series_duration_check CHECK (duration_days >= 0),
+    CONSTRAINT shift_series_horizon_check CHECK (horizon_days BETWEEN 1 AND 365),
+    CONSTRAINT shift_series_hourly_rate_check CHECK (hourly_rate IS NULL OR hourly_rate > 0)
+);
+
+CREATE INDEX idx_shift_series_organization_id ON shift_series(organization_id);
+
+-- occurrence_date is the date the rule produced, which stays put when a single
+-- occurrence is moved, so it is never generated twice.
+ALTER TABLE shifts ADD COLUMN series_id VARCHAR(255) REFERENCES shift_series(id);
+ALTER TABLE shifts ADD COLUMN occurrence_date DATE;
+ALTER TABLE shifts ADD CONSTRAINT shifts_series_occurrence_check
+    CHECK ((series_id IS NULL) = (occurrence_date IS NULL));
+CREATE UNIQUE INDEX idx_shifts_series_occurrence ON shifts(series_id, occurrence_date);
@@ -0,0 +1,38 @@
+ALTER TABLE timesheet_versions
+    ALTER COLUMN clock_in_at TYPE TIMESTAMP USING clock_in_at AT TIME ZONE 'UTC',
+    ALTER COLUMN clock_out_at TYPE TIMESTAMP USING clock_out_at AT TIME ZONE 'UTC';
+ALTER TABLE timesheet_breaks
+    ALTER COLUMN started_at TYPE TIMESTAMP USING started_at AT TIME ZONE 'UTC',
+    ALTER COLUMN ended_at TYPE TIMESTAMP USING ended_at AT TIME ZONE 'UTC';
+ALTER TABLE timesheets
+    ALTER COLUMN clock_in_at TYPE TIMESTAMP USING clock_in_at AT TIME ZONE 'UTC',
+    ALTER COLUMN clock_out_at TYPE TIMESTAMP USING clock_out_at AT TIME ZONE 'UTC';
+
+-- Series and shifts go back to the local dates they start and end on.
+ALTER TABLE shift_series DROP CONSTRAINT IF EXISTS shift_series_time_range_check;
+ALTER TABLE shift_series ADD COLUMN starts_on DATE;
+ALTER TABLE shift_series ADD COLUMN duration_days INTEGER NOT NULL DEFAULT 0;
+UPDATE shift_series
+SET starts_on = (starts_at AT TIME ZONE timezone)::date,
+    duration_days = ((ends_at - INTERVAL '1 microsecond') AT TIME ZONE timezone)::date - (starts_at AT TIME ZONE timezone)::date;
+ALTER TABLE shift_series ALTER COLUMN starts_on SET NOT NULL;
+ALTER TABLE shift_series ADD CONSTRAINT shift_series_duration_check CHECK (duration_days >= 0);
+ALTER TABLE shift_series DROP COLUMN IF EXISTS timezone;
+ALTER TABLE shift_series DROP COLUMN IF EXISTS ends_at;
+ALTER TABLE shift_series DROP COLUMN IF EXISTS starts_at;
+
+DROP INDEX IF EXISTS idx_shifts_starts_at;
+ALTER TABLE shifts DROP CONSTRAINT IF EXISTS shifts_time_range_check;
+ALTER TABLE shifts ADD COLUMN start_date DATE;
+ALTER TABLE shifts ADD COLUMN end_date DATE;
+UPDATE shifts
+SET start_date = (starts_at AT TIME ZONE timezone)::date,
+    end_date = ((ends_at - INTERVAL '1 microsecond') AT TIME ZONE timezone)::date;
+ALTER TABLE shifts ALTER COLUMN start_date SET NOT NULL;
+ALTER TABLE shifts ALTER COLUMN end_date SET NOT NULL;
+ALTER TABLE shifts ADD CONSTRAINT shifts_date_range_check CHECK (end_date >= start_date);
+ALTER TABLE shifts DROP COLUMN IF EXISTS timezone;
+ALTER TABLE shifts DROP COLUMN IF EXISTS ends_at;
+ALTER TABLE shifts DROP COLUMN IF EXISTS starts_at;
+
+ALTER TABLE locations DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,48 @@
+-- Locations carry the IANA timezone their shifts are scheduled in.
+ALTER TABLE locations ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Shifts move from whole days to exact instants so overnight shifts and DST
+-- changes can be represented. Existing shifts ran whole days in UTC: from
+-- midnight on their first day to midnight after their last. timezone is copied
+-- from the location, like its name.
+ALTER TABLE shifts ADD COLUMN starts_at TIMESTAMPTZ;
+ALTER TABLE shifts ADD COLUMN ends_at TIMESTAMPTZ;
+ALTER TABLE shifts ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+UPDATE shifts
+SET starts_at = start_date::timestamp AT TIME ZONE 'UTC',
+    ends_at = (end_date + 1)::timestamp AT TIME ZONE 'UTC';
+ALTER TABLE shifts ALTER COLUMN starts_at SET NOT NULL;
+ALTER TABLE shifts ALTER COLUMN ends_at SET NOT NULL;
+ALTER TABLE shifts ALTER COLUMN timezone DROP DEFAULT;
+ALTER TABLE shifts DROP CONSTRAINT shifts_date_range_check;
+ALTER TABLE shifts DROP COLUMN start_date;
+ALTER TABLE shifts DROP COLUMN end_date;
+ALTER TABLE shifts ADD CONSTRAINT shifts_time_range_check CHECK (ends_at > starts_at);
+CREATE INDEX idx_shifts_starts_at ON shifts(starts_at);
+
+-- A series' template is its first occurrence. Later occurrences keep its
+-- local start and end times in the series' timezone.
+ALTER TABLE shift_series ADD COLUMN starts_at TIMESTAMPTZ;
+ALTER TABLE shift_series ADD COLUMN ends_at TIMESTAMPTZ;
+ALTER TABLE shift_series ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+UPDATE shift_series
+SET starts_at = starts_on::timestamp AT TIME ZONE 'UTC',
+    ends_at = (starts_on + duration_days + 1)::timestamp AT TIME ZONE 'UTC';
+ALTER TABLE shift_series ALTER COLUMN starts_at SET NOT NULL;
+ALTER TABLE shift_series ALTER COLUMN ends_at SET NOT NULL;
+ALTER TABLE shift_series ALTER COLUMN timezone DROP DEFAULT;
+ALTER TABLE shift_series DROP COLUMN starts_on;
+ALTER TABLE shift_series DROP COLUMN duration_days;
+ALTER TABLE shift_series ADD CONSTRAINT shift_series_time_range_check CHECK (ends_at > starts_at);
+
+-- Worked time is billed from these, so they must be absolute instants for
+-- hours to come out right across DST changes. They were written in UTC.
+ALTER TABLE timesheets
+    ALTER COLUMN clock_in_at TYPE TIMESTAMPTZ USING clock_in_at AT TIME ZONE 'UTC',
+    ALTER COLUMN clock_out_at TYPE TIMESTAMPTZ USING clock_out_at AT TIME ZONE 'UTC';
+ALTER TABLE timesheet_breaks
+    ALTER COLUMN started_at TYPE TIMESTAMPTZ USING started_at AT TIME ZONE 'UTC',
+    ALTER COLUMN ended_at TYPE TIMESTAMPTZ USING ended_at AT TIME ZONE 'UTC';
+ALTER TABLE timesheet_versions
+    ALTER COLUMN clock_in_at TYPE TIMESTAMPTZ USING clock_in_at AT TIME ZONE 'UTC',
+    ALTER COLUMN clock_out_at TYPE TIMESTAMPTZ USING clock_out_at AT TIME ZONE 'UTC';
@@ -0,0 +1,12 @@
+ALTER TABLE shift_assignments DROP CONSTRAINT IF EXISTS shift_assignments_no_overlap;
+
+DROP TRIGGER IF EXISTS shifts_assignment_period_refresh ON shifts;
+DROP FUNCTION IF EXISTS shifts_assignment_period_refresh();
+DROP TRIGGER IF EXISTS shift_assignments_period_refresh ON shift_assignments;
+DROP FUNCTION IF EXISTS shift_assignments_period_refresh();
+
+ALTER TABLE shift_assignments DROP COLUMN IF EXISTS shift_period;
+
+DROP FUNCTION IF EXISTS shift_booking_period(TIMESTAMPTZ, TIMESTAMPTZ, VARCHAR);
+
+DROP EXTENSION IF EXISTS btree_gist;
@@ -0,0 +1,67 @@
+-- A worker cannot hold accepted assignments on overlapping shifts. Exclusion
+-- constraints only see their own table, so each assignment carries a copy of
+-- its shift's time range, kept in step with the shift by triggers. Cancelled
+-- shifts have no range and so never conflict.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE FUNCTION shift_booking_period(p_starts_at TIMESTAMPTZ, p_ends_at TIMESTAMPTZ, p_status VARCHAR)
+RETURNS TSTZRANGE LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE WHEN p_status = 'cancelled' THEN NULL ELSE tstzrange(p_starts_at, p_ends_at) END
+$$;
+
+ALTER TABLE shift_assignments ADD COLUMN shift_period TSTZRANGE;
+
+UPDATE shift_assignments a
+SET shift_period = shift_booking_period(s.starts_at, s.ends_at, s.status)
+FROM shifts s
+WHERE s.id = a.shift_id;
+
+CREATE FUNCTION shift_assignments_period_refresh() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    SELECT shift_booking_period(starts_at, ends_at, status) INTO NEW.shift_period
+    FROM shifts
+    WHERE id = NEW.shift_id;
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER shift_assignments_period_refresh
+    BEFORE INSERT OR UPDATE OF shift_id ON shift_assignments
+    FOR EACH ROW EXECUTE FUNCTION shift_assignments_period_refresh();
+
+CREATE FUNCTION shifts_assignment_period_refresh() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE shift_assignments
+    SET shift_period = shift_booking_period(NEW.starts_at, NEW.ends_at, NEW.status)
+    WHERE shift_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER shifts_assignment_period_refresh
+    AFTER UPDATE OF starts_at, ends_at, status ON shifts
+    FOR EACH ROW EXECUTE FUNCTION shifts_assignment_period_refresh();
+
+-- Bookings made before this migration may already overlap, and the
+-- constraint cannot be added while they do. Which booking to drop is a
+-- staffing decision, so list them and stop rather than pick one.
+DO $$
+DECLARE
+    overlapping TEXT;
+BEGIN
+    SELECT string_agg(format('worker %s: %s and %s', a.worker_id, a.id, b.id), '; ' ORDER BY a.worker_id, a.id, b.id)
+    INTO overlapping
+    FROM shift_assignments a
+    JOIN shift_assignments b ON b.worker_id = a.worker_id AND b.id > a.id
+    WHERE a.status = 'accepted' AND b.status = 'accepted'
+    AND a.shift_period && b.shift_period;
+
+    IF overlapping IS NOT NULL THEN
+        RAISE EXCEPTION 'accepted assignments on overlapping shifts must be resolved first: %', overlapping
+            USING HINT = 'Withdraw or decline one assignment of each pair, then run the migration again.';
+    END IF;
+END
+$$;
+
+ALTER TABLE shift_assignments ADD CONSTRAINT shift_assignments_no_overlap
+    EXCLUDE USING gist (worker_id WITH =, shift_period WITH &&) WHERE (status = 'accepted');
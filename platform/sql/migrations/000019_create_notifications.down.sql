@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_invoices_due_date;
+ALTER TABLE invoices DROP COLUMN IF EXISTS overdue_at;
+ALTER TABLE invoices DROP COLUMN IF EXISTS due_date;
+
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS notification_preferences;
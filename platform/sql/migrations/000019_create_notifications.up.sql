@@ -0,0 +1,54 @@
+-- Which channels a user wants each kind of notification on. Users without a
+-- row for a notification type get the service defaults.
+CREATE TABLE notification_preferences (
+    user_id VARCHAR(255) NOT NULL,
+    notification_type VARCHAR(255) NOT NULL,
+    email BOOLEAN NOT NULL,
+    sms BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    PRIMARY KEY (user_id, notification_type),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- One row per message to a user on a channel; doubles as the delivery log.
+-- The unique index makes queueing the messages for an event idempotent.
+CREATE TABLE notifications (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    notification_type VARCHAR(255) NOT NULL,
+    channel VARCHAR(255) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT,
+    body TEXT NOT NULL,
+    status VARCHAR(255) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT notifications_channel_check CHECK (channel IN ('email', 'sms')),
+    CONSTRAINT notifications_status_check CHECK (status IN ('pending', 'sent', 'failed'))
+);
+
+CREATE UNIQUE INDEX idx_notifications_event_user_channel ON notifications(event_id, user_id, channel);
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, created_at);
+CREATE INDEX idx_notifications_due ON notifications(next_attempt_at) WHERE status = 'pending';
+
+-- Invoices fall due a fixed term after they are issued. overdue_at records
+-- when an unpaid invoice was flagged as overdue, so it is flagged only once.
+ALTER TABLE invoices ADD COLUMN due_date DATE;
+ALTER TABLE invoices ADD COLUMN overdue_at TIMESTAMP;
+
+UPDATE invoices i
+SET due_date = COALESCE((
+    SELECT MAX(t.created_at)::date
+    FROM invoice_status_transitions t
+    WHERE t.invoice_id = i.id AND t.to_status = 'issued'
+), i.created_at::date) + 30
+WHERE i.status IN ('issued', 'partially_paid', 'disputed', 'paid');
+
+CREATE INDEX idx_invoices_due_date ON invoices(due_date) WHERE overdue_at IS NULL;
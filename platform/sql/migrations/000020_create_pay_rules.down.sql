@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_invoice_line_items_timesheet_category;
+DELETE FROM invoice_line_items WHERE timesheet_id IS NOT NULL AND pay_category <> 'regular';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_line_items_timesheet_id ON invoice_line_items(timesheet_id) WHERE timesheet_id IS NOT NULL;
+ALTER TABLE invoice_line_items DROP CONSTRAINT IF EXISTS invoice_line_items_pay_category_check;
+ALTER TABLE invoice_line_items DROP COLUMN IF EXISTS trace;
+ALTER TABLE invoice_line_items DROP COLUMN IF EXISTS multiplier_bps;
+ALTER TABLE invoice_line_items DROP COLUMN IF EXISTS pay_category;
+
+DROP TABLE IF EXISTS pay_rule_holidays;
+DROP TABLE IF EXISTS pay_rules;
@@ -0,0 +1,50 @@
+-- Each organization's overtime, holiday, night and minimum shift rules. A NULL
+-- or zero threshold turns that rule off; organizations without a row pay and
+-- bill every hour as regular time. Multipliers are basis points of the base
+-- rate, so 15000 is time and a half.
+CREATE TABLE pay_rules (
+    organization_id VARCHAR(255) PRIMARY KEY,
+    daily_overtime_minutes INTEGER,
+    daily_double_time_minutes INTEGER,
+    weekly_overtime_minutes INTEGER,
+    overtime_bps INTEGER NOT NULL DEFAULT 15000,
+    double_time_bps INTEGER NOT NULL DEFAULT 20000,
+    holiday_bps INTEGER NOT NULL DEFAULT 15000,
+    -- Wall clock times in the shift's timezone; the window wraps past midnight
+    -- when night_end is before night_start.
+    night_start TIME,
+    night_end TIME,
+    night_differential_bps INTEGER NOT NULL DEFAULT 0,
+    minimum_shift_minutes INTEGER,
+    -- Pay workers the minimum shift guarantee without billing it.
+    absorb_minimum_shift BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    CONSTRAINT pay_rules_multipliers_check CHECK (overtime_bps >= 10000 AND double_time_bps >= 10000 AND holiday_bps >= 10000),
+    CONSTRAINT pay_rules_night_check CHECK ((night_start IS NULL) = (night_end IS NULL) AND night_differential_bps >= 0)
+);
+
+-- The organization's holiday calendar, paid at holiday_bps.
+CREATE TABLE pay_rule_holidays (
+    organization_id VARCHAR(255) NOT NULL,
+    holiday_date DATE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    PRIMARY KEY (organization_id, holiday_date),
+    FOREIGN KEY (organization_id) REFERENCES pay_rules(organization_id) ON DELETE CASCADE
+);
+
+-- A timesheet now gets one labor line per pay category, each with the
+-- multiplier it was billed at and the reasons its hours fell in the category.
+ALTER TABLE invoice_line_items ADD COLUMN pay_category VARCHAR(255);
+ALTER TABLE invoice_line_items ADD COLUMN multiplier_bps INTEGER;
+ALTER TABLE invoice_line_items ADD COLUMN trace JSONB;
+UPDATE invoice_line_items SET pay_category = 'regular', multiplier_bps = 10000 WHERE timesheet_id IS NOT NULL;
+ALTER TABLE invoice_line_items ADD CONSTRAINT invoice_line_items_pay_category_check
+    CHECK ((timesheet_id IS NULL) = (pay_category IS NULL));
+
+DROP INDEX idx_invoice_line_items_timesheet_id;
+CREATE UNIQUE INDEX idx_invoice_line_items_timesheet_category ON invoice_line_items(timesheet_id, pay_category)
+    WHERE timesheet_id IS NOT NULL;
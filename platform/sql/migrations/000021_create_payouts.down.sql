@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_timesheets_unpaid;
+ALTER TABLE timesheets DROP COLUMN IF EXISTS payout_id;
+
+DROP TABLE IF EXISTS payout_lines;
+DROP TABLE IF EXISTS payouts;
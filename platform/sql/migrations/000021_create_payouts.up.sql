@@ -0,0 +1,59 @@
+-- What the platform owes each worker for a pay period, and how paying it
+-- through the payout provider is going. A period is paid in one payout, which
+-- later runs amend until it is submitted to the provider.
+CREATE TABLE payouts (
+    id VARCHAR(255) PRIMARY KEY,
+    worker_id VARCHAR(255) NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(255) NOT NULL DEFAULT 'pending',
+    provider VARCHAR(255),
+    provider_transfer_id VARCHAR(255),
+    failure_reason TEXT,
+    submitted_at TIMESTAMP,
+    paid_at TIMESTAMP,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (worker_id) REFERENCES users(id),
+    UNIQUE (worker_id, period_start, period_end),
+    UNIQUE (provider, provider_transfer_id),
+    CONSTRAINT payouts_status_check CHECK (status IN ('pending', 'processing', 'paid', 'failed')),
+    CONSTRAINT payouts_amount_check CHECK (amount >= 0),
+    CONSTRAINT payouts_period_check CHECK (period_end >= period_start)
+);
+
+CREATE INDEX idx_payouts_worker_id ON payouts(worker_id, period_start);
+CREATE INDEX idx_payouts_status ON payouts(status) WHERE status IN ('pending', 'processing');
+
+-- One line per timesheet and pay category: the payable hours and the rate
+-- they are paid at. Amounts are minor units of the payout's currency.
+CREATE TABLE payout_lines (
+    id VARCHAR(255) PRIMARY KEY,
+    payout_id VARCHAR(255) NOT NULL,
+    organization_id VARCHAR(255) NOT NULL,
+    shift_id VARCHAR(255) NOT NULL,
+    timesheet_id VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    pay_category VARCHAR(255) NOT NULL,
+    multiplier_bps INTEGER NOT NULL,
+    hours NUMERIC(12, 4) NOT NULL,
+    hourly_rate BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    trace JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (payout_id) REFERENCES payouts(id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (shift_id) REFERENCES shifts(id),
+    FOREIGN KEY (timesheet_id) REFERENCES timesheets(id),
+    UNIQUE (timesheet_id, pay_category)
+);
+
+CREATE INDEX idx_payout_lines_payout_id ON payout_lines(payout_id);
+
+-- Set once a timesheet's hours are in a payout, so they are paid only once.
+ALTER TABLE timesheets ADD COLUMN payout_id VARCHAR(255) REFERENCES payouts(id);
+CREATE INDEX idx_timesheets_unpaid ON timesheets(worker_id, clock_in_at) WHERE status = 'approved' AND payout_id IS NULL;
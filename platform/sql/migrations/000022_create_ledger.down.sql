@@ -0,0 +1,8 @@
+DROP VIEW IF EXISTS ledger_employer_balances;
+DROP VIEW IF EXISTS ledger_account_balances;
+
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP FUNCTION IF EXISTS ledger_immutable();
+DROP FUNCTION IF EXISTS ledger_entry_balanced();
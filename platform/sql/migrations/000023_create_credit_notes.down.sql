@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS payment_refunds;
+DROP TABLE IF EXISTS credit_note_lines;
+DROP TABLE IF EXISTS credit_notes;
+
+UPDATE invoices SET status = 'void' WHERE status = 'credited';
+ALTER TABLE invoices DROP CONSTRAINT invoices_status_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check
+    CHECK (status IN ('draft', 'issued', 'partially_paid', 'paid', 'void', 'disputed'));
@@ -0,0 +1,64 @@
+-- An invoice whose every charge has been credited back.
+ALTER TABLE invoices DROP CONSTRAINT invoices_status_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check
+    CHECK (status IN ('draft', 'issued', 'partially_paid', 'paid', 'void', 'disputed', 'credited'));
+
+-- A credit note reduces what is owed on an issued invoice. Its amounts are
+-- totals of its lines, split the way the invoice's are.
+CREATE TABLE credit_notes (
+    id VARCHAR(255) PRIMARY KEY,
+    invoice_id VARCHAR(255) NOT NULL,
+    organization_id VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    subtotal_amount BIGINT NOT NULL,
+    platform_fee_amount BIGINT NOT NULL,
+    tax_amount BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    CONSTRAINT credit_notes_amount_check CHECK (amount > 0)
+);
+
+CREATE INDEX idx_credit_notes_invoice_id ON credit_notes(invoice_id, created_at);
+
+-- Each line credits part or all of one invoice line item and its tax.
+CREATE TABLE credit_note_lines (
+    id VARCHAR(255) PRIMARY KEY,
+    credit_note_id VARCHAR(255) NOT NULL,
+    line_item_id VARCHAR(255) NOT NULL,
+    kind VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    amount BIGINT NOT NULL,
+    tax_amount BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL,
+    FOREIGN KEY (credit_note_id) REFERENCES credit_notes(id),
+    FOREIGN KEY (line_item_id) REFERENCES invoice_line_items(id),
+    UNIQUE (credit_note_id, line_item_id),
+    CONSTRAINT credit_note_lines_amount_check CHECK (amount >= 0 AND tax_amount >= 0 AND amount + tax_amount > 0)
+);
+
+CREATE INDEX idx_credit_note_lines_line_item_id ON credit_note_lines(line_item_id);
+
+-- Refunds requested through the gateway. refunded_amount on the payment only
+-- moves when the provider confirms one.
+CREATE TABLE payment_refunds (
+    id VARCHAR(255) PRIMARY KEY,
+    payment_id VARCHAR(255) NOT NULL,
+    provider_refund_id VARCHAR(255) NOT NULL,
+    amount BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(255) NOT NULL DEFAULT 'pending',
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    UNIQUE (payment_id, provider_refund_id),
+    CONSTRAINT payment_refunds_status_check CHECK (status IN ('pending', 'succeeded')),
+    CONSTRAINT payment_refunds_amount_check CHECK (amount > 0)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_invoices_organization_number;
+ALTER TABLE invoices DROP COLUMN IF EXISTS invoice_number;
+
+DROP TABLE IF EXISTS invoice_number_sequences;
+DROP TABLE IF EXISTS invoice_numbering;
@@ -0,0 +1,72 @@
+-- How an organization's invoices are numbered once issued. Organizations
+-- without a row use the default template with a prefix taken from their name.
+CREATE TABLE invoice_numbering (
+    organization_id VARCHAR(255) PRIMARY KEY,
+    prefix VARCHAR(12) NOT NULL,
+    template VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id)
+);
+
+-- The last number handed out in each period of an organization's numbering:
+-- a year, a month, or '' for templates that never restart. Issuing
+-- increments the row in the issuing transaction, so concurrent issues queue
+-- on its lock and a rolled back issue gives its number back.
+CREATE TABLE invoice_number_sequences (
+    organization_id VARCHAR(255) NOT NULL,
+    period VARCHAR(7) NOT NULL,
+    last_number BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, period),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    CONSTRAINT invoice_number_sequences_last_number_check CHECK (last_number > 0)
+);
+
+ALTER TABLE invoices ADD COLUMN invoice_number VARCHAR(255);
+CREATE UNIQUE INDEX idx_invoices_organization_number ON invoices(organization_id, invoice_number)
+    WHERE invoice_number IS NOT NULL;
+
+-- Number the invoices already issued with the default template, per year of
+-- issue in the order they were issued. Drafts voided without ever being
+-- issued stay unnumbered.
+WITH issued AS (
+    SELECT
+        i.id,
+        i.organization_id,
+        COALESCE(NULLIF(upper(left(regexp_replace(o.name, '[^A-Za-z0-9]', '', 'g'), 4)), ''), 'INV') AS prefix,
+        COALESCE((
+            SELECT MIN(t.created_at)
+            FROM invoice_status_transitions t
+            WHERE t.invoice_id = i.id AND t.to_status = 'issued'
+        ), i.created_at) AS issued_at
+    FROM invoices i
+    JOIN organizations o ON o.id = i.organization_id
+    WHERE i.status <> 'draft'
+    AND (i.status <> 'void' OR EXISTS (
+        SELECT 1 FROM invoice_status_transitions t WHERE t.invoice_id = i.id AND t.to_status = 'issued'
+    ))
+), numbered AS (
+    SELECT
+        id,
+        organization_id,
+        prefix,
+        issued_at,
+        row_number() OVER (
+            PARTITION BY organization_id, date_part('year', issued_at)
+            ORDER BY issued_at, id
+        ) AS seq
+    FROM issued
+)
+UPDATE invoices i
+SET invoice_number = n.prefix || '-' || to_char(n.issued_at, 'YYYY') || '-' || lpad(n.seq::text, 5, '0')
+FROM numbered n
+WHERE n.id = i.id;
+
+INSERT INTO invoice_number_sequences (organization_id, period, last_number)
+SELECT organization_id, split_part(invoice_number, '-', 2), COUNT(*)
+FROM invoices
+WHERE invoice_number IS NOT NULL
+GROUP BY organization_id, split_part(invoice_number, '-', 2);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_payouts_status;
+CREATE INDEX idx_payouts_status ON payouts(status) WHERE status IN ('pending', 'processing');
+
+ALTER TABLE payouts DROP COLUMN IF EXISTS reissues;
+ALTER TABLE payouts DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE payouts DROP COLUMN IF EXISTS attempts;
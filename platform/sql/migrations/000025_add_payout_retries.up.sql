@@ -0,0 +1,11 @@
+-- Submitting a payout is retried with backoff when the provider cannot be
+-- reached, rather than failing it. attempts counts the failed submissions
+-- since the payout was last queued.
+ALTER TABLE payouts ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payouts ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW();
+-- reissues counts how often platform staff resubmitted a payout the provider
+-- failed. Each reissue is a new transfer, so it needs its own idempotency key.
+ALTER TABLE payouts ADD COLUMN reissues INTEGER NOT NULL DEFAULT 0;
+
+DROP INDEX idx_payouts_status;
+CREATE INDEX idx_payouts_status ON payouts(status, next_attempt_at) WHERE status IN ('pending', 'processing');
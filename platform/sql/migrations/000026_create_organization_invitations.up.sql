@@ -0,0 +1,23 @@
+-- Owners invite users into their organization rather than adding them
+-- outright; the invited user accepts or declines. Until then they stay where
+-- they are.
+CREATE TABLE organization_invitations (
+    id VARCHAR(255) PRIMARY KEY,
+    organization_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    role VARCHAR(255) NOT NULL DEFAULT 'member',
+    status VARCHAR(255) NOT NULL DEFAULT 'pending',
+    created_by VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT organization_invitations_role_check CHECK (role IN ('owner', 'member')),
+    CONSTRAINT organization_invitations_status_check CHECK (status IN ('pending', 'accepted', 'declined'))
+);
+
+-- An organization has at most one open invitation for a user.
+CREATE UNIQUE INDEX idx_organization_invitations_pending ON organization_invitations(organization_id, user_id)
+    WHERE status = 'pending';
+CREATE INDEX idx_organization_invitations_user_id ON organization_invitations(user_id, created_at);
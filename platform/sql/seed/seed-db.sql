@@ -26,13 +26,13 @@ $$  LANGUAGE plpgsql VOLATILE;
 -- Seed data for shifts table
 INSERT INTO invoices (id, start_date, end_date, shifts_filled, invoice_amount, status, user_id, shift_id, created_by, updated_by, invoice_name)
 VALUES
-    ('inv_' || generate_ksuid(), '2024-10-01', '2024-10-07', '2024-10-07', 1500.00, 'approved', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Summer Music Festival Staff'),
-    ('inv_' || generate_ksuid(), '2024-10-08', '2024-10-14', '2024-10-14', 1200.50, 'pending', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Corporate Office Relocation'),
-    ('inv_' || generate_ksuid(), '2024-10-15', '2024-10-21', '2024-10-21', 1750.25, 'approved', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Annual Tech Conference Support'),
-    ('inv_' || generate_ksuid(), '2024-10-22', '2024-10-28', '2024-10-28', 1300.75, 'rejected', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Holiday Season Retail Assistance'),
-    ('inv_' || generate_ksuid(), '2024-10-29', '2024-11-04', '2024-11-04', 1600.00, 'approved', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Hospital Emergency Room Coverage'),
-    ('inv_' || generate_ksuid(), '2024-11-05', '2024-11-11', '2024-11-11', 1450.50, 'pending', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'University Orientation Week'),
-    ('inv_' || generate_ksuid(), '2024-11-12', '2024-11-18', '2024-11-18', 1800.25, 'approved', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Construction Site Safety Team'),
-    ('inv_' || generate_ksuid(), '2024-11-19', '2024-11-25', '2024-11-25', 1350.75, 'pending', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Film Production Crew'),
-    ('inv_' || generate_ksuid(), '2024-11-26', '2024-12-02', '2024-12-02', 1550.00, 'approved', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Warehouse Inventory Audit'),
-    ('inv_' || generate_ksuid(), '2024-12-03', '2024-12-09', '2024-12-09', 1400.50, 'rejected', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Catering Staff for Gala Dinner');
\ No newline at end of file
+    ('inv_' || generate_ksuid(), '2024-10-01', '2024-10-07', '2024-10-07', 150000, 'paid', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Summer Music Festival Staff'),
+    ('inv_' || generate_ksuid(), '2024-10-08', '2024-10-14', '2024-10-14', 120050, 'issued', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Corporate Office Relocation'),
+    ('inv_' || generate_ksuid(), '2024-10-15', '2024-10-21', '2024-10-21', 175025, 'paid', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Annual Tech Conference Support'),
+    ('inv_' || generate_ksuid(), '2024-10-22', '2024-10-28', '2024-10-28', 130075, 'disputed', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Holiday Season Retail Assistance'),
+    ('inv_' || generate_ksuid(), '2024-10-29', '2024-11-04', '2024-11-04', 160000, 'paid', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Hospital Emergency Room Coverage'),
+    ('inv_' || generate_ksuid(), '2024-11-05', '2024-11-11', '2024-11-11', 145050, 'issued', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'University Orientation Week'),
+    ('inv_' || generate_ksuid(), '2024-11-12', '2024-11-18', '2024-11-18', 180025, 'paid', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Construction Site Safety Team'),
+    ('inv_' || generate_ksuid(), '2024-11-19', '2024-11-25', '2024-11-25', 135075, 'issued', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Film Production Crew'),
+    ('inv_' || generate_ksuid(), '2024-11-26', '2024-12-02', '2024-12-02', 155000, 'paid', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Warehouse Inventory Audit'),
+    ('inv_' || generate_ksuid(), '2024-12-03', '2024-12-09', '2024-12-09', 140050, 'disputed', 'user_' || generate_ksuid(), 'shift_' || generate_ksuid(), 'user_' || generate_ksuid(), 'user_' || generate_ksuid(), 'Catering Staff for Gala Dinner');
\ No newline at end of file